@@ -0,0 +1,17 @@
+-- Drop streak index
+DROP INDEX IF EXISTS idx_logs_user_updated;
+
+-- Drop badges
+DROP INDEX IF EXISTS idx_user_badges_awarded_at;
+DROP TABLE IF EXISTS user_badges;
+
+-- Drop triggers
+DROP TRIGGER IF EXISTS trigger_update_challenge_participants_count ON challenge_participants;
+DROP TRIGGER IF EXISTS update_challenges_updated_at ON challenges;
+
+-- Drop functions
+DROP FUNCTION IF EXISTS update_challenge_participants_count();
+
+-- Drop tables
+DROP TABLE IF EXISTS challenge_participants;
+DROP TABLE IF EXISTS challenges;
@@ -0,0 +1,67 @@
+-- Reading challenges created by users (e.g. "read 5 books from the Classic list in March")
+CREATE TABLE IF NOT EXISTS challenges (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    creator_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    description TEXT,
+    target_count INTEGER NOT NULL CHECK (target_count > 0),
+    list_id UUID REFERENCES lists(id) ON DELETE SET NULL, -- Only books from this list count
+    category TEXT, -- Only books in this category count
+    starts_on DATE NOT NULL,
+    ends_on DATE NOT NULL,
+    is_public BOOLEAN DEFAULT true,
+    participants_count INTEGER DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK (ends_on >= starts_on)
+);
+
+CREATE INDEX idx_challenges_creator_id ON challenges(creator_id);
+CREATE INDEX idx_challenges_dates ON challenges(starts_on, ends_on);
+CREATE INDEX idx_challenges_is_public ON challenges(is_public);
+
+CREATE TABLE IF NOT EXISTS challenge_participants (
+    challenge_id UUID NOT NULL REFERENCES challenges(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    joined_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    completed_at TIMESTAMPTZ,
+    PRIMARY KEY (challenge_id, user_id)
+);
+
+CREATE INDEX idx_challenge_participants_user_id ON challenge_participants(user_id);
+
+CREATE TRIGGER update_challenges_updated_at BEFORE UPDATE ON challenges
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- Keep participants_count in sync
+CREATE OR REPLACE FUNCTION update_challenge_participants_count()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        UPDATE challenges SET participants_count = participants_count + 1 WHERE id = NEW.challenge_id;
+        RETURN NEW;
+    ELSIF TG_OP = 'DELETE' THEN
+        UPDATE challenges SET participants_count = GREATEST(participants_count - 1, 0) WHERE id = OLD.challenge_id;
+        RETURN OLD;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trigger_update_challenge_participants_count
+AFTER INSERT OR DELETE ON challenge_participants
+FOR EACH ROW EXECUTE FUNCTION update_challenge_participants_count();
+
+-- Badges awarded by the rules engine
+CREATE TABLE IF NOT EXISTS user_badges (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    badge VARCHAR(50) NOT NULL,
+    log_id UUID REFERENCES logs(id) ON DELETE SET NULL, -- The log that triggered the award
+    awarded_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, badge)
+);
+
+CREATE INDEX idx_user_badges_awarded_at ON user_badges(user_id, awarded_at DESC);
+
+-- Speed up daily activity lookups for streaks
+CREATE INDEX IF NOT EXISTS idx_logs_user_updated ON logs(user_id, updated_at DESC);
@@ -0,0 +1,10 @@
+-- Drop triggers
+DROP TRIGGER IF EXISTS trigger_update_shelf_logs_count ON log_shelves;
+DROP TRIGGER IF EXISTS update_shelves_updated_at ON shelves;
+
+-- Drop functions
+DROP FUNCTION IF EXISTS update_shelf_logs_count();
+
+-- Drop tables
+DROP TABLE IF EXISTS log_shelves;
+DROP TABLE IF EXISTS shelves;
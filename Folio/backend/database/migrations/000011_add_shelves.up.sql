@@ -0,0 +1,47 @@
+-- Personal shelves (owned, library, audiobook, re-read-someday, ...) that sit alongside log status
+CREATE TABLE IF NOT EXISTS shelves (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    slug VARCHAR(100) NOT NULL,
+    description TEXT,
+    is_public BOOLEAN DEFAULT true,
+    logs_count INTEGER DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE(user_id, slug)
+);
+
+CREATE INDEX idx_shelves_user_id ON shelves(user_id);
+
+-- A log can sit on any number of the owner's shelves
+CREATE TABLE IF NOT EXISTS log_shelves (
+    shelf_id UUID NOT NULL REFERENCES shelves(id) ON DELETE CASCADE,
+    log_id UUID NOT NULL REFERENCES logs(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (shelf_id, log_id)
+);
+
+CREATE INDEX idx_log_shelves_log_id ON log_shelves(log_id);
+
+CREATE TRIGGER update_shelves_updated_at BEFORE UPDATE ON shelves
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- Keep logs_count in sync
+CREATE OR REPLACE FUNCTION update_shelf_logs_count()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        UPDATE shelves SET logs_count = logs_count + 1 WHERE id = NEW.shelf_id;
+        RETURN NEW;
+    ELSIF TG_OP = 'DELETE' THEN
+        UPDATE shelves SET logs_count = GREATEST(logs_count - 1, 0) WHERE id = OLD.shelf_id;
+        RETURN OLD;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trigger_update_shelf_logs_count
+AFTER INSERT OR DELETE ON log_shelves
+FOR EACH ROW EXECUTE FUNCTION update_shelf_logs_count();
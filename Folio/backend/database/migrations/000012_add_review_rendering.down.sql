@@ -0,0 +1,7 @@
+-- Drop tables
+DROP TABLE IF EXISTS log_mentions;
+
+-- Drop columns
+ALTER TABLE logs DROP COLUMN IF EXISTS review_has_spoilers;
+ALTER TABLE logs DROP COLUMN IF EXISTS review_html;
+ALTER TABLE logs DROP CONSTRAINT IF EXISTS logs_review_format_check;
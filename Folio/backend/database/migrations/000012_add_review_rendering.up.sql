@@ -0,0 +1,17 @@
+-- Only plain text and the safe markdown subset are understood by the renderer
+UPDATE logs SET review_format = 'text' WHERE review_format IS NULL OR review_format NOT IN ('text', 'markdown');
+ALTER TABLE logs ADD CONSTRAINT logs_review_format_check CHECK (review_format IN ('text', 'markdown'));
+
+-- Sanitized HTML rendered from review when the log is written
+ALTER TABLE logs ADD COLUMN review_html TEXT;
+ALTER TABLE logs ADD COLUMN review_has_spoilers BOOLEAN DEFAULT false;
+
+-- Users @mentioned in a review
+CREATE TABLE IF NOT EXISTS log_mentions (
+    log_id UUID NOT NULL REFERENCES logs(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (log_id, user_id)
+);
+
+CREATE INDEX idx_log_mentions_user_id ON log_mentions(user_id);
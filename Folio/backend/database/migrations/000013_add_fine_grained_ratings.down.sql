@@ -0,0 +1,13 @@
+-- Drop indexes
+DROP INDEX IF EXISTS idx_logs_book_rating;
+
+-- Drop dimension ratings
+ALTER TABLE logs DROP COLUMN IF EXISTS rating_pacing;
+ALTER TABLE logs DROP COLUMN IF EXISTS rating_characters;
+ALTER TABLE logs DROP COLUMN IF EXISTS rating_prose;
+ALTER TABLE logs DROP COLUMN IF EXISTS rating_plot;
+
+-- Back to whole stars; half stars round up
+ALTER TABLE logs DROP CONSTRAINT IF EXISTS logs_rating_check;
+ALTER TABLE logs ALTER COLUMN rating TYPE INTEGER USING CEIL(rating)::integer;
+ALTER TABLE logs ADD CONSTRAINT logs_rating_check CHECK (rating >= 1 AND rating <= 5);
@@ -0,0 +1,17 @@
+-- Allow half-star ratings; existing whole-star values carry over unchanged
+ALTER TABLE logs DROP CONSTRAINT IF EXISTS logs_rating_check;
+ALTER TABLE logs ALTER COLUMN rating TYPE NUMERIC(2, 1) USING rating::numeric;
+ALTER TABLE logs ADD CONSTRAINT logs_rating_check
+    CHECK (rating >= 0.5 AND rating <= 5 AND rating * 2 = TRUNC(rating * 2));
+
+-- Optional per-dimension ratings on the same half-star scale
+ALTER TABLE logs ADD COLUMN rating_plot NUMERIC(2, 1)
+    CHECK (rating_plot >= 0.5 AND rating_plot <= 5 AND rating_plot * 2 = TRUNC(rating_plot * 2));
+ALTER TABLE logs ADD COLUMN rating_prose NUMERIC(2, 1)
+    CHECK (rating_prose >= 0.5 AND rating_prose <= 5 AND rating_prose * 2 = TRUNC(rating_prose * 2));
+ALTER TABLE logs ADD COLUMN rating_characters NUMERIC(2, 1)
+    CHECK (rating_characters >= 0.5 AND rating_characters <= 5 AND rating_characters * 2 = TRUNC(rating_characters * 2));
+ALTER TABLE logs ADD COLUMN rating_pacing NUMERIC(2, 1)
+    CHECK (rating_pacing >= 0.5 AND rating_pacing <= 5 AND rating_pacing * 2 = TRUNC(rating_pacing * 2));
+
+CREATE INDEX idx_logs_book_rating ON logs(book_id, rating) WHERE rating IS NOT NULL;
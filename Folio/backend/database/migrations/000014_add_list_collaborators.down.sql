@@ -0,0 +1,5 @@
+-- Drop columns
+ALTER TABLE list_items DROP COLUMN IF EXISTS added_by;
+
+-- Drop tables
+DROP TABLE IF EXISTS list_collaborators;
@@ -0,0 +1,21 @@
+-- Users invited to help curate a list. Invitations grant nothing until accepted.
+CREATE TABLE IF NOT EXISTS list_collaborators (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    list_id UUID NOT NULL REFERENCES lists(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role VARCHAR(20) NOT NULL DEFAULT 'editor' CHECK (role IN ('editor', 'viewer')),
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'accepted')),
+    invited_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    accepted_at TIMESTAMPTZ,
+    UNIQUE(list_id, user_id)
+);
+
+CREATE INDEX idx_list_collaborators_user_id ON list_collaborators(user_id, status);
+
+-- Who added each book; existing items were added by the list owner
+ALTER TABLE list_items ADD COLUMN added_by UUID REFERENCES users(id) ON DELETE SET NULL;
+
+UPDATE list_items li SET added_by = l.user_id
+FROM lists l
+WHERE li.list_id = l.id;
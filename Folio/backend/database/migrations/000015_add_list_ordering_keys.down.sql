@@ -0,0 +1,21 @@
+-- Drop triggers
+DROP TRIGGER IF EXISTS trigger_bump_list_version ON list_items;
+DROP TRIGGER IF EXISTS trigger_assign_list_item_position_key ON list_items;
+
+-- Drop functions
+DROP FUNCTION IF EXISTS bump_list_version();
+DROP FUNCTION IF EXISTS assign_list_item_position_key();
+
+-- Carry the current order back into item_order
+UPDATE list_items li SET item_order = ranked.n
+FROM (
+    SELECT id, ROW_NUMBER() OVER (PARTITION BY list_id ORDER BY position_key) - 1 AS n
+    FROM list_items
+) ranked
+WHERE li.id = ranked.id;
+
+-- Drop columns
+DROP INDEX IF EXISTS idx_list_items_position;
+ALTER TABLE lists DROP COLUMN IF EXISTS is_ranked;
+ALTER TABLE lists DROP COLUMN IF EXISTS version;
+ALTER TABLE list_items DROP COLUMN IF EXISTS position_key;
@@ -0,0 +1,53 @@
+-- Fractional ordering keys: moving an item rewrites only that item's key.
+-- Keys are base62 strings compared byte-wise, so they are always sorted with COLLATE "C".
+ALTER TABLE list_items ADD COLUMN position_key TEXT COLLATE "C";
+
+-- Existing items keep their current order. Fixed-width keys sort like the numbers
+-- they encode; the trailing '1' keeps room to insert before any of them.
+UPDATE list_items li SET position_key = lpad(ranked.n::text, 7, '0') || '1'
+FROM (
+    SELECT id, ROW_NUMBER() OVER (PARTITION BY list_id ORDER BY item_order ASC, created_at DESC) AS n
+    FROM list_items
+) ranked
+WHERE li.id = ranked.id;
+
+-- Inserts that don't supply a key (seeds, scripts) go to the end of the list
+CREATE OR REPLACE FUNCTION assign_list_item_position_key()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF NEW.position_key IS NULL THEN
+        SELECT COALESCE(MAX(position_key), '') || 'V' INTO NEW.position_key
+        FROM list_items WHERE list_id = NEW.list_id;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trigger_assign_list_item_position_key
+    BEFORE INSERT ON list_items
+    FOR EACH ROW EXECUTE FUNCTION assign_list_item_position_key();
+
+ALTER TABLE list_items ALTER COLUMN position_key SET NOT NULL;
+CREATE INDEX idx_list_items_position ON list_items(list_id, position_key);
+
+-- Optimistic concurrency: every change to a list or its items bumps version
+ALTER TABLE lists ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+
+CREATE OR REPLACE FUNCTION bump_list_version()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        UPDATE lists SET version = version + 1 WHERE id = OLD.list_id;
+        RETURN OLD;
+    END IF;
+    UPDATE lists SET version = version + 1 WHERE id = NEW.list_id;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trigger_bump_list_version
+    AFTER INSERT OR UPDATE OR DELETE ON list_items
+    FOR EACH ROW EXECUTE FUNCTION bump_list_version();
+
+-- Ranked lists show each item's position as a number
+ALTER TABLE lists ADD COLUMN is_ranked BOOLEAN NOT NULL DEFAULT false;
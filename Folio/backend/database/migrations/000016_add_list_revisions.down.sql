@@ -0,0 +1,3 @@
+-- Drop tables
+DROP TABLE IF EXISTS list_views;
+DROP TABLE IF EXISTS list_revisions;
@@ -0,0 +1,28 @@
+-- One row per change to a list. snapshot is the full list state after the change,
+-- which is what a restore writes back.
+CREATE TABLE IF NOT EXISTS list_revisions (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    list_id UUID NOT NULL REFERENCES lists(id) ON DELETE CASCADE,
+    version INTEGER NOT NULL,
+    user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    action VARCHAR(30) NOT NULL CHECK (action IN (
+        'list_created', 'list_updated', 'item_added', 'item_removed',
+        'item_moved', 'items_reordered', 'item_note_updated', 'list_restored'
+    )),
+    item_id UUID,
+    book_id VARCHAR(255),
+    details JSONB NOT NULL DEFAULT '{}'::jsonb,
+    snapshot JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_list_revisions_list_version ON list_revisions(list_id, version DESC);
+
+-- The list version each user last saw, for "what changed since you last viewed"
+CREATE TABLE IF NOT EXISTS list_views (
+    list_id UUID NOT NULL REFERENCES lists(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    last_viewed_version INTEGER NOT NULL,
+    last_viewed_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (list_id, user_id)
+);
@@ -0,0 +1,14 @@
+-- Drop triggers
+DROP TRIGGER IF EXISTS trigger_update_list_subscribers_count ON list_subscriptions;
+
+-- Drop functions
+DROP FUNCTION IF EXISTS update_list_subscribers_count();
+
+-- Drop indexes
+DROP INDEX IF EXISTS idx_list_revisions_added;
+
+-- Drop columns
+ALTER TABLE lists DROP COLUMN IF EXISTS subscribers_count;
+
+-- Drop tables
+DROP TABLE IF EXISTS list_subscriptions;
@@ -0,0 +1,33 @@
+-- Users following a list to hear about its changes
+CREATE TABLE IF NOT EXISTS list_subscriptions (
+    list_id UUID NOT NULL REFERENCES lists(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (list_id, user_id)
+);
+
+CREATE INDEX idx_list_subscriptions_user_id ON list_subscriptions(user_id);
+
+ALTER TABLE lists ADD COLUMN subscribers_count INTEGER DEFAULT 0;
+
+-- Keep subscribers_count in sync
+CREATE OR REPLACE FUNCTION update_list_subscribers_count()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        UPDATE lists SET subscribers_count = subscribers_count + 1 WHERE id = NEW.list_id;
+        RETURN NEW;
+    ELSIF TG_OP = 'DELETE' THEN
+        UPDATE lists SET subscribers_count = GREATEST(subscribers_count - 1, 0) WHERE id = OLD.list_id;
+        RETURN OLD;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trigger_update_list_subscribers_count
+    AFTER INSERT OR DELETE ON list_subscriptions
+    FOR EACH ROW EXECUTE FUNCTION update_list_subscribers_count();
+
+-- Feed lookups of recently added items
+CREATE INDEX idx_list_revisions_added ON list_revisions(list_id, created_at) WHERE action = 'item_added';
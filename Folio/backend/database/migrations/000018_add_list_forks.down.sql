@@ -0,0 +1,12 @@
+-- Drop triggers
+DROP TRIGGER IF EXISTS trigger_update_list_forks_count ON lists;
+
+-- Drop functions
+DROP FUNCTION IF EXISTS update_list_forks_count();
+
+-- Drop indexes
+DROP INDEX IF EXISTS idx_lists_forked_from;
+
+-- Drop columns
+ALTER TABLE lists DROP COLUMN IF EXISTS forks_count;
+ALTER TABLE lists DROP COLUMN IF EXISTS forked_from_list_id;
@@ -0,0 +1,22 @@
+-- Provenance for lists copied from another list
+ALTER TABLE lists ADD COLUMN forked_from_list_id UUID REFERENCES lists(id) ON DELETE SET NULL;
+ALTER TABLE lists ADD COLUMN forks_count INTEGER DEFAULT 0;
+
+CREATE INDEX idx_lists_forked_from ON lists(forked_from_list_id) WHERE forked_from_list_id IS NOT NULL;
+
+-- Keep forks_count in sync
+CREATE OR REPLACE FUNCTION update_list_forks_count()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'INSERT' AND NEW.forked_from_list_id IS NOT NULL THEN
+        UPDATE lists SET forks_count = forks_count + 1 WHERE id = NEW.forked_from_list_id;
+    ELSIF TG_OP = 'DELETE' AND OLD.forked_from_list_id IS NOT NULL THEN
+        UPDATE lists SET forks_count = GREATEST(forks_count - 1, 0) WHERE id = OLD.forked_from_list_id;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trigger_update_list_forks_count
+    AFTER INSERT OR DELETE ON lists
+    FOR EACH ROW EXECUTE FUNCTION update_list_forks_count();
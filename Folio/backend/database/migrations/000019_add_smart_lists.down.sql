@@ -0,0 +1,6 @@
+-- Drop constraints
+ALTER TABLE lists DROP CONSTRAINT IF EXISTS lists_smart_rules_check;
+
+-- Drop columns
+ALTER TABLE lists DROP COLUMN IF EXISTS smart_rules;
+ALTER TABLE lists DROP COLUMN IF EXISTS list_type;
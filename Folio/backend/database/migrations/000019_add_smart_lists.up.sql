@@ -0,0 +1,6 @@
+-- Smart lists hold a saved query instead of list_items; their books are computed on read
+ALTER TABLE lists ADD COLUMN list_type VARCHAR(10) NOT NULL DEFAULT 'static' CHECK (list_type IN ('static', 'smart'));
+ALTER TABLE lists ADD COLUMN smart_rules JSONB;
+
+ALTER TABLE lists ADD CONSTRAINT lists_smart_rules_check
+    CHECK ((list_type = 'smart') = (smart_rules IS NOT NULL));
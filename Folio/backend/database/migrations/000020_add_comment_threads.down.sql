@@ -0,0 +1,67 @@
+-- Replies become top-level comments, and soft-deleted comments go away with the
+-- columns that mark them (while the current triggers still count them correctly)
+DROP INDEX IF EXISTS idx_log_comments_thread;
+DROP INDEX IF EXISTS idx_list_comments_thread;
+ALTER TABLE log_comments DROP COLUMN IF EXISTS parent_id;
+ALTER TABLE list_comments DROP COLUMN IF EXISTS parent_id;
+DELETE FROM log_comments WHERE deleted_at IS NOT NULL;
+DELETE FROM list_comments WHERE deleted_at IS NOT NULL;
+
+-- Restore the original counter triggers
+CREATE OR REPLACE FUNCTION update_log_comments_count()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        UPDATE logs SET comments_count = comments_count + 1 WHERE id = NEW.log_id;
+        RETURN NEW;
+    ELSIF TG_OP = 'DELETE' THEN
+        UPDATE logs SET comments_count = GREATEST(comments_count - 1, 0) WHERE id = OLD.log_id;
+        RETURN OLD;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trigger_update_log_comments_count ON log_comments;
+CREATE TRIGGER trigger_update_log_comments_count
+AFTER INSERT OR DELETE ON log_comments
+FOR EACH ROW EXECUTE FUNCTION update_log_comments_count();
+
+CREATE OR REPLACE FUNCTION update_list_comments_count()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        UPDATE lists SET comments_count = comments_count + 1 WHERE id = NEW.list_id;
+        RETURN NEW;
+    ELSIF TG_OP = 'DELETE' THEN
+        UPDATE lists SET comments_count = comments_count - 1 WHERE id = OLD.list_id;
+        RETURN OLD;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trigger_update_list_comments_count ON list_comments;
+CREATE TRIGGER trigger_update_list_comments_count
+    AFTER INSERT OR DELETE ON list_comments
+    FOR EACH ROW EXECUTE FUNCTION update_list_comments_count();
+
+CREATE OR REPLACE FUNCTION update_list_likes_count()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        UPDATE lists SET likes_count = likes_count + 1 WHERE id = NEW.list_id;
+        RETURN NEW;
+    ELSIF TG_OP = 'DELETE' THEN
+        UPDATE lists SET likes_count = likes_count - 1 WHERE id = OLD.list_id;
+        RETURN OLD;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Drop columns
+ALTER TABLE log_comments DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE log_comments DROP COLUMN IF EXISTS edited_at;
+ALTER TABLE list_comments DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE list_comments DROP COLUMN IF EXISTS edited_at;
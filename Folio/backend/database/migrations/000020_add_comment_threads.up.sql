@@ -0,0 +1,72 @@
+-- Threaded replies, edits and soft deletes for log and list comments.
+-- A deleted comment keeps its row (with the content cleared) so its replies stay in place.
+ALTER TABLE log_comments ADD COLUMN parent_id UUID REFERENCES log_comments(id) ON DELETE CASCADE;
+ALTER TABLE log_comments ADD COLUMN edited_at TIMESTAMPTZ;
+ALTER TABLE log_comments ADD COLUMN deleted_at TIMESTAMPTZ;
+
+ALTER TABLE list_comments ADD COLUMN parent_id UUID REFERENCES list_comments(id) ON DELETE CASCADE;
+ALTER TABLE list_comments ADD COLUMN edited_at TIMESTAMPTZ;
+ALTER TABLE list_comments ADD COLUMN deleted_at TIMESTAMPTZ;
+
+CREATE INDEX idx_log_comments_thread ON log_comments(log_id, parent_id, created_at);
+CREATE INDEX idx_list_comments_thread ON list_comments(list_id, parent_id, created_at);
+
+-- comments_count counts comments that aren't deleted, and never goes below zero
+CREATE OR REPLACE FUNCTION update_log_comments_count()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'INSERT' AND NEW.deleted_at IS NULL THEN
+        UPDATE logs SET comments_count = comments_count + 1 WHERE id = NEW.log_id;
+    ELSIF TG_OP = 'UPDATE' AND OLD.deleted_at IS NULL AND NEW.deleted_at IS NOT NULL THEN
+        UPDATE logs SET comments_count = GREATEST(comments_count - 1, 0) WHERE id = NEW.log_id;
+    ELSIF TG_OP = 'DELETE' AND OLD.deleted_at IS NULL THEN
+        UPDATE logs SET comments_count = GREATEST(comments_count - 1, 0) WHERE id = OLD.log_id;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trigger_update_log_comments_count ON log_comments;
+CREATE TRIGGER trigger_update_log_comments_count
+AFTER INSERT OR UPDATE OF deleted_at OR DELETE ON log_comments
+FOR EACH ROW EXECUTE FUNCTION update_log_comments_count();
+
+CREATE OR REPLACE FUNCTION update_list_comments_count()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'INSERT' AND NEW.deleted_at IS NULL THEN
+        UPDATE lists SET comments_count = comments_count + 1 WHERE id = NEW.list_id;
+    ELSIF TG_OP = 'UPDATE' AND OLD.deleted_at IS NULL AND NEW.deleted_at IS NOT NULL THEN
+        UPDATE lists SET comments_count = GREATEST(comments_count - 1, 0) WHERE id = NEW.list_id;
+    ELSIF TG_OP = 'DELETE' AND OLD.deleted_at IS NULL THEN
+        UPDATE lists SET comments_count = GREATEST(comments_count - 1, 0) WHERE id = OLD.list_id;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trigger_update_list_comments_count ON list_comments;
+CREATE TRIGGER trigger_update_list_comments_count
+    AFTER INSERT OR UPDATE OF deleted_at OR DELETE ON list_comments
+    FOR EACH ROW EXECUTE FUNCTION update_list_comments_count();
+
+-- The list likes counter had the same problem
+CREATE OR REPLACE FUNCTION update_list_likes_count()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        UPDATE lists SET likes_count = likes_count + 1 WHERE id = NEW.list_id;
+        RETURN NEW;
+    ELSIF TG_OP = 'DELETE' THEN
+        UPDATE lists SET likes_count = GREATEST(likes_count - 1, 0) WHERE id = OLD.list_id;
+        RETURN OLD;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Repair counters that already drifted
+UPDATE lists l SET
+    comments_count = (SELECT COUNT(*) FROM list_comments c WHERE c.list_id = l.id),
+    likes_count = (SELECT COUNT(*) FROM list_likes ll WHERE ll.list_id = l.id);
+UPDATE logs l SET comments_count = (SELECT COUNT(*) FROM log_comments c WHERE c.log_id = l.id);
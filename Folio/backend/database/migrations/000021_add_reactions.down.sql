@@ -0,0 +1,75 @@
+-- Drop triggers
+DROP TRIGGER IF EXISTS trigger_delete_annotation_reactions ON annotations;
+DROP TRIGGER IF EXISTS trigger_delete_list_comment_reactions ON list_comments;
+DROP TRIGGER IF EXISTS trigger_delete_log_comment_reactions ON log_comments;
+DROP TRIGGER IF EXISTS trigger_delete_list_reactions ON lists;
+DROP TRIGGER IF EXISTS trigger_delete_log_reactions ON logs;
+DROP TRIGGER IF EXISTS trigger_update_reaction_likes_count ON reactions;
+
+-- Drop views
+DROP VIEW IF EXISTS log_likes;
+DROP VIEW IF EXISTS list_likes;
+
+-- Drop functions
+DROP FUNCTION IF EXISTS delete_target_reactions();
+DROP FUNCTION IF EXISTS update_reaction_likes_count();
+DROP FUNCTION IF EXISTS insert_like_reaction();
+
+-- Recreate the like tables from the "like" reactions
+CREATE TABLE log_likes (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    log_id UUID NOT NULL REFERENCES logs(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE(user_id, log_id)
+);
+
+CREATE INDEX idx_log_likes_user_id ON log_likes(user_id);
+CREATE INDEX idx_log_likes_log_id ON log_likes(log_id);
+CREATE INDEX idx_log_likes_created_at ON log_likes(created_at DESC);
+
+CREATE TABLE list_likes (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    list_id UUID NOT NULL REFERENCES lists(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    UNIQUE(list_id, user_id)
+);
+
+CREATE INDEX idx_list_likes_list_id ON list_likes(list_id);
+CREATE INDEX idx_list_likes_user_id ON list_likes(user_id);
+
+INSERT INTO log_likes (id, user_id, log_id, created_at)
+SELECT r.id, r.user_id, r.target_id, r.created_at FROM reactions r
+JOIN logs l ON l.id = r.target_id
+WHERE r.target_type = 'log' AND r.reaction = 'like';
+
+INSERT INTO list_likes (id, list_id, user_id, created_at)
+SELECT r.id, r.target_id, r.user_id, r.created_at FROM reactions r
+JOIN lists l ON l.id = r.target_id
+WHERE r.target_type = 'list' AND r.reaction = 'like';
+
+CREATE OR REPLACE FUNCTION update_log_likes_count()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        UPDATE logs SET likes_count = likes_count + 1 WHERE id = NEW.log_id;
+        RETURN NEW;
+    ELSIF TG_OP = 'DELETE' THEN
+        UPDATE logs SET likes_count = GREATEST(likes_count - 1, 0) WHERE id = OLD.log_id;
+        RETURN OLD;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trigger_update_log_likes_count
+AFTER INSERT OR DELETE ON log_likes
+FOR EACH ROW EXECUTE FUNCTION update_log_likes_count();
+
+CREATE TRIGGER trigger_update_list_likes_count
+    AFTER INSERT OR DELETE ON list_likes
+    FOR EACH ROW EXECUTE FUNCTION update_list_likes_count();
+
+-- Drop tables
+DROP TABLE IF EXISTS reactions;
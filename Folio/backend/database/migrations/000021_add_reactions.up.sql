@@ -0,0 +1,119 @@
+-- Emoji-style reactions on logs, lists, comments and annotations, from a fixed set.
+-- A user can leave several different reactions on the same thing.
+CREATE TABLE IF NOT EXISTS reactions (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    target_type VARCHAR(20) NOT NULL CHECK (target_type IN ('log', 'list', 'log_comment', 'list_comment', 'annotation')),
+    target_id UUID NOT NULL,
+    reaction VARCHAR(20) NOT NULL CHECK (reaction IN ('like', 'love', 'laugh', 'wow', 'sad', 'insightful')),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE(user_id, target_type, target_id, reaction)
+);
+
+CREATE INDEX idx_reactions_target ON reactions(target_type, target_id, reaction, created_at DESC);
+CREATE INDEX idx_reactions_user_id ON reactions(user_id);
+
+-- Existing likes become "like" reactions
+INSERT INTO reactions (user_id, target_type, target_id, reaction, created_at)
+SELECT user_id, 'log', log_id, 'like', created_at FROM log_likes
+ON CONFLICT DO NOTHING;
+
+INSERT INTO reactions (user_id, target_type, target_id, reaction, created_at)
+SELECT user_id, 'list', list_id, 'like', created_at FROM list_likes
+ON CONFLICT DO NOTHING;
+
+DROP TRIGGER IF EXISTS trigger_update_log_likes_count ON log_likes;
+DROP TRIGGER IF EXISTS trigger_update_list_likes_count ON list_likes;
+DROP TABLE log_likes;
+DROP TABLE list_likes;
+
+-- The old like tables live on as views of the "like" reactions, so existing
+-- queries (is_liked, liked_by, counts) keep working unchanged
+CREATE VIEW log_likes AS
+    SELECT id, user_id, target_id AS log_id, created_at
+    FROM reactions WHERE target_type = 'log' AND reaction = 'like';
+
+CREATE VIEW list_likes AS
+    SELECT id, target_id AS list_id, user_id, created_at
+    FROM reactions WHERE target_type = 'list' AND reaction = 'like';
+
+CREATE OR REPLACE FUNCTION insert_like_reaction()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_TABLE_NAME = 'log_likes' THEN
+        INSERT INTO reactions (user_id, target_type, target_id, reaction, created_at)
+        VALUES (NEW.user_id, 'log', NEW.log_id, 'like', COALESCE(NEW.created_at, NOW()))
+        ON CONFLICT DO NOTHING;
+    ELSE
+        INSERT INTO reactions (user_id, target_type, target_id, reaction, created_at)
+        VALUES (NEW.user_id, 'list', NEW.list_id, 'like', COALESCE(NEW.created_at, NOW()))
+        ON CONFLICT DO NOTHING;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trigger_insert_log_like
+    INSTEAD OF INSERT ON log_likes
+    FOR EACH ROW EXECUTE FUNCTION insert_like_reaction();
+
+CREATE TRIGGER trigger_insert_list_like
+    INSTEAD OF INSERT ON list_likes
+    FOR EACH ROW EXECUTE FUNCTION insert_like_reaction();
+
+-- likes_count on logs and lists keeps counting "like" reactions
+CREATE OR REPLACE FUNCTION update_reaction_likes_count()
+RETURNS TRIGGER AS $$
+DECLARE
+    r reactions%ROWTYPE;
+    delta INTEGER;
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        r := NEW;
+        delta := 1;
+    ELSE
+        r := OLD;
+        delta := -1;
+    END IF;
+
+    IF r.reaction = 'like' AND r.target_type = 'log' THEN
+        UPDATE logs SET likes_count = GREATEST(likes_count + delta, 0) WHERE id = r.target_id;
+    ELSIF r.reaction = 'like' AND r.target_type = 'list' THEN
+        UPDATE lists SET likes_count = GREATEST(likes_count + delta, 0) WHERE id = r.target_id;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trigger_update_reaction_likes_count
+    AFTER INSERT OR DELETE ON reactions
+    FOR EACH ROW EXECUTE FUNCTION update_reaction_likes_count();
+
+-- Reactions have no foreign key to their target, so clean them up when it goes away
+CREATE OR REPLACE FUNCTION delete_target_reactions()
+RETURNS TRIGGER AS $$
+BEGIN
+    DELETE FROM reactions WHERE target_type = TG_ARGV[0] AND target_id = OLD.id;
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trigger_delete_log_reactions
+    AFTER DELETE ON logs
+    FOR EACH ROW EXECUTE FUNCTION delete_target_reactions('log');
+
+CREATE TRIGGER trigger_delete_list_reactions
+    AFTER DELETE ON lists
+    FOR EACH ROW EXECUTE FUNCTION delete_target_reactions('list');
+
+CREATE TRIGGER trigger_delete_log_comment_reactions
+    AFTER DELETE ON log_comments
+    FOR EACH ROW EXECUTE FUNCTION delete_target_reactions('log_comment');
+
+CREATE TRIGGER trigger_delete_list_comment_reactions
+    AFTER DELETE ON list_comments
+    FOR EACH ROW EXECUTE FUNCTION delete_target_reactions('list_comment');
+
+CREATE TRIGGER trigger_delete_annotation_reactions
+    AFTER DELETE ON annotations
+    FOR EACH ROW EXECUTE FUNCTION delete_target_reactions('annotation');
@@ -0,0 +1,5 @@
+-- Drop indexes
+DROP INDEX IF EXISTS idx_lists_tags;
+
+-- Drop columns
+ALTER TABLE lists DROP COLUMN IF EXISTS tags;
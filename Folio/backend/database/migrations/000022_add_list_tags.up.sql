@@ -0,0 +1,4 @@
+-- Free-form tags on lists ("cozy mysteries", "booker winners"), stored lowercased
+ALTER TABLE lists ADD COLUMN tags TEXT[] NOT NULL DEFAULT '{}';
+
+CREATE INDEX idx_lists_tags ON lists USING GIN (tags);
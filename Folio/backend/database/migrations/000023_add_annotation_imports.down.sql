@@ -0,0 +1,9 @@
+-- Drop indexes
+DROP INDEX IF EXISTS idx_annotations_import_fingerprint;
+DROP INDEX IF EXISTS idx_annotations_parent;
+
+-- Drop columns
+ALTER TABLE annotations DROP COLUMN IF EXISTS import_fingerprint;
+ALTER TABLE annotations DROP COLUMN IF EXISTS location_end;
+ALTER TABLE annotations DROP COLUMN IF EXISTS location_start;
+ALTER TABLE annotations DROP COLUMN IF EXISTS parent_id;
@@ -0,0 +1,9 @@
+-- Imported annotations keep where they came from in the book, which highlight a note
+-- belongs to, and a fingerprint so importing the same file twice doesn't duplicate them
+ALTER TABLE annotations ADD COLUMN parent_id UUID REFERENCES annotations(id) ON DELETE SET NULL;
+ALTER TABLE annotations ADD COLUMN location_start INTEGER;
+ALTER TABLE annotations ADD COLUMN location_end INTEGER;
+ALTER TABLE annotations ADD COLUMN import_fingerprint VARCHAR(64);
+
+CREATE INDEX idx_annotations_parent ON annotations(parent_id) WHERE parent_id IS NOT NULL;
+CREATE UNIQUE INDEX idx_annotations_import_fingerprint ON annotations(user_id, import_fingerprint) WHERE import_fingerprint IS NOT NULL;
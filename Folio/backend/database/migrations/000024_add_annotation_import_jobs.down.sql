@@ -0,0 +1,9 @@
+-- Drop indexes
+DROP INDEX IF EXISTS idx_annotations_import;
+DROP INDEX IF EXISTS idx_annotation_imports_user;
+
+-- Drop columns
+ALTER TABLE annotations DROP COLUMN IF EXISTS import_id;
+
+-- Drop tables
+DROP TABLE IF EXISTS annotation_imports;
@@ -0,0 +1,18 @@
+-- One row per completed annotation import. The file hash makes re-uploading the same
+-- export a no-op, and annotations remember which import created them.
+CREATE TABLE IF NOT EXISTS annotation_imports (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    format VARCHAR(20) NOT NULL CHECK (format IN ('kindle', 'readwise', 'apple-books', 'kobo')),
+    file_name VARCHAR(255),
+    file_hash VARCHAR(64) NOT NULL,
+    stats JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE(user_id, format, file_hash)
+);
+
+CREATE INDEX idx_annotation_imports_user ON annotation_imports(user_id, created_at DESC);
+
+ALTER TABLE annotations ADD COLUMN import_id UUID REFERENCES annotation_imports(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_annotations_import ON annotations(import_id) WHERE import_id IS NOT NULL;
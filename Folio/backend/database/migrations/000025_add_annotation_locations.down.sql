@@ -0,0 +1,13 @@
+-- Drop indexes
+DROP INDEX IF EXISTS idx_annotations_user_book_position;
+
+-- Drop constraints
+ALTER TABLE annotations DROP CONSTRAINT IF EXISTS annotations_parent_not_self;
+ALTER TABLE annotations DROP CONSTRAINT IF EXISTS annotations_offsets_ordered;
+
+-- Drop columns
+ALTER TABLE annotations DROP COLUMN IF EXISTS end_offset;
+ALTER TABLE annotations DROP COLUMN IF EXISTS start_offset;
+ALTER TABLE annotations DROP COLUMN IF EXISTS cfi;
+ALTER TABLE annotations DROP COLUMN IF EXISTS location_percent;
+ALTER TABLE annotations DROP COLUMN IF EXISTS chapter;
@@ -0,0 +1,12 @@
+-- Structured positions for annotations: the chapter, how far through the book, an EPUB CFI
+-- and character offsets within the page or chapter. A note may belong to a highlight.
+ALTER TABLE annotations ADD COLUMN chapter VARCHAR(500);
+ALTER TABLE annotations ADD COLUMN location_percent NUMERIC(5, 2) CHECK (location_percent >= 0 AND location_percent <= 100);
+ALTER TABLE annotations ADD COLUMN cfi TEXT;
+ALTER TABLE annotations ADD COLUMN start_offset INTEGER CHECK (start_offset >= 0);
+ALTER TABLE annotations ADD COLUMN end_offset INTEGER;
+
+ALTER TABLE annotations ADD CONSTRAINT annotations_offsets_ordered CHECK (end_offset IS NULL OR start_offset IS NULL OR end_offset >= start_offset);
+ALTER TABLE annotations ADD CONSTRAINT annotations_parent_not_self CHECK (parent_id IS NULL OR parent_id != id);
+
+CREATE INDEX idx_annotations_user_book_position ON annotations(user_id, book_id, location_percent, page_number);
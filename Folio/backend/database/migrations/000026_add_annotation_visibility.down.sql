@@ -0,0 +1,6 @@
+-- Drop indexes
+DROP INDEX IF EXISTS idx_annotations_user_shared;
+DROP INDEX IF EXISTS idx_annotations_book_shared;
+
+-- Drop columns
+ALTER TABLE annotations DROP COLUMN IF EXISTS visibility;
@@ -0,0 +1,6 @@
+-- Annotations can be shared with followers or everyone. Existing annotations stay private.
+ALTER TABLE annotations ADD COLUMN visibility VARCHAR(20) NOT NULL DEFAULT 'private'
+    CHECK (visibility IN ('private', 'followers', 'public'));
+
+CREATE INDEX idx_annotations_book_shared ON annotations(book_id, created_at DESC) WHERE visibility <> 'private';
+CREATE INDEX idx_annotations_user_shared ON annotations(user_id, created_at DESC) WHERE visibility <> 'private';
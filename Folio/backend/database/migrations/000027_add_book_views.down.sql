@@ -0,0 +1,6 @@
+-- Drop indexes
+DROP INDEX IF EXISTS idx_annotations_user_book_created;
+DROP INDEX IF EXISTS idx_book_views_user_viewed;
+
+-- Drop tables
+DROP TABLE IF EXISTS book_views;
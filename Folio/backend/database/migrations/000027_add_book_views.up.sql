@@ -0,0 +1,14 @@
+-- The books a user has recently opened, one row per book, so that new annotations can be
+-- matched to what they were just looking at
+CREATE TABLE IF NOT EXISTS book_views (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    book_id VARCHAR(255) NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+    view_count INTEGER NOT NULL DEFAULT 1,
+    viewed_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, book_id)
+);
+
+CREATE INDEX idx_book_views_user_viewed ON book_views(user_id, viewed_at DESC);
+
+-- Annotation association looks up the user's latest annotations per book
+CREATE INDEX idx_annotations_user_book_created ON annotations(user_id, book_id, created_at DESC) WHERE book_id IS NOT NULL;
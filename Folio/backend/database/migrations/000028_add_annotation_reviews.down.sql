@@ -0,0 +1,7 @@
+-- Drop indexes
+DROP INDEX IF EXISTS idx_annotation_reviews_user_reviewed;
+DROP INDEX IF EXISTS idx_annotation_reviews_user_due;
+
+-- Drop tables
+DROP TABLE IF EXISTS review_settings;
+DROP TABLE IF EXISTS annotation_reviews;
@@ -0,0 +1,27 @@
+-- Spaced-repetition state of each reviewed annotation. Annotations without a row have
+-- never been reviewed and are offered as new cards.
+CREATE TABLE IF NOT EXISTS annotation_reviews (
+    annotation_id UUID PRIMARY KEY REFERENCES annotations(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    ease_factor NUMERIC(4,2) NOT NULL DEFAULT 2.5 CHECK (ease_factor >= 1.3),
+    interval_days INTEGER NOT NULL DEFAULT 0 CHECK (interval_days >= 0),
+    repetitions INTEGER NOT NULL DEFAULT 0 CHECK (repetitions >= 0),
+    lapses INTEGER NOT NULL DEFAULT 0 CHECK (lapses >= 0),
+    due_on DATE NOT NULL,
+    last_grade SMALLINT CHECK (last_grade BETWEEN 0 AND 5),
+    last_reviewed_at TIMESTAMPTZ,
+    review_count INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_annotation_reviews_user_due ON annotation_reviews(user_id, due_on);
+CREATE INDEX idx_annotation_reviews_user_reviewed ON annotation_reviews(user_id, last_reviewed_at DESC);
+
+-- Per-user review preferences; users without a row get the defaults
+CREATE TABLE IF NOT EXISTS review_settings (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    cards_per_day INTEGER NOT NULL DEFAULT 20 CHECK (cards_per_day BETWEEN 1 AND 200),
+    include_tags TEXT[] NOT NULL DEFAULT '{}',
+    cloze_cards BOOLEAN NOT NULL DEFAULT false,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,12 @@
+-- Drop indexes
+DROP INDEX IF EXISTS idx_annotations_search;
+
+-- Restore the English-only index
+CREATE INDEX IF NOT EXISTS idx_annotations_content_fts ON annotations USING GIN (to_tsvector('english', content));
+
+-- Drop columns
+ALTER TABLE annotations DROP COLUMN IF EXISTS search_vector;
+ALTER TABLE annotations DROP COLUMN IF EXISTS language;
+
+-- Drop functions
+DROP FUNCTION IF EXISTS annotation_search_config(TEXT);
@@ -0,0 +1,35 @@
+-- The language of each annotation, as an ISO 639-1 code, picks the text search
+-- configuration it is indexed with. Annotations without one are indexed as English, as
+-- all annotations were before.
+ALTER TABLE annotations ADD COLUMN language VARCHAR(8);
+
+-- Maps a language code to its text search configuration; keep in sync with langdetect.Languages
+CREATE OR REPLACE FUNCTION annotation_search_config(lang TEXT)
+RETURNS regconfig AS $$
+    SELECT (CASE lang
+        WHEN 'da' THEN 'danish'
+        WHEN 'de' THEN 'german'
+        WHEN 'es' THEN 'spanish'
+        WHEN 'fi' THEN 'finnish'
+        WHEN 'fr' THEN 'french'
+        WHEN 'hu' THEN 'hungarian'
+        WHEN 'it' THEN 'italian'
+        WHEN 'nl' THEN 'dutch'
+        WHEN 'no' THEN 'norwegian'
+        WHEN 'pt' THEN 'portuguese'
+        WHEN 'ro' THEN 'romanian'
+        WHEN 'ru' THEN 'russian'
+        WHEN 'sv' THEN 'swedish'
+        WHEN 'tr' THEN 'turkish'
+        ELSE 'english'
+    END)::regconfig
+$$ LANGUAGE SQL IMMUTABLE;
+
+-- The content is weighted above the surrounding passage it was taken from
+ALTER TABLE annotations ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector(annotation_search_config(language), content), 'A') ||
+    setweight(to_tsvector(annotation_search_config(language), COALESCE(context, '')), 'B')
+) STORED;
+
+DROP INDEX IF EXISTS idx_annotations_content_fts;
+CREATE INDEX idx_annotations_search ON annotations USING GIN (search_vector);
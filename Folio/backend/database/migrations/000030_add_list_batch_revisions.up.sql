@@ -0,0 +1,8 @@
+-- Batch and transfer edits are stored as one revision listing every operation,
+-- instead of one full snapshot per item
+ALTER TABLE list_revisions DROP CONSTRAINT IF EXISTS list_revisions_action_check;
+ALTER TABLE list_revisions ADD CONSTRAINT list_revisions_action_check CHECK (action IN (
+    'list_created', 'list_updated', 'item_added', 'item_removed',
+    'item_moved', 'items_reordered', 'item_note_updated', 'list_restored',
+    'items_batch'
+));
@@ -0,0 +1,10 @@
+-- Drop triggers
+DROP TRIGGER IF EXISTS trigger_reading_day_annotation ON annotations;
+DROP TRIGGER IF EXISTS trigger_reading_day_log_update ON logs;
+DROP TRIGGER IF EXISTS trigger_reading_day_log_insert ON logs;
+
+-- Drop functions
+DROP FUNCTION IF EXISTS record_reading_day();
+
+-- Drop tables
+DROP TABLE IF EXISTS reading_days;
@@ -0,0 +1,48 @@
+-- The days each user did something that counts as reading, used for streaks. Rows are
+-- only ever added, so editing a log later doesn't move or erase the days it was read on.
+-- Days are UTC dates, matching how streaks are computed.
+CREATE TABLE IF NOT EXISTS reading_days (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    day DATE NOT NULL,
+    PRIMARY KEY (user_id, day)
+);
+
+CREATE OR REPLACE FUNCTION record_reading_day()
+RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO reading_days (user_id, day)
+    VALUES (NEW.user_id, (NOW() AT TIME ZONE 'UTC')::date)
+    ON CONFLICT DO NOTHING;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Creating a log or changing what the reader recorded in it counts; likes and comments
+-- from other users, which also update the row, don't
+DROP TRIGGER IF EXISTS trigger_reading_day_log_insert ON logs;
+CREATE TRIGGER trigger_reading_day_log_insert
+    AFTER INSERT ON logs
+    FOR EACH ROW EXECUTE FUNCTION record_reading_day();
+
+DROP TRIGGER IF EXISTS trigger_reading_day_log_update ON logs;
+CREATE TRIGGER trigger_reading_day_log_update
+    AFTER UPDATE ON logs
+    FOR EACH ROW
+    WHEN ((OLD.status, OLD.rating, OLD.review, OLD.notes, OLD.start_date, OLD.finish_date)
+          IS DISTINCT FROM (NEW.status, NEW.rating, NEW.review, NEW.notes, NEW.start_date, NEW.finish_date))
+    EXECUTE FUNCTION record_reading_day();
+
+DROP TRIGGER IF EXISTS trigger_reading_day_annotation ON annotations;
+CREATE TRIGGER trigger_reading_day_annotation
+    AFTER INSERT ON annotations
+    FOR EACH ROW EXECUTE FUNCTION record_reading_day();
+
+-- Backfill from the activity streaks were computed from until now; only the latest edit
+-- of each log is still known
+INSERT INTO reading_days (user_id, day)
+SELECT user_id, (created_at AT TIME ZONE 'UTC')::date FROM logs
+UNION
+SELECT user_id, (updated_at AT TIME ZONE 'UTC')::date FROM logs
+UNION
+SELECT user_id, (created_at AT TIME ZONE 'UTC')::date FROM annotations
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,224 @@
+// Package exporters renders a user's annotations, grouped by book, for use outside Folio.
+//
+// Supported formats:
+//   - markdown: one note per book with YAML front matter, highlights as blockquotes
+//   - obsidian: like markdown, with callouts, #tags, author wikilinks and block ids
+//   - json: books with their annotations and the notes written on each highlight
+//   - csv: one row per annotation
+//
+// An export is either a single file or a zip with one file per book.
+package exporters
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Book is one book's annotations. Annotations not linked to a book are exported
+// together as a Book with an empty ID.
+type Book struct {
+	ID          string
+	Title       string
+	Authors     []string
+	ISBN        string
+	Annotations []Annotation
+}
+
+// Annotation is one highlight or note. Notes written on a highlight are nested under it.
+type Annotation struct {
+	ID            string
+	ParentID      *string
+	Type          string
+	Content       string
+	Context       *string
+	Page          *int
+	Chapter       *string
+	Percent       *float64
+	LocationStart *int
+	LocationEnd   *int
+	Tags          []string
+	CreatedAt     time.Time
+	Notes         []Annotation
+}
+
+// Format is one supported export format
+type Format struct {
+	Name        string
+	Extension   string
+	ContentType string
+	// Document renders a single book as a file of its own
+	Document func(book Book, exportedAt time.Time) ([]byte, error)
+	// Combined renders every book into one file
+	Combined func(books []Book, exportedAt time.Time) ([]byte, error)
+	// Zipped is set when the format is delivered as a zip unless a single file is asked for
+	Zipped bool
+}
+
+var formats = map[string]Format{
+	"markdown": {Name: "markdown", Extension: "md", ContentType: "text/markdown; charset=utf-8", Document: markdownDocument, Combined: markdownCombined},
+	"obsidian": {Name: "obsidian", Extension: "md", ContentType: "text/markdown; charset=utf-8", Document: obsidianDocument, Combined: obsidianCombined, Zipped: true},
+	"json":     {Name: "json", Extension: "json", ContentType: "application/json", Document: jsonDocument, Combined: jsonCombined},
+	"csv":      {Name: "csv", Extension: "csv", ContentType: "text/csv; charset=utf-8", Document: csvDocument, Combined: csvCombined},
+}
+
+// Lookup returns the format with the given name
+func Lookup(name string) (Format, error) {
+	format, ok := formats[name]
+	if !ok {
+		names := make([]string, 0, len(formats))
+		for name := range formats {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return Format{}, fmt.Errorf("unsupported format. Must be one of: %s", strings.Join(names, ", "))
+	}
+	return format, nil
+}
+
+// Thread nests notes under the highlight they were written on. Notes whose highlight
+// isn't among annotations stay at the top level. Order is otherwise preserved.
+func Thread(annotations []Annotation) []Annotation {
+	children := map[string][]Annotation{}
+	present := map[string]bool{}
+	for _, annotation := range annotations {
+		present[annotation.ID] = true
+	}
+	for _, annotation := range annotations {
+		if annotation.ParentID != nil && present[*annotation.ParentID] && *annotation.ParentID != annotation.ID {
+			children[*annotation.ParentID] = append(children[*annotation.ParentID], annotation)
+		}
+	}
+
+	var attach func(annotation Annotation, depth int) Annotation
+	attach = func(annotation Annotation, depth int) Annotation {
+		// Parent links come from user data, so bound the depth in case of a cycle
+		if depth < 10 {
+			for _, child := range children[annotation.ID] {
+				annotation.Notes = append(annotation.Notes, attach(child, depth+1))
+			}
+		}
+		return annotation
+	}
+
+	threaded := []Annotation{}
+	for _, annotation := range annotations {
+		if annotation.ParentID != nil && present[*annotation.ParentID] && *annotation.ParentID != annotation.ID {
+			continue
+		}
+		threaded = append(threaded, attach(annotation, 0))
+	}
+	return threaded
+}
+
+// Zip renders each book as its own file and packs them into a zip archive
+func Zip(format Format, books []Book, exportedAt time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+
+	used := map[string]int{}
+	for _, book := range books {
+		document, err := format.Document(book, exportedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		name := FileName(book.Title)
+		used[strings.ToLower(name)]++
+		if n := used[strings.ToLower(name)]; n > 1 {
+			name = fmt.Sprintf("%s (%d)", name, n)
+		}
+
+		file, err := archive.CreateHeader(&zip.FileHeader{
+			Name:     name + "." + format.Extension,
+			Method:   zip.Deflate,
+			Modified: exportedAt,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := file.Write(document); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// FileName turns a book title into a file name that is safe on every common filesystem
+func FileName(title string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r < 32 || strings.ContainsRune(`/\:*?"<>|#^[]`, r):
+			return -1
+		}
+		return r
+	}, title)
+	name = strings.Trim(strings.Join(strings.Fields(name), " "), ". ")
+	if runes := []rune(name); len(runes) > 100 {
+		name = strings.TrimSpace(string(runes[:100]))
+	}
+	if name == "" {
+		name = "Untitled"
+	}
+	return name
+}
+
+// bookTags returns the tags used on a book's annotations, sorted
+func bookTags(book Book) []string {
+	seen := map[string]bool{}
+	tags := []string{}
+	var collect func(annotations []Annotation)
+	collect = func(annotations []Annotation) {
+		for _, annotation := range annotations {
+			for _, tag := range annotation.Tags {
+				if !seen[tag] {
+					seen[tag] = true
+					tags = append(tags, tag)
+				}
+			}
+			collect(annotation.Notes)
+		}
+	}
+	collect(book.Annotations)
+	sort.Strings(tags)
+	return tags
+}
+
+// countAnnotations counts a book's annotations, including nested notes
+func countAnnotations(annotations []Annotation) int {
+	count := len(annotations)
+	for _, annotation := range annotations {
+		count += countAnnotations(annotation.Notes)
+	}
+	return count
+}
+
+// reference describes where in the book an annotation is, e.g. "Chapter 3, p. 12, loc. 140-152"
+func reference(annotation Annotation) string {
+	parts := []string{}
+	if annotation.Chapter != nil && *annotation.Chapter != "" {
+		parts = append(parts, *annotation.Chapter)
+	}
+	if annotation.Page != nil {
+		parts = append(parts, fmt.Sprintf("p. %d", *annotation.Page))
+	}
+	if annotation.LocationStart != nil {
+		if annotation.LocationEnd != nil && *annotation.LocationEnd != *annotation.LocationStart {
+			parts = append(parts, fmt.Sprintf("loc. %d-%d", *annotation.LocationStart, *annotation.LocationEnd))
+		} else {
+			parts = append(parts, fmt.Sprintf("loc. %d", *annotation.LocationStart))
+		}
+	}
+	if annotation.Page == nil && annotation.LocationStart == nil && annotation.Percent != nil {
+		parts = append(parts, strconv.FormatFloat(*annotation.Percent, 'f', -1, 64)+"%")
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,167 @@
+package exporters
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+var exportedAt = time.Date(2024, time.May, 1, 12, 0, 0, 0, time.UTC)
+
+func strPtr(s string) *string { return &s }
+
+func intPtr(n int) *int { return &n }
+
+func floatPtr(f float64) *float64 { return &f }
+
+// testBooks covers nested notes, multi-line content, every location field and
+// titles and tags that need escaping
+func testBooks() []Book {
+	highlightID := "3f2a9c1e-7b4d-4e8a-9c2f-1a2b3c4d5e6f"
+	return []Book{
+		{
+			ID:      "book-1",
+			Title:   `The "Quoted": Title`,
+			Authors: []string{"Ursula K. Le Guin"},
+			ISBN:    "9780441478125",
+			Annotations: Thread([]Annotation{
+				{
+					ID:            highlightID,
+					Type:          "highlight",
+					Content:       "Light is the left hand of darkness\nand darkness the right hand of light.",
+					Context:       strPtr("Tormer's Lay"),
+					Page:          intPtr(233),
+					Chapter:       strPtr("Chapter 16"),
+					LocationStart: intPtr(3120),
+					LocationEnd:   intPtr(3124),
+					Tags:          []string{"philosophy/taoism", "favourite quotes"},
+					CreatedAt:     time.Date(2024, time.January, 2, 9, 30, 0, 0, time.UTC),
+				},
+				{
+					ID:        "9d8c7b6a-5f4e-4d3c-8b2a-0f1e2d3c4b5a",
+					ParentID:  &highlightID,
+					Type:      "note",
+					Content:   "Compare with the yin-yang.\nSee also chapter 19.",
+					Tags:      []string{"comparison"},
+					CreatedAt: time.Date(2024, time.January, 2, 9, 35, 0, 0, time.UTC),
+				},
+				{
+					ID:        "aa11bb22-cc33-dd44-ee55-ff6677889900",
+					Type:      "note",
+					Content:   "Gethenian politics, \"shifgrethor\", and trust.",
+					Percent:   floatPtr(42.5),
+					CreatedAt: time.Date(2024, time.January, 3, 18, 0, 0, 0, time.UTC),
+				},
+			}),
+		},
+		{
+			Title: "Unassociated",
+			Annotations: []Annotation{
+				{
+					ID:        "00000000-0000-0000-0000-000000000001",
+					Type:      "highlight",
+					Content:   "A line with, commas and a \"quote\"",
+					CreatedAt: time.Date(2024, time.February, 1, 0, 0, 0, 0, time.UTC),
+				},
+			},
+		},
+	}
+}
+
+// golden compares got with testdata/name, rewriting the file when -update is set
+func golden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.MkdirAll("testdata", 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading golden file: %v (run go test -update to create it)", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s differs from golden file\n--- got ---\n%s\n--- want ---\n%s", name, got, want)
+	}
+}
+
+func TestExportGolden(t *testing.T) {
+	books := testBooks()
+
+	tests := []struct {
+		format string
+		golden string
+		single bool
+	}{
+		{"markdown", "markdown_document.md", true},
+		{"markdown", "markdown_combined.md", false},
+		{"obsidian", "obsidian_document.md", true},
+		{"json", "json_document.json", true},
+		{"json", "json_combined.json", false},
+		{"csv", "csv_combined.csv", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.golden, func(t *testing.T) {
+			format, err := Lookup(tt.format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []byte
+			if tt.single {
+				got, err = format.Document(books[0], exportedAt)
+			} else {
+				got, err = format.Combined(books, exportedAt)
+			}
+			if err != nil {
+				t.Fatalf("export error = %v", err)
+			}
+			golden(t, tt.golden, got)
+		})
+	}
+}
+
+func TestYAMLString(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"Dune", `"Dune"`},
+		{"", `""`},
+		{"Title: Subtitle", `"Title: Subtitle"`},
+		{`She said "hi"`, `"She said \"hi\""`},
+		{`back\slash`, `"back\\slash"`},
+		{"line one\nline two", `"line one\nline two"`},
+		{"- dash", `"- dash"`},
+		{"# not a comment", `"# not a comment"`},
+		{"yes", `"yes"`},
+		{"Café ☕", `"Café ☕"`},
+		{"tab\there", `"tab\there"`},
+	}
+
+	for _, tt := range tests {
+		if got := yamlString(tt.input); got != tt.want {
+			t.Errorf("yamlString(%q) = %s, want %s", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	for _, name := range []string{"markdown", "obsidian", "json", "csv"} {
+		if _, err := Lookup(name); err != nil {
+			t.Errorf("Lookup(%q) error = %v", name, err)
+		}
+	}
+	if _, err := Lookup("pdf"); err == nil {
+		t.Errorf("Lookup(%q) succeeded, want error", "pdf")
+	}
+}
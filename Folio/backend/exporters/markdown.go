@@ -0,0 +1,257 @@
+package exporters
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// markdownStyle is what differs between plain markdown and Obsidian notes
+type markdownStyle struct {
+	obsidian bool
+}
+
+func markdownDocument(book Book, exportedAt time.Time) ([]byte, error) {
+	return markdownStyle{}.document(book, exportedAt), nil
+}
+
+func markdownCombined(books []Book, exportedAt time.Time) ([]byte, error) {
+	return markdownStyle{}.combined(books, exportedAt), nil
+}
+
+func obsidianDocument(book Book, exportedAt time.Time) ([]byte, error) {
+	return markdownStyle{obsidian: true}.document(book, exportedAt), nil
+}
+
+func obsidianCombined(books []Book, exportedAt time.Time) ([]byte, error) {
+	return markdownStyle{obsidian: true}.combined(books, exportedAt), nil
+}
+
+// yamlString quotes s as a YAML double-quoted scalar; JSON strings are valid YAML
+func yamlString(s string) string {
+	quoted, _ := json.Marshal(s)
+	return string(quoted)
+}
+
+// writeYAMLList writes a front matter list, or an empty list when there are no items
+func writeYAMLList(b *strings.Builder, key string, items []string) {
+	if len(items) == 0 {
+		fmt.Fprintf(b, "%s: []\n", key)
+		return
+	}
+	fmt.Fprintf(b, "%s:\n", key)
+	for _, item := range items {
+		fmt.Fprintf(b, "  - %s\n", yamlString(item))
+	}
+}
+
+// obsidianTag turns a Folio tag into something Obsidian recognizes as one tag
+func obsidianTag(tag string) string {
+	return strings.Join(strings.Fields(tag), "-")
+}
+
+func (s markdownStyle) frontMatterTags(tags []string) []string {
+	if !s.obsidian {
+		return tags
+	}
+	converted := make([]string, len(tags))
+	for i, tag := range tags {
+		converted[i] = obsidianTag(tag)
+	}
+	return converted
+}
+
+// document renders a book as a note of its own, with the book's details as front matter
+func (s markdownStyle) document(book Book, exportedAt time.Time) []byte {
+	var b strings.Builder
+
+	authors := book.Authors
+	if s.obsidian {
+		authors = make([]string, len(book.Authors))
+		for i, author := range book.Authors {
+			authors[i] = "[[" + author + "]]"
+		}
+	}
+
+	b.WriteString("---\n")
+	fmt.Fprintf(&b, "title: %s\n", yamlString(book.Title))
+	writeYAMLList(&b, "authors", authors)
+	if book.ISBN != "" {
+		fmt.Fprintf(&b, "isbn: %s\n", yamlString(book.ISBN))
+	}
+	writeYAMLList(&b, "tags", s.frontMatterTags(bookTags(book)))
+	if book.ID != "" {
+		fmt.Fprintf(&b, "folio_book_id: %s\n", yamlString(book.ID))
+	}
+	fmt.Fprintf(&b, "annotations: %d\n", countAnnotations(book.Annotations))
+	fmt.Fprintf(&b, "exported_at: %s\n", exportedAt.UTC().Format(time.RFC3339))
+	b.WriteString("---\n\n")
+
+	s.writeBook(&b, book, 1)
+	return []byte(b.String())
+}
+
+// combined renders every book into one file, one section per book
+func (s markdownStyle) combined(books []Book, exportedAt time.Time) []byte {
+	var b strings.Builder
+
+	tags := []string{}
+	seen := map[string]bool{}
+	total := 0
+	for _, book := range books {
+		total += countAnnotations(book.Annotations)
+		for _, tag := range bookTags(book) {
+			if !seen[tag] {
+				seen[tag] = true
+				tags = append(tags, tag)
+			}
+		}
+	}
+
+	b.WriteString("---\n")
+	b.WriteString("title: \"Annotations\"\n")
+	writeYAMLList(&b, "tags", s.frontMatterTags(tags))
+	fmt.Fprintf(&b, "books: %d\n", len(books))
+	fmt.Fprintf(&b, "annotations: %d\n", total)
+	fmt.Fprintf(&b, "exported_at: %s\n", exportedAt.UTC().Format(time.RFC3339))
+	b.WriteString("---\n\n")
+	b.WriteString("# Annotations\n")
+
+	for _, book := range books {
+		b.WriteString("\n")
+		s.writeBook(&b, book, 2)
+	}
+	return []byte(b.String())
+}
+
+// writeBook writes a book's heading, details and annotations
+func (s markdownStyle) writeBook(b *strings.Builder, book Book, level int) {
+	fmt.Fprintf(b, "%s %s\n\n", strings.Repeat("#", level), book.Title)
+
+	details := []string{}
+	if len(book.Authors) > 0 {
+		if s.obsidian {
+			links := make([]string, len(book.Authors))
+			for i, author := range book.Authors {
+				links[i] = "[[" + author + "]]"
+			}
+			details = append(details, strings.Join(links, ", "))
+		} else {
+			details = append(details, "*"+strings.Join(book.Authors, ", ")+"*")
+		}
+	}
+	if book.ISBN != "" {
+		details = append(details, "ISBN "+book.ISBN)
+	}
+	if len(details) > 0 {
+		b.WriteString(strings.Join(details, " · ") + "\n\n")
+	}
+
+	for _, annotation := range book.Annotations {
+		s.writeAnnotation(b, annotation)
+	}
+}
+
+// quote prefixes every line of text for a blockquote or callout
+func quote(text string) string {
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	for i, line := range lines {
+		if line == "" {
+			lines[i] = ">"
+		} else {
+			lines[i] = "> " + line
+		}
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// writeAnnotation writes a highlight or note followed by the notes written on it
+func (s markdownStyle) writeAnnotation(b *strings.Builder, annotation Annotation) {
+	ref := reference(annotation)
+
+	if s.obsidian {
+		callout := "quote"
+		if annotation.Type == "note" {
+			callout = "note"
+		}
+		fmt.Fprintf(b, "> [!%s]", callout)
+		if ref != "" {
+			b.WriteString(" " + ref)
+		}
+		b.WriteString("\n")
+		b.WriteString(quote(annotation.Content))
+		if annotation.Context != nil && *annotation.Context != "" {
+			b.WriteString(">\n")
+			b.WriteString(quote("*Context:* " + *annotation.Context))
+		}
+		b.WriteString("\n")
+
+		trailer := []string{}
+		for _, tag := range annotation.Tags {
+			trailer = append(trailer, "#"+obsidianTag(tag))
+		}
+		// Block ids let other notes link straight to this annotation
+		trailer = append(trailer, "^folio-"+blockID(annotation.ID))
+		b.WriteString(strings.Join(trailer, " ") + "\n\n")
+	} else {
+		if annotation.Type == "note" {
+			b.WriteString(annotation.Content + "\n")
+		} else {
+			b.WriteString(quote(annotation.Content))
+		}
+		if ref != "" {
+			if annotation.Type == "note" {
+				fmt.Fprintf(b, "\n— %s\n", ref)
+			} else {
+				fmt.Fprintf(b, ">\n> — %s\n", ref)
+			}
+		}
+		if annotation.Context != nil && *annotation.Context != "" {
+			fmt.Fprintf(b, "\n*Context:* %s\n", *annotation.Context)
+		}
+		if len(annotation.Tags) > 0 {
+			fmt.Fprintf(b, "\nTags: %s\n", strings.Join(annotation.Tags, ", "))
+		}
+		b.WriteString("\n")
+	}
+
+	for _, note := range annotation.Notes {
+		s.writeNote(b, note, 1)
+	}
+}
+
+// writeNote writes a note on a highlight as an indented list item
+func (s markdownStyle) writeNote(b *strings.Builder, note Annotation, depth int) {
+	indent := strings.Repeat("  ", depth-1)
+	lines := strings.Split(strings.TrimRight(note.Content, "\n"), "\n")
+	if s.obsidian {
+		// Tags and the block id go at the end of the item so they belong to it
+		for _, tag := range note.Tags {
+			lines[len(lines)-1] += " #" + obsidianTag(tag)
+		}
+		lines[len(lines)-1] += " ^folio-" + blockID(note.ID)
+	}
+	fmt.Fprintf(b, "%s- **Note:** %s\n", indent, lines[0])
+	for _, line := range lines[1:] {
+		fmt.Fprintf(b, "%s  %s\n", indent, line)
+	}
+	if !s.obsidian && len(note.Tags) > 0 {
+		fmt.Fprintf(b, "%s  Tags: %s\n", indent, strings.Join(note.Tags, ", "))
+	}
+	for _, child := range note.Notes {
+		s.writeNote(b, child, depth+1)
+	}
+	if depth == 1 {
+		b.WriteString("\n")
+	}
+}
+
+// blockID shortens an annotation id to the characters Obsidian allows in block ids
+func blockID(id string) string {
+	id = strings.ReplaceAll(id, "-", "")
+	if len(id) > 12 {
+		id = id[:12]
+	}
+	return id
+}
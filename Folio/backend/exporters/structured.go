@@ -0,0 +1,168 @@
+package exporters
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type jsonAnnotation struct {
+	ID            string           `json:"id"`
+	Type          string           `json:"type"`
+	Content       string           `json:"content"`
+	Context       *string          `json:"context"`
+	PageNumber    *int             `json:"page_number"`
+	Chapter       *string          `json:"chapter"`
+	Percent       *float64         `json:"percent"`
+	LocationStart *int             `json:"location_start"`
+	LocationEnd   *int             `json:"location_end"`
+	Reference     string           `json:"reference"`
+	Tags          []string         `json:"tags"`
+	CreatedAt     time.Time        `json:"created_at"`
+	Notes         []jsonAnnotation `json:"notes"`
+}
+
+type jsonBook struct {
+	ID          *string          `json:"id"`
+	Title       string           `json:"title"`
+	Authors     []string         `json:"authors"`
+	ISBN        *string          `json:"isbn"`
+	Tags        []string         `json:"tags"`
+	Annotations []jsonAnnotation `json:"annotations"`
+}
+
+func toJSONAnnotations(annotations []Annotation) []jsonAnnotation {
+	converted := make([]jsonAnnotation, 0, len(annotations))
+	for _, annotation := range annotations {
+		tags := annotation.Tags
+		if tags == nil {
+			tags = []string{}
+		}
+		converted = append(converted, jsonAnnotation{
+			ID:            annotation.ID,
+			Type:          annotation.Type,
+			Content:       annotation.Content,
+			Context:       annotation.Context,
+			PageNumber:    annotation.Page,
+			Chapter:       annotation.Chapter,
+			Percent:       annotation.Percent,
+			LocationStart: annotation.LocationStart,
+			LocationEnd:   annotation.LocationEnd,
+			Reference:     reference(annotation),
+			Tags:          tags,
+			CreatedAt:     annotation.CreatedAt,
+			Notes:         toJSONAnnotations(annotation.Notes),
+		})
+	}
+	return converted
+}
+
+func toJSONBook(book Book) jsonBook {
+	converted := jsonBook{
+		Title:       book.Title,
+		Authors:     book.Authors,
+		Tags:        bookTags(book),
+		Annotations: toJSONAnnotations(book.Annotations),
+	}
+	if converted.Authors == nil {
+		converted.Authors = []string{}
+	}
+	if book.ID != "" {
+		converted.ID = &book.ID
+	}
+	if book.ISBN != "" {
+		converted.ISBN = &book.ISBN
+	}
+	return converted
+}
+
+func jsonDocument(book Book, exportedAt time.Time) ([]byte, error) {
+	return json.MarshalIndent(map[string]interface{}{
+		"exported_at": exportedAt.UTC(),
+		"book":        toJSONBook(book),
+	}, "", "  ")
+}
+
+func jsonCombined(books []Book, exportedAt time.Time) ([]byte, error) {
+	converted := make([]jsonBook, 0, len(books))
+	total := 0
+	for _, book := range books {
+		converted = append(converted, toJSONBook(book))
+		total += countAnnotations(book.Annotations)
+	}
+	return json.MarshalIndent(map[string]interface{}{
+		"exported_at": exportedAt.UTC(),
+		"books":       converted,
+		"count":       total,
+	}, "", "  ")
+}
+
+var csvHeader = []string{
+	"book_id", "book_title", "book_authors", "isbn",
+	"annotation_id", "parent_id", "type", "content", "context",
+	"chapter", "page_number", "percent", "location_start", "location_end", "tags", "created_at",
+}
+
+// writeCSVRows writes one row per annotation; notes follow their highlight and name it as parent
+func writeCSVRows(w *csv.Writer, book Book, annotations []Annotation) error {
+	optionalInt := func(v *int) string {
+		if v == nil {
+			return ""
+		}
+		return strconv.Itoa(*v)
+	}
+	optionalFloat := func(v *float64) string {
+		if v == nil {
+			return ""
+		}
+		return strconv.FormatFloat(*v, 'f', -1, 64)
+	}
+	optionalString := func(v *string) string {
+		if v == nil {
+			return ""
+		}
+		return *v
+	}
+
+	for _, annotation := range annotations {
+		err := w.Write([]string{
+			book.ID, book.Title, strings.Join(book.Authors, "; "), book.ISBN,
+			annotation.ID, optionalString(annotation.ParentID), annotation.Type, annotation.Content,
+			optionalString(annotation.Context), optionalString(annotation.Chapter), optionalInt(annotation.Page), optionalFloat(annotation.Percent),
+			optionalInt(annotation.LocationStart), optionalInt(annotation.LocationEnd),
+			strings.Join(annotation.Tags, ", "), annotation.CreatedAt.UTC().Format(time.RFC3339),
+		})
+		if err != nil {
+			return err
+		}
+		if err := writeCSVRows(w, book, annotation.Notes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func csvDocument(book Book, exportedAt time.Time) ([]byte, error) {
+	return csvCombined([]Book{book}, exportedAt)
+}
+
+func csvCombined(books []Book, _ time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.Write(csvHeader); err != nil {
+		return nil, err
+	}
+	for _, book := range books {
+		if err := writeCSVRows(w, book, book.Annotations); err != nil {
+			return nil, err
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,752 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"folio/api/auth"
+	"folio/api/similarity"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Weights of the signals that tie an annotation to a book. A book's score is the sum of its
+// signals, between 0 and 1.
+const (
+	associationReadingWeight  = 0.3  // the book's log status is 'reading'
+	associationProgressWeight = 0.2  // the log was updated recently
+	associationViewWeight     = 0.2  // the book page was opened recently
+	associationActivityWeight = 0.2  // the user annotated the book recently
+	associationTitleWeight    = 0.5  // the text names the book's title
+	associationAuthorWeight   = 0.15 // the text names one of its authors
+	associationQuoteWeight    = 0.5  // the text quotes a passage highlighted in the book
+	associationSimilarWeight  = 0.3  // scaled by the best similarity to the book's annotations
+	associationNearbyWeight   = 0.1  // the page is close to the user's last annotated page
+	// associationPastEndPenalty is taken off when the page is past the book's last page
+	associationPastEndPenalty = 0.6
+)
+
+// Recency signals halve in weight every half-life and are ignored past associationWindow
+const (
+	associationWindow      = 30 * 24 * time.Hour
+	progressHalfLife       = 7 * 24 * time.Hour
+	viewHalfLife           = 2 * 24 * time.Hour
+	activityHalfLife       = 3 * 24 * time.Hour
+	associationNearbyPages = 25
+	// minQuoteLength keeps short, common phrases from counting as quotes
+	minQuoteLength     = 30
+	minSimilarPassage  = 0.2
+	maxBookCandidates  = 50
+	maxPassagesPerBook = 100
+)
+
+// An annotation is associated automatically when its best book scores at least
+// autoAssociateScore and leads the next one by autoAssociateMargin
+const (
+	autoAssociateScore  = 0.5
+	autoAssociateMargin = 0.15
+	maxBookSuggestions  = 5
+	maxBulkAssignments  = 500
+)
+
+// bookCandidate is a book the user has been reading, viewing or annotating lately
+type bookCandidate struct {
+	ID        string
+	Title     string
+	Authors   []string
+	CoverURL  *string
+	PageCount *int
+	Status    *string
+	// LogUpdatedAt is the last change to the user's log of the book, such as progress
+	LogUpdatedAt    *time.Time
+	ViewedAt        *time.Time
+	LastAnnotatedAt *time.Time
+	// LastPage is the page of the user's latest annotation on the book that has one
+	LastPage *int
+	passages []string
+}
+
+// bookSuggestion is a candidate book ranked for one annotation
+type bookSuggestion struct {
+	Book    *bookCandidate
+	Score   float64
+	Reasons []string
+}
+
+// bookAssociator ranks the user's candidate books for annotation text
+type bookAssociator struct {
+	candidates []*bookCandidate
+	index      *similarity.Index
+	// bookOf maps an indexed annotation to its book
+	bookOf map[string]*bookCandidate
+}
+
+// loadBookAssociator gathers the books the user is reading, or logged, viewed or annotated
+// within the association window around from and to, along with the passages annotated in them
+func (h *AnnotationHandler) loadBookAssociator(ctx context.Context, userID string, from, to time.Time) (*bookAssociator, error) {
+	rows, err := h.DB.Query(ctx, `
+		WITH candidate_books AS (
+			SELECT book_id FROM logs
+			WHERE user_id = $1 AND (status = 'reading' OR updated_at BETWEEN $2 AND $3)
+			UNION
+			SELECT book_id FROM book_views
+			WHERE user_id = $1 AND viewed_at BETWEEN $2 AND $3
+			UNION
+			SELECT book_id FROM annotations
+			WHERE user_id = $1 AND book_id IS NOT NULL AND created_at BETWEEN $2 AND $3
+		)
+		SELECT b.id, b.title, b.authors, b.cover_url, b.page_count,
+		       l.status, l.updated_at, v.viewed_at, la.created_at,
+		       (SELECT page_number FROM annotations
+		        WHERE user_id = $1 AND book_id = b.id AND page_number IS NOT NULL
+		        ORDER BY created_at DESC LIMIT 1)
+		FROM candidate_books cb
+		JOIN books b ON b.id = cb.book_id
+		LEFT JOIN LATERAL (
+			SELECT status, updated_at FROM logs
+			WHERE user_id = $1 AND book_id = b.id
+			ORDER BY updated_at DESC LIMIT 1
+		) l ON true
+		LEFT JOIN book_views v ON v.user_id = $1 AND v.book_id = b.id
+		LEFT JOIN LATERAL (
+			SELECT created_at FROM annotations
+			WHERE user_id = $1 AND book_id = b.id
+			ORDER BY created_at DESC LIMIT 1
+		) la ON true
+		ORDER BY GREATEST(l.updated_at, v.viewed_at, la.created_at) DESC NULLS LAST, b.id
+		LIMIT $4
+	`, userID, from.Add(-associationWindow), to.Add(associationWindow), maxBookCandidates)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	associator := &bookAssociator{bookOf: map[string]*bookCandidate{}}
+	byID := map[string]*bookCandidate{}
+	ids := []string{}
+	for rows.Next() {
+		candidate := &bookCandidate{}
+		err := rows.Scan(
+			&candidate.ID, &candidate.Title, &candidate.Authors, &candidate.CoverURL, &candidate.PageCount,
+			&candidate.Status, &candidate.LogUpdatedAt, &candidate.ViewedAt, &candidate.LastAnnotatedAt, &candidate.LastPage,
+		)
+		if err != nil {
+			continue
+		}
+		associator.candidates = append(associator.candidates, candidate)
+		byID[candidate.ID] = candidate
+		ids = append(ids, candidate.ID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		associator.index = similarity.NewIndex(nil)
+		return associator, nil
+	}
+
+	// Passages are the latest annotations on each book that the user can see, their own
+	// and those shared by others
+	passageRows, err := h.DB.Query(ctx, `
+		SELECT id, book_id, content
+		FROM (
+			SELECT a.id, a.book_id, a.content,
+			       ROW_NUMBER() OVER (PARTITION BY a.book_id ORDER BY a.created_at DESC) AS position
+			FROM annotations a
+			WHERE a.book_id = ANY($2::text[]) AND `+annotationVisibleSQL("a", "$1")+`
+		) recent
+		WHERE position <= $3
+	`, userID, ids, maxPassagesPerBook)
+	if err != nil {
+		return nil, err
+	}
+	defer passageRows.Close()
+
+	docs := []similarity.Document{}
+	for passageRows.Next() {
+		var id, bookID, content string
+		if err := passageRows.Scan(&id, &bookID, &content); err != nil {
+			continue
+		}
+		candidate := byID[bookID]
+		if candidate == nil {
+			continue
+		}
+		candidate.passages = append(candidate.passages, normalizeForMatch(content))
+		associator.bookOf[id] = candidate
+		docs = append(docs, similarity.Document{ID: id, Text: content})
+	}
+	associator.index = similarity.NewIndex(docs)
+	return associator, passageRows.Err()
+}
+
+// rank scores each candidate book for an annotation with the given text and page, captured
+// at the given time, best first. Books without any signal are left out.
+func (a *bookAssociator) rank(text string, page *int, at time.Time) []bookSuggestion {
+	normalized := normalizeForMatch(text)
+
+	// The best similarity of the text to each book's annotations
+	similar := map[*bookCandidate]float64{}
+	for _, match := range a.index.Similar(a.index.Vectorize(text), len(a.index.Documents), minSimilarPassage, "") {
+		if book := a.bookOf[match.Document.ID]; book != nil && match.Score > similar[book] {
+			similar[book] = match.Score
+		}
+	}
+
+	suggestions := []bookSuggestion{}
+	for _, book := range a.candidates {
+		score := 0.0
+		reasons := []string{}
+
+		if book.Status != nil && *book.Status == "reading" {
+			score += associationReadingWeight
+			reasons = append(reasons, "currently reading")
+		}
+		if weight := recencyWeight(book.LogUpdatedAt, at, progressHalfLife); weight > 0 {
+			score += associationProgressWeight * weight
+			reasons = append(reasons, "reading log updated "+timeAgo(*book.LogUpdatedAt, at))
+		}
+		if weight := recencyWeight(book.ViewedAt, at, viewHalfLife); weight > 0 {
+			score += associationViewWeight * weight
+			reasons = append(reasons, "viewed "+timeAgo(*book.ViewedAt, at))
+		}
+		if weight := recencyWeight(book.LastAnnotatedAt, at, activityHalfLife); weight > 0 {
+			score += associationActivityWeight * weight
+			reasons = append(reasons, "annotated "+timeAgo(*book.LastAnnotatedAt, at))
+		}
+
+		if title := mainTitle(book.Title); title != "" && containsPhrase(normalized, title) {
+			score += associationTitleWeight
+			reasons = append(reasons, "mentions the title")
+		}
+		for _, author := range book.Authors {
+			if surname := authorSurname(author); surname != "" && containsPhrase(normalized, surname) {
+				score += associationAuthorWeight
+				reasons = append(reasons, "mentions "+author)
+				break
+			}
+		}
+
+		if quotesPassage(normalized, book.passages) {
+			score += associationQuoteWeight
+			reasons = append(reasons, "quotes a passage highlighted in this book")
+		} else if best := similar[book]; best > 0 {
+			score += associationSimilarWeight * best
+			reasons = append(reasons, "similar to annotations on this book")
+		}
+
+		if page != nil && *page > 0 {
+			if book.PageCount != nil && *book.PageCount > 0 && *page > *book.PageCount {
+				score -= associationPastEndPenalty
+				reasons = append(reasons, fmt.Sprintf("page %d is past the end of this book (%d pages)", *page, *book.PageCount))
+			} else if book.LastPage != nil && abs(*page-*book.LastPage) <= associationNearbyPages {
+				score += associationNearbyWeight
+				reasons = append(reasons, fmt.Sprintf("near your last annotation, on page %d", *book.LastPage))
+			}
+		}
+
+		if score <= 0 {
+			continue
+		}
+		suggestions = append(suggestions, bookSuggestion{
+			Book:    book,
+			Score:   math.Round(math.Min(score, 1)*100) / 100,
+			Reasons: reasons,
+		})
+	}
+
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		return suggestions[i].Score > suggestions[j].Score
+	})
+	return suggestions
+}
+
+// confidentSuggestion returns the suggestion an annotation can be associated with without
+// asking, or nil. The only book being read still wins when nothing points elsewhere, as
+// before scoring existed.
+func confidentSuggestion(suggestions []bookSuggestion) *bookSuggestion {
+	if len(suggestions) == 0 {
+		return nil
+	}
+	best := &suggestions[0]
+	if len(suggestions) > 1 && best.Score-suggestions[1].Score < autoAssociateMargin {
+		return nil
+	}
+	if best.Score >= autoAssociateScore {
+		return best
+	}
+
+	reading := 0
+	for _, suggestion := range suggestions {
+		if suggestion.Book.Status != nil && *suggestion.Book.Status == "reading" {
+			reading++
+		}
+	}
+	if reading == 1 && best.Book.Status != nil && *best.Book.Status == "reading" {
+		return best
+	}
+	return nil
+}
+
+// bookSuggestionsJSON converts up to limit suggestions for a response
+func bookSuggestionsJSON(suggestions []bookSuggestion, limit int) []map[string]interface{} {
+	result := []map[string]interface{}{}
+	for i, suggestion := range suggestions {
+		if i == limit {
+			break
+		}
+		result = append(result, map[string]interface{}{
+			"book": map[string]interface{}{
+				"id":         suggestion.Book.ID,
+				"title":      suggestion.Book.Title,
+				"authors":    suggestion.Book.Authors,
+				"cover_url":  suggestion.Book.CoverURL,
+				"page_count": suggestion.Book.PageCount,
+			},
+			"score":   suggestion.Score,
+			"reasons": suggestion.Reasons,
+		})
+	}
+	return result
+}
+
+// recencyWeight is 1 for a signal at the time of the annotation, halving every halfLife
+// either side of it, and 0 for missing signals or ones outside the association window
+func recencyWeight(signal *time.Time, at time.Time, halfLife time.Duration) float64 {
+	if signal == nil {
+		return 0
+	}
+	age := at.Sub(*signal)
+	if age < 0 {
+		age = -age
+	}
+	if age > associationWindow {
+		return 0
+	}
+	return math.Pow(0.5, float64(age)/float64(halfLife))
+}
+
+// timeAgo describes roughly how far signal is from at, for suggestion reasons
+func timeAgo(signal, at time.Time) string {
+	age := at.Sub(signal)
+	if age < 0 {
+		return "afterwards"
+	}
+	switch {
+	case age < time.Hour:
+		return "just now"
+	case age < 24*time.Hour:
+		return "today"
+	case age < 48*time.Hour:
+		return "yesterday"
+	default:
+		return fmt.Sprintf("%d days ago", int(age.Hours()/24))
+	}
+}
+
+// normalizeForMatch lowercases text and reduces it to words separated by single spaces,
+// padded with a space on each side so phrases can be matched on word boundaries
+func normalizeForMatch(text string) string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return " " + strings.Join(words, " ") + " "
+}
+
+// containsPhrase reports whether normalized text contains phrase as whole words
+func containsPhrase(normalized, phrase string) bool {
+	return strings.Contains(normalized, normalizeForMatch(phrase))
+}
+
+// mainTitle is a title without its subtitle, or "" when it is too short to be told apart
+// from ordinary words
+func mainTitle(title string) string {
+	if i := strings.IndexAny(title, ":;("); i > 0 {
+		title = title[:i]
+	}
+	title = strings.TrimSpace(title)
+	if len([]rune(title)) < 4 {
+		return ""
+	}
+	return title
+}
+
+// authorSurname is the last word of an author's name, or "" when it is too short to match
+// reliably
+func authorSurname(author string) string {
+	words := strings.Fields(author)
+	if len(words) == 0 {
+		return ""
+	}
+	surname := words[len(words)-1]
+	if len([]rune(surname)) < 4 {
+		return ""
+	}
+	return surname
+}
+
+// quotesPassage reports whether normalized text and one of the passages contain each
+// other, e.g. a highlight captured again or a note that quotes one
+func quotesPassage(normalized string, passages []string) bool {
+	if len(normalized) < minQuoteLength {
+		return false
+	}
+	for _, passage := range passages {
+		if len(passage) < minQuoteLength {
+			continue
+		}
+		if strings.Contains(passage, normalized) || strings.Contains(normalized, passage) {
+			return true
+		}
+	}
+	return false
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// unassociatedAnnotation is an annotation without a book, as ranked by the associator
+type unassociatedAnnotation struct {
+	ID         string
+	Content    string
+	Context    *string
+	PageNumber *int
+	ParentID   *string
+	CreatedAt  time.Time
+}
+
+// matchText is the text an unassociated annotation is matched on
+func (a unassociatedAnnotation) matchText() string {
+	if a.Context != nil {
+		return a.Content + "\n" + *a.Context
+	}
+	return a.Content
+}
+
+// loadUnassociated returns the user's unassociated annotations, limited to ids when given
+func (h *AnnotationHandler) loadUnassociated(ctx context.Context, userID string, ids []string) ([]unassociatedAnnotation, error) {
+	qb := newQueryBuilder(userID)
+	if len(ids) > 0 {
+		qb.where("id = ANY(" + qb.arg(ids) + "::uuid[])")
+	}
+	rows, err := h.DB.Query(ctx, `
+		SELECT id, content, context, page_number, parent_id, created_at
+		FROM annotations
+		WHERE user_id = $1 AND is_associated = false`+qb.and()+`
+		ORDER BY created_at DESC
+		LIMIT `+qb.arg(maxBulkAssignments), qb.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	annotations := []unassociatedAnnotation{}
+	for rows.Next() {
+		var annotation unassociatedAnnotation
+		err := rows.Scan(&annotation.ID, &annotation.Content, &annotation.Context, &annotation.PageNumber, &annotation.ParentID, &annotation.CreatedAt)
+		if err != nil {
+			continue
+		}
+		annotations = append(annotations, annotation)
+	}
+	return annotations, rows.Err()
+}
+
+// bookAssociators holds an associator for each associationWindow-long span of capture
+// times, keyed by the span's start, so that each annotation is ranked against the books
+// the user had around when it was made rather than only the most recent ones
+type bookAssociators map[int64]*bookAssociator
+
+// associationSpan is the start of the span of capture times that at falls in
+func associationSpan(at time.Time) time.Time {
+	return at.Truncate(associationWindow)
+}
+
+// rank scores the candidate books for an annotation captured at the given time
+func (a bookAssociators) rank(text string, page *int, at time.Time) []bookSuggestion {
+	associator := a[associationSpan(at).Unix()]
+	if associator == nil {
+		return []bookSuggestion{}
+	}
+	return associator.rank(text, page, at)
+}
+
+// associatorFor loads an associator for each span of capture times in annotations
+func (h *AnnotationHandler) associatorFor(ctx context.Context, userID string, annotations []unassociatedAnnotation) (bookAssociators, error) {
+	associators := bookAssociators{}
+	for _, annotation := range annotations {
+		from := associationSpan(annotation.CreatedAt)
+		if associators[from.Unix()] != nil {
+			continue
+		}
+		associator, err := h.loadBookAssociator(ctx, userID, from, from.Add(associationWindow))
+		if err != nil {
+			return nil, err
+		}
+		associators[from.Unix()] = associator
+	}
+	return associators, nil
+}
+
+// GetAnnotationBookSuggestions ranks the books an annotation may belong to
+func (h *AnnotationHandler) GetAnnotationBookSuggestions(c echo.Context) error {
+	userID := auth.GetUserID(c)
+	if userID == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": "unauthorized",
+		})
+	}
+
+	annotationID := c.Param("id")
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	var annotation unassociatedAnnotation
+	var bookID *string
+	err := h.DB.QueryRow(ctx, `
+		SELECT id, content, context, page_number, parent_id, created_at, book_id
+		FROM annotations
+		WHERE id = $1 AND user_id = $2
+	`, annotationID, userID).Scan(
+		&annotation.ID, &annotation.Content, &annotation.Context, &annotation.PageNumber,
+		&annotation.ParentID, &annotation.CreatedAt, &bookID,
+	)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": "annotation not found",
+		})
+	}
+
+	associator, err := h.associatorFor(ctx, userID, []unassociatedAnnotation{annotation})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to suggest books",
+		})
+	}
+	suggestions := associator.rank(annotation.matchText(), annotation.PageNumber, annotation.CreatedAt)
+
+	var suggestedBookID *string
+	if best := confidentSuggestion(suggestions); best != nil {
+		suggestedBookID = &best.Book.ID
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"annotation_id":     annotationID,
+		"book_id":           bookID,
+		"suggested_book_id": suggestedBookID,
+		"suggestions":       bookSuggestionsJSON(suggestions, maxBookSuggestions),
+	})
+}
+
+// AssignAnnotationsRequest assigns unassociated annotations to books, either all to
+// BookID or, with Auto, each to its confident suggestion
+type AssignAnnotationsRequest struct {
+	AnnotationIDs []string `json:"annotation_ids"`
+	BookID        *string  `json:"book_id"`
+	Auto          bool     `json:"auto"`
+}
+
+// AssignAnnotations associates unassociated annotations with books in bulk. With book_id the
+// listed annotations move to that book; with auto the listed annotations, or all
+// unassociated ones, move to their best book when it is a confident match, and the rest are
+// returned with their suggestions. Notes attached to an assigned highlight follow it, and
+// notes whose highlight isn't assigned along with them are skipped, so a note never ends up
+// on a different book from its highlight.
+// ?dry_run=true reports what would be assigned without changing anything.
+func (h *AnnotationHandler) AssignAnnotations(c echo.Context) error {
+	userID := auth.GetUserID(c)
+	if userID == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": "unauthorized",
+		})
+	}
+
+	var req AssignAnnotationsRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "invalid request body",
+		})
+	}
+	hasBook := req.BookID != nil && *req.BookID != ""
+	if hasBook == req.Auto {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "either book_id or auto is required",
+		})
+	}
+	if hasBook && len(req.AnnotationIDs) == 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "annotation_ids is required",
+		})
+	}
+	if len(req.AnnotationIDs) > maxBulkAssignments {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": fmt.Sprintf("at most %d annotations can be assigned at once", maxBulkAssignments),
+		})
+	}
+
+	dryRun, err := parseOptionalBool(c, "dry_run")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 30*time.Second)
+	defer cancel()
+
+	if hasBook {
+		var exists bool
+		err := h.DB.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM books WHERE id = $1)", *req.BookID).Scan(&exists)
+		if err != nil || !exists {
+			return c.JSON(http.StatusNotFound, map[string]string{
+				"error": "book not found",
+			})
+		}
+	}
+
+	annotations, err := h.loadUnassociated(ctx, userID, req.AnnotationIDs)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to load annotations",
+		})
+	}
+
+	// Work out the book of each annotation
+	assignments := []map[string]interface{}{}
+	unresolved := []map[string]interface{}{}
+	skipped := []string{}
+	byBook := map[string][]string{}
+	// Notes on a highlight follow the highlight rather than being assigned alone
+	highlights := []unassociatedAnnotation{}
+	notes := []unassociatedAnnotation{}
+	for _, annotation := range annotations {
+		if annotation.ParentID != nil {
+			notes = append(notes, annotation)
+		} else {
+			highlights = append(highlights, annotation)
+		}
+	}
+	if hasBook {
+		for _, annotation := range highlights {
+			byBook[*req.BookID] = append(byBook[*req.BookID], annotation.ID)
+			assignments = append(assignments, map[string]interface{}{
+				"annotation_id": annotation.ID,
+				"book_id":       *req.BookID,
+			})
+		}
+	} else {
+		associator, err := h.associatorFor(ctx, userID, annotations)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": "failed to suggest books",
+			})
+		}
+		for _, annotation := range highlights {
+			suggestions := associator.rank(annotation.matchText(), annotation.PageNumber, annotation.CreatedAt)
+			best := confidentSuggestion(suggestions)
+			if best == nil {
+				unresolved = append(unresolved, map[string]interface{}{
+					"annotation_id": annotation.ID,
+					"suggestions":   bookSuggestionsJSON(suggestions, 3),
+				})
+				continue
+			}
+			byBook[best.Book.ID] = append(byBook[best.Book.ID], annotation.ID)
+			assignments = append(assignments, map[string]interface{}{
+				"annotation_id": annotation.ID,
+				"book_id":       best.Book.ID,
+				"title":         best.Book.Title,
+				"score":         best.Score,
+				"reasons":       best.Reasons,
+			})
+		}
+	}
+
+	// Notes whose highlight isn't being assigned here stay where they are
+	assigned := map[string]bool{}
+	for _, ids := range byBook {
+		for _, id := range ids {
+			assigned[id] = true
+		}
+	}
+	for _, note := range notes {
+		if !assigned[*note.ParentID] {
+			skipped = append(skipped, note.ID)
+		}
+	}
+
+	// Listed annotations that don't exist, aren't the user's or already have a book
+	found := map[string]bool{}
+	for _, annotation := range annotations {
+		found[annotation.ID] = true
+	}
+	for _, id := range req.AnnotationIDs {
+		if !found[id] {
+			skipped = append(skipped, id)
+		}
+	}
+
+	response := map[string]interface{}{
+		"assignments": assignments,
+		"assigned":    len(assignments),
+		"unresolved":  unresolved,
+		"skipped":     skipped,
+		"dry_run":     dryRun != nil && *dryRun,
+	}
+	if dryRun != nil && *dryRun {
+		return c.JSON(http.StatusOK, response)
+	}
+
+	tx, err := h.DB.Begin(ctx)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to assign annotations",
+		})
+	}
+	defer tx.Rollback(ctx)
+
+	attachedNotes := 0
+	for bookID, ids := range byBook {
+		_, err := tx.Exec(ctx, `
+			UPDATE annotations
+			SET book_id = $1, is_associated = true, updated_at = NOW()
+			WHERE user_id = $2 AND id = ANY($3::uuid[]) AND is_associated = false
+		`, bookID, userID, ids)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": "failed to assign annotations",
+			})
+		}
+
+		tag, err := tx.Exec(ctx, `
+			UPDATE annotations
+			SET book_id = $1, is_associated = true, updated_at = NOW()
+			WHERE user_id = $2 AND parent_id = ANY($3::uuid[]) AND is_associated = false
+		`, bookID, userID, ids)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": "failed to assign annotations",
+			})
+		}
+		attachedNotes += int(tag.RowsAffected())
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to assign annotations",
+		})
+	}
+
+	response["attached_notes"] = attachedNotes
+	return c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"folio/api/similarity"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestRecencyWeight(t *testing.T) {
+	at := time.Date(2024, time.June, 15, 12, 0, 0, 0, time.UTC)
+	timePtr := func(d time.Duration) *time.Time {
+		signal := at.Add(d)
+		return &signal
+	}
+	day := 24 * time.Hour
+
+	tests := []struct {
+		name   string
+		signal *time.Time
+		want   float64
+	}{
+		{"missing", nil, 0},
+		{"same time", timePtr(0), 1},
+		{"one half-life before", timePtr(-7 * day), 0.5},
+		{"one half-life after", timePtr(7 * day), 0.5},
+		{"two half-lives before", timePtr(-14 * day), 0.25},
+		{"at the edge of the window", timePtr(-30 * day), math.Pow(0.5, 30.0/7)},
+		{"past the window", timePtr(-30*day - time.Hour), 0},
+		{"past the window after", timePtr(31 * day), 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := recencyWeight(tt.signal, at, progressHalfLife); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("recencyWeight() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRankWeights(t *testing.T) {
+	at := time.Date(2024, time.June, 15, 12, 0, 0, 0, time.UTC)
+	intPtr := func(n int) *int { return &n }
+	strPtr := func(s string) *string { return &s }
+	reading := strPtr("reading")
+	passage := "It is a truth universally acknowledged that a single man must be in want of a wife"
+
+	tests := []struct {
+		name string
+		book bookCandidate
+		text string
+		page *int
+		want float64
+	}{
+		{"no signal is left out", bookCandidate{}, "unrelated words", nil, 0},
+		{"currently reading", bookCandidate{Status: reading}, "unrelated words", nil, associationReadingWeight},
+		{"log updated", bookCandidate{LogUpdatedAt: &at}, "unrelated words", nil, associationProgressWeight},
+		{"viewed", bookCandidate{ViewedAt: &at}, "unrelated words", nil, associationViewWeight},
+		{"annotated", bookCandidate{LastAnnotatedAt: &at}, "unrelated words", nil, associationActivityWeight},
+		{"mentions the title", bookCandidate{Title: "Middlemarch: A Study of Provincial Life"}, "back to middlemarch tonight", nil, associationTitleWeight},
+		{"mentions the author", bookCandidate{Authors: []string{"George Eliot"}}, "eliot at her best", nil, associationAuthorWeight},
+		{"quotes a passage", bookCandidate{passages: []string{normalizeForMatch(passage)}}, passage, nil, associationQuoteWeight},
+		{"near the last page", bookCandidate{Status: reading, LastPage: intPtr(100)}, "unrelated words", intPtr(120), associationReadingWeight + associationNearbyWeight},
+		{"far from the last page", bookCandidate{Status: reading, LastPage: intPtr(100)}, "unrelated words", intPtr(200), associationReadingWeight},
+		{"past the end", bookCandidate{Status: reading, PageCount: intPtr(200)}, "unrelated words", intPtr(250), 0},
+		{"capped at one", bookCandidate{Status: reading, Title: "Pride and Prejudice", passages: []string{normalizeForMatch(passage)}}, "pride and prejudice: " + passage, nil, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			book := tt.book
+			associator := &bookAssociator{
+				candidates: []*bookCandidate{&book},
+				index:      similarity.NewIndex(nil),
+				bookOf:     map[string]*bookCandidate{},
+			}
+			suggestions := associator.rank(tt.text, tt.page, at)
+			got := 0.0
+			if len(suggestions) > 0 {
+				got = suggestions[0].Score
+			}
+			if math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("score = %v, want %v (reasons %v)", got, tt.want, suggestions)
+			}
+		})
+	}
+}
+
+func TestConfidentSuggestion(t *testing.T) {
+	reading := "reading"
+	suggestion := func(score float64, isReading bool) bookSuggestion {
+		book := &bookCandidate{}
+		if isReading {
+			book.Status = &reading
+		}
+		return bookSuggestion{Book: book, Score: score}
+	}
+
+	tests := []struct {
+		name        string
+		suggestions []bookSuggestion
+		want        bool
+	}{
+		{"none", nil, false},
+		{"single confident", []bookSuggestion{suggestion(autoAssociateScore, false)}, true},
+		{"single below the score", []bookSuggestion{suggestion(0.4, false)}, false},
+		{"leads by the margin", []bookSuggestion{suggestion(0.7, false), suggestion(0.5, false)}, true},
+		{"within the margin", []bookSuggestion{suggestion(0.7, false), suggestion(0.6, false)}, false},
+		{"only book being read", []bookSuggestion{suggestion(0.3, true), suggestion(0.1, false)}, true},
+		{"two books being read", []bookSuggestion{suggestion(0.3, true), suggestion(0.1, true)}, false},
+		{"only book being read within the margin", []bookSuggestion{suggestion(0.3, true), suggestion(0.2, false)}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := confidentSuggestion(tt.suggestions)
+			if (got != nil) != tt.want {
+				t.Fatalf("confidentSuggestion() = %v, want a suggestion: %v", got, tt.want)
+			}
+			if got != nil && got != &tt.suggestions[0] {
+				t.Errorf("confidentSuggestion() = %v, want the best suggestion", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"context"
+	"folio/api/auth"
+	"folio/api/exporters"
+	"mime"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// unassociatedExportTitle heads the annotations that aren't linked to a book
+const unassociatedExportTitle = "Unassociated annotations"
+
+// ExportAnnotations downloads the current user's annotations grouped by book.
+// format is markdown (default), obsidian, json or csv. delivery=file returns one
+// file and delivery=zip one file per book; obsidian defaults to zip and the others
+// to file. book_id and tag narrow the export; tag includes the tags nested under
+// it. A single-file export of one book is that book's note, front matter included.
+func (h *AnnotationHandler) ExportAnnotations(c echo.Context) error {
+	userID := auth.GetUserID(c)
+	if userID == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": "unauthorized",
+		})
+	}
+
+	formatName := c.QueryParam("format")
+	if formatName == "" {
+		formatName = "markdown"
+	}
+	format, err := exporters.Lookup(formatName)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	zipped := format.Zipped
+	switch c.QueryParam("delivery") {
+	case "":
+	case "file":
+		zipped = false
+	case "zip":
+		zipped = true
+	default:
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "delivery must be file or zip",
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 30*time.Second)
+	defer cancel()
+
+	qb := newQueryBuilder(userID)
+	if bookID := c.QueryParam("book_id"); bookID != "" {
+		qb.where("a.book_id = " + qb.arg(bookID))
+	}
+	if tag := c.QueryParam("tag"); tag != "" {
+		qb.where(tagMatchSQL("a", "$1", qb.arg(tag)))
+	}
+
+	// Unassociated annotations sort last, after every book. Within a book they're put in
+	// reading order below, the same way the annotations view orders them.
+	query := `
+		SELECT a.id, a.parent_id, a.type, a.content, a.context, a.page_number, a.chapter, a.location_percent::float8,
+		       a.location_start, a.location_end, a.tags, a.created_at, a.cfi, a.start_offset,
+		       a.book_id, b.title, b.authors, COALESCE(b.isbn_13, b.isbn_10), b.page_count
+		FROM annotations a
+		LEFT JOIN books b ON a.book_id = b.id
+		WHERE a.user_id = $1` + qb.and() + `
+		ORDER BY b.title IS NULL, lower(b.title), a.book_id, a.created_at ASC
+	`
+
+	rows, err := h.DB.Query(ctx, query, qb.args...)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to fetch annotations",
+		})
+	}
+	defer rows.Close()
+
+	books := []exporters.Book{}
+	bookIndex := map[string]int{}
+	positions := map[string]annotationPosition{}
+	for rows.Next() {
+		var annotation exporters.Annotation
+		var cfi, bookID, bookTitle, isbn *string
+		var startOffset, pageCount *int
+		var bookAuthors []string
+
+		err := rows.Scan(
+			&annotation.ID, &annotation.ParentID, &annotation.Type, &annotation.Content,
+			&annotation.Context, &annotation.Page, &annotation.Chapter, &annotation.Percent, &annotation.LocationStart, &annotation.LocationEnd,
+			&annotation.Tags, &annotation.CreatedAt, &cfi, &startOffset,
+			&bookID, &bookTitle, &bookAuthors, &isbn, &pageCount,
+		)
+		if err != nil {
+			continue
+		}
+
+		position := annotationPosition{
+			Percent:       annotation.Percent,
+			Page:          annotation.Page,
+			LocationStart: annotation.LocationStart,
+			StartOffset:   startOffset,
+			CreatedAt:     annotation.CreatedAt,
+			PageCount:     pageCount,
+		}
+		if cfi != nil {
+			position.CFI = cfiSteps(*cfi)
+		}
+		positions[annotation.ID] = position
+
+		key := ""
+		if bookID != nil && bookTitle != nil {
+			key = *bookID
+		}
+		i, ok := bookIndex[key]
+		if !ok {
+			book := exporters.Book{Title: unassociatedExportTitle}
+			if key != "" {
+				book = exporters.Book{ID: key, Title: *bookTitle, Authors: bookAuthors}
+				if isbn != nil {
+					book.ISBN = *isbn
+				}
+			}
+			i = len(books)
+			bookIndex[key] = i
+			books = append(books, book)
+		}
+		books[i].Annotations = append(books[i].Annotations, annotation)
+	}
+
+	for i := range books {
+		annotations := books[i].Annotations
+		sort.SliceStable(annotations, func(j, k int) bool {
+			return compareAnnotationPositions(positions[annotations[j].ID], positions[annotations[k].ID]) < 0
+		})
+		books[i].Annotations = exporters.Thread(annotations)
+	}
+
+	exportedAt := time.Now()
+	fileName := "folio-annotations-" + exportedAt.Format("2006-01-02")
+	if c.QueryParam("book_id") != "" && len(books) == 1 {
+		fileName = exporters.FileName(books[0].Title)
+	}
+
+	var data []byte
+	contentType := format.ContentType
+	switch {
+	case zipped:
+		data, err = exporters.Zip(format, books, exportedAt)
+		contentType, fileName = "application/zip", fileName+".zip"
+	case len(books) == 1:
+		data, err = format.Document(books[0], exportedAt)
+		fileName += "." + format.Extension
+	default:
+		data, err = format.Combined(books, exportedAt)
+		fileName += "." + format.Extension
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to export annotations",
+		})
+	}
+
+	c.Response().Header().Set(echo.HeaderContentDisposition, mime.FormatMediaType("attachment", map[string]string{"filename": fileName}))
+	return c.Blob(http.StatusOK, contentType, data)
+}
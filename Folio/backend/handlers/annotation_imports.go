@@ -0,0 +1,654 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"folio/api/auth"
+	"folio/api/importers"
+	"folio/api/langdetect"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+)
+
+// maxImportFileSize bounds each uploaded export file
+const maxImportFileSize = 50 << 20
+
+// maxImportPreview bounds how many annotations a dry run lists
+const maxImportPreview = 500
+
+// errImportTooLarge is returned when an uploaded export exceeds maxImportFileSize
+var errImportTooLarge = fmt.Errorf("file must be at most %d MB", maxImportFileSize>>20)
+
+// readUploadedFile reads one multipart file field, or nil when the field is absent
+func readUploadedFile(c echo.Context, field string) ([]byte, string, error) {
+	header, err := c.FormFile(field)
+	if err != nil {
+		return nil, "", nil
+	}
+	if header.Size > maxImportFileSize {
+		return nil, "", errImportTooLarge
+	}
+	file, err := header.Open()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read %s", field)
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxImportFileSize+1))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read %s", field)
+	}
+	if len(data) > maxImportFileSize {
+		return nil, "", errImportTooLarge
+	}
+	return data, header.Filename, nil
+}
+
+// readImportFiles returns the uploaded export, sent either as the "file" field of a
+// multipart form, with an optional "library" companion file, or as the raw request body
+func readImportFiles(c echo.Context) (importers.Files, string, error) {
+	files := importers.Files{}
+	fileName := ""
+
+	if strings.HasPrefix(c.Request().Header.Get(echo.HeaderContentType), echo.MIMEMultipartForm) {
+		data, name, err := readUploadedFile(c, "file")
+		if err != nil {
+			return nil, "", err
+		}
+		library, _, err := readUploadedFile(c, "library")
+		if err != nil {
+			return nil, "", err
+		}
+		files["file"], files["library"], fileName = data, library, name
+	} else {
+		data, err := io.ReadAll(io.LimitReader(c.Request().Body, maxImportFileSize+1))
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to read file")
+		}
+		if len(data) > maxImportFileSize {
+			return nil, "", errImportTooLarge
+		}
+		files["file"] = data
+	}
+
+	if len(strings.TrimSpace(string(files["file"]))) == 0 {
+		return nil, "", fmt.Errorf("file is required")
+	}
+	return files, fileName, nil
+}
+
+// importFileHash identifies an upload, so importing the same export twice is recognized
+func importFileHash(files importers.Files) string {
+	hash := sha256.New()
+	hash.Write(files["file"])
+	hash.Write([]byte{0})
+	hash.Write(files["library"])
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// clippingFingerprint identifies a clipping independently of which book it was matched to,
+// so re-importing overlapping exports doesn't duplicate it. The source app's own id is used
+// when there is one.
+func clippingFingerprint(format string, clipping importers.Clipping) string {
+	parts := []string{format, clipping.ExternalID, string(clipping.Kind)}
+	if clipping.ExternalID == "" {
+		location := ""
+		if clipping.LocationStart != nil {
+			location = fmt.Sprintf("%d-%d", *clipping.LocationStart, *clipping.LocationEnd)
+		} else if clipping.Page != nil {
+			location = fmt.Sprintf("p%d", *clipping.Page)
+		}
+		// Kindle fingerprints have no format prefix so they match annotations imported before
+		// other formats were supported; every other format is prefixed
+		parts = []string{string(clipping.Kind), strings.ToLower(clipping.Title), location, clipping.Content}
+		if format != "kindle" {
+			parts = append([]string{format}, parts...)
+		}
+	}
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
+
+// dedupeClippings drops exact repeats and highlights that were later extended. Kindle
+// appends a new entry when a highlight is changed, so when two highlights of the same
+// book overlap and one contains the other's text, only the longer one is kept.
+func dedupeClippings(format string, clippings []importers.Clipping) (kept []importers.Clipping, duplicates int) {
+	byTitle := map[string][]int{}
+	for i, clipping := range clippings {
+		if clipping.Kind == importers.KindHighlight && clipping.LocationStart != nil {
+			byTitle[clipping.Title] = append(byTitle[clipping.Title], i)
+		}
+	}
+
+	seen := map[string]bool{}
+	for i, clipping := range clippings {
+		fingerprint := clippingFingerprint(format, clipping)
+		if seen[fingerprint] {
+			duplicates++
+			continue
+		}
+
+		superseded := false
+		if clipping.Kind == importers.KindHighlight && clipping.LocationStart != nil {
+			for _, j := range byTitle[clipping.Title] {
+				other := clippings[j]
+				if i == j {
+					continue
+				}
+				overlaps := *other.LocationStart <= *clipping.LocationEnd && *clipping.LocationStart <= *other.LocationEnd
+				longer := len(other.Content) > len(clipping.Content) || (len(other.Content) == len(clipping.Content) && j > i)
+				if overlaps && longer && strings.Contains(other.Content, clipping.Content) {
+					superseded = true
+					break
+				}
+			}
+		}
+		if superseded {
+			duplicates++
+			continue
+		}
+
+		seen[fingerprint] = true
+		kept = append(kept, clipping)
+	}
+	return kept, duplicates
+}
+
+// shortTitle drops a subtitle or series suffix, e.g. "Dune: Deluxe Edition" or
+// "Dune (Dune Chronicles, Book 1)" both become "Dune"
+func shortTitle(title string) string {
+	if i := strings.IndexAny(title, ":("); i > 0 {
+		return strings.TrimSpace(title[:i])
+	}
+	return title
+}
+
+// matchImportedBook finds the books row for a clipping's title and authors, preferring
+// books the user has logged. Title matches whose authors clearly differ are rejected.
+func (h *AnnotationHandler) matchImportedBook(ctx context.Context, userID, title string, authors []string) *string {
+	lastNames := []string{}
+	for _, author := range authors {
+		if fields := strings.Fields(strings.ToLower(author)); len(fields) > 0 {
+			lastNames = append(lastNames, fields[len(fields)-1])
+		}
+	}
+
+	var bookID string
+	err := h.DB.QueryRow(ctx, `
+		SELECT b.id
+		FROM books b
+		WHERE (lower(b.title) = lower($2) OR lower(b.title) = lower($3) OR lower(b.title) LIKE lower($3) || ':%')
+		AND (
+			cardinality($4::text[]) = 0
+			OR COALESCE(cardinality(b.authors), 0) = 0
+			OR EXISTS(SELECT 1 FROM unnest(b.authors) a, unnest($4::text[]) n WHERE lower(a) LIKE '%' || n || '%')
+		)
+		ORDER BY EXISTS(SELECT 1 FROM logs l WHERE l.book_id = b.id AND l.user_id = $1) DESC,
+		         (lower(b.title) = lower($2)) DESC,
+		         COALESCE(cardinality(b.authors), 0) > 0 DESC
+		LIMIT 1
+	`, userID, title, shortTitle(title), lastNames).Scan(&bookID)
+	if err != nil {
+		return nil
+	}
+	return &bookID
+}
+
+// plannedAnnotation is one annotation an import will create, or found already exists
+type plannedAnnotation struct {
+	Clipping    importers.Clipping
+	BookID      *string
+	Fingerprint string
+	Parent      int    // index in the plan of the highlight a note belongs to, or -1
+	ExistingID  string // set when the annotation was imported or captured before
+	ID          string // the created or existing annotation
+}
+
+// importPlan is what an import will do, worked out before anything is written
+type importPlan struct {
+	Annotations []plannedAnnotation
+	Stats       map[string]interface{}
+}
+
+// findHighlightForNote returns the index in plan of the highlight a Kindle note belongs to:
+// Kindle places a note at the location where its highlight ends
+func findHighlightForNote(note importers.Clipping, plan []plannedAnnotation) int {
+	if note.LocationStart == nil {
+		return -1
+	}
+	best, bestDistance := -1, 0
+	for i, planned := range plan {
+		highlight := planned.Clipping
+		if highlight.Kind != importers.KindHighlight || highlight.Title != note.Title || highlight.LocationStart == nil {
+			continue
+		}
+		if *note.LocationStart < *highlight.LocationStart || *note.LocationStart > *highlight.LocationEnd {
+			continue
+		}
+		distance := *highlight.LocationEnd - *note.LocationStart
+		if best < 0 || distance < bestDistance {
+			best, bestDistance = i, distance
+		}
+	}
+	return best
+}
+
+// planImport matches clippings to books, links notes to their highlights and finds the
+// ones that already exist. Highlights come before notes in the plan so notes can point at them.
+func (h *AnnotationHandler) planImport(ctx context.Context, q rowQuerier, userID string, format importers.Format, clippings []importers.Clipping, unparsed int) (*importPlan, error) {
+	clippings, fileDuplicates := dedupeClippings(format.Name, clippings)
+
+	plan := []plannedAnnotation{}
+	notes := []importers.Clipping{}
+	bookmarks := 0
+	for _, clipping := range clippings {
+		switch clipping.Kind {
+		case importers.KindHighlight:
+			plan = append(plan, plannedAnnotation{Clipping: clipping, Parent: -1})
+		case importers.KindNote:
+			notes = append(notes, clipping)
+		default:
+			bookmarks++
+		}
+	}
+
+	// Notes stored on their highlight become child notes of it
+	highlights := len(plan)
+	for i := 0; i < highlights; i++ {
+		highlight := plan[i].Clipping
+		if highlight.Note == "" {
+			continue
+		}
+		note := highlight
+		note.Kind, note.Content, note.Note, note.Tags = importers.KindNote, highlight.Note, "", nil
+		if note.ExternalID != "" {
+			note.ExternalID += "#note"
+		}
+		plan = append(plan, plannedAnnotation{Clipping: note, Parent: i})
+	}
+	for _, note := range notes {
+		parent := -1
+		if format.NotesByLocation {
+			parent = findHighlightForNote(note, plan[:highlights])
+		}
+		plan = append(plan, plannedAnnotation{Clipping: note, Parent: parent})
+	}
+
+	// Match every distinct title once
+	bookIDs := map[string]*string{}
+	unmatched := []string{}
+	existing, attached := 0, 0
+	for i := range plan {
+		planned := &plan[i]
+		title := planned.Clipping.Title
+		if _, ok := bookIDs[title]; !ok {
+			bookIDs[title] = h.matchImportedBook(ctx, userID, title, planned.Clipping.Authors)
+			if bookIDs[title] == nil {
+				unmatched = append(unmatched, title)
+			}
+		}
+		planned.BookID = bookIDs[title]
+		planned.Fingerprint = clippingFingerprint(format.Name, planned.Clipping)
+		if planned.Parent >= 0 {
+			attached++
+		}
+
+		// Already imported, or already captured by hand with the same text
+		err := q.QueryRow(ctx, `
+			SELECT id FROM annotations
+			WHERE user_id = $1
+			AND (import_fingerprint = $2 OR (book_id IS NOT DISTINCT FROM $3 AND type = $4 AND content = $5))
+			LIMIT 1
+		`, userID, planned.Fingerprint, planned.BookID, string(planned.Clipping.Kind), planned.Clipping.Content).Scan(&planned.ExistingID)
+		if err == nil {
+			planned.ID = planned.ExistingID
+			existing++
+		} else if err != pgx.ErrNoRows {
+			return nil, err
+		}
+	}
+
+	return &importPlan{
+		Annotations: plan,
+		Stats: map[string]interface{}{
+			"imported":          len(plan) - existing,
+			"highlights":        highlights,
+			"notes":             len(plan) - highlights,
+			"notes_attached":    attached,
+			"bookmarks_skipped": bookmarks,
+			"duplicates":        fileDuplicates + existing,
+			"unparsed":          unparsed,
+			"books_matched":     len(bookIDs) - len(unmatched),
+			"unmatched_titles":  unmatched,
+		},
+	}, nil
+}
+
+// ImportAnnotations imports highlights and notes from a reading app's export. :format is
+// kindle, readwise, apple-books or kobo. Each book title is matched to a books row;
+// clippings for unknown books are imported unassociated so they show up for manual
+// assignment. With dry_run=true nothing is written and the planned annotations are
+// returned instead. Uploading the same file again returns the earlier import.
+func (h *AnnotationHandler) ImportAnnotations(c echo.Context) error {
+	userID := auth.GetUserID(c)
+	if userID == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": "unauthorized",
+		})
+	}
+
+	format, err := importers.Lookup(c.Param("format"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	dryRun, err := parseOptionalBool(c, "dry_run")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	files, fileName, err := readImportFiles(c)
+	if err == errImportTooLarge {
+		return c.JSON(http.StatusRequestEntityTooLarge, map[string]string{
+			"error": err.Error(),
+		})
+	}
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	clippings, unparsed, err := format.Parse(files)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+	if len(clippings) == 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "no annotations found in the " + format.Name + " export",
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 60*time.Second)
+	defer cancel()
+
+	fileHash := importFileHash(files)
+
+	if dryRun == nil || !*dryRun {
+		if job, err := h.getImportJob(ctx, "i.user_id = $1 AND i.format = $2 AND i.file_hash = $3", userID, format.Name, fileHash); err == nil {
+			job["already_imported"] = true
+			return c.JSON(http.StatusOK, job)
+		}
+	}
+
+	if dryRun != nil && *dryRun {
+		plan, err := h.planImport(ctx, h.DB, userID, format, clippings, unparsed)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": "failed to plan import",
+			})
+		}
+
+		preview := []map[string]interface{}{}
+		for i, planned := range plan.Annotations {
+			if i == maxImportPreview {
+				break
+			}
+			var parent *int
+			if planned.Parent >= 0 {
+				parent = &plan.Annotations[i].Parent
+			}
+			preview = append(preview, map[string]interface{}{
+				"type":           planned.Clipping.Kind,
+				"content":        planned.Clipping.Content,
+				"book_title":     planned.Clipping.Title,
+				"authors":        planned.Clipping.Authors,
+				"book_id":        planned.BookID,
+				"page_number":    planned.Clipping.Page,
+				"location_start": planned.Clipping.LocationStart,
+				"location_end":   planned.Clipping.LocationEnd,
+				"cfi":            planned.Clipping.CFI,
+				"tags":           planned.Clipping.Tags,
+				"added_at":       planned.Clipping.AddedAt,
+				"parent_index":   parent,
+				"is_duplicate":   planned.ExistingID != "",
+			})
+		}
+
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"dry_run":           true,
+			"format":            format.Name,
+			"stats":             plan.Stats,
+			"annotations":       preview,
+			"preview_truncated": len(plan.Annotations) > maxImportPreview,
+		})
+	}
+
+	tx, err := h.DB.Begin(ctx)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to start transaction",
+		})
+	}
+	defer tx.Rollback(ctx)
+
+	plan, err := h.planImport(ctx, tx, userID, format, clippings, unparsed)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to plan import",
+		})
+	}
+
+	// A concurrent upload of the same file loses the race here and reports the other import
+	stats, _ := json.Marshal(plan.Stats)
+	var importID string
+	err = tx.QueryRow(ctx, `
+		INSERT INTO annotation_imports (user_id, format, file_name, file_hash, stats, created_at)
+		VALUES ($1, $2, NULLIF($3, ''), $4, $5, NOW())
+		ON CONFLICT (user_id, format, file_hash) DO NOTHING
+		RETURNING id
+	`, userID, format.Name, fileName, fileHash, stats).Scan(&importID)
+	if err == pgx.ErrNoRows {
+		tx.Rollback(ctx)
+		if job, err := h.getImportJob(ctx, "i.user_id = $1 AND i.format = $2 AND i.file_hash = $3", userID, format.Name, fileHash); err == nil {
+			job["already_imported"] = true
+			return c.JSON(http.StatusOK, job)
+		}
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to record import",
+		})
+	}
+
+	for i := range plan.Annotations {
+		planned := &plan.Annotations[i]
+		if planned.ExistingID != "" {
+			continue
+		}
+		var parentID *string
+		if planned.Parent >= 0 {
+			parentID = &plan.Annotations[planned.Parent].ID
+		}
+		clipping := planned.Clipping
+		tags := clipping.Tags
+		if tags == nil {
+			tags = []string{}
+		}
+
+		err := tx.QueryRow(ctx, `
+			INSERT INTO annotations (user_id, book_id, type, content, page_number, location_start, location_end, cfi, tags,
+			                         parent_id, source, is_associated, import_fingerprint, import_id, language, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, ''), $9, $10, 'import', $11, $12, $13, NULLIF($14, ''), COALESCE($15, NOW()), NOW())
+			RETURNING id
+		`, userID, planned.BookID, string(clipping.Kind), clipping.Content, clipping.Page, clipping.LocationStart,
+			clipping.LocationEnd, clipping.CFI, tags, parentID, planned.BookID != nil, planned.Fingerprint, importID,
+			langdetect.Detect(clipping.Content), clipping.AddedAt).Scan(&planned.ID)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": "failed to import annotations",
+			})
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to import annotations",
+		})
+	}
+
+	job, err := h.getImportJob(ctx, "i.id = $1", importID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to fetch import",
+		})
+	}
+	job["already_imported"] = false
+	return c.JSON(http.StatusCreated, job)
+}
+
+// getImportJob loads one annotation import matching condition
+func (h *AnnotationHandler) getImportJob(ctx context.Context, condition string, args ...interface{}) (map[string]interface{}, error) {
+	var job struct {
+		ID        string
+		Format    string
+		FileName  *string
+		Stats     json.RawMessage
+		CreatedAt time.Time
+		Count     int
+	}
+	err := h.DB.QueryRow(ctx, `
+		SELECT i.id, i.format, i.file_name, i.stats, i.created_at,
+		       (SELECT COUNT(*) FROM annotations a WHERE a.import_id = i.id)
+		FROM annotation_imports i
+		WHERE `+condition, args...).Scan(&job.ID, &job.Format, &job.FileName, &job.Stats, &job.CreatedAt, &job.Count)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"id":                job.ID,
+		"format":            job.Format,
+		"file_name":         job.FileName,
+		"stats":             job.Stats,
+		"annotations_count": job.Count,
+		"created_at":        job.CreatedAt,
+	}, nil
+}
+
+// importSorts are the sort keys accepted by GetAnnotationImports
+var importSorts = map[string]sortOption{
+	"created_at": {Expr: "i.created_at", Type: "timestamptz", Desc: true},
+}
+
+// GetAnnotationImports returns a page of the current user's past imports
+func (h *AnnotationHandler) GetAnnotationImports(c echo.Context) error {
+	userID := auth.GetUserID(c)
+	if userID == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": "unauthorized",
+		})
+	}
+
+	page, err := parsePageRequest(c, importSorts, "created_at", 20, 100)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	qb := newQueryBuilder(userID)
+	if format := c.QueryParam("format"); format != "" {
+		qb.where("i.format = " + qb.arg(format))
+	}
+	page.applyCursor(qb, "i.id")
+
+	query := `
+		SELECT i.id, i.format, i.file_name, i.stats, i.created_at,
+		       (SELECT COUNT(*) FROM annotations a WHERE a.import_id = i.id),
+		       ` + page.cursorColumn() + `
+		FROM annotation_imports i
+		WHERE i.user_id = $1` + qb.and() + `
+		` + page.orderBy(qb, "i.id")
+
+	rows, err := h.DB.Query(ctx, query, qb.args...)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to fetch imports",
+		})
+	}
+	defer rows.Close()
+
+	imports := []map[string]interface{}{}
+	cursors := []pageCursor{}
+	for rows.Next() {
+		var job struct {
+			ID          string
+			Format      string
+			FileName    *string
+			Stats       json.RawMessage
+			CreatedAt   time.Time
+			Count       int
+			CursorValue string
+		}
+		if err := rows.Scan(&job.ID, &job.Format, &job.FileName, &job.Stats, &job.CreatedAt, &job.Count, &job.CursorValue); err != nil {
+			continue
+		}
+
+		cursors = append(cursors, pageCursor{Value: job.CursorValue, ID: job.ID})
+		imports = append(imports, map[string]interface{}{
+			"id":                job.ID,
+			"format":            job.Format,
+			"file_name":         job.FileName,
+			"stats":             job.Stats,
+			"annotations_count": job.Count,
+			"created_at":        job.CreatedAt,
+		})
+	}
+
+	imports, nextCursor := page.trim(imports, cursors)
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"imports":     imports,
+		"count":       len(imports),
+		"next_cursor": nextCursor,
+		"has_more":    nextCursor != nil,
+	})
+}
+
+// GetAnnotationImport returns one of the current user's imports
+func (h *AnnotationHandler) GetAnnotationImport(c echo.Context) error {
+	userID := auth.GetUserID(c)
+	if userID == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": "unauthorized",
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	job, err := h.getImportJob(ctx, "i.id = $1 AND i.user_id = $2", c.Param("id"), userID)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": "import not found",
+		})
+	}
+	return c.JSON(http.StatusOK, job)
+}
@@ -0,0 +1,447 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"folio/api/auth"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+)
+
+// maxChapterLength and maxCFILength bound the free-text parts of a location
+const (
+	maxChapterLength = 500
+	maxCFILength     = 1000
+)
+
+// AnnotationLocation is where in a book an annotation is. Every field is optional;
+// percent is how far through the book, cfi is an EPUB Canonical Fragment Identifier
+// and the offsets are character positions within the page or chapter.
+type AnnotationLocation struct {
+	Chapter       *string  `json:"chapter"`
+	Percent       *float64 `json:"percent"`
+	CFI           *string  `json:"cfi"`
+	StartOffset   *int     `json:"start_offset"`
+	EndOffset     *int     `json:"end_offset"`
+	LocationStart *int     `json:"location_start"`
+	LocationEnd   *int     `json:"location_end"`
+}
+
+// validate checks the location and normalizes its text fields
+func (l *AnnotationLocation) validate() error {
+	if l.Chapter != nil {
+		chapter := strings.TrimSpace(*l.Chapter)
+		if len(chapter) > maxChapterLength {
+			return fmt.Errorf("chapter must be at most %d characters", maxChapterLength)
+		}
+		l.Chapter = &chapter
+		if chapter == "" {
+			l.Chapter = nil
+		}
+	}
+	if l.Percent != nil && (*l.Percent < 0 || *l.Percent > 100) {
+		return fmt.Errorf("percent must be between 0 and 100")
+	}
+	if l.CFI != nil {
+		cfi, err := normalizeCFI(*l.CFI)
+		if err != nil {
+			return err
+		}
+		l.CFI = &cfi
+	}
+	if (l.StartOffset != nil && *l.StartOffset < 0) || (l.EndOffset != nil && *l.EndOffset < 0) {
+		return fmt.Errorf("offsets must not be negative")
+	}
+	if l.StartOffset != nil && l.EndOffset != nil && *l.EndOffset < *l.StartOffset {
+		return fmt.Errorf("end_offset must not be before start_offset")
+	}
+	if l.LocationStart != nil && l.LocationEnd == nil {
+		l.LocationEnd = l.LocationStart
+	}
+	if l.LocationStart != nil && *l.LocationEnd < *l.LocationStart {
+		return fmt.Errorf("location_end must not be before location_start")
+	}
+	return nil
+}
+
+// normalizeCFI accepts a CFI with or without its epubcfi() wrapper and returns it wrapped
+func normalizeCFI(cfi string) (string, error) {
+	cfi = strings.TrimSpace(cfi)
+	if strings.HasPrefix(cfi, "epubcfi(") && strings.HasSuffix(cfi, ")") {
+		cfi = cfi[len("epubcfi(") : len(cfi)-1]
+	}
+	if !strings.HasPrefix(cfi, "/") || len(cfi) > maxCFILength {
+		return "", fmt.Errorf("cfi must be an EPUB CFI such as epubcfi(/6/4!/4/2:10)")
+	}
+	if cfiSteps(cfi) == nil {
+		return "", fmt.Errorf("cfi must be an EPUB CFI such as epubcfi(/6/4!/4/2:10)")
+	}
+	return "epubcfi(" + cfi + ")", nil
+}
+
+// cfiSteps reduces a CFI to the numbers that order it: each /step, then the :offset.
+// Range CFIs are ordered by where they start. Returns nil when the CFI can't be read.
+func cfiSteps(cfi string) []int {
+	cfi = strings.TrimSuffix(strings.TrimPrefix(cfi, "epubcfi("), ")")
+
+	// A range is parent,start,end; it starts at parent followed by start
+	if parts := strings.Split(cfi, ","); len(parts) == 3 {
+		cfi = parts[0] + parts[1]
+	}
+
+	steps := []int{}
+	for i := 0; i < len(cfi); i++ {
+		if cfi[i] != '/' && cfi[i] != ':' {
+			continue
+		}
+		j := i + 1
+		for j < len(cfi) && cfi[j] >= '0' && cfi[j] <= '9' {
+			j++
+		}
+		n, err := strconv.Atoi(cfi[i+1 : j])
+		if err != nil {
+			return nil
+		}
+		steps = append(steps, n)
+		// Skip assertions like [chap01] and side bias
+		if j < len(cfi) && cfi[j] == '[' {
+			if end := strings.IndexByte(cfi[j:], ']'); end >= 0 {
+				j += end
+			}
+		}
+		i = j - 1
+	}
+	if len(steps) == 0 {
+		return nil
+	}
+	return steps
+}
+
+// annotationPosition is what reading order is decided by
+type annotationPosition struct {
+	Percent       *float64
+	CFI           []int
+	Page          *int
+	LocationStart *int
+	StartOffset   *int
+	CreatedAt     time.Time
+	// PageCount is the book's page count, which lets a page be compared with a percent
+	PageCount *int
+}
+
+// Position axes that annotations from different sources can share, in sort order.
+// Annotations on the same axis are compared on it first, so manual captures with pages
+// interleave with imports that carry a percent instead of following them.
+const (
+	axisFraction = iota
+	axisPage
+	axisOther
+)
+
+// axis is the shared axis p can be placed on, and its value there
+func (p annotationPosition) axis() (int, float64) {
+	switch {
+	case p.Percent != nil:
+		return axisFraction, *p.Percent / 100
+	case p.Page != nil && p.PageCount != nil && *p.PageCount > 0:
+		return axisFraction, float64(*p.Page) / float64(*p.PageCount)
+	case p.Page != nil:
+		return axisPage, float64(*p.Page)
+	}
+	return axisOther, 0
+}
+
+// Position precisions, most precise first. They break ties on a shared axis and order the
+// annotations that have none, since a location can't be placed relative to a CFI without
+// knowing the book's layout.
+const (
+	precisionCFI = iota
+	precisionLocation
+	precisionPage
+	precisionPercent
+	precisionNone
+)
+
+// precision is the most precise kind of position p has
+func (p annotationPosition) precision() int {
+	switch {
+	case p.CFI != nil:
+		return precisionCFI
+	case p.LocationStart != nil:
+		return precisionLocation
+	case p.Page != nil:
+		return precisionPage
+	case p.Percent != nil:
+		return precisionPercent
+	}
+	return precisionNone
+}
+
+// compareAnnotationPositions orders two annotations by where they are in the book. Each is
+// ranked by its shared axis, then its most precise position, then its start offset, then when
+// it was made, so the order is the same however the annotations are paired up.
+func compareAnnotationPositions(a, b annotationPosition) int {
+	compareInts := func(x, y int) int {
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+		return 0
+	}
+
+	axis, aValue := a.axis()
+	bAxis, bValue := b.axis()
+	if c := compareInts(axis, bAxis); c != 0 {
+		return c
+	}
+	if aValue < bValue {
+		return -1
+	}
+	if aValue > bValue {
+		return 1
+	}
+
+	precision := a.precision()
+	if c := compareInts(precision, b.precision()); c != 0 {
+		return c
+	}
+	switch precision {
+	case precisionCFI:
+		for i := 0; i < len(a.CFI) && i < len(b.CFI); i++ {
+			if c := compareInts(a.CFI[i], b.CFI[i]); c != 0 {
+				return c
+			}
+		}
+		if c := compareInts(len(a.CFI), len(b.CFI)); c != 0 {
+			return c
+		}
+	case precisionLocation:
+		if c := compareInts(*a.LocationStart, *b.LocationStart); c != 0 {
+			return c
+		}
+	case precisionPage:
+		if c := compareInts(*a.Page, *b.Page); c != 0 {
+			return c
+		}
+	case precisionPercent:
+		if *a.Percent < *b.Percent {
+			return -1
+		}
+		if *a.Percent > *b.Percent {
+			return 1
+		}
+	}
+
+	// Offsets only apply within the same position; those without one go last
+	if precision != precisionNone && (a.StartOffset != nil || b.StartOffset != nil) {
+		if a.StartOffset == nil {
+			return 1
+		}
+		if b.StartOffset == nil {
+			return -1
+		}
+		if c := compareInts(*a.StartOffset, *b.StartOffset); c != 0 {
+			return c
+		}
+	}
+
+	switch {
+	case a.CreatedAt.Before(b.CreatedAt):
+		return -1
+	case a.CreatedAt.After(b.CreatedAt):
+		return 1
+	}
+	return 0
+}
+
+// annotationLocationJSON is the location object included in annotation responses
+func annotationLocationJSON(page *int, location AnnotationLocation) map[string]interface{} {
+	return map[string]interface{}{
+		"page":           page,
+		"chapter":        location.Chapter,
+		"percent":        location.Percent,
+		"cfi":            location.CFI,
+		"start_offset":   location.StartOffset,
+		"end_offset":     location.EndOffset,
+		"location_start": location.LocationStart,
+		"location_end":   location.LocationEnd,
+	}
+}
+
+// checkAnnotationParent verifies that parentID is one of the user's highlights that a note
+// can attach to, and returns the highlight's book
+func checkAnnotationParent(ctx context.Context, q rowQuerier, userID, parentID string) (*string, error) {
+	var parentType string
+	var parentBookID *string
+	err := q.QueryRow(ctx, `
+		SELECT type, book_id FROM annotations WHERE id = $1 AND user_id = $2
+	`, parentID, userID).Scan(&parentType, &parentBookID)
+	if err == pgx.ErrNoRows {
+		return nil, fmt.Errorf("parent annotation not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to check parent annotation")
+	}
+	if parentType != "highlight" {
+		return nil, fmt.Errorf("notes can only be attached to highlights")
+	}
+	return parentBookID, nil
+}
+
+// SetAnnotationParent attaches a note to one of the user's highlights. The note moves to
+// the highlight's book if it had none.
+func (h *AnnotationHandler) SetAnnotationParent(c echo.Context) error {
+	userID := auth.GetUserID(c)
+	if userID == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": "unauthorized",
+		})
+	}
+
+	annotationID := c.Param("id")
+
+	var req struct {
+		ParentID string `json:"parent_id"`
+	}
+	if err := c.Bind(&req); err != nil || req.ParentID == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "parent_id is required",
+		})
+	}
+	if req.ParentID == annotationID {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "an annotation cannot be its own parent",
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	var annotationType string
+	var bookID *string
+	err := h.DB.QueryRow(ctx, `
+		SELECT type, book_id FROM annotations WHERE id = $1 AND user_id = $2
+	`, annotationID, userID).Scan(&annotationType, &bookID)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": "annotation not found",
+		})
+	}
+	if annotationType != "note" {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "only notes can be attached to a highlight",
+		})
+	}
+
+	parentBookID, err := checkAnnotationParent(ctx, h.DB, userID, req.ParentID)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+	if bookID != nil && parentBookID != nil && *bookID != *parentBookID {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "the note and highlight belong to different books",
+		})
+	}
+
+	var updatedAt time.Time
+	err = h.DB.QueryRow(ctx, `
+		UPDATE annotations
+		SET parent_id = $1,
+		    book_id = COALESCE(book_id, $2),
+		    is_associated = (COALESCE(book_id, $2) IS NOT NULL),
+		    updated_at = NOW()
+		WHERE id = $3 AND user_id = $4
+		RETURNING updated_at
+	`, req.ParentID, parentBookID, annotationID, userID).Scan(&updatedAt)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to attach note",
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"id":         annotationID,
+		"parent_id":  req.ParentID,
+		"updated_at": updatedAt,
+	})
+}
+
+// RemoveAnnotationParent detaches a note from its highlight
+func (h *AnnotationHandler) RemoveAnnotationParent(c echo.Context) error {
+	userID := auth.GetUserID(c)
+	if userID == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": "unauthorized",
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	result, err := h.DB.Exec(ctx, `
+		UPDATE annotations SET parent_id = NULL, updated_at = NOW()
+		WHERE id = $1 AND user_id = $2 AND parent_id IS NOT NULL
+	`, c.Param("id"), userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to detach note",
+		})
+	}
+	if result.RowsAffected() == 0 {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": "annotation not found or not attached",
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message": "note detached",
+	})
+}
+
+// readingOrderAnnotation is an annotation in a reading-order listing
+type readingOrderAnnotation struct {
+	ParentID *string
+	Position annotationPosition
+	JSON     map[string]interface{}
+}
+
+// sortInReadingOrder sorts annotations by position in the book
+func sortInReadingOrder(annotations []readingOrderAnnotation) {
+	sort.SliceStable(annotations, func(i, j int) bool {
+		return compareAnnotationPositions(annotations[i].Position, annotations[j].Position) < 0
+	})
+}
+
+// threadInReadingOrder sorts annotations by position and nests each note under the highlight
+// it belongs to. Notes whose highlight isn't in the list are listed on their own.
+func threadInReadingOrder(annotations []readingOrderAnnotation) []map[string]interface{} {
+	sortInReadingOrder(annotations)
+
+	byID := map[string]map[string]interface{}{}
+	for _, annotation := range annotations {
+		annotation.JSON["notes"] = []map[string]interface{}{}
+		byID[annotation.JSON["id"].(string)] = annotation.JSON
+	}
+
+	threaded := []map[string]interface{}{}
+	for _, annotation := range annotations {
+		if annotation.ParentID != nil {
+			if parent, ok := byID[*annotation.ParentID]; ok {
+				parent["notes"] = append(parent["notes"].([]map[string]interface{}), annotation.JSON)
+				continue
+			}
+		}
+		threaded = append(threaded, annotation.JSON)
+	}
+	return threaded
+}
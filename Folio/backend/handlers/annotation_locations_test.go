@@ -0,0 +1,232 @@
+package handlers
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestCFISteps(t *testing.T) {
+	tests := []struct {
+		name string
+		cfi  string
+		want []int
+	}{
+		{"unwrapped", "/6/4!/4/2:10", []int{6, 4, 4, 2, 10}},
+		{"wrapped", "epubcfi(/6/4!/4/2:10)", []int{6, 4, 4, 2, 10}},
+		{"no offset", "epubcfi(/6/14)", []int{6, 14}},
+		{"assertions are skipped", "epubcfi(/6/4[chap01ref]!/4[body01]/10[para05]/3:10)", []int{6, 4, 4, 10, 3, 10}},
+		{"range starts at parent and start", "epubcfi(/6/4!/4/10,/2:5,/3:12)", []int{6, 4, 4, 10, 2, 5}},
+		{"empty", "", nil},
+		{"no steps", "epubcfi(chapter)", nil},
+		{"step without a number", "epubcfi(/6//4)", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cfiSteps(tt.cfi); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("cfiSteps(%q) = %v, want %v", tt.cfi, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompareAnnotationPositions(t *testing.T) {
+	intPtr := func(n int) *int { return &n }
+	floatPtr := func(f float64) *float64 { return &f }
+	earlier := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	later := earlier.Add(time.Hour)
+
+	tests := []struct {
+		name string
+		a, b annotationPosition
+		want int
+	}{
+		{
+			name: "cfi steps",
+			a:    annotationPosition{CFI: []int{6, 4, 2}},
+			b:    annotationPosition{CFI: []int{6, 10}},
+			want: -1,
+		},
+		{
+			name: "cfi prefix comes first",
+			a:    annotationPosition{CFI: []int{6, 4}},
+			b:    annotationPosition{CFI: []int{6, 4, 2}},
+			want: -1,
+		},
+		{
+			name: "shared page wins over cfi",
+			a:    annotationPosition{CFI: []int{6, 4}, Page: intPtr(300)},
+			b:    annotationPosition{CFI: []int{6, 8}, Page: intPtr(10)},
+			want: 1,
+		},
+		{
+			name: "page against page count meets percent",
+			a:    annotationPosition{Page: intPtr(150), PageCount: intPtr(300)},
+			b:    annotationPosition{Percent: floatPtr(25), LocationStart: intPtr(4000)},
+			want: 1,
+		},
+		{
+			name: "precision breaks a tie on the shared axis",
+			a:    annotationPosition{Page: intPtr(150), PageCount: intPtr(300)},
+			b:    annotationPosition{Percent: floatPtr(50), LocationStart: intPtr(4000)},
+			want: 1,
+		},
+		{
+			name: "pages without a page count stay on their own axis",
+			a:    annotationPosition{Page: intPtr(1)},
+			b:    annotationPosition{Percent: floatPtr(90)},
+			want: 1,
+		},
+		{
+			name: "location",
+			a:    annotationPosition{LocationStart: intPtr(900)},
+			b:    annotationPosition{LocationStart: intPtr(120)},
+			want: 1,
+		},
+		{
+			name: "page",
+			a:    annotationPosition{Page: intPtr(3)},
+			b:    annotationPosition{Page: intPtr(12)},
+			want: -1,
+		},
+		{
+			name: "percent",
+			a:    annotationPosition{Percent: floatPtr(50)},
+			b:    annotationPosition{Percent: floatPtr(12.5)},
+			want: 1,
+		},
+		{
+			name: "shared axis before none",
+			a:    annotationPosition{Page: intPtr(1)},
+			b:    annotationPosition{CFI: []int{6, 40}},
+			want: -1,
+		},
+		{
+			name: "more precise first without a shared axis",
+			a:    annotationPosition{LocationStart: intPtr(10)},
+			b:    annotationPosition{CFI: []int{6, 40}},
+			want: 1,
+		},
+		{
+			name: "positioned before unpositioned",
+			a:    annotationPosition{Percent: floatPtr(99), CreatedAt: later},
+			b:    annotationPosition{CreatedAt: earlier},
+			want: -1,
+		},
+		{
+			name: "start offset on the same page",
+			a:    annotationPosition{Page: intPtr(5), StartOffset: intPtr(40)},
+			b:    annotationPosition{Page: intPtr(5), StartOffset: intPtr(10)},
+			want: 1,
+		},
+		{
+			name: "missing start offset goes last",
+			a:    annotationPosition{Page: intPtr(5), CreatedAt: earlier},
+			b:    annotationPosition{Page: intPtr(5), StartOffset: intPtr(10), CreatedAt: later},
+			want: 1,
+		},
+		{
+			name: "created at breaks ties",
+			a:    annotationPosition{Page: intPtr(5), CreatedAt: earlier},
+			b:    annotationPosition{Page: intPtr(5), CreatedAt: later},
+			want: -1,
+		},
+		{
+			name: "equal",
+			a:    annotationPosition{CreatedAt: earlier},
+			b:    annotationPosition{CreatedAt: earlier},
+			want: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := compareAnnotationPositions(tt.a, tt.b); got != tt.want {
+				t.Errorf("compareAnnotationPositions(a, b) = %d, want %d", got, tt.want)
+			}
+			if got := compareAnnotationPositions(tt.b, tt.a); got != -tt.want {
+				t.Errorf("compareAnnotationPositions(b, a) = %d, want %d", got, -tt.want)
+			}
+		})
+	}
+}
+
+func TestMixedSourcesInterleave(t *testing.T) {
+	intPtr := func(n int) *int { return &n }
+	floatPtr := func(f float64) *float64 { return &f }
+	created := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	// Manual captures with pages of a 300 page book, then a Kindle import with locations
+	// and percents, made later
+	manualEarly := annotationPosition{Page: intPtr(30), PageCount: intPtr(300), CreatedAt: created}
+	manualLate := annotationPosition{Page: intPtr(270), PageCount: intPtr(300), CreatedAt: created}
+	kindleMiddle := annotationPosition{Percent: floatPtr(50), LocationStart: intPtr(4000), CreatedAt: created.Add(time.Hour)}
+	kindleLast := annotationPosition{Percent: floatPtr(95), LocationStart: intPtr(7600), CreatedAt: created.Add(time.Hour)}
+
+	annotations := []readingOrderAnnotation{
+		{Position: manualLate}, {Position: kindleLast}, {Position: manualEarly}, {Position: kindleMiddle},
+	}
+	sortInReadingOrder(annotations)
+
+	want := []annotationPosition{manualEarly, kindleMiddle, manualLate, kindleLast}
+	for i, annotation := range annotations {
+		if !reflect.DeepEqual(annotation.Position, want[i]) {
+			t.Errorf("position %d = %+v, want %+v", i, annotation.Position, want[i])
+		}
+	}
+}
+
+func TestCompareAnnotationPositionsIsTransitive(t *testing.T) {
+	intPtr := func(n int) *int { return &n }
+	floatPtr := func(f float64) *float64 { return &f }
+	created := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	positions := []annotationPosition{
+		{CFI: []int{1}, Page: intPtr(30)},
+		{Page: intPtr(20)},
+		{CFI: []int{2}, Page: intPtr(10)},
+		{LocationStart: intPtr(500), Percent: floatPtr(90)},
+		{LocationStart: intPtr(100), Page: intPtr(40)},
+		{LocationStart: intPtr(900)},
+		{CFI: []int{3}, LocationStart: intPtr(50)},
+		{Page: intPtr(150), PageCount: intPtr(300)},
+		{Percent: floatPtr(50), LocationStart: intPtr(20)},
+		{Percent: floatPtr(5), StartOffset: intPtr(3)},
+		{Percent: floatPtr(5)},
+		{Page: intPtr(20), StartOffset: intPtr(7), CreatedAt: created.Add(time.Minute)},
+		{CreatedAt: created},
+	}
+
+	for _, a := range positions {
+		for _, b := range positions {
+			for _, c := range positions {
+				ab := compareAnnotationPositions(a, b)
+				bc := compareAnnotationPositions(b, c)
+				ac := compareAnnotationPositions(a, c)
+				if ab <= 0 && bc <= 0 && ac > 0 {
+					t.Errorf("%+v <= %+v <= %+v but the first sorts after the last", a, b, c)
+				}
+			}
+		}
+	}
+
+	// Compared on whichever fields both share, these three would form a cycle
+	cycle := []annotationPosition{
+		{CFI: []int{1}, LocationStart: intPtr(900)},
+		{LocationStart: intPtr(500)},
+		{CFI: []int{2}, LocationStart: intPtr(100)},
+	}
+	sort.SliceStable(cycle, func(i, j int) bool {
+		return compareAnnotationPositions(cycle[i], cycle[j]) < 0
+	})
+	want := []annotationPosition{
+		{CFI: []int{1}, LocationStart: intPtr(900)},
+		{CFI: []int{2}, LocationStart: intPtr(100)},
+		{LocationStart: intPtr(500)},
+	}
+	if !reflect.DeepEqual(cycle, want) {
+		t.Errorf("sorted = %+v, want %+v", cycle, want)
+	}
+}
@@ -0,0 +1,429 @@
+package handlers
+
+import (
+	"context"
+	"folio/api/auth"
+	"folio/api/review"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+)
+
+// ReviewSettings are a user's spaced-repetition preferences
+type ReviewSettings struct {
+	// CardsPerDay caps how many cards are reviewed a day, due cards before new ones
+	CardsPerDay int `json:"cards_per_day"`
+	// IncludeTags limits reviews to annotations with these tags or tags nested under
+	// them; empty includes everything
+	IncludeTags []string `json:"include_tags"`
+	// ClozeCards adds a fill-in-the-blank version of each highlight to the queue
+	ClozeCards bool `json:"cloze_cards"`
+}
+
+const (
+	defaultCardsPerDay = 20
+	maxCardsPerDay     = 200
+)
+
+// reviewableSQL limits reviews to highlights and notes of their own; notes written on a
+// highlight are part of reviewing the highlight
+const reviewableSQL = "(a.type = 'highlight' OR a.parent_id IS NULL)"
+
+// reviewSettings returns the user's review settings, or the defaults
+func (h *AnnotationHandler) reviewSettings(ctx context.Context, userID string) (ReviewSettings, error) {
+	settings := ReviewSettings{CardsPerDay: defaultCardsPerDay, IncludeTags: []string{}}
+	err := h.DB.QueryRow(ctx, `
+		SELECT cards_per_day, include_tags, cloze_cards FROM review_settings WHERE user_id = $1
+	`, userID).Scan(&settings.CardsPerDay, &settings.IncludeTags, &settings.ClozeCards)
+	if err == pgx.ErrNoRows {
+		return settings, nil
+	}
+	return settings, err
+}
+
+// GetReviewSettings returns the current user's review settings
+func (h *AnnotationHandler) GetReviewSettings(c echo.Context) error {
+	userID := auth.GetUserID(c)
+	if userID == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": "unauthorized",
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	settings, err := h.reviewSettings(ctx, userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to fetch review settings",
+		})
+	}
+	return c.JSON(http.StatusOK, settings)
+}
+
+// UpdateReviewSettingsRequest changes the review settings that are sent
+type UpdateReviewSettingsRequest struct {
+	CardsPerDay *int     `json:"cards_per_day"`
+	IncludeTags []string `json:"include_tags"`
+	ClozeCards  *bool    `json:"cloze_cards"`
+}
+
+// UpdateReviewSettings changes the current user's review settings
+func (h *AnnotationHandler) UpdateReviewSettings(c echo.Context) error {
+	userID := auth.GetUserID(c)
+	if userID == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": "unauthorized",
+		})
+	}
+
+	var req UpdateReviewSettingsRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "invalid request body",
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	settings, err := h.reviewSettings(ctx, userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to fetch review settings",
+		})
+	}
+
+	if req.CardsPerDay != nil {
+		if *req.CardsPerDay < 1 || *req.CardsPerDay > maxCardsPerDay {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "cards_per_day must be between 1 and " + strconv.Itoa(maxCardsPerDay),
+			})
+		}
+		settings.CardsPerDay = *req.CardsPerDay
+	}
+	if req.IncludeTags != nil {
+		tags := []string{}
+		seen := map[string]bool{}
+		for _, tag := range req.IncludeTags {
+			tag, err := normalizeTag(tag)
+			if err != nil {
+				return c.JSON(http.StatusBadRequest, map[string]string{
+					"error": "include_tags: " + err.Error(),
+				})
+			}
+			if !seen[tag] {
+				seen[tag] = true
+				tags = append(tags, tag)
+			}
+		}
+		settings.IncludeTags = tags
+	}
+	if req.ClozeCards != nil {
+		settings.ClozeCards = *req.ClozeCards
+	}
+
+	_, err = h.DB.Exec(ctx, `
+		INSERT INTO review_settings (user_id, cards_per_day, include_tags, cloze_cards, updated_at)
+		VALUES ($1, $2, $3, $4, NOW())
+		ON CONFLICT (user_id)
+		DO UPDATE SET cards_per_day = EXCLUDED.cards_per_day, include_tags = EXCLUDED.include_tags,
+		              cloze_cards = EXCLUDED.cloze_cards, updated_at = NOW()
+	`, userID, settings.CardsPerDay, settings.IncludeTags, settings.ClozeCards)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to update review settings",
+		})
+	}
+
+	return c.JSON(http.StatusOK, settings)
+}
+
+// reviewIntervals previews the interval in days each grade would give a card
+func reviewIntervals(card review.Card, today time.Time) map[string]int {
+	intervals := map[string]int{}
+	for grade := review.MinGrade; grade <= review.MaxGrade; grade++ {
+		intervals[strconv.Itoa(grade)] = review.Schedule(card, grade, today).IntervalDays
+	}
+	return intervals
+}
+
+// GetReviewQueue returns today's review cards: annotations due for review, oldest due
+// first, then never-reviewed ones up to the user's daily limit. New cards are picked in
+// an order that is stable for the day, so reloading the queue doesn't reshuffle it.
+func (h *AnnotationHandler) GetReviewQueue(c echo.Context) error {
+	userID := auth.GetUserID(c)
+	if userID == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": "unauthorized",
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	settings, err := h.reviewSettings(ctx, userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to fetch review settings",
+		})
+	}
+
+	today := review.Day(time.Now())
+
+	var reviewedToday int
+	err = h.DB.QueryRow(ctx, `
+		SELECT COUNT(*) FROM annotation_reviews WHERE user_id = $1 AND last_reviewed_at >= $2
+	`, userID, today).Scan(&reviewedToday)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to build review queue",
+		})
+	}
+	remaining := settings.CardsPerDay - reviewedToday
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	qb := newQueryBuilder(userID, today)
+	qb.where(reviewableSQL)
+	if len(settings.IncludeTags) > 0 {
+		qb.where(anyTagMatchSQL("a", qb.arg(settings.IncludeTags)))
+	}
+
+	var dueCount, newCount int
+	err = h.DB.QueryRow(ctx, `
+		SELECT COUNT(*) FILTER (WHERE r.due_on <= $2::date),
+		       COUNT(*) FILTER (WHERE r.annotation_id IS NULL)
+		FROM annotations a
+		LEFT JOIN annotation_reviews r ON r.annotation_id = a.id
+		WHERE a.user_id = $1`+qb.and(), qb.args...).Scan(&dueCount, &newCount)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to build review queue",
+		})
+	}
+
+	cards := []map[string]interface{}{}
+	if remaining > 0 {
+		// Due cards come first; new cards fill what is left of the day's limit
+		query := `
+			SELECT a.id, a.type, a.content, a.context, a.page_number, a.tags, a.created_at,
+			       b.id, b.title, b.authors, b.cover_url,
+			       r.ease_factor::float8, r.interval_days, r.repetitions, r.lapses, r.due_on, r.last_reviewed_at
+			FROM annotations a
+			LEFT JOIN annotation_reviews r ON r.annotation_id = a.id
+			LEFT JOIN books b ON a.book_id = b.id
+			WHERE a.user_id = $1 AND (r.due_on <= $2::date OR r.annotation_id IS NULL)` + qb.and() + `
+			ORDER BY r.annotation_id IS NULL, r.due_on, r.last_reviewed_at, md5(a.id::text || $2::date::text)
+			LIMIT ` + qb.arg(remaining)
+
+		rows, err := h.DB.Query(ctx, query, qb.args...)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": "failed to build review queue",
+			})
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var card struct {
+				ID             string
+				Type           string
+				Content        string
+				Context        *string
+				PageNumber     *int
+				Tags           []string
+				CreatedAt      time.Time
+				BookID         *string
+				BookTitle      *string
+				BookAuthors    []string
+				BookCover      *string
+				Ease           *float64
+				IntervalDays   *int
+				Repetitions    *int
+				Lapses         *int
+				DueOn          *time.Time
+				LastReviewedAt *time.Time
+			}
+			err := rows.Scan(
+				&card.ID, &card.Type, &card.Content, &card.Context, &card.PageNumber, &card.Tags, &card.CreatedAt,
+				&card.BookID, &card.BookTitle, &card.BookAuthors, &card.BookCover,
+				&card.Ease, &card.IntervalDays, &card.Repetitions, &card.Lapses, &card.DueOn, &card.LastReviewedAt,
+			)
+			if err != nil {
+				continue
+			}
+
+			annotation := map[string]interface{}{
+				"id":          card.ID,
+				"type":        card.Type,
+				"content":     card.Content,
+				"context":     card.Context,
+				"page_number": card.PageNumber,
+				"tags":        card.Tags,
+				"created_at":  card.CreatedAt,
+			}
+			if card.BookID != nil {
+				annotation["book"] = map[string]interface{}{
+					"id":        *card.BookID,
+					"title":     card.BookTitle,
+					"authors":   card.BookAuthors,
+					"cover_url": card.BookCover,
+				}
+			}
+
+			state := review.NewCard(today)
+			result := map[string]interface{}{
+				"annotation": annotation,
+				"is_new":     card.Ease == nil,
+				"schedule":   nil,
+			}
+			if card.Ease != nil {
+				state = review.Card{Ease: *card.Ease, IntervalDays: *card.IntervalDays, Repetitions: *card.Repetitions, Lapses: *card.Lapses, DueOn: *card.DueOn}
+				result["schedule"] = map[string]interface{}{
+					"ease_factor":      state.Ease,
+					"interval_days":    state.IntervalDays,
+					"repetitions":      state.Repetitions,
+					"lapses":           state.Lapses,
+					"due_on":           state.DueOn.Format("2006-01-02"),
+					"last_reviewed_at": card.LastReviewedAt,
+				}
+			}
+			result["intervals"] = reviewIntervals(state, today)
+			if settings.ClozeCards && card.Type == "highlight" {
+				if cloze := review.MakeCloze(card.Content); cloze != nil {
+					result["cloze"] = cloze
+				}
+			}
+			cards = append(cards, result)
+		}
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"date":           today.Format("2006-01-02"),
+		"cards":          cards,
+		"count":          len(cards),
+		"due_count":      dueCount,
+		"new_count":      newCount,
+		"reviewed_today": reviewedToday,
+		"remaining":      remaining,
+		"settings":       settings,
+	})
+}
+
+// ReviewAnnotation records a review of one of the user's annotations, graded from 0
+// (forgotten) to 5 (perfect recall), and schedules its next review. Only cards that are
+// new or due can be reviewed.
+func (h *AnnotationHandler) ReviewAnnotation(c echo.Context) error {
+	userID := auth.GetUserID(c)
+	if userID == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": "unauthorized",
+		})
+	}
+
+	annotationID := c.Param("id")
+
+	var req struct {
+		Grade *int `json:"grade"`
+	}
+	if err := c.Bind(&req); err != nil || req.Grade == nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "grade is required",
+		})
+	}
+	if err := review.ValidateGrade(*req.Grade); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	tx, err := h.DB.Begin(ctx)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to record review",
+		})
+	}
+	defer tx.Rollback(ctx)
+
+	var reviewable bool
+	err = tx.QueryRow(ctx, "SELECT "+reviewableSQL+" FROM annotations a WHERE a.id = $1 AND a.user_id = $2", annotationID, userID).Scan(&reviewable)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": "annotation not found",
+		})
+	}
+	if !reviewable {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "notes on a highlight are reviewed with the highlight",
+		})
+	}
+
+	now := time.Now()
+	card := review.NewCard(now)
+	err = tx.QueryRow(ctx, `
+		SELECT ease_factor::float8, interval_days, repetitions, lapses, due_on
+		FROM annotation_reviews
+		WHERE annotation_id = $1
+		FOR UPDATE
+	`, annotationID).Scan(&card.Ease, &card.IntervalDays, &card.Repetitions, &card.Lapses, &card.DueOn)
+	if err != nil && err != pgx.ErrNoRows {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to record review",
+		})
+	}
+	// Every review schedules the next one at least a day out, so this also stops a card
+	// being graded twice in a day
+	if err == nil && card.DueOn.After(review.Day(now)) {
+		return c.JSON(http.StatusConflict, map[string]string{
+			"error": "annotation is not due for review until " + card.DueOn.Format("2006-01-02"),
+		})
+	}
+
+	next := review.Schedule(card, *req.Grade, now)
+
+	var reviewCount int
+	err = tx.QueryRow(ctx, `
+		INSERT INTO annotation_reviews (annotation_id, user_id, ease_factor, interval_days, repetitions, lapses,
+		                                due_on, last_grade, last_reviewed_at, review_count)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, 1)
+		ON CONFLICT (annotation_id)
+		DO UPDATE SET ease_factor = EXCLUDED.ease_factor, interval_days = EXCLUDED.interval_days,
+		              repetitions = EXCLUDED.repetitions, lapses = EXCLUDED.lapses, due_on = EXCLUDED.due_on,
+		              last_grade = EXCLUDED.last_grade, last_reviewed_at = EXCLUDED.last_reviewed_at,
+		              review_count = annotation_reviews.review_count + 1
+		RETURNING review_count
+	`, annotationID, userID, next.Ease, next.IntervalDays, next.Repetitions, next.Lapses,
+		next.DueOn, *req.Grade, now).Scan(&reviewCount)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to record review",
+		})
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to record review",
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"annotation_id":    annotationID,
+		"grade":            *req.Grade,
+		"ease_factor":      next.Ease,
+		"interval_days":    next.IntervalDays,
+		"repetitions":      next.Repetitions,
+		"lapses":           next.Lapses,
+		"due_on":           next.DueOn.Format("2006-01-02"),
+		"last_reviewed_at": now,
+		"review_count":     reviewCount,
+	})
+}
@@ -0,0 +1,404 @@
+package handlers
+
+import (
+	"context"
+	"folio/api/auth"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/labstack/echo/v4"
+)
+
+// annotationVisibilities are who can see an annotation besides its owner
+var annotationVisibilities = map[string]bool{
+	"private":   true,
+	"followers": true,
+	"public":    true,
+}
+
+// annotationVisibleSQL is a condition that annotation alias may be seen by the viewer in
+// placeholder viewerArg, which may be NULL for anonymous viewers
+func annotationVisibleSQL(alias, viewerArg string) string {
+	return `(` + alias + `.visibility = 'public'
+		OR ` + alias + `.user_id = ` + viewerArg + `
+		OR (` + alias + `.visibility = 'followers' AND EXISTS(
+			SELECT 1 FROM followers f WHERE f.follower_id = ` + viewerArg + ` AND f.following_id = ` + alias + `.user_id
+		)))`
+}
+
+// sharedHighlightSorts are the sort keys accepted by GetBookHighlights
+var sharedHighlightSorts = map[string]sortOption{
+	"created_at": {Expr: "a.created_at", Type: "timestamptz", Desc: true},
+}
+
+// GetBookHighlights returns a page of the highlights and notes on a book that the viewer
+// can see: public ones, followers-only ones from people they follow, and their own
+func (h *AnnotationHandler) GetBookHighlights(c echo.Context) error {
+	userID := auth.GetUserID(c)
+
+	page, err := parsePageRequest(c, sharedHighlightSorts, "created_at", 20, 100)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	qb := newQueryBuilder(c.Param("id"), nullableUserID(userID))
+	qb.where("a.visibility <> 'private'")
+	if annotationType := c.QueryParam("type"); annotationType != "" {
+		qb.where("a.type = " + qb.arg(annotationType))
+	}
+	page.applyCursor(qb, "a.id")
+
+	query := `
+		SELECT a.id, a.type, a.content, a.page_number, a.chapter, a.parent_id, a.visibility, a.created_at,
+		       u.id, u.username, u.name, u.picture,
+		       ` + page.cursorColumn() + `
+		FROM annotations a
+		JOIN users u ON a.user_id = u.id
+		WHERE a.book_id = $1 AND ` + annotationVisibleSQL("a", "$2") + qb.and() + `
+		` + page.orderBy(qb, "a.id")
+
+	rows, err := h.DB.Query(ctx, query, qb.args...)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to fetch highlights",
+		})
+	}
+	defer rows.Close()
+
+	highlights := []map[string]interface{}{}
+	cursors := []pageCursor{}
+	ids := []string{}
+	for rows.Next() {
+		var annotation struct {
+			ID          string
+			Type        string
+			Content     string
+			PageNumber  *int
+			Chapter     *string
+			ParentID    *string
+			Visibility  string
+			CreatedAt   time.Time
+			UserID      string
+			Username    string
+			Name        string
+			Picture     *string
+			CursorValue string
+		}
+		err := rows.Scan(
+			&annotation.ID, &annotation.Type, &annotation.Content, &annotation.PageNumber,
+			&annotation.Chapter, &annotation.ParentID, &annotation.Visibility, &annotation.CreatedAt,
+			&annotation.UserID, &annotation.Username, &annotation.Name, &annotation.Picture,
+			&annotation.CursorValue,
+		)
+		if err != nil {
+			continue
+		}
+
+		cursors = append(cursors, pageCursor{Value: annotation.CursorValue, ID: annotation.ID})
+		ids = append(ids, annotation.ID)
+		highlights = append(highlights, map[string]interface{}{
+			"id":          annotation.ID,
+			"type":        annotation.Type,
+			"content":     annotation.Content,
+			"page_number": annotation.PageNumber,
+			"chapter":     annotation.Chapter,
+			"parent_id":   annotation.ParentID,
+			"visibility":  annotation.Visibility,
+			"created_at":  annotation.CreatedAt,
+			"user": map[string]interface{}{
+				"id":       annotation.UserID,
+				"username": annotation.Username,
+				"name":     annotation.Name,
+				"picture":  annotation.Picture,
+			},
+		})
+	}
+	rows.Close()
+
+	counts := reactionCounts(ctx, h.DB, "annotation", ids)
+	for _, highlight := range highlights {
+		byType := counts[highlight["id"].(string)]
+		if byType == nil {
+			byType = map[string]int{}
+		}
+		highlight["reaction_counts"] = byType
+	}
+
+	highlights, nextCursor := page.trim(highlights, cursors)
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"highlights":  highlights,
+		"count":       len(highlights),
+		"next_cursor": nextCursor,
+		"has_more":    nextCursor != nil,
+	})
+}
+
+// maxPopularHighlightScan bounds how many shared highlights of a book are clustered
+const maxPopularHighlightScan = 5000
+
+// highlightShingleWords is how many consecutive words two highlights must share to count
+// as the same passage. Shorter highlights only match identical ones.
+const highlightShingleWords = 8
+
+// sharedHighlight is one reader's highlight considered for a book's popular passages
+type sharedHighlight struct {
+	ID         string
+	UserID     string
+	Content    string
+	PageNumber *int
+	Chapter    *string
+	Username   string
+	Name       string
+	Picture    *string
+	words      []string
+}
+
+// highlightWords lowercases text and splits it into words, dropping punctuation, so that
+// the same passage highlighted with different quote marks or trailing periods still matches
+func highlightWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\''
+	})
+}
+
+// clusterHighlights groups highlights of the same passage: identical text, or text sharing
+// a run of highlightShingleWords words, which catches highlights that overlap or contain
+// one another. Each group is matched against its representative, the most common wording in
+// it, rather than any member, so a chain of overlapping highlights doesn't merge distant
+// passages. Returns groups of indexes into highlights.
+func clusterHighlights(highlights []sharedHighlight) [][]int {
+	// Identical wordings always belong together
+	byWording := map[string][]int{}
+	wordings := []string{}
+	for i, highlight := range highlights {
+		if len(highlight.words) == 0 {
+			continue
+		}
+		key := strings.Join(highlight.words, " ")
+		if _, ok := byWording[key]; !ok {
+			wordings = append(wordings, key)
+		}
+		byWording[key] = append(byWording[key], i)
+	}
+
+	// The most common wordings become representatives first
+	sort.SliceStable(wordings, func(i, j int) bool {
+		return len(byWording[wordings[i]]) > len(byWording[wordings[j]])
+	})
+
+	shingles := func(words []string) []string {
+		keys := []string{}
+		for start := 0; start+highlightShingleWords <= len(words); start++ {
+			keys = append(keys, strings.Join(words[start:start+highlightShingleWords], " "))
+		}
+		return keys
+	}
+
+	clusters := [][]int{}
+	representativeShingles := map[string]int{}
+	for _, key := range wordings {
+		members := byWording[key]
+		keys := shingles(highlights[members[0]].words)
+
+		cluster := -1
+		for _, shingle := range keys {
+			if c, ok := representativeShingles[shingle]; ok && (cluster == -1 || c < cluster) {
+				cluster = c
+			}
+		}
+		if cluster >= 0 {
+			clusters[cluster] = append(clusters[cluster], members...)
+			continue
+		}
+
+		cluster = len(clusters)
+		clusters = append(clusters, append([]int{}, members...))
+		for _, shingle := range keys {
+			if _, ok := representativeShingles[shingle]; !ok {
+				representativeShingles[shingle] = cluster
+			}
+		}
+	}
+
+	for _, cluster := range clusters {
+		sort.Ints(cluster)
+	}
+	return clusters
+}
+
+// GetPopularHighlights returns the passages of a book that the most readers highlighted,
+// counting the highlights the viewer can see. Overlapping highlights of one passage are
+// counted together, and the most commonly highlighted wording is shown as the passage.
+func (h *AnnotationHandler) GetPopularHighlights(c echo.Context) error {
+	userID := auth.GetUserID(c)
+	bookID := c.Param("id")
+
+	limit, err := parseOptionalInt(c, "limit")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+	if limit == nil || *limit < 1 || *limit > 100 {
+		defaultLimit := 20
+		limit = &defaultLimit
+	}
+	minReaders, err := parseOptionalInt(c, "min_readers")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+	if minReaders == nil || *minReaders < 1 {
+		one := 1
+		minReaders = &one
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	rows, err := h.DB.Query(ctx, `
+		SELECT a.id, a.user_id, a.content, a.page_number, a.chapter, u.username, u.name, u.picture
+		FROM annotations a
+		JOIN users u ON a.user_id = u.id
+		WHERE a.book_id = $1 AND a.type = 'highlight'
+		AND `+annotationVisibleSQL("a", "$2")+`
+		ORDER BY a.created_at DESC
+		LIMIT $3
+	`, bookID, nullableUserID(userID), maxPopularHighlightScan)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to fetch highlights",
+		})
+	}
+	defer rows.Close()
+
+	highlights := []sharedHighlight{}
+	for rows.Next() {
+		var highlight sharedHighlight
+		err := rows.Scan(
+			&highlight.ID, &highlight.UserID, &highlight.Content, &highlight.PageNumber,
+			&highlight.Chapter, &highlight.Username, &highlight.Name, &highlight.Picture,
+		)
+		if err != nil {
+			continue
+		}
+		highlight.words = highlightWords(highlight.Content)
+		highlights = append(highlights, highlight)
+	}
+	rows.Close()
+
+	passages := []map[string]interface{}{}
+	for _, cluster := range clusterHighlights(highlights) {
+		readers := map[string]bool{}
+		sampleReaders := []map[string]interface{}{}
+		wordings := map[string]int{}
+		var myAnnotationID *string
+		for _, i := range cluster {
+			highlight := highlights[i]
+			wordings[strings.Join(highlight.words, " ")]++
+			if highlight.UserID == userID {
+				id := highlight.ID
+				myAnnotationID = &id
+			}
+			if readers[highlight.UserID] {
+				continue
+			}
+			readers[highlight.UserID] = true
+			if len(sampleReaders) < 3 {
+				sampleReaders = append(sampleReaders, map[string]interface{}{
+					"id":       highlight.UserID,
+					"username": highlight.Username,
+					"name":     highlight.Name,
+					"picture":  highlight.Picture,
+				})
+			}
+		}
+		if len(readers) < *minReaders {
+			continue
+		}
+
+		// Show the wording most readers chose, preferring the shorter on a tie
+		best := highlights[cluster[0]]
+		bestKey := strings.Join(best.words, " ")
+		for _, i := range cluster[1:] {
+			key := strings.Join(highlights[i].words, " ")
+			if wordings[key] > wordings[bestKey] || (wordings[key] == wordings[bestKey] && len(key) < len(bestKey)) {
+				best, bestKey = highlights[i], key
+			}
+		}
+
+		passages = append(passages, map[string]interface{}{
+			"passage":           best.Content,
+			"page_number":       best.PageNumber,
+			"chapter":           best.Chapter,
+			"readers_count":     len(readers),
+			"highlights_count":  len(cluster),
+			"sample_readers":    sampleReaders,
+			"highlighted_by_me": myAnnotationID != nil,
+			"my_annotation_id":  myAnnotationID,
+		})
+	}
+
+	sort.SliceStable(passages, func(i, j int) bool {
+		a, b := passages[i], passages[j]
+		if a["readers_count"].(int) != b["readers_count"].(int) {
+			return a["readers_count"].(int) > b["readers_count"].(int)
+		}
+		return a["highlights_count"].(int) > b["highlights_count"].(int)
+	})
+	if len(passages) > *limit {
+		passages = passages[:*limit]
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"book_id":    bookID,
+		"highlights": passages,
+		"count":      len(passages),
+	})
+}
+
+// sharedHighlightFeedSQL selects feed events for highlights shared in the last 30 days by
+// people the user ($1) follows. Its columns match GetFeed's: the highlight text is the title,
+// the note written on it the description, and its book the preview.
+const sharedHighlightFeedSQL = `
+	SELECT
+		'highlight_shared' as event_type,
+		a.id as entity_id,
+		a.user_id,
+		a.content as title,
+		(SELECT n.content FROM annotations n
+		 WHERE n.parent_id = a.id AND n.user_id = a.user_id AND n.visibility <> 'private'
+		 ORDER BY n.created_at LIMIT 1) as description,
+		0 as items_count,
+		(SELECT COUNT(*)::int FROM reactions r WHERE r.target_type = 'annotation' AND r.target_id = a.id) as likes_count,
+		0 as comments_count,
+		NULL::text as header_image_url,
+		NULL::text as theme_color,
+		a.created_at,
+		u.username,
+		u.name as user_name,
+		u.picture,
+		ARRAY[b.id::text] as book_ids,
+		ARRAY[b.title::text] as book_titles,
+		ARRAY[COALESCE(b.cover_url, '')::text] as book_covers,
+		EXISTS(SELECT 1 FROM reactions r WHERE r.target_type = 'annotation' AND r.target_id = a.id AND r.user_id = $1 AND r.reaction = 'like') as is_liked
+	FROM annotations a
+	JOIN followers f ON f.following_id = a.user_id AND f.follower_id = $1
+	JOIN users u ON a.user_id = u.id
+	JOIN books b ON a.book_id = b.id
+	WHERE a.type = 'highlight'
+	AND a.visibility IN ('public', 'followers')
+	AND a.created_at > NOW() - INTERVAL '30 days'
+	ORDER BY a.created_at DESC
+	LIMIT 50
+`
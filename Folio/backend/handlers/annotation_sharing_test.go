@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHighlightWords(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"It was the best of times.", []string{"it", "was", "the", "best", "of", "times"}},
+		{"“Don't panic,” he said—calmly…", []string{"don't", "panic", "he", "said", "calmly"}},
+		{"  Chapter 42:\n\tTHE answer ", []string{"chapter", "42", "the", "answer"}},
+		{"Café élan", []string{"café", "élan"}},
+		{"...!?", []string{}},
+		{"", []string{}},
+	}
+
+	for _, tt := range tests {
+		if got := highlightWords(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("highlightWords(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestClusterHighlights(t *testing.T) {
+	// words returns the words numbered from first to last, so ranges can be made to overlap
+	words := func(first, last int) string {
+		names := []string{}
+		for i := first; i <= last; i++ {
+			names = append(names, "w"+strings.Repeat("x", i))
+		}
+		return strings.Join(names, " ")
+	}
+
+	tests := []struct {
+		name     string
+		contents []string
+		want     [][]int
+	}{
+		{
+			name:     "identical text ignoring case and punctuation",
+			contents: []string{"All happy families are alike.", "all happy families are alike", "Something else entirely"},
+			want:     [][]int{{0, 1}, {2}},
+		},
+		{
+			name:     "short highlights only match identical ones",
+			contents: []string{"call me ishmael", "call me ishmael some years ago"},
+			want:     [][]int{{0}, {1}},
+		},
+		{
+			name:     "a highlight containing another",
+			contents: []string{words(1, 10), words(1, 20)},
+			want:     [][]int{{0, 1}},
+		},
+		{
+			name:     "overlap shorter than a shingle",
+			contents: []string{words(1, 10), words(4, 14)},
+			want:     [][]int{{0}, {1}},
+		},
+		{
+			name:     "a chain of overlaps does not merge distant passages",
+			contents: []string{words(1, 12), words(5, 16), words(9, 20)},
+			want:     [][]int{{0, 1}, {2}},
+		},
+		{
+			name:     "the most common wording is the representative",
+			contents: []string{words(1, 12), words(5, 16), words(9, 20), words(5, 16)},
+			want:     [][]int{{0, 1, 2, 3}},
+		},
+		{
+			name:     "highlights without words are left out",
+			contents: []string{"—", "real words here"},
+			want:     [][]int{{1}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			highlights := make([]sharedHighlight, len(tt.contents))
+			for i, content := range tt.contents {
+				highlights[i] = sharedHighlight{Content: content, words: highlightWords(content)}
+			}
+			if got := clusterHighlights(highlights); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("clusterHighlights() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
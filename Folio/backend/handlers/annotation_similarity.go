@@ -0,0 +1,293 @@
+package handlers
+
+import (
+	"context"
+	"folio/api/auth"
+	"folio/api/similarity"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// maxSimilarityDocuments bounds how many of a user's most recent annotations are indexed
+const maxSimilarityDocuments = 2000
+
+// maxCaptureSuggestionDocuments is the smaller index built while capturing, so that
+// suggesting tags stays cheap next to saving the annotation
+const maxCaptureSuggestionDocuments = 300
+
+// Clustering settings for candidate themes: untagged annotations at least
+// themeClusterThreshold similar are grouped, and groups need themeClusterMinSize members
+const (
+	themeClusterThreshold = 0.1
+	themeClusterMinSize   = 3
+	maxCandidateThemes    = 10
+	// maxThemeClusterDocuments bounds how many untagged annotations are clustered
+	maxThemeClusterDocuments = 500
+)
+
+// loadAnnotationIndex builds a similarity index over the user's annotations, leaving out
+// the annotation with id exclude
+func (h *AnnotationHandler) loadAnnotationIndex(ctx context.Context, userID, exclude string) (*similarity.Index, error) {
+	return h.queryAnnotationIndex(ctx, `
+		SELECT id, content, tags
+		FROM annotations
+		WHERE user_id = $1 AND id::text <> $2
+		ORDER BY created_at DESC
+		LIMIT $3
+	`, userID, exclude, maxSimilarityDocuments)
+}
+
+// queryAnnotationIndex builds a similarity index over the annotations a query returns as
+// id, content and tags
+func (h *AnnotationHandler) queryAnnotationIndex(ctx context.Context, query string, args ...interface{}) (*similarity.Index, error) {
+	rows, err := h.DB.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	docs := []similarity.Document{}
+	for rows.Next() {
+		var doc similarity.Document
+		if err := rows.Scan(&doc.ID, &doc.Text, &doc.Tags); err != nil {
+			continue
+		}
+		docs = append(docs, doc)
+	}
+	return similarity.NewIndex(docs), rows.Err()
+}
+
+// captureTagSuggestions suggests tags for a just-captured annotation from the user's most
+// recent annotations
+func (h *AnnotationHandler) captureTagSuggestions(ctx context.Context, userID, annotationID, content string, tags []string) ([]map[string]interface{}, error) {
+	index, err := h.queryAnnotationIndex(ctx, `
+		SELECT id, content, tags
+		FROM annotations
+		WHERE user_id = $1 AND id <> $2
+		ORDER BY created_at DESC
+		LIMIT $3
+	`, userID, annotationID, maxCaptureSuggestionDocuments)
+	if err != nil {
+		return nil, err
+	}
+	return suggestedTagsJSON(index.SuggestTags(content, tags, 5)), nil
+}
+
+// suggestedTagsJSON converts tag suggestions for a response
+func suggestedTagsJSON(suggestions []similarity.TagSuggestion) []map[string]interface{} {
+	tags := []map[string]interface{}{}
+	for _, suggestion := range suggestions {
+		tags = append(tags, map[string]interface{}{
+			"tag":   suggestion.Tag,
+			"score": suggestion.Score,
+		})
+	}
+	return tags
+}
+
+// GetRelatedAnnotations returns the user's annotations most similar in wording to one of them
+func (h *AnnotationHandler) GetRelatedAnnotations(c echo.Context) error {
+	userID := auth.GetUserID(c)
+	if userID == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": "unauthorized",
+		})
+	}
+
+	annotationID := c.Param("id")
+
+	limit, err := parseOptionalInt(c, "limit")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+	if limit == nil || *limit < 1 || *limit > 50 {
+		defaultLimit := 10
+		limit = &defaultLimit
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	var content string
+	err = h.DB.QueryRow(ctx, "SELECT content FROM annotations WHERE id = $1 AND user_id = $2", annotationID, userID).Scan(&content)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": "annotation not found",
+		})
+	}
+
+	index, err := h.loadAnnotationIndex(ctx, userID, annotationID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to find related annotations",
+		})
+	}
+	matches := index.Similar(index.Vectorize(content), *limit, 0.05, annotationID)
+
+	ids := make([]string, len(matches))
+	for i, match := range matches {
+		ids[i] = match.Document.ID
+	}
+
+	rows, err := h.DB.Query(ctx, `
+		SELECT a.id, a.type, a.content, a.page_number, a.tags, a.created_at, b.id, b.title, b.cover_url
+		FROM annotations a
+		LEFT JOIN books b ON a.book_id = b.id
+		WHERE a.id = ANY($1::uuid[]) AND a.user_id = $2
+	`, ids, userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to find related annotations",
+		})
+	}
+	defer rows.Close()
+
+	details := map[string]map[string]interface{}{}
+	for rows.Next() {
+		var annotation struct {
+			ID         string
+			Type       string
+			Content    string
+			PageNumber *int
+			Tags       []string
+			CreatedAt  time.Time
+			BookID     *string
+			BookTitle  *string
+			BookCover  *string
+		}
+		err := rows.Scan(
+			&annotation.ID, &annotation.Type, &annotation.Content, &annotation.PageNumber,
+			&annotation.Tags, &annotation.CreatedAt, &annotation.BookID, &annotation.BookTitle, &annotation.BookCover,
+		)
+		if err != nil {
+			continue
+		}
+
+		result := map[string]interface{}{
+			"id":          annotation.ID,
+			"type":        annotation.Type,
+			"content":     annotation.Content,
+			"page_number": annotation.PageNumber,
+			"tags":        annotation.Tags,
+			"created_at":  annotation.CreatedAt,
+		}
+		if annotation.BookID != nil {
+			result["book"] = map[string]interface{}{
+				"id":        *annotation.BookID,
+				"title":     annotation.BookTitle,
+				"cover_url": annotation.BookCover,
+			}
+		}
+		details[annotation.ID] = result
+	}
+
+	related := []map[string]interface{}{}
+	for _, match := range matches {
+		result, ok := details[match.Document.ID]
+		if !ok {
+			continue
+		}
+		result["score"] = math.Round(match.Score*1000) / 1000
+		result["shared_terms"] = match.Terms
+		related = append(related, result)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"annotation_id": annotationID,
+		"related":       related,
+		"count":         len(related),
+	})
+}
+
+// SuggestAnnotationTags suggests tags for text the user is about to save, based on how
+// they tagged similar annotations before
+func (h *AnnotationHandler) SuggestAnnotationTags(c echo.Context) error {
+	userID := auth.GetUserID(c)
+	if userID == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": "unauthorized",
+		})
+	}
+
+	var req struct {
+		Content string   `json:"content"`
+		Tags    []string `json:"tags"`
+	}
+	if err := c.Bind(&req); err != nil || strings.TrimSpace(req.Content) == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "content is required",
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	index, err := h.loadAnnotationIndex(ctx, userID, "")
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to suggest tags",
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"suggested_tags": suggestedTagsJSON(index.SuggestTags(req.Content, req.Tags, 5)),
+	})
+}
+
+// candidateThemes clusters the user's most recent untagged annotations into groups that
+// could become themes, each named by the words its annotations share
+func (h *AnnotationHandler) candidateThemes(ctx context.Context, userID string) ([]map[string]interface{}, error) {
+	index, err := h.queryAnnotationIndex(ctx, `
+		SELECT id, content, tags
+		FROM annotations
+		WHERE user_id = $1 AND COALESCE(cardinality(tags), 0) = 0
+		ORDER BY created_at DESC
+		LIMIT $2
+	`, userID, maxThemeClusterDocuments)
+	if err != nil {
+		return nil, err
+	}
+
+	clusters := index.Cluster(func(doc similarity.Document) bool {
+		return true
+	}, themeClusterThreshold, themeClusterMinSize)
+
+	themes := []map[string]interface{}{}
+	for _, cluster := range clusters {
+		if len(themes) == maxCandidateThemes {
+			break
+		}
+		if len(cluster.Label) == 0 {
+			continue
+		}
+
+		ids := []string{}
+		samples := []string{}
+		for _, doc := range cluster.Documents {
+			ids = append(ids, doc.ID)
+			if len(samples) < 3 {
+				sample := doc.Text
+				if runes := []rune(sample); len(runes) > 200 {
+					sample = string(runes[:200]) + "…"
+				}
+				samples = append(samples, sample)
+			}
+		}
+
+		themes = append(themes, map[string]interface{}{
+			"suggested_tag":  cluster.Label[0],
+			"terms":          cluster.Label,
+			"annotation_ids": ids,
+			"count":          len(ids),
+			"cohesion":       cluster.Cohesion,
+			"samples":        samples,
+		})
+	}
+	return themes, nil
+}
@@ -0,0 +1,556 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"folio/api/auth"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+)
+
+// Tags nest by path: "philosophy/stoicism" is a tag under "philosophy", the same convention
+// Obsidian uses. Renaming or merging a tag carries the tags nested under it along.
+const tagSeparator = "/"
+
+var errTagNotFound = errors.New("tag not found")
+
+// normalizeTag trims the segments of a tag path and rejects empty ones
+func normalizeTag(tag string) (string, error) {
+	segments := strings.Split(tag, tagSeparator)
+	for i, segment := range segments {
+		segments[i] = strings.TrimSpace(segment)
+		if segments[i] == "" {
+			return "", errors.New("tags cannot be empty or have empty segments")
+		}
+	}
+	return strings.Join(segments, tagSeparator), nil
+}
+
+// parentTag returns the tag a nested tag sits under, or "" for a top-level tag
+func parentTag(tag string) string {
+	if i := strings.LastIndex(tag, tagSeparator); i >= 0 {
+		return tag[:i]
+	}
+	return ""
+}
+
+// tagName is the last segment of a tag path
+func tagName(tag string) string {
+	return tag[strings.LastIndex(tag, tagSeparator)+1:]
+}
+
+// isTagWithin reports whether tag is ancestor or is nested anywhere under it
+func isTagWithin(tag, ancestor string) bool {
+	return tag == ancestor || strings.HasPrefix(tag, ancestor+tagSeparator)
+}
+
+// tagMatchSQL is a condition that annotation alias has the tag in placeholder tagArg or one
+// nested under it. userArg is the annotation owner's placeholder, whose user_tags list
+// the nested tags.
+func tagMatchSQL(alias, userArg, tagArg string) string {
+	return `(` + tagArg + `::text = ANY(` + alias + `.tags) OR ` + alias + `.tags && ARRAY(
+		SELECT ut.tag FROM user_tags ut
+		WHERE ut.user_id = ` + userArg + ` AND starts_with(ut.tag, ` + tagArg + `::text || '` + tagSeparator + `')
+	))`
+}
+
+// anyTagMatchSQL is a condition that annotation alias has one of the tags in array
+// placeholder tagsArg, or a tag nested under one of them
+func anyTagMatchSQL(alias, tagsArg string) string {
+	return `EXISTS (
+		SELECT 1 FROM unnest(` + alias + `.tags) AS t(tag), unnest(` + tagsArg + `::text[]) AS wanted(tag)
+		WHERE t.tag = wanted.tag OR starts_with(t.tag, wanted.tag || '` + tagSeparator + `')
+	)`
+}
+
+// tagRename moves a tag, and the tags nested under it, to a new path
+type tagRename struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// apply returns the new name of tag, and whether the rename touched it
+func (r tagRename) apply(tag string) (string, bool) {
+	if !isTagWithin(tag, r.From) {
+		return tag, false
+	}
+	return r.To + tag[len(r.From):], true
+}
+
+// renameTag applies the first of renames that touches tag, so renames sorted most specific
+// first let the most specific one win
+func renameTag(renames []tagRename, tag string) string {
+	for _, rename := range renames {
+		if renamed, ok := rename.apply(tag); ok {
+			return renamed
+		}
+	}
+	return tag
+}
+
+// retagResult reports what a retag changed
+type retagResult struct {
+	Renamed            []tagRename
+	AnnotationsUpdated int
+}
+
+// retag applies renames to all of the user's annotations in one transaction. When renames
+// overlap, the most specific one wins. Tags that end up on an annotation twice are kept
+// once, so renaming onto an existing tag merges them. The user_tags trigger moves the
+// usage counts; retag restores when each tag was last used.
+func (h *AnnotationHandler) retag(ctx context.Context, userID string, renames []tagRename) (*retagResult, error) {
+	sort.SliceStable(renames, func(i, j int) bool {
+		return len(renames[i].From) > len(renames[j].From)
+	})
+
+	tx, err := h.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	// The user's tags, with when each was last used
+	rows, err := tx.Query(ctx, "SELECT tag, last_used_at FROM user_tags WHERE user_id = $1", userID)
+	if err != nil {
+		return nil, err
+	}
+	lastUsed := map[string]time.Time{}
+	for rows.Next() {
+		var tag string
+		var usedAt *time.Time
+		if err := rows.Scan(&tag, &usedAt); err != nil {
+			continue
+		}
+		if usedAt != nil {
+			lastUsed[tag] = *usedAt
+		} else {
+			lastUsed[tag] = time.Time{}
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Work out the new name of every affected tag
+	mapping := map[string]string{}
+	for tag := range lastUsed {
+		if renamed := renameTag(renames, tag); renamed != tag {
+			mapping[tag] = renamed
+		}
+	}
+	if len(mapping) == 0 {
+		return nil, errTagNotFound
+	}
+	affected := make([]string, 0, len(mapping))
+	for tag := range mapping {
+		affected = append(affected, tag)
+	}
+
+	rows, err = tx.Query(ctx, `
+		SELECT id, tags FROM annotations
+		WHERE user_id = $1 AND tags && $2::text[]
+		FOR UPDATE
+	`, userID, affected)
+	if err != nil {
+		return nil, err
+	}
+	type retagged struct {
+		id   string
+		tags []string
+	}
+	updates := []retagged{}
+	for rows.Next() {
+		var id string
+		var tags []string
+		if err := rows.Scan(&id, &tags); err != nil {
+			continue
+		}
+		updates = append(updates, retagged{id, rewriteTags(tags, mapping)})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, update := range updates {
+		if _, err := tx.Exec(ctx, "UPDATE annotations SET tags = $1 WHERE id = $2", update.tags, update.id); err != nil {
+			return nil, err
+		}
+	}
+
+	// The trigger stamps every tag of a rewritten annotation with the annotation's update
+	// time, renamed or not. Restore each from the snapshot, keeping the latest real use
+	// across the tags that were combined.
+	latest := map[string]time.Time{}
+	for _, update := range updates {
+		for _, tag := range update.tags {
+			latest[tag] = lastUsed[tag]
+		}
+	}
+	for from, to := range mapping {
+		for _, tag := range []string{from, to} {
+			if usedAt := lastUsed[tag]; usedAt.After(latest[to]) {
+				latest[to] = usedAt
+			}
+		}
+	}
+	for tag, usedAt := range latest {
+		var restored *time.Time
+		if !usedAt.IsZero() {
+			restored = &usedAt
+		}
+		_, err := tx.Exec(ctx, `
+			UPDATE user_tags SET last_used_at = $3
+			WHERE user_id = $1 AND tag = $2
+		`, userID, tag, restored)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Review settings that pick tags follow the renames, including tags in them that are
+	// only used nested under others
+	var includeTags []string
+	err = tx.QueryRow(ctx, "SELECT include_tags FROM review_settings WHERE user_id = $1 FOR UPDATE", userID).Scan(&includeTags)
+	if err != nil && err != pgx.ErrNoRows {
+		return nil, err
+	}
+	includeMapping := map[string]string{}
+	for _, tag := range includeTags {
+		if renamed := renameTag(renames, tag); renamed != tag {
+			includeMapping[tag] = renamed
+		}
+	}
+	if len(includeMapping) > 0 {
+		_, err := tx.Exec(ctx, `
+			UPDATE review_settings SET include_tags = $2, updated_at = NOW()
+			WHERE user_id = $1
+		`, userID, rewriteTags(includeTags, includeMapping))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	result := &retagResult{AnnotationsUpdated: len(updates)}
+	for _, from := range affected {
+		result.Renamed = append(result.Renamed, tagRename{From: from, To: mapping[from]})
+	}
+	sort.Slice(result.Renamed, func(i, j int) bool {
+		return result.Renamed[i].From < result.Renamed[j].From
+	})
+	return result, nil
+}
+
+// rewriteTags renames tags by mapping, keeping their order and dropping repeats
+func rewriteTags(tags []string, mapping map[string]string) []string {
+	seen := map[string]bool{}
+	rewritten := []string{}
+	for _, tag := range tags {
+		if renamed, ok := mapping[tag]; ok {
+			tag = renamed
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			rewritten = append(rewritten, tag)
+		}
+	}
+	return rewritten
+}
+
+// retagResponse writes the outcome of a retag, or its error
+func retagResponse(c echo.Context, result *retagResult, err error) error {
+	if err == errTagNotFound {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": "tag not found",
+		})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to update tags",
+		})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"renamed":             result.Renamed,
+		"annotations_updated": result.AnnotationsUpdated,
+	})
+}
+
+// RenameTag renames one of the user's tags on all their annotations, along with the tags
+// nested under it. Renaming onto a tag that already exists merges the two.
+func (h *AnnotationHandler) RenameTag(c echo.Context) error {
+	userID := auth.GetUserID(c)
+	if userID == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": "unauthorized",
+		})
+	}
+
+	var req tagRename
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "invalid request body",
+		})
+	}
+	from, err := normalizeTag(req.From)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "from: " + err.Error(),
+		})
+	}
+	to, err := normalizeTag(req.To)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "to: " + err.Error(),
+		})
+	}
+	if isTagWithin(to, from) {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "a tag cannot be renamed to itself or moved under itself",
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 30*time.Second)
+	defer cancel()
+
+	result, err := h.retag(ctx, userID, []tagRename{{From: from, To: to}})
+	return retagResponse(c, result, err)
+}
+
+// MergeTagsRequest merges the Sources tags into Target
+type MergeTagsRequest struct {
+	Sources []string `json:"sources"`
+	Target  string   `json:"target"`
+}
+
+// MergeTags replaces several of the user's tags with one. Tags nested under a source move
+// under the target.
+func (h *AnnotationHandler) MergeTags(c echo.Context) error {
+	userID := auth.GetUserID(c)
+	if userID == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": "unauthorized",
+		})
+	}
+
+	var req MergeTagsRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "invalid request body",
+		})
+	}
+	target, err := normalizeTag(req.Target)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "target: " + err.Error(),
+		})
+	}
+
+	renames := []tagRename{}
+	for _, source := range req.Sources {
+		source, err := normalizeTag(source)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "sources: " + err.Error(),
+			})
+		}
+		if source == target {
+			continue
+		}
+		if isTagWithin(target, source) {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "the target cannot be nested under a source",
+			})
+		}
+		renames = append(renames, tagRename{From: source, To: target})
+	}
+	if len(renames) == 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "at least one source other than the target is required",
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 30*time.Second)
+	defer cancel()
+
+	result, err := h.retag(ctx, userID, renames)
+	return retagResponse(c, result, err)
+}
+
+// NestTagRequest moves Tag under Parent, or to the top level when Parent is empty
+type NestTagRequest struct {
+	Tag    string `json:"tag"`
+	Parent string `json:"parent"`
+}
+
+// NestTag moves one of the user's tags, with the tags nested under it, under another tag,
+// e.g. "stoicism" under "philosophy" becomes "philosophy/stoicism"
+func (h *AnnotationHandler) NestTag(c echo.Context) error {
+	userID := auth.GetUserID(c)
+	if userID == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": "unauthorized",
+		})
+	}
+
+	var req NestTagRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "invalid request body",
+		})
+	}
+	tag, err := normalizeTag(req.Tag)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "tag: " + err.Error(),
+		})
+	}
+
+	name := tagName(tag)
+	to := name
+	if strings.TrimSpace(req.Parent) != "" {
+		parent, err := normalizeTag(req.Parent)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "parent: " + err.Error(),
+			})
+		}
+		if isTagWithin(parent, tag) {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "a tag cannot be nested under itself",
+			})
+		}
+		to = parent + tagSeparator + name
+	}
+	if to == tag {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "the tag is already there",
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 30*time.Second)
+	defer cancel()
+
+	result, err := h.retag(ctx, userID, []tagRename{{From: tag, To: to}})
+	return retagResponse(c, result, err)
+}
+
+// tagNode is a tag in the user's tag tree
+type tagNode struct {
+	Tag  string `json:"tag"`
+	Name string `json:"name"`
+	// UsageCount is how many annotations have exactly this tag
+	UsageCount int `json:"usage_count"`
+	// TotalCount is how many annotations have this tag or one nested under it
+	TotalCount int        `json:"total_count"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	Children   []*tagNode `json:"children"`
+}
+
+// tagTree returns the user's tags as a tree. Parents that were never used on their own
+// appear with a usage count of 0.
+func (h *AnnotationHandler) tagTree(ctx context.Context, userID string) ([]*tagNode, error) {
+	nodes := map[string]*tagNode{}
+	node := func(tag string) *tagNode {
+		if nodes[tag] == nil {
+			nodes[tag] = &tagNode{Tag: tag, Name: tagName(tag), Children: []*tagNode{}}
+		}
+		return nodes[tag]
+	}
+
+	rows, err := h.DB.Query(ctx, "SELECT tag, usage_count, last_used_at FROM user_tags WHERE user_id = $1", userID)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var tag string
+		var usageCount *int
+		var lastUsedAt *time.Time
+		if err := rows.Scan(&tag, &usageCount, &lastUsedAt); err != nil {
+			continue
+		}
+		n := node(tag)
+		if usageCount != nil {
+			n.UsageCount = *usageCount
+		}
+		n.LastUsedAt = lastUsedAt
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Annotations count once towards each ancestor, even with several tags under it
+	rows, err = h.DB.Query(ctx, `
+		SELECT prefix, COUNT(DISTINCT a.id)
+		FROM annotations a
+		CROSS JOIN LATERAL unnest(a.tags) AS t(tag)
+		CROSS JOIN LATERAL generate_series(1, array_length(string_to_array(t.tag, '`+tagSeparator+`'), 1)) AS depth
+		CROSS JOIN LATERAL (
+			SELECT array_to_string((string_to_array(t.tag, '`+tagSeparator+`'))[1:depth], '`+tagSeparator+`') AS prefix
+		) p
+		WHERE a.user_id = $1
+		GROUP BY prefix
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var prefix string
+		var total int
+		if err := rows.Scan(&prefix, &total); err != nil {
+			continue
+		}
+		node(prefix).TotalCount = total
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Link every tag to its parent, creating parents that only exist as a path
+	tags := make([]string, 0, len(nodes))
+	for tag := range nodes {
+		tags = append(tags, tag)
+	}
+	for _, tag := range tags {
+		for child := tag; parentTag(child) != ""; child = parentTag(child) {
+			if _, ok := nodes[parentTag(child)]; ok {
+				break
+			}
+			node(parentTag(child))
+		}
+	}
+	roots := []*tagNode{}
+	for tag, n := range nodes {
+		if parent := parentTag(tag); parent != "" {
+			nodes[parent].Children = append(nodes[parent].Children, n)
+		} else {
+			roots = append(roots, n)
+		}
+	}
+
+	var sortNodes func([]*tagNode)
+	sortNodes = func(list []*tagNode) {
+		sort.Slice(list, func(i, j int) bool {
+			if list[i].TotalCount != list[j].TotalCount {
+				return list[i].TotalCount > list[j].TotalCount
+			}
+			return list[i].Tag < list[j].Tag
+		})
+		for _, n := range list {
+			sortNodes(n.Children)
+		}
+	}
+	sortNodes(roots)
+	return roots, nil
+}
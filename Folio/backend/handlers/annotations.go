@@ -4,7 +4,12 @@ import (
 	"context"
 	"fmt"
 	"folio/api/auth"
+	"folio/api/langdetect"
+	"html"
+	"log"
 	"net/http"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -22,6 +27,10 @@ type CaptureAnnotationRequest struct {
 	PageNumber *int     `json:"page_number"`
 	Tags       []string `json:"tags"`
 	Context    *string  `json:"context"`
+	ParentID   *string  `json:"parent_id"` // a highlight this note is written on
+	Location   *AnnotationLocation `json:"location"`
+	Visibility string   `json:"visibility"` // 'private' (default), 'followers' or 'public'
+	Language   string   `json:"language"`   // ISO 639-1 code; detected from the content when empty
 }
 
 type UpdateAnnotationRequest struct {
@@ -29,6 +38,9 @@ type UpdateAnnotationRequest struct {
 	Content    *string  `json:"content"`
 	PageNumber *int     `json:"page_number"`
 	Tags       []string `json:"tags"`
+	Location   *AnnotationLocation `json:"location"`
+	Visibility *string  `json:"visibility"`
+	Language   *string  `json:"language"` // "" clears it; detected again when only the content changes, if it can be
 }
 
 type RecentBook struct {
@@ -72,38 +84,89 @@ func (h *AnnotationHandler) CaptureAnnotation(c echo.Context) error {
 		})
 	}
 
+	if req.Visibility == "" {
+		req.Visibility = "private"
+	}
+	if !annotationVisibilities[req.Visibility] {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "visibility must be private, followers or public",
+		})
+	}
+
+	location := AnnotationLocation{}
+	if req.Location != nil {
+		location = *req.Location
+	}
+	if err := location.validate(); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// The language picks how the annotation is indexed for search
+	var language *string
+	if req.Language != "" {
+		code, ok := langdetect.Normalize(req.Language)
+		if !ok {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "unsupported language",
+			})
+		}
+		language = &code
+	} else if code := langdetect.Detect(req.Content); code != "" {
+		language = &code
+	}
+
 	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
 	defer cancel()
 
 	var bookID *string
 	isAssociated := false
+	var bookSuggestions []bookSuggestion
+
+	// A note written on a highlight belongs to the highlight's book
+	var parentBookID *string
+	if req.ParentID != nil && *req.ParentID != "" {
+		if req.Type != "note" {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "only notes can be attached to a highlight",
+			})
+		}
+		var err error
+		parentBookID, err = checkAnnotationParent(ctx, h.DB, userID, *req.ParentID)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": err.Error(),
+			})
+		}
+		if req.BookID != nil && *req.BookID != "" && parentBookID != nil && *req.BookID != *parentBookID {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "the note and highlight belong to different books",
+			})
+		}
+	} else {
+		req.ParentID = nil
+	}
 
 	// If book_id is provided explicitly, trust it
 	if req.BookID != nil && *req.BookID != "" {
 		bookID = req.BookID
 		isAssociated = true
+	} else if req.ParentID != nil {
+		bookID = parentBookID
+		isAssociated = bookID != nil
 	} else {
-		// Run auto-detection: find books with status='reading'
-		query := `
-			SELECT book_id 
-			FROM logs 
-			WHERE user_id = $1 AND status = 'reading'
-		`
-		rows, err := h.DB.Query(ctx, query, userID)
-		if err == nil {
-			defer rows.Close()
-			
-			var readingBooks []string
-			for rows.Next() {
-				var bid string
-				if err := rows.Scan(&bid); err == nil {
-					readingBooks = append(readingBooks, bid)
-				}
-			}
-
-			// If exactly one book is being read, auto-associate
-			if len(readingBooks) == 1 {
-				bookID = &readingBooks[0]
+		// Rank the books the user has been reading, viewing and annotating lately, and
+		// associate when one clearly stands out
+		text := req.Content
+		if req.Context != nil {
+			text += "\n" + *req.Context
+		}
+		now := time.Now()
+		if associator, err := h.loadBookAssociator(ctx, userID, now, now); err == nil {
+			bookSuggestions = associator.rank(text, req.PageNumber, now)
+			if best := confidentSuggestion(bookSuggestions); best != nil {
+				bookID = &best.Book.ID
 				isAssociated = true
 			}
 		}
@@ -111,14 +174,16 @@ func (h *AnnotationHandler) CaptureAnnotation(c echo.Context) error {
 
 	// Insert the annotation
 	query := `
-		INSERT INTO annotations (user_id, book_id, type, content, context, page_number, tags, is_associated, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NOW(), NOW())
+		INSERT INTO annotations (user_id, book_id, type, content, context, page_number, tags, is_associated, parent_id,
+		                         chapter, location_percent, cfi, start_offset, end_offset, location_start, location_end, visibility, language, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, NOW(), NOW())
 		RETURNING id, created_at, updated_at
 	`
 
 	var annotationID string
 	var createdAt, updatedAt time.Time
-	err := h.DB.QueryRow(ctx, query, userID, bookID, req.Type, req.Content, req.Context, req.PageNumber, req.Tags, isAssociated).
+	err := h.DB.QueryRow(ctx, query, userID, bookID, req.Type, req.Content, req.Context, req.PageNumber, req.Tags, isAssociated, req.ParentID,
+		location.Chapter, location.Percent, location.CFI, location.StartOffset, location.EndOffset, location.LocationStart, location.LocationEnd, req.Visibility, language).
 		Scan(&annotationID, &createdAt, &updatedAt)
 	
 	if err != nil {
@@ -136,12 +201,32 @@ func (h *AnnotationHandler) CaptureAnnotation(c echo.Context) error {
 		"content":       req.Content,
 		"context":       req.Context,
 		"page_number":   req.PageNumber,
+		"location":      annotationLocationJSON(req.PageNumber, location),
+		"parent_id":     req.ParentID,
+		"visibility":    req.Visibility,
+		"language":      language,
 		"tags":          req.Tags,
 		"is_associated": isAssociated,
 		"created_at":    createdAt,
 		"updated_at":    updatedAt,
 	}
 
+	// Capturing counts as reading activity, so it can extend a streak and earn badges
+	response["badges_awarded"] = evaluateBadges(ctx, h.DB, userID, nil)
+
+	// Books the annotation may belong to, when it was matched automatically
+	if bookSuggestions != nil {
+		response["book_suggestions"] = bookSuggestionsJSON(bookSuggestions, maxBookSuggestions)
+	}
+
+	// Suggest further tags from how the user tagged similar recent annotations
+	suggestedTags, err := h.captureTagSuggestions(ctx, userID, annotationID, req.Content, req.Tags)
+	if err != nil {
+		log.Printf("failed to suggest tags for user %s: %v", userID, err)
+		suggestedTags = []map[string]interface{}{}
+	}
+	response["suggested_tags"] = suggestedTags
+
 	// If associated with a book, fetch and include book details
 	if bookID != nil {
 		bookQuery := `
@@ -239,11 +324,23 @@ func (h *AnnotationHandler) GetBookAnnotations(c echo.Context) error {
 
 	annotationType := c.QueryParam("type") // Optional filter: 'note' or 'highlight'
 
+	// view=reading_order nests notes under their highlights and sorts by position in the book
+	view := c.QueryParam("view")
+	if view != "" && view != "flat" && view != "reading_order" {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "view must be flat or reading_order",
+		})
+	}
+
 	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
 	defer cancel()
 
+	// Both views are sorted by compareAnnotationPositions, which needs the page count to
+	// place pages against percents
 	query := `
-		SELECT id, type, content, context, page_number, tags, is_associated, created_at, updated_at
+		SELECT id, type, content, context, page_number, chapter, location_percent::float8, cfi,
+		       start_offset, end_offset, location_start, location_end, parent_id, tags, visibility, is_associated, created_at, updated_at,
+		       (SELECT page_count FROM books WHERE id = $2)
 		FROM annotations
 		WHERE user_id = $1 AND book_id = $2
 	`
@@ -255,7 +352,7 @@ func (h *AnnotationHandler) GetBookAnnotations(c echo.Context) error {
 		args = append(args, annotationType)
 	}
 
-	query += " ORDER BY page_number ASC NULLS LAST, created_at ASC"
+	query += " ORDER BY created_at ASC"
 
 	rows, err := h.DB.Query(ctx, query, args...)
 	if err != nil {
@@ -265,7 +362,7 @@ func (h *AnnotationHandler) GetBookAnnotations(c echo.Context) error {
 	}
 	defer rows.Close()
 
-	annotations := []map[string]interface{}{}
+	ordered := []readingOrderAnnotation{}
 	for rows.Next() {
 		var annotation struct {
 			ID           string
@@ -273,41 +370,81 @@ func (h *AnnotationHandler) GetBookAnnotations(c echo.Context) error {
 			Content      string
 			Context      *string
 			PageNumber   *int
+			Location     AnnotationLocation
+			ParentID     *string
 			Tags         []string
+			Visibility   string
 			IsAssociated bool
 			CreatedAt    time.Time
 			UpdatedAt    time.Time
 		}
+		var pageCount *int
 
 		err := rows.Scan(
 			&annotation.ID, &annotation.Type, &annotation.Content,
-			&annotation.Context, &annotation.PageNumber, &annotation.Tags,
-			&annotation.IsAssociated, &annotation.CreatedAt, &annotation.UpdatedAt,
+			&annotation.Context, &annotation.PageNumber, &annotation.Location.Chapter,
+			&annotation.Location.Percent, &annotation.Location.CFI, &annotation.Location.StartOffset,
+			&annotation.Location.EndOffset, &annotation.Location.LocationStart,
+			&annotation.Location.LocationEnd, &annotation.ParentID, &annotation.Tags,
+			&annotation.Visibility, &annotation.IsAssociated, &annotation.CreatedAt, &annotation.UpdatedAt,
+			&pageCount,
 		)
 		if err != nil {
 			continue
 		}
 
-		annotations = append(annotations, map[string]interface{}{
-			"id":            annotation.ID,
-			"type":          annotation.Type,
-			"content":       annotation.Content,
-			"context":       annotation.Context,
-			"page_number":   annotation.PageNumber,
-			"tags":          annotation.Tags,
-			"is_associated": annotation.IsAssociated,
-			"created_at":    annotation.CreatedAt,
-			"updated_at":    annotation.UpdatedAt,
+		result := map[string]interface{}{
+			"id":             annotation.ID,
+			"type":           annotation.Type,
+			"content":        annotation.Content,
+			"context":        annotation.Context,
+			"page_number":    annotation.PageNumber,
+			"location_start": annotation.Location.LocationStart,
+			"location_end":   annotation.Location.LocationEnd,
+			"location":       annotationLocationJSON(annotation.PageNumber, annotation.Location),
+			"parent_id":      annotation.ParentID,
+			"visibility":     annotation.Visibility,
+			"tags":           annotation.Tags,
+			"is_associated":  annotation.IsAssociated,
+			"created_at":     annotation.CreatedAt,
+			"updated_at":     annotation.UpdatedAt,
+		}
+		position := annotationPosition{
+			Percent:       annotation.Location.Percent,
+			Page:          annotation.PageNumber,
+			LocationStart: annotation.Location.LocationStart,
+			StartOffset:   annotation.Location.StartOffset,
+			CreatedAt:     annotation.CreatedAt,
+			PageCount:     pageCount,
+		}
+		if annotation.Location.CFI != nil {
+			position.CFI = cfiSteps(*annotation.Location.CFI)
+		}
+		ordered = append(ordered, readingOrderAnnotation{ParentID: annotation.ParentID, Position: position, JSON: result})
+	}
+
+	if view == "reading_order" {
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"annotations": threadInReadingOrder(ordered),
+			"count":       len(ordered),
+			"view":        "reading_order",
 		})
 	}
 
+	sortInReadingOrder(ordered)
+	annotations := make([]map[string]interface{}, 0, len(ordered))
+	for _, annotation := range ordered {
+		annotations = append(annotations, annotation.JSON)
+	}
+
 	return c.JSON(http.StatusOK, map[string]interface{}{
 		"annotations": annotations,
 		"count":       len(annotations),
 	})
 }
 
-// GetUnassociatedAnnotations returns all annotations not linked to a book
+// GetUnassociatedAnnotations returns all annotations not linked to a book. With
+// ?suggestions=true each comes with the books it may belong to.
 func (h *AnnotationHandler) GetUnassociatedAnnotations(c echo.Context) error {
 	userID := auth.GetUserID(c)
 	if userID == "" {
@@ -316,11 +453,18 @@ func (h *AnnotationHandler) GetUnassociatedAnnotations(c echo.Context) error {
 		})
 	}
 
-	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
+	withSuggestions, err := parseOptionalBool(c, "suggestions")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
 	defer cancel()
 
 	query := `
-		SELECT id, type, content, context, page_number, tags, created_at, updated_at
+		SELECT id, type, content, context, page_number, parent_id, tags, created_at, updated_at
 		FROM annotations
 		WHERE user_id = $1 AND is_associated = false
 		ORDER BY created_at DESC
@@ -335,6 +479,7 @@ func (h *AnnotationHandler) GetUnassociatedAnnotations(c echo.Context) error {
 	defer rows.Close()
 
 	annotations := []map[string]interface{}{}
+	unassociated := []unassociatedAnnotation{}
 	for rows.Next() {
 		var annotation struct {
 			ID         string
@@ -342,6 +487,7 @@ func (h *AnnotationHandler) GetUnassociatedAnnotations(c echo.Context) error {
 			Content    string
 			Context    *string
 			PageNumber *int
+			ParentID   *string
 			Tags       []string
 			CreatedAt  time.Time
 			UpdatedAt  time.Time
@@ -349,7 +495,7 @@ func (h *AnnotationHandler) GetUnassociatedAnnotations(c echo.Context) error {
 
 		err := rows.Scan(
 			&annotation.ID, &annotation.Type, &annotation.Content,
-			&annotation.Context, &annotation.PageNumber, &annotation.Tags,
+			&annotation.Context, &annotation.PageNumber, &annotation.ParentID, &annotation.Tags,
 			&annotation.CreatedAt, &annotation.UpdatedAt,
 		)
 		if err != nil {
@@ -362,10 +508,37 @@ func (h *AnnotationHandler) GetUnassociatedAnnotations(c echo.Context) error {
 			"content":     annotation.Content,
 			"context":     annotation.Context,
 			"page_number": annotation.PageNumber,
+			"parent_id":   annotation.ParentID,
 			"tags":        annotation.Tags,
 			"created_at":  annotation.CreatedAt,
 			"updated_at":  annotation.UpdatedAt,
 		})
+		unassociated = append(unassociated, unassociatedAnnotation{
+			ID:         annotation.ID,
+			Content:    annotation.Content,
+			Context:    annotation.Context,
+			PageNumber: annotation.PageNumber,
+			ParentID:   annotation.ParentID,
+			CreatedAt:  annotation.CreatedAt,
+		})
+	}
+
+	if withSuggestions != nil && *withSuggestions && len(unassociated) > 0 {
+		associator, err := h.associatorFor(ctx, userID, unassociated)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": "failed to suggest books",
+			})
+		}
+		for i, annotation := range unassociated {
+			suggestions := associator.rank(annotation.matchText(), annotation.PageNumber, annotation.CreatedAt)
+			var suggestedBookID *string
+			if best := confidentSuggestion(suggestions); best != nil {
+				suggestedBookID = &best.Book.ID
+			}
+			annotations[i]["book_suggestions"] = bookSuggestionsJSON(suggestions, 3)
+			annotations[i]["suggested_book_id"] = suggestedBookID
+		}
 	}
 
 	return c.JSON(http.StatusOK, map[string]interface{}{
@@ -396,6 +569,27 @@ func (h *AnnotationHandler) UpdateAnnotation(c echo.Context) error {
 			"error": "invalid request body",
 		})
 	}
+	if req.Visibility != nil && !annotationVisibilities[*req.Visibility] {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "visibility must be private, followers or public",
+		})
+	}
+	if req.Language != nil && *req.Language != "" {
+		code, ok := langdetect.Normalize(*req.Language)
+		if !ok {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "unsupported language",
+			})
+		}
+		req.Language = &code
+	}
+	if req.Location != nil {
+		if err := req.Location.validate(); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": err.Error(),
+			})
+		}
+	}
 
 	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
 	defer cancel()
@@ -435,6 +629,24 @@ func (h *AnnotationHandler) UpdateAnnotation(c echo.Context) error {
 		argCount++
 	}
 
+	// An explicit language wins; otherwise new content is detected again, keeping the
+	// current language when the new content can't be called
+	if req.Language != nil {
+		var language *string
+		if *req.Language != "" {
+			language = req.Language
+		}
+		updates = append(updates, fmt.Sprintf("language = $%d", argCount))
+		args = append(args, language)
+		argCount++
+	} else if req.Content != nil {
+		if code := langdetect.Detect(*req.Content); code != "" {
+			updates = append(updates, fmt.Sprintf("language = $%d", argCount))
+			args = append(args, code)
+			argCount++
+		}
+	}
+
 	if req.PageNumber != nil {
 		updates = append(updates, fmt.Sprintf("page_number = $%d", argCount))
 		args = append(args, *req.PageNumber)
@@ -447,6 +659,36 @@ func (h *AnnotationHandler) UpdateAnnotation(c echo.Context) error {
 		argCount++
 	}
 
+	if req.Visibility != nil {
+		updates = append(updates, fmt.Sprintf("visibility = $%d", argCount))
+		args = append(args, *req.Visibility)
+		argCount++
+	}
+
+	// Only the location fields that are sent are changed
+	if location := req.Location; location != nil {
+		fields := []struct {
+			column string
+			value  interface{}
+			set    bool
+		}{
+			{"chapter", location.Chapter, location.Chapter != nil},
+			{"location_percent", location.Percent, location.Percent != nil},
+			{"cfi", location.CFI, location.CFI != nil},
+			{"start_offset", location.StartOffset, location.StartOffset != nil},
+			{"end_offset", location.EndOffset, location.EndOffset != nil},
+			{"location_start", location.LocationStart, location.LocationStart != nil},
+			{"location_end", location.LocationEnd, location.LocationEnd != nil},
+		}
+		for _, field := range fields {
+			if field.set {
+				updates = append(updates, fmt.Sprintf("%s = $%d", field.column, argCount))
+				args = append(args, field.value)
+				argCount++
+			}
+		}
+	}
+
 	if len(updates) == 0 {
 		return c.JSON(http.StatusBadRequest, map[string]string{
 			"error": "no fields to update",
@@ -520,7 +762,56 @@ func (h *AnnotationHandler) DeleteAnnotation(c echo.Context) error {
 	})
 }
 
-// SearchAnnotations performs full-text search across user's annotations
+// annotationSearchSorts are the sort keys accepted by SearchAnnotations
+var annotationSearchSorts = map[string]sortOption{
+	"relevance":  {Expr: "ts_rank_cd(a.search_vector, query)", Type: "real", Desc: true},
+	"created_at": {Expr: "a.created_at", Type: "timestamptz", Desc: true},
+}
+
+// Matched words in search headlines are wrapped in these markers by Postgres, and turned
+// into <mark> tags once the rest of the text is HTML-escaped
+const (
+	headlineStart = "\x02"
+	headlineStop  = "\x03"
+)
+
+// headlineOptionsSQL configures ts_headline to return up to three fragments of the content
+const headlineOptionsSQL = `'StartSel="' || chr(2) || '", StopSel="' || chr(3) || '", MaxFragments=3, MaxWords=35, MinWords=12, FragmentDelimiter=" … "'`
+
+// headlineHTML escapes a search headline and marks its matched words
+func headlineHTML(headline string) string {
+	escaped := html.EscapeString(headline)
+	escaped = strings.ReplaceAll(escaped, headlineStart, "<mark>")
+	return strings.ReplaceAll(escaped, headlineStop, "</mark>")
+}
+
+// searchConfigs returns the text search configurations of the supported languages
+func searchConfigs() []string {
+	configs := make([]string, 0, len(langdetect.Languages))
+	for _, config := range langdetect.Languages {
+		configs = append(configs, config)
+	}
+	sort.Strings(configs)
+	return configs
+}
+
+// anySearchQuerySQL parses the search in placeholder arg with each of configs and ORs the
+// results. Unlike a query built per row from the row's language, it is the same for every
+// row, so idx_annotations_search can find the candidates; each row must still match the
+// query for its own language.
+func anySearchQuerySQL(configs []string, arg string) string {
+	queries := make([]string, len(configs))
+	for i, config := range configs {
+		queries[i] = "websearch_to_tsquery('" + config + "'::regconfig, " + arg + ")"
+	}
+	return strings.Join(queries, " || ")
+}
+
+// SearchAnnotations performs full-text search on annotation content and the passages around
+// it. Each annotation is matched with the text search configuration of its language. q
+// accepts web search syntax: "quoted phrases", OR, and -excluded words. Results can be
+// narrowed by lang, tag (including nested tags), book_id, type and a from/to date range,
+// and are sorted by relevance or created_at and paginated with cursors.
 func (h *AnnotationHandler) SearchAnnotations(c echo.Context) error {
 	userID := auth.GetUserID(c)
 	if userID == "" {
@@ -530,28 +821,86 @@ func (h *AnnotationHandler) SearchAnnotations(c echo.Context) error {
 	}
 
 	searchQuery := c.QueryParam("q")
-	if searchQuery == "" {
+	if strings.TrimSpace(searchQuery) == "" {
 		return c.JSON(http.StatusBadRequest, map[string]string{
 			"error": "search query 'q' is required",
 		})
 	}
 
+	page, err := parsePageRequest(c, annotationSearchSorts, "relevance", 50, 100)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	qb := newQueryBuilder(userID, searchQuery)
+	configs := searchConfigs()
+	if lang := c.QueryParam("lang"); lang != "" {
+		code, ok := langdetect.Normalize(lang)
+		if !ok {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "unsupported language",
+			})
+		}
+		// Annotations without a language are indexed as English
+		qb.where("COALESCE(a.language, 'en') = " + qb.arg(code))
+		configs = []string{langdetect.Languages[code]}
+	}
+	if tag := c.QueryParam("tag"); tag != "" {
+		qb.where(tagMatchSQL("a", "$1", qb.arg(tag)))
+	}
+	if bookID := c.QueryParam("book_id"); bookID != "" {
+		qb.where("a.book_id = " + qb.arg(bookID))
+	}
+	if annotationType := c.QueryParam("type"); annotationType != "" {
+		if annotationType != "note" && annotationType != "highlight" {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "type must be 'note' or 'highlight'",
+			})
+		}
+		qb.where("a.type = " + qb.arg(annotationType))
+	}
+	from, err := parseOptionalDate(c, "from")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+	if from != nil {
+		qb.where("a.created_at >= " + qb.arg(*from))
+	}
+	to, err := parseOptionalDate(c, "to")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+	if to != nil {
+		// to includes the whole day
+		qb.where("a.created_at < " + qb.arg(to.AddDate(0, 0, 1)))
+	}
+	page.applyCursor(qb, "a.id")
+
 	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
 	defer cancel()
 
 	query := `
-		SELECT a.id, a.type, a.content, a.context, a.page_number, a.tags, 
-		       a.book_id, a.is_associated, a.created_at,
-		       b.title as book_title, b.cover_url as book_cover
+		SELECT a.id, a.type, a.content, a.context, a.page_number, a.tags,
+		       a.book_id, a.is_associated, a.created_at, a.language,
+		       b.title as book_title, b.cover_url as book_cover,
+		       ts_headline(annotation_search_config(a.language), a.content, query, ` + headlineOptionsSQL + `),
+		       ts_rank_cd(a.search_vector, query)::float8,
+		       ` + page.cursorColumn() + `
 		FROM annotations a
+		CROSS JOIN LATERAL websearch_to_tsquery(annotation_search_config(a.language), $2) AS query
 		LEFT JOIN books b ON a.book_id = b.id
-		WHERE a.user_id = $1 
-		  AND to_tsvector('english', a.content) @@ plainto_tsquery('english', $2)
-		ORDER BY a.created_at DESC
-		LIMIT 50
-	`
+		WHERE a.user_id = $1
+		  AND a.search_vector @@ (` + anySearchQuerySQL(configs, "$2") + `)
+		  AND a.search_vector @@ query` + qb.and() + `
+		` + page.orderBy(qb, "a.id")
 
-	rows, err := h.DB.Query(ctx, query, userID, searchQuery)
+	rows, err := h.DB.Query(ctx, query, qb.args...)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{
 			"error": "failed to search annotations",
@@ -560,6 +909,7 @@ func (h *AnnotationHandler) SearchAnnotations(c echo.Context) error {
 	defer rows.Close()
 
 	results := []map[string]interface{}{}
+	cursors := []pageCursor{}
 	for rows.Next() {
 		var annotation struct {
 			ID           string
@@ -571,19 +921,25 @@ func (h *AnnotationHandler) SearchAnnotations(c echo.Context) error {
 			BookID       *string
 			IsAssociated bool
 			CreatedAt    time.Time
+			Language     *string
 			BookTitle    *string
 			BookCover    *string
+			Headline     string
+			Rank         float64
 		}
+		var cursor pageCursor
 
 		err := rows.Scan(
 			&annotation.ID, &annotation.Type, &annotation.Content,
 			&annotation.Context, &annotation.PageNumber, &annotation.Tags,
-			&annotation.BookID, &annotation.IsAssociated, &annotation.CreatedAt,
+			&annotation.BookID, &annotation.IsAssociated, &annotation.CreatedAt, &annotation.Language,
 			&annotation.BookTitle, &annotation.BookCover,
+			&annotation.Headline, &annotation.Rank, &cursor.Value,
 		)
 		if err != nil {
 			continue
 		}
+		cursor.ID = annotation.ID
 
 		result := map[string]interface{}{
 			"id":            annotation.ID,
@@ -593,6 +949,9 @@ func (h *AnnotationHandler) SearchAnnotations(c echo.Context) error {
 			"page_number":   annotation.PageNumber,
 			"tags":          annotation.Tags,
 			"is_associated": annotation.IsAssociated,
+			"language":      annotation.Language,
+			"headline":      headlineHTML(annotation.Headline),
+			"rank":          annotation.Rank,
 			"created_at":    annotation.CreatedAt,
 		}
 
@@ -605,16 +964,23 @@ func (h *AnnotationHandler) SearchAnnotations(c echo.Context) error {
 		}
 
 		results = append(results, result)
+		cursors = append(cursors, cursor)
 	}
 
+	results, nextCursor := page.trim(results, cursors)
+
 	return c.JSON(http.StatusOK, map[string]interface{}{
-		"results": results,
-		"count":   len(results),
-		"query":   searchQuery,
+		"results":     results,
+		"count":       len(results),
+		"query":       searchQuery,
+		"next_cursor": nextCursor,
+		"has_more":    nextCursor != nil,
 	})
 }
 
-// GetUserThemes returns the user's most frequently used tags
+// GetUserThemes returns the user's most frequently used tags. view=tree returns all of
+// them nested by path instead, with counts that include the tags under each.
+// candidates=true also clusters recent untagged annotations into candidate themes.
 func (h *AnnotationHandler) GetUserThemes(c echo.Context) error {
 	userID := auth.GetUserID(c)
 	if userID == "" {
@@ -623,9 +989,49 @@ func (h *AnnotationHandler) GetUserThemes(c echo.Context) error {
 		})
 	}
 
+	view := c.QueryParam("view")
+	if view != "" && view != "flat" && view != "tree" {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "view must be flat or tree",
+		})
+	}
+
+	includeCandidates, err := parseOptionalBool(c, "candidates")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
 	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
 	defer cancel()
 
+	// Untagged annotations that read alike, offered as themes to adopt
+	candidates := []map[string]interface{}{}
+	if includeCandidates != nil && *includeCandidates {
+		candidates, err = h.candidateThemes(ctx, userID)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": "failed to find candidate themes",
+			})
+		}
+	}
+
+	if view == "tree" {
+		tree, err := h.tagTree(ctx, userID)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": "failed to fetch user themes",
+			})
+		}
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"view":             "tree",
+			"themes":           tree,
+			"count":            len(tree),
+			"candidate_themes": candidates,
+		})
+	}
+
 	query := `
 		SELECT tag, usage_count, last_used_at
 		FROM user_tags
@@ -655,20 +1061,28 @@ func (h *AnnotationHandler) GetUserThemes(c echo.Context) error {
 			continue
 		}
 
+		var parent *string
+		if p := parentTag(theme.Tag); p != "" {
+			parent = &p
+		}
+
 		themes = append(themes, map[string]interface{}{
 			"tag":         theme.Tag,
+			"parent":      parent,
 			"usage_count": theme.UsageCount,
 			"last_used_at": theme.LastUsedAt,
 		})
 	}
 
 	return c.JSON(http.StatusOK, map[string]interface{}{
-		"themes": themes,
-		"count":  len(themes),
+		"themes":           themes,
+		"count":            len(themes),
+		"candidate_themes": candidates,
 	})
 }
 
-// GetAnnotationThread returns all annotations for a specific tag in chronological order
+// GetAnnotationThread returns all annotations for a specific tag in chronological order,
+// including those with tags nested under it unless nested=false
 func (h *AnnotationHandler) GetAnnotationThread(c echo.Context) error {
 	userID := auth.GetUserID(c)
 	if userID == "" {
@@ -684,9 +1098,21 @@ func (h *AnnotationHandler) GetAnnotationThread(c echo.Context) error {
 		})
 	}
 
+	nested, err := parseOptionalBool(c, "nested")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
 	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
 	defer cancel()
 
+	tagCondition := "$2 = ANY(a.tags)"
+	if nested == nil || *nested {
+		tagCondition = tagMatchSQL("a", "$1", "$2")
+	}
+
 	query := `
 		SELECT a.id, a.type, a.content, a.context, a.page_number, a.tags, 
 		       a.created_at, a.updated_at,
@@ -694,7 +1120,7 @@ func (h *AnnotationHandler) GetAnnotationThread(c echo.Context) error {
 		       b.cover_url as book_cover
 		FROM annotations a
 		LEFT JOIN books b ON a.book_id = b.id
-		WHERE a.user_id = $1 AND $2 = ANY(a.tags)
+		WHERE a.user_id = $1 AND ` + tagCondition + `
 		ORDER BY a.created_at ASC
 	`
 
@@ -0,0 +1,248 @@
+package handlers
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// BadgeRule describes a badge and the condition a user must meet to earn it
+type BadgeRule struct {
+	Badge       string `json:"badge"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	check       func(ctx context.Context, db *pgxpool.Pool, userID string) (bool, error)
+}
+
+// sqlBadgeCheck builds a rule check from a query that returns a single boolean for $1 = user_id
+func sqlBadgeCheck(query string) func(ctx context.Context, db *pgxpool.Pool, userID string) (bool, error) {
+	return func(ctx context.Context, db *pgxpool.Pool, userID string) (bool, error) {
+		var earned bool
+		err := db.QueryRow(ctx, query, userID).Scan(&earned)
+		return earned, err
+	}
+}
+
+// streakBadgeCheck builds a rule check that passes once the user's longest streak reaches days
+func streakBadgeCheck(days int) func(ctx context.Context, db *pgxpool.Pool, userID string) (bool, error) {
+	return func(ctx context.Context, db *pgxpool.Pool, userID string) (bool, error) {
+		streak, err := getReadingStreak(ctx, db, userID)
+		if err != nil {
+			return false, err
+		}
+		return streak.Longest >= days, nil
+	}
+}
+
+// badgeRules is the rules engine evaluated whenever a log is created or finished
+var badgeRules = []BadgeRule{
+	{
+		Badge:       "first_log",
+		Name:        "First Page",
+		Description: "Logged your first book",
+		check:       sqlBadgeCheck(`SELECT EXISTS(SELECT 1 FROM logs WHERE user_id = $1)`),
+	},
+	{
+		Badge:       "first_finish",
+		Name:        "The End",
+		Description: "Finished your first book",
+		check:       sqlBadgeCheck(`SELECT EXISTS(SELECT 1 FROM logs WHERE user_id = $1 AND status = 'read')`),
+	},
+	{
+		Badge:       "bookworm",
+		Name:        "Bookworm",
+		Description: "Finished 10 books",
+		check:       sqlBadgeCheck(`SELECT COUNT(*) >= 10 FROM logs WHERE user_id = $1 AND status = 'read'`),
+	},
+	{
+		Badge:       "centurion",
+		Name:        "Centurion",
+		Description: "Finished 100 books",
+		check:       sqlBadgeCheck(`SELECT COUNT(*) >= 100 FROM logs WHERE user_id = $1 AND status = 'read'`),
+	},
+	{
+		Badge:       "critic",
+		Name:        "Critic",
+		Description: "Wrote 10 reviews",
+		check: sqlBadgeCheck(`
+			SELECT COUNT(*) >= 10 FROM logs
+			WHERE user_id = $1 AND review IS NOT NULL AND review != ''
+		`),
+	},
+	{
+		Badge:       "genre_explorer",
+		Name:        "Genre Explorer",
+		Description: "Finished books in 5 different categories",
+		check: sqlBadgeCheck(`
+			SELECT COUNT(DISTINCT category) >= 5
+			FROM logs l
+			JOIN books b ON l.book_id = b.id,
+			     unnest(b.categories) AS category
+			WHERE l.user_id = $1 AND l.status = 'read'
+		`),
+	},
+	{
+		Badge:       "annotator",
+		Name:        "Marginalia",
+		Description: "Captured 25 annotations",
+		check:       sqlBadgeCheck(`SELECT COUNT(*) >= 25 FROM annotations WHERE user_id = $1`),
+	},
+	{
+		Badge:       "streak_7",
+		Name:        "Week Streak",
+		Description: "Read for 7 days in a row",
+		check:       streakBadgeCheck(7),
+	},
+	{
+		Badge:       "streak_30",
+		Name:        "Month Streak",
+		Description: "Read for 30 days in a row",
+		check:       streakBadgeCheck(30),
+	},
+	{
+		Badge:       "challenge_complete",
+		Name:        "Challenger",
+		Description: "Completed a reading challenge",
+		check: sqlBadgeCheck(`
+			SELECT EXISTS(SELECT 1 FROM challenge_participants WHERE user_id = $1 AND completed_at IS NOT NULL)
+		`),
+	},
+}
+
+// findBadgeRule looks up a rule by badge code
+func findBadgeRule(badge string) *BadgeRule {
+	for i := range badgeRules {
+		if badgeRules[i].Badge == badge {
+			return &badgeRules[i]
+		}
+	}
+	return nil
+}
+
+// evaluateBadges runs every rule the user hasn't earned yet and awards the ones that now pass.
+// Failures are skipped so that badge evaluation never blocks the triggering action.
+func evaluateBadges(ctx context.Context, db *pgxpool.Pool, userID string, logID *string) []map[string]interface{} {
+	awarded := []map[string]interface{}{}
+
+	earned := make(map[string]bool)
+	rows, err := db.Query(ctx, "SELECT badge FROM user_badges WHERE user_id = $1", userID)
+	if err != nil {
+		return awarded
+	}
+	for rows.Next() {
+		var badge string
+		if err := rows.Scan(&badge); err == nil {
+			earned[badge] = true
+		}
+	}
+	rows.Close()
+
+	for _, rule := range badgeRules {
+		if earned[rule.Badge] {
+			continue
+		}
+
+		ok, err := rule.check(ctx, db, userID)
+		if err != nil || !ok {
+			continue
+		}
+
+		var awardedAt time.Time
+		err = db.QueryRow(ctx, `
+			INSERT INTO user_badges (user_id, badge, log_id, awarded_at)
+			VALUES ($1, $2, $3, NOW())
+			ON CONFLICT (user_id, badge) DO NOTHING
+			RETURNING awarded_at
+		`, userID, rule.Badge, logID).Scan(&awardedAt)
+		if err != nil {
+			continue
+		}
+
+		awarded = append(awarded, map[string]interface{}{
+			"badge":       rule.Badge,
+			"name":        rule.Name,
+			"description": rule.Description,
+			"awarded_at":  awardedAt,
+		})
+	}
+
+	return awarded
+}
+
+// ReadingStreak summarises consecutive days of reading activity
+type ReadingStreak struct {
+	Current      int        `json:"current"`
+	Longest      int        `json:"longest"`
+	ActiveToday  bool       `json:"active_today"`
+	LastActiveOn *time.Time `json:"last_active_on"`
+}
+
+// getReadingStreak computes the user's streak from their reading days. Any day a log was
+// created or edited, or an annotation captured, is recorded as one by database triggers.
+func getReadingStreak(ctx context.Context, db *pgxpool.Pool, userID string) (ReadingStreak, error) {
+	query := `
+		SELECT day FROM reading_days
+		WHERE user_id = $1
+		ORDER BY day DESC
+	`
+
+	rows, err := db.Query(ctx, query, userID)
+	if err != nil {
+		return ReadingStreak{}, err
+	}
+	defer rows.Close()
+
+	days := []time.Time{}
+	for rows.Next() {
+		var day time.Time
+		if err := rows.Scan(&day); err == nil {
+			days = append(days, day)
+		}
+	}
+
+	return computeStreak(days, time.Now().UTC()), rows.Err()
+}
+
+// computeStreak walks activity days (sorted newest first) to find the current and longest runs.
+// The current streak stays alive until a full day has been missed, so yesterday's activity still counts.
+func computeStreak(days []time.Time, now time.Time) ReadingStreak {
+	streak := ReadingStreak{}
+	if len(days) == 0 {
+		return streak
+	}
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	latest := time.Date(days[0].Year(), days[0].Month(), days[0].Day(), 0, 0, 0, 0, time.UTC)
+	streak.LastActiveOn = &latest
+	streak.ActiveToday = latest.Equal(today)
+
+	run := 1
+	longest := 1
+	currentOpen := latest.Equal(today) || latest.Equal(today.AddDate(0, 0, -1))
+	if currentOpen {
+		streak.Current = 1
+	}
+
+	for i := 1; i < len(days); i++ {
+		prev := time.Date(days[i-1].Year(), days[i-1].Month(), days[i-1].Day(), 0, 0, 0, 0, time.UTC)
+		day := time.Date(days[i].Year(), days[i].Month(), days[i].Day(), 0, 0, 0, 0, time.UTC)
+
+		if prev.AddDate(0, 0, -1).Equal(day) {
+			run++
+		} else {
+			currentOpen = false
+			run = 1
+		}
+
+		if currentOpen {
+			streak.Current = run
+		}
+		if run > longest {
+			longest = run
+		}
+	}
+
+	streak.Longest = longest
+	return streak
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestComputeStreak(t *testing.T) {
+	now := time.Date(2024, time.March, 10, 15, 30, 0, 0, time.UTC)
+	// daysAgo lists activity days counted back from now, newest first as the query returns them
+	daysAgo := func(offsets ...int) []time.Time {
+		days := make([]time.Time, len(offsets))
+		for i, offset := range offsets {
+			days[i] = time.Date(2024, time.March, 10-offset, 0, 0, 0, 0, time.UTC)
+		}
+		return days
+	}
+
+	tests := []struct {
+		name        string
+		days        []time.Time
+		current     int
+		longest     int
+		activeToday bool
+	}{
+		{"no activity", nil, 0, 0, false},
+		{"today only", daysAgo(0), 1, 1, true},
+		{"yesterday keeps the streak alive", daysAgo(1), 1, 1, false},
+		{"two days ago breaks it", daysAgo(2), 0, 1, false},
+		{"run ending today", daysAgo(0, 1, 2, 3), 4, 4, true},
+		{"run ending yesterday", daysAgo(1, 2, 3), 3, 3, false},
+		{"gap ends the current run", daysAgo(0, 1, 3, 4, 5), 2, 3, true},
+		{"longest run in the past", daysAgo(0, 5, 6, 7, 8, 9), 1, 5, true},
+		{"old runs only", daysAgo(4, 5, 6, 20, 21), 0, 3, false},
+		{"times within a day", []time.Time{now.Add(-time.Hour), now.Add(-25 * time.Hour)}, 2, 2, true},
+		{"across a month boundary", daysAgo(9, 10, 11), 0, 3, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := computeStreak(tt.days, now)
+			if got.Current != tt.current || got.Longest != tt.longest || got.ActiveToday != tt.activeToday {
+				t.Errorf("computeStreak() = current %d, longest %d, active today %v; want %d, %d, %v",
+					got.Current, got.Longest, got.ActiveToday, tt.current, tt.longest, tt.activeToday)
+			}
+			if len(tt.days) == 0 {
+				if got.LastActiveOn != nil {
+					t.Errorf("LastActiveOn = %v, want nil", got.LastActiveOn)
+				}
+				return
+			}
+			latest := tt.days[0]
+			want := time.Date(latest.Year(), latest.Month(), latest.Day(), 0, 0, 0, 0, time.UTC)
+			if got.LastActiveOn == nil || !got.LastActiveOn.Equal(want) {
+				t.Errorf("LastActiveOn = %v, want %v", got.LastActiveOn, want)
+			}
+		})
+	}
+}
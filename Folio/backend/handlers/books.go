@@ -4,7 +4,9 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"folio/api/auth"
 	"io"
+	"log"
 	"net/http"
 	"net/url"
 	"time"
@@ -120,8 +122,15 @@ func (h *BookHandler) GetBook(c echo.Context) error {
 		&book.Language, &book.Publisher, &book.Rating, &book.RatingsCount,
 	)
 
+	// Remember that a signed-in reader looked at the book, which helps place annotations
+	// they capture afterwards
+	userID := auth.GetUserID(c)
+
 	if err == nil {
 		// Book found in cache
+		if userID != "" {
+			h.recordBookView(ctx, userID, bookID)
+		}
 		return c.JSON(http.StatusOK, book)
 	}
 
@@ -133,12 +142,30 @@ func (h *BookHandler) GetBook(c echo.Context) error {
 		})
 	}
 
-	// Cache the book for future requests
-	go h.cacheGoogleBook(ctx, bookData)
+	// Cache the book for future requests; views can only be recorded for cached books
+	if err := h.cacheBook(ctx, bookData); err != nil {
+		log.Printf("failed to cache book %s: %v", bookID, err)
+	} else if userID != "" {
+		h.recordBookView(ctx, userID, bookID)
+	}
 
 	return c.JSON(http.StatusOK, bookData)
 }
 
+// recordBookView counts a view of a book by the user; failures are logged, since the
+// view only improves later annotation matching
+func (h *BookHandler) recordBookView(ctx context.Context, userID, bookID string) {
+	_, err := h.DB.Exec(ctx, `
+		INSERT INTO book_views (user_id, book_id)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id, book_id)
+		DO UPDATE SET view_count = book_views.view_count + 1, viewed_at = NOW()
+	`, userID, bookID)
+	if err != nil {
+		log.Printf("failed to record view of book %s for user %s: %v", bookID, userID, err)
+	}
+}
+
 // searchLocalBooks searches for books in our local database
 func (h *BookHandler) searchLocalBooks(ctx context.Context, query string) ([]BookSearchResult, error) {
 	searchQuery := "%" + query + "%"
@@ -238,13 +265,6 @@ func (h *BookHandler) cacheBooks(ctx context.Context, books []BookSearchResult)
 	}
 }
 
-// cacheGoogleBook stores a single Google Book in our local database
-func (h *BookHandler) cacheGoogleBook(ctx context.Context, bookData interface{}) {
-	// This would need to be implemented based on the structure of bookData
-	// For now, we'll implement a basic version
-	fmt.Printf("Caching Google Book: %v\n", bookData)
-}
-
 func (h *BookHandler) searchGoogleBooks(query string) ([]BookSearchResult, error) {
 	apiKey := getEnv("GOOGLE_BOOKS_API_KEY", "")
 	baseURL := "https://www.googleapis.com/books/v1/volumes"
@@ -350,7 +370,14 @@ func (h *BookHandler) cacheBook(ctx context.Context, bookData map[string]interfa
 	return err
 }
 
-// GetBookReviews gets public reviews for a book
+// reviewSorts are the sort keys accepted by GetBookReviews
+var reviewSorts = map[string]sortOption{
+	"created_at":  {Expr: "l.created_at", Type: "timestamptz", Desc: true},
+	"rating":      {Expr: "COALESCE(l.rating, 0)", Type: "numeric", Desc: true},
+	"likes_count": {Expr: "COALESCE(l.likes_count, 0)", Type: "integer", Desc: true},
+}
+
+// GetBookReviews gets a page of public reviews for a book
 func (h *BookHandler) GetBookReviews(c echo.Context) error {
 	bookID := c.Param("id")
 	if bookID == "" {
@@ -359,20 +386,67 @@ func (h *BookHandler) GetBookReviews(c echo.Context) error {
 		})
 	}
 
+	page, err := parsePageRequest(c, reviewSorts, "created_at", 50, 100)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	qb := newQueryBuilder(bookID)
+
+	minRating, err := parseOptionalFloat(c, "min_rating")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+	if minRating != nil {
+		qb.where("l.rating >= " + qb.arg(*minRating))
+	}
+
+	maxRating, err := parseOptionalFloat(c, "max_rating")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+	if maxRating != nil {
+		qb.where("l.rating <= " + qb.arg(*maxRating))
+	}
+
+	hasReview, err := parseOptionalBool(c, "has_review")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+	if hasReview != nil {
+		if *hasReview {
+			qb.where("(l.review IS NOT NULL AND l.review != '')")
+		} else {
+			qb.where("(l.review IS NULL OR l.review = '')")
+		}
+	}
+
+	page.applyCursor(qb, "l.id")
+
 	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
 	defer cancel()
 
 	query := `
-		SELECT l.id, l.user_id, l.status, l.rating, l.review, l.notes, l.created_at, l.updated_at,
-		       u.username, u.name, u.picture
+		SELECT l.id, l.user_id, l.status, l.rating,
+		       l.rating_plot, l.rating_prose, l.rating_characters, l.rating_pacing, l.review,
+		       COALESCE(l.review_format, 'text'), l.review_html, COALESCE(l.review_has_spoilers, false),
+		       COALESCE(l.spoiler_flag, false), l.notes, l.created_at, l.updated_at,
+		       u.username, u.name, u.picture,
+		       ` + page.cursorColumn() + `
 		FROM logs l
 		JOIN users u ON l.user_id = u.id
-		WHERE l.book_id = $1 AND l.is_public = true
-		ORDER BY l.created_at DESC
-		LIMIT 50
-	`
+		WHERE l.book_id = $1 AND l.is_public = true` + qb.and() + `
+		` + page.orderBy(qb, "l.id")
 
-	rows, err := h.DB.Query(ctx, query, bookID)
+	rows, err := h.DB.Query(ctx, query, qb.args...)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{
 			"error": "failed to fetch reviews",
@@ -381,38 +455,54 @@ func (h *BookHandler) GetBookReviews(c echo.Context) error {
 	defer rows.Close()
 
 	reviews := []map[string]interface{}{}
+	cursors := []pageCursor{}
 	for rows.Next() {
 		var review struct {
-			ID        string
-			UserID    string
-			Status    string
-			Rating    *int
-			Review    *string
-			Notes     *string
-			CreatedAt time.Time
-			UpdatedAt time.Time
-			Username  string
-			Name      string
-			Picture   *string
+			ID           string
+			UserID       string
+			Status       string
+			Rating       *float64
+			Dimensions   RatingDimensions
+			Review       *string
+			ReviewFormat string
+			ReviewHTML   *string
+			HasSpoilers  bool
+			SpoilerFlag  bool
+			Notes        *string
+			CreatedAt    time.Time
+			UpdatedAt    time.Time
+			Username     string
+			Name         string
+			Picture      *string
+			CursorValue  string
 		}
 
 		err := rows.Scan(
 			&review.ID, &review.UserID, &review.Status, &review.Rating,
-			&review.Review, &review.Notes, &review.CreatedAt, &review.UpdatedAt,
+			&review.Dimensions.Plot, &review.Dimensions.Prose, &review.Dimensions.Characters, &review.Dimensions.Pacing,
+			&review.Review, &review.ReviewFormat, &review.ReviewHTML, &review.HasSpoilers,
+			&review.SpoilerFlag, &review.Notes, &review.CreatedAt, &review.UpdatedAt,
 			&review.Username, &review.Name, &review.Picture,
+			&review.CursorValue,
 		)
 		if err != nil {
 			continue
 		}
 
+		cursors = append(cursors, pageCursor{Value: review.CursorValue, ID: review.ID})
 		reviews = append(reviews, map[string]interface{}{
-			"id":         review.ID,
-			"status":     review.Status,
-			"rating":     review.Rating,
-			"review":     review.Review,
-			"notes":      review.Notes,
-			"created_at": review.CreatedAt,
-			"updated_at": review.UpdatedAt,
+			"id":                review.ID,
+			"status":            review.Status,
+			"rating":            review.Rating,
+			"rating_dimensions": review.Dimensions.toMap(),
+			"review":            review.Review,
+			"review_format":     review.ReviewFormat,
+			"review_html":       reviewHTML(review.ReviewHTML, review.Review),
+			"has_spoilers":      review.HasSpoilers,
+			"spoiler_flag":      review.SpoilerFlag,
+			"notes":             review.Notes,
+			"created_at":        review.CreatedAt,
+			"updated_at":        review.UpdatedAt,
 			"user": map[string]interface{}{
 				"id":       review.UserID,
 				"username": review.Username,
@@ -422,9 +512,13 @@ func (h *BookHandler) GetBookReviews(c echo.Context) error {
 		})
 	}
 
+	reviews, nextCursor := page.trim(reviews, cursors)
+
 	return c.JSON(http.StatusOK, map[string]interface{}{
-		"reviews": reviews,
-		"count":   len(reviews),
+		"reviews":     reviews,
+		"count":       len(reviews),
+		"next_cursor": nextCursor,
+		"has_more":    nextCursor != nil,
 	})
 }
 
@@ -447,7 +541,11 @@ func (h *BookHandler) GetBookStats(c echo.Context) error {
 			COUNT(CASE WHEN status = 'read' THEN 1 END) as read,
 			COUNT(CASE WHEN status = 'dnf' THEN 1 END) as dnf,
 			AVG(CASE WHEN rating IS NOT NULL THEN rating END) as avg_rating,
-			COUNT(CASE WHEN rating IS NOT NULL THEN 1 END) as rating_count
+			COUNT(CASE WHEN rating IS NOT NULL THEN 1 END) as rating_count,
+			AVG(rating_plot), COUNT(rating_plot),
+			AVG(rating_prose), COUNT(rating_prose),
+			AVG(rating_characters), COUNT(rating_characters),
+			AVG(rating_pacing), COUNT(rating_pacing)
 		FROM logs
 		WHERE book_id = $1 AND is_public = true
 	`
@@ -459,11 +557,17 @@ func (h *BookHandler) GetBookStats(c echo.Context) error {
 		DNF         int
 		AvgRating   *float64
 		RatingCount int
+		DimAvgs     [4]*float64
+		DimCounts   [4]int
 	}
 
 	err := h.DB.QueryRow(ctx, query, bookID).Scan(
 		&stats.WantToRead, &stats.Reading, &stats.Read, &stats.DNF,
 		&stats.AvgRating, &stats.RatingCount,
+		&stats.DimAvgs[0], &stats.DimCounts[0],
+		&stats.DimAvgs[1], &stats.DimCounts[1],
+		&stats.DimAvgs[2], &stats.DimCounts[2],
+		&stats.DimAvgs[3], &stats.DimCounts[3],
 	)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{
@@ -471,6 +575,36 @@ func (h *BookHandler) GetBookStats(c echo.Context) error {
 		})
 	}
 
+	dimensions := map[string]interface{}{}
+	for i, dimension := range ratingDimensions {
+		dimensions[dimension] = map[string]interface{}{
+			"avg_rating":   stats.DimAvgs[i],
+			"rating_count": stats.DimCounts[i],
+		}
+	}
+
+	// Half-star histogram; empty buckets are reported as zero
+	histogram := map[string]int{}
+	for _, bucket := range ratingHistogramBuckets {
+		histogram[bucket] = 0
+	}
+	rows, err := h.DB.Query(ctx, `
+		SELECT rating::text, COUNT(*)
+		FROM logs
+		WHERE book_id = $1 AND is_public = true AND rating IS NOT NULL
+		GROUP BY rating
+	`, bookID)
+	if err == nil {
+		defer rows.Close()
+		for rows.Next() {
+			var bucket string
+			var count int
+			if err := rows.Scan(&bucket, &count); err == nil {
+				histogram[bucket] = count
+			}
+		}
+	}
+
 	return c.JSON(http.StatusOK, map[string]interface{}{
 		"want_to_read": stats.WantToRead,
 		"reading":      stats.Reading,
@@ -478,6 +612,8 @@ func (h *BookHandler) GetBookStats(c echo.Context) error {
 		"dnf":          stats.DNF,
 		"avg_rating":   stats.AvgRating,
 		"rating_count": stats.RatingCount,
+		"histogram":    histogram,
+		"dimensions":   dimensions,
 	})
 }
 
@@ -0,0 +1,637 @@
+package handlers
+
+import (
+	"context"
+	"folio/api/auth"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/labstack/echo/v4"
+)
+
+type ChallengeHandler struct {
+	DB *pgxpool.Pool
+}
+
+type CreateChallengeRequest struct {
+	Name        string  `json:"name"`
+	Description *string `json:"description"`
+	TargetCount int     `json:"target_count"`
+	ListID      *string `json:"list_id"`
+	Category    *string `json:"category"`
+	StartsOn    string  `json:"starts_on"`
+	EndsOn      string  `json:"ends_on"`
+	IsPublic    *bool   `json:"is_public"`
+}
+
+// challengeProgressSQL counts the distinct books a participant (cp.user_id) has finished
+// that satisfy a challenge's (c) date window, list and category constraints
+const challengeProgressSQL = `
+	(SELECT COUNT(DISTINCT l.book_id)
+	 FROM logs l
+	 JOIN books b ON l.book_id = b.id
+	 WHERE l.user_id = cp.user_id
+	   AND l.status = 'read'` + challengeProgressFilterSQL + `)
+`
+
+// publicChallengeProgressSQL is challengeProgressSQL restricted to public logs, for
+// anything shown to other participants
+const publicChallengeProgressSQL = `
+	(SELECT COUNT(DISTINCT l.book_id)
+	 FROM logs l
+	 JOIN books b ON l.book_id = b.id
+	 WHERE l.user_id = cp.user_id
+	   AND l.status = 'read'
+	   AND l.is_public` + challengeProgressFilterSQL + `)
+`
+
+// challengeProgressFilterSQL holds the date window, list and category constraints shared by
+// both progress counts
+const challengeProgressFilterSQL = `
+	   AND COALESCE(l.finish_date, l.updated_at::date) BETWEEN c.starts_on AND c.ends_on
+	   AND (c.list_id IS NULL OR EXISTS (
+	       SELECT 1 FROM list_items li WHERE li.list_id = c.list_id AND li.book_id = l.book_id
+	   ))
+	   AND (c.category IS NULL OR c.category = ANY(b.categories))`
+
+// CreateChallenge creates a new reading challenge and enrolls the creator
+func (h *ChallengeHandler) CreateChallenge(c echo.Context) error {
+	userID := auth.GetUserID(c)
+	if userID == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": "unauthorized",
+		})
+	}
+
+	var req CreateChallengeRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "invalid request body",
+		})
+	}
+
+	if req.Name == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "name is required",
+		})
+	}
+
+	if req.TargetCount <= 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "target_count must be greater than 0",
+		})
+	}
+
+	startsOn, err := time.Parse("2006-01-02", req.StartsOn)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "starts_on must be a date (YYYY-MM-DD)",
+		})
+	}
+	endsOn, err := time.Parse("2006-01-02", req.EndsOn)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "ends_on must be a date (YYYY-MM-DD)",
+		})
+	}
+	if endsOn.Before(startsOn) {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "ends_on must not be before starts_on",
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	// A challenge can only be scoped to a list the creator is allowed to see
+	if req.ListID != nil && *req.ListID != "" {
+		var listOwnerID string
+		var listIsPublic bool
+		err := h.DB.QueryRow(ctx, "SELECT user_id, is_public FROM lists WHERE id = $1", *req.ListID).Scan(&listOwnerID, &listIsPublic)
+		if err != nil {
+			return c.JSON(http.StatusNotFound, map[string]string{
+				"error": "list not found",
+			})
+		}
+		if !listIsPublic && listOwnerID != userID {
+			return c.JSON(http.StatusForbidden, map[string]string{
+				"error": "you don't have permission to use this list",
+			})
+		}
+	} else {
+		req.ListID = nil
+	}
+
+	if req.Category != nil && *req.Category == "" {
+		req.Category = nil
+	}
+
+	isPublic := true
+	if req.IsPublic != nil {
+		isPublic = *req.IsPublic
+	}
+
+	tx, err := h.DB.Begin(ctx)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to start transaction",
+		})
+	}
+	defer tx.Rollback(ctx)
+
+	var challengeID string
+	var createdAt time.Time
+	err = tx.QueryRow(ctx, `
+		INSERT INTO challenges (creator_id, name, description, target_count, list_id, category, starts_on, ends_on, is_public, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NOW(), NOW())
+		RETURNING id, created_at
+	`, userID, req.Name, req.Description, req.TargetCount, req.ListID, req.Category, startsOn, endsOn, isPublic).Scan(&challengeID, &createdAt)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to create challenge",
+		})
+	}
+
+	_, err = tx.Exec(ctx, "INSERT INTO challenge_participants (challenge_id, user_id, joined_at) VALUES ($1, $2, NOW())", challengeID, userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to join challenge",
+		})
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to commit transaction",
+		})
+	}
+
+	return c.JSON(http.StatusCreated, map[string]interface{}{
+		"id":                 challengeID,
+		"creator_id":         userID,
+		"name":               req.Name,
+		"description":        req.Description,
+		"target_count":       req.TargetCount,
+		"list_id":            req.ListID,
+		"category":           req.Category,
+		"starts_on":          req.StartsOn,
+		"ends_on":            req.EndsOn,
+		"is_public":          isPublic,
+		"participants_count": 1,
+		"created_at":         createdAt,
+	})
+}
+
+// GetChallenges lists public challenges that haven't ended, plus the ones the user has joined
+func (h *ChallengeHandler) GetChallenges(c echo.Context) error {
+	userID := auth.GetUserID(c)
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	query := `
+		SELECT c.id, c.creator_id, c.name, c.description, c.target_count, c.list_id, c.category,
+		       c.starts_on, c.ends_on, c.is_public, c.participants_count, c.created_at,
+		       u.username, u.name, u.picture,
+		       EXISTS(SELECT 1 FROM challenge_participants WHERE challenge_id = c.id AND user_id = $1) as is_joined
+		FROM challenges c
+		JOIN users u ON c.creator_id = u.id
+		WHERE (c.is_public = true AND c.ends_on >= CURRENT_DATE)
+		   OR EXISTS(SELECT 1 FROM challenge_participants WHERE challenge_id = c.id AND user_id = $1)
+		ORDER BY c.participants_count DESC, c.starts_on ASC
+		LIMIT 50
+	`
+
+	rows, err := h.DB.Query(ctx, query, nullableUserID(userID))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to fetch challenges",
+		})
+	}
+	defer rows.Close()
+
+	challenges := []map[string]interface{}{}
+	for rows.Next() {
+		var challenge struct {
+			ID                string
+			CreatorID         string
+			Name              string
+			Description       *string
+			TargetCount       int
+			ListID            *string
+			Category          *string
+			StartsOn          time.Time
+			EndsOn            time.Time
+			IsPublic          bool
+			ParticipantsCount int
+			CreatedAt         time.Time
+			Username          string
+			CreatorName       string
+			Picture           *string
+			IsJoined          bool
+		}
+
+		err := rows.Scan(
+			&challenge.ID, &challenge.CreatorID, &challenge.Name, &challenge.Description,
+			&challenge.TargetCount, &challenge.ListID, &challenge.Category,
+			&challenge.StartsOn, &challenge.EndsOn, &challenge.IsPublic,
+			&challenge.ParticipantsCount, &challenge.CreatedAt,
+			&challenge.Username, &challenge.CreatorName, &challenge.Picture, &challenge.IsJoined,
+		)
+		if err != nil {
+			continue
+		}
+
+		challenges = append(challenges, map[string]interface{}{
+			"id":                 challenge.ID,
+			"name":               challenge.Name,
+			"description":        challenge.Description,
+			"target_count":       challenge.TargetCount,
+			"list_id":            challenge.ListID,
+			"category":           challenge.Category,
+			"starts_on":          challenge.StartsOn.Format("2006-01-02"),
+			"ends_on":            challenge.EndsOn.Format("2006-01-02"),
+			"is_public":          challenge.IsPublic,
+			"participants_count": challenge.ParticipantsCount,
+			"is_joined":          challenge.IsJoined,
+			"created_at":         challenge.CreatedAt,
+			"creator": map[string]interface{}{
+				"id":       challenge.CreatorID,
+				"username": challenge.Username,
+				"name":     challenge.CreatorName,
+				"picture":  challenge.Picture,
+			},
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"challenges": challenges,
+		"count":      len(challenges),
+	})
+}
+
+// GetChallenge returns a challenge with its leaderboard
+func (h *ChallengeHandler) GetChallenge(c echo.Context) error {
+	challengeID := c.Param("id")
+	if challengeID == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "challenge_id is required",
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	currentUserID := auth.GetUserID(c)
+
+	var challenge struct {
+		ID                string
+		CreatorID         string
+		Name              string
+		Description       *string
+		TargetCount       int
+		ListID            *string
+		ListName          *string
+		Category          *string
+		StartsOn          time.Time
+		EndsOn            time.Time
+		IsPublic          bool
+		ParticipantsCount int
+		CreatedAt         time.Time
+		Username          string
+		CreatorName       string
+		Picture           *string
+	}
+
+	err := h.DB.QueryRow(ctx, `
+		SELECT c.id, c.creator_id, c.name, c.description, c.target_count, c.list_id, li.name, c.category,
+		       c.starts_on, c.ends_on, c.is_public, c.participants_count, c.created_at,
+		       u.username, u.name, u.picture
+		FROM challenges c
+		JOIN users u ON c.creator_id = u.id
+		LEFT JOIN lists li ON c.list_id = li.id
+		WHERE c.id = $1
+	`, challengeID).Scan(
+		&challenge.ID, &challenge.CreatorID, &challenge.Name, &challenge.Description,
+		&challenge.TargetCount, &challenge.ListID, &challenge.ListName, &challenge.Category,
+		&challenge.StartsOn, &challenge.EndsOn, &challenge.IsPublic,
+		&challenge.ParticipantsCount, &challenge.CreatedAt,
+		&challenge.Username, &challenge.CreatorName, &challenge.Picture,
+	)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": "challenge not found",
+		})
+	}
+
+	var isJoined bool
+	if currentUserID != "" {
+		h.DB.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM challenge_participants WHERE challenge_id = $1 AND user_id = $2)", challengeID, currentUserID).Scan(&isJoined)
+	}
+
+	if !challenge.IsPublic && currentUserID != challenge.CreatorID && !isJoined {
+		return c.JSON(http.StatusForbidden, map[string]string{
+			"error": "you don't have permission to view this challenge",
+		})
+	}
+
+	leaderboard, err := h.getLeaderboard(ctx, challengeID, challenge.TargetCount)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to fetch leaderboard",
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"id":                 challenge.ID,
+		"name":               challenge.Name,
+		"description":        challenge.Description,
+		"target_count":       challenge.TargetCount,
+		"list_id":            challenge.ListID,
+		"list_name":          challenge.ListName,
+		"category":           challenge.Category,
+		"starts_on":          challenge.StartsOn.Format("2006-01-02"),
+		"ends_on":            challenge.EndsOn.Format("2006-01-02"),
+		"is_public":          challenge.IsPublic,
+		"participants_count": challenge.ParticipantsCount,
+		"is_joined":          isJoined,
+		"created_at":         challenge.CreatedAt,
+		"creator": map[string]interface{}{
+			"id":       challenge.CreatorID,
+			"username": challenge.Username,
+			"name":     challenge.CreatorName,
+			"picture":  challenge.Picture,
+		},
+		"leaderboard": leaderboard,
+	})
+}
+
+// JoinChallenge enrolls the current user in a challenge
+func (h *ChallengeHandler) JoinChallenge(c echo.Context) error {
+	userID := auth.GetUserID(c)
+	if userID == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": "unauthorized",
+		})
+	}
+
+	challengeID := c.Param("id")
+	if challengeID == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "challenge_id is required",
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	var creatorID string
+	var isPublic, hasEnded bool
+	err := h.DB.QueryRow(ctx, "SELECT creator_id, is_public, ends_on < CURRENT_DATE FROM challenges WHERE id = $1", challengeID).Scan(&creatorID, &isPublic, &hasEnded)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": "challenge not found",
+		})
+	}
+
+	if !isPublic && creatorID != userID {
+		return c.JSON(http.StatusForbidden, map[string]string{
+			"error": "cannot join private challenge",
+		})
+	}
+
+	if hasEnded {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "challenge has already ended",
+		})
+	}
+
+	_, err = h.DB.Exec(ctx, `
+		INSERT INTO challenge_participants (challenge_id, user_id, joined_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (challenge_id, user_id) DO NOTHING
+	`, challengeID, userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to join challenge",
+		})
+	}
+
+	// Books finished before joining may already complete the challenge
+	if err := updateChallengeCompletions(ctx, h.DB, userID); err != nil {
+		log.Printf("failed to update challenge completions for user %s: %v", userID, err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"joined":  true,
+	})
+}
+
+// LeaveChallenge removes the current user from a challenge
+func (h *ChallengeHandler) LeaveChallenge(c echo.Context) error {
+	userID := auth.GetUserID(c)
+	if userID == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": "unauthorized",
+		})
+	}
+
+	challengeID := c.Param("id")
+	if challengeID == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "challenge_id is required",
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	_, err := h.DB.Exec(ctx, "DELETE FROM challenge_participants WHERE challenge_id = $1 AND user_id = $2", challengeID, userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to leave challenge",
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"joined":  false,
+	})
+}
+
+// GetMyStreak returns the current user's daily reading streak
+func (h *ChallengeHandler) GetMyStreak(c echo.Context) error {
+	userID := auth.GetUserID(c)
+	if userID == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": "unauthorized",
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	streak, err := getReadingStreak(ctx, h.DB, userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to compute streak",
+		})
+	}
+
+	return c.JSON(http.StatusOK, streak)
+}
+
+// GetUserBadges returns the badges a user has earned along with the full catalogue
+func (h *ChallengeHandler) GetUserBadges(c echo.Context) error {
+	username := c.Param("username")
+	if username == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "username is required",
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	query := `
+		SELECT ub.badge, ub.log_id, ub.awarded_at
+		FROM user_badges ub
+		JOIN users u ON ub.user_id = u.id
+		WHERE u.username = $1
+		ORDER BY ub.awarded_at DESC
+	`
+
+	rows, err := h.DB.Query(ctx, query, username)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to fetch badges",
+		})
+	}
+	defer rows.Close()
+
+	badges := []map[string]interface{}{}
+	for rows.Next() {
+		var badge struct {
+			Badge     string
+			LogID     *string
+			AwardedAt time.Time
+		}
+
+		if err := rows.Scan(&badge.Badge, &badge.LogID, &badge.AwardedAt); err != nil {
+			continue
+		}
+
+		result := map[string]interface{}{
+			"badge":      badge.Badge,
+			"log_id":     badge.LogID,
+			"awarded_at": badge.AwardedAt,
+		}
+		if rule := findBadgeRule(badge.Badge); rule != nil {
+			result["name"] = rule.Name
+			result["description"] = rule.Description
+		}
+
+		badges = append(badges, result)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"badges":    badges,
+		"count":     len(badges),
+		"available": badgeRules,
+	})
+}
+
+// getLeaderboard ranks a challenge's participants by the progress of their public logs.
+// completed_at is only exposed once that public progress reaches the target, so a
+// completion earned through private logs does not show up
+func (h *ChallengeHandler) getLeaderboard(ctx context.Context, challengeID string, targetCount int) ([]map[string]interface{}, error) {
+	query := `
+		SELECT user_id, username, name, picture, joined_at,
+		       CASE WHEN progress >= target_count THEN completed_at END AS completed_at,
+		       progress
+		FROM (
+			SELECT cp.user_id, u.username, u.name, u.picture, cp.joined_at, cp.completed_at,
+			       c.target_count,
+			       ` + publicChallengeProgressSQL + ` as progress
+			FROM challenge_participants cp
+			JOIN challenges c ON cp.challenge_id = c.id
+			JOIN users u ON cp.user_id = u.id
+			WHERE cp.challenge_id = $1
+		) p
+		ORDER BY progress DESC,
+		         CASE WHEN progress >= target_count THEN completed_at END ASC NULLS LAST,
+		         joined_at ASC
+		LIMIT 100
+	`
+
+	rows, err := h.DB.Query(ctx, query, challengeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	leaderboard := []map[string]interface{}{}
+	rank := 0
+	for rows.Next() {
+		var entry struct {
+			UserID      string
+			Username    string
+			Name        string
+			Picture     *string
+			JoinedAt    time.Time
+			CompletedAt *time.Time
+			Progress    int
+		}
+
+		err := rows.Scan(
+			&entry.UserID, &entry.Username, &entry.Name, &entry.Picture,
+			&entry.JoinedAt, &entry.CompletedAt, &entry.Progress,
+		)
+		if err != nil {
+			continue
+		}
+
+		rank++
+		leaderboard = append(leaderboard, map[string]interface{}{
+			"rank":         rank,
+			"progress":     entry.Progress,
+			"target_count": targetCount,
+			"completed":    entry.Progress >= targetCount,
+			"completed_at": entry.CompletedAt,
+			"joined_at":    entry.JoinedAt,
+			"user": map[string]interface{}{
+				"id":       entry.UserID,
+				"username": entry.Username,
+				"name":     entry.Name,
+				"picture":  entry.Picture,
+			},
+		})
+	}
+
+	return leaderboard, rows.Err()
+}
+
+// updateChallengeCompletions stamps completed_at on every challenge the user has now met
+func updateChallengeCompletions(ctx context.Context, db *pgxpool.Pool, userID string) error {
+	query := `
+		UPDATE challenge_participants cp
+		SET completed_at = NOW()
+		FROM challenges c
+		WHERE cp.challenge_id = c.id
+		  AND cp.user_id = $1
+		  AND cp.completed_at IS NULL
+		  AND ` + challengeProgressSQL + ` >= c.target_count
+	`
+	_, err := db.Exec(ctx, query, userID)
+	return err
+}
+
+// nullableUserID converts an empty user ID into NULL so it can be compared against UUID columns
+func nullableUserID(userID string) *string {
+	if userID == "" {
+		return nil
+	}
+	return &userID
+}
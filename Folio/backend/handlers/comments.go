@@ -0,0 +1,458 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"folio/api/auth"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/labstack/echo/v4"
+)
+
+const maxCommentLength = 5000
+
+// CommentHandler serves comments on logs and lists. Both kinds share threading,
+// editing, soft deletes and pagination; commentTarget says which table to use.
+type CommentHandler struct {
+	DB *pgxpool.Pool
+}
+
+// commentTarget describes one commentable entity and its comments table
+type commentTarget struct {
+	Kind        string // "log" or "list", used in responses and errors
+	Table       string // comments table
+	Column      string // column in Table referencing the entity
+	EntityTable string // table of the commented entity
+}
+
+var (
+	logCommentTarget  = commentTarget{Kind: "log", Table: "log_comments", Column: "log_id", EntityTable: "logs"}
+	listCommentTarget = commentTarget{Kind: "list", Table: "list_comments", Column: "list_id", EntityTable: "lists"}
+)
+
+// commentSorts are the sort keys accepted when listing comments; oldest first by default
+var commentSorts = map[string]sortOption{
+	"created_at": {Expr: "c.created_at", Type: "timestamptz", Desc: false},
+}
+
+type CreateCommentRequest struct {
+	Content  string  `json:"content"`
+	ParentID *string `json:"parent_id"`
+}
+
+type UpdateCommentRequest struct {
+	Content string `json:"content"`
+}
+
+// canViewTarget reports whether the entity exists and the user may read and comment on it,
+// and returns its owner, who may also moderate its comments
+func (h *CommentHandler) canViewTarget(ctx context.Context, target commentTarget, entityID, userID string) (ownerID string, ok bool, err error) {
+	var isPublic bool
+	err = h.DB.QueryRow(ctx, "SELECT user_id, COALESCE(is_public, true) FROM "+target.EntityTable+" WHERE id = $1", entityID).Scan(&ownerID, &isPublic)
+	if err != nil {
+		return "", false, err
+	}
+	if isPublic || ownerID == userID {
+		return ownerID, true, nil
+	}
+	if target.Kind == "list" {
+		_, role, _ := getListRole(ctx, h.DB, entityID, userID)
+		return ownerID, canViewList(role), nil
+	}
+	return ownerID, false, nil
+}
+
+// validateCommentContent trims and checks comment text
+func validateCommentContent(content string) (string, error) {
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return "", fmt.Errorf("content is required")
+	}
+	if len(content) > maxCommentLength {
+		return "", fmt.Errorf("content must be at most %d characters", maxCommentLength)
+	}
+	return content, nil
+}
+
+// GetLogComments returns a page of comments on a log
+func (h *CommentHandler) GetLogComments(c echo.Context) error {
+	return h.getComments(c, logCommentTarget)
+}
+
+// GetListComments returns a page of comments on a list
+func (h *CommentHandler) GetListComments(c echo.Context) error {
+	return h.getComments(c, listCommentTarget)
+}
+
+// getComments returns a page of top-level comments, or of replies to parent_id.
+// Deleted comments are kept as placeholders while they still have replies.
+func (h *CommentHandler) getComments(c echo.Context, target commentTarget) error {
+	entityID := c.Param("id")
+
+	page, err := parsePageRequest(c, commentSorts, "created_at", 50, 100)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	currentUserID := auth.GetUserID(c)
+	if _, ok, err := h.canViewTarget(ctx, target, entityID, currentUserID); err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": target.Kind + " not found",
+		})
+	} else if !ok {
+		return c.JSON(http.StatusForbidden, map[string]string{
+			"error": "you don't have permission to view this " + target.Kind,
+		})
+	}
+
+	qb := newQueryBuilder(entityID)
+	if parentID := c.QueryParam("parent_id"); parentID != "" {
+		qb.where("c.parent_id = " + qb.arg(parentID))
+	} else {
+		qb.where("c.parent_id IS NULL")
+	}
+	page.applyCursor(qb, "c.id")
+
+	query := `
+		SELECT c.id, c.user_id, c.parent_id, c.content, c.created_at, c.updated_at, c.edited_at,
+		       c.deleted_at IS NOT NULL,
+		       (SELECT COUNT(*) FROM ` + target.Table + ` r WHERE r.parent_id = c.id AND r.deleted_at IS NULL),
+		       u.username, u.name, u.picture,
+		       ` + page.cursorColumn() + `
+		FROM ` + target.Table + ` c
+		JOIN users u ON c.user_id = u.id
+		WHERE c.` + target.Column + ` = $1
+		AND (c.deleted_at IS NULL OR EXISTS(SELECT 1 FROM ` + target.Table + ` r WHERE r.parent_id = c.id))` + qb.and() + `
+		` + page.orderBy(qb, "c.id")
+
+	rows, err := h.DB.Query(ctx, query, qb.args...)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to fetch comments",
+		})
+	}
+	defer rows.Close()
+
+	comments := []map[string]interface{}{}
+	cursors := []pageCursor{}
+	for rows.Next() {
+		var comment struct {
+			ID           string
+			UserID       string
+			ParentID     *string
+			Content      string
+			CreatedAt    time.Time
+			UpdatedAt    time.Time
+			EditedAt     *time.Time
+			IsDeleted    bool
+			RepliesCount int
+			Username     string
+			Name         string
+			Picture      *string
+			CursorValue  string
+		}
+
+		err := rows.Scan(
+			&comment.ID, &comment.UserID, &comment.ParentID, &comment.Content,
+			&comment.CreatedAt, &comment.UpdatedAt, &comment.EditedAt, &comment.IsDeleted,
+			&comment.RepliesCount, &comment.Username, &comment.Name, &comment.Picture,
+			&comment.CursorValue,
+		)
+		if err != nil {
+			continue
+		}
+
+		// Deleted comments keep their place in the thread but not their author or text
+		var user map[string]interface{}
+		if !comment.IsDeleted {
+			user = map[string]interface{}{
+				"id":       comment.UserID,
+				"username": comment.Username,
+				"name":     comment.Name,
+				"picture":  comment.Picture,
+			}
+		}
+
+		cursors = append(cursors, pageCursor{Value: comment.CursorValue, ID: comment.ID})
+		comments = append(comments, map[string]interface{}{
+			"id":            comment.ID,
+			target.Column:   entityID,
+			"parent_id":     comment.ParentID,
+			"content":       comment.Content,
+			"created_at":    comment.CreatedAt,
+			"updated_at":    comment.UpdatedAt,
+			"edited_at":     comment.EditedAt,
+			"is_deleted":    comment.IsDeleted,
+			"replies_count": comment.RepliesCount,
+			"user":          user,
+		})
+	}
+
+	comments, nextCursor := page.trim(comments, cursors)
+
+	commentIDs := make([]string, len(comments))
+	for i, comment := range comments {
+		commentIDs[i] = comment["id"].(string)
+	}
+	counts := reactionCounts(ctx, h.DB, target.Kind+"_comment", commentIDs)
+	for _, comment := range comments {
+		comment["reaction_counts"] = counts[comment["id"].(string)]
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"comments":    comments,
+		"count":       len(comments),
+		"next_cursor": nextCursor,
+		"has_more":    nextCursor != nil,
+	})
+}
+
+// CreateLogComment adds a comment or reply to a log
+func (h *CommentHandler) CreateLogComment(c echo.Context) error {
+	return h.createComment(c, logCommentTarget)
+}
+
+// CreateListComment adds a comment or reply to a list
+func (h *CommentHandler) CreateListComment(c echo.Context) error {
+	return h.createComment(c, listCommentTarget)
+}
+
+func (h *CommentHandler) createComment(c echo.Context, target commentTarget) error {
+	userID := auth.GetUserID(c)
+	if userID == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": "unauthorized",
+		})
+	}
+
+	entityID := c.Param("id")
+
+	var req CreateCommentRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "invalid request body",
+		})
+	}
+
+	content, err := validateCommentContent(req.Content)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	if _, ok, err := h.canViewTarget(ctx, target, entityID, userID); err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": target.Kind + " not found",
+		})
+	} else if !ok {
+		return c.JSON(http.StatusForbidden, map[string]string{
+			"error": "cannot comment on private " + target.Kind,
+		})
+	}
+
+	// Replies must be to a live comment on the same entity
+	if req.ParentID != nil {
+		var parentDeleted bool
+		err := h.DB.QueryRow(ctx, "SELECT deleted_at IS NOT NULL FROM "+target.Table+" WHERE id = $1 AND "+target.Column+" = $2", *req.ParentID, entityID).Scan(&parentDeleted)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "parent comment not found",
+			})
+		}
+		if parentDeleted {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "cannot reply to a deleted comment",
+			})
+		}
+	}
+
+	query := `
+		INSERT INTO ` + target.Table + ` (user_id, ` + target.Column + `, parent_id, content, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, NOW(), NOW())
+		RETURNING id, created_at, updated_at
+	`
+
+	var commentID string
+	var createdAt, updatedAt time.Time
+	err = h.DB.QueryRow(ctx, query, userID, entityID, req.ParentID, content).Scan(&commentID, &createdAt, &updatedAt)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to create comment",
+		})
+	}
+
+	// Get user info for response
+	var username, name string
+	var picture *string
+	h.DB.QueryRow(ctx, "SELECT username, name, picture FROM users WHERE id = $1", userID).Scan(&username, &name, &picture)
+
+	return c.JSON(http.StatusCreated, map[string]interface{}{
+		"id":            commentID,
+		target.Column:   entityID,
+		"parent_id":     req.ParentID,
+		"content":       content,
+		"created_at":    createdAt,
+		"updated_at":    updatedAt,
+		"edited_at":     nil,
+		"is_deleted":    false,
+		"replies_count": 0,
+		"user": map[string]interface{}{
+			"id":       userID,
+			"username": username,
+			"name":     name,
+			"picture":  picture,
+		},
+	})
+}
+
+// UpdateLogComment edits the current user's comment on a log
+func (h *CommentHandler) UpdateLogComment(c echo.Context) error {
+	return h.updateComment(c, logCommentTarget)
+}
+
+// UpdateListComment edits the current user's comment on a list
+func (h *CommentHandler) UpdateListComment(c echo.Context) error {
+	return h.updateComment(c, listCommentTarget)
+}
+
+func (h *CommentHandler) updateComment(c echo.Context, target commentTarget) error {
+	userID := auth.GetUserID(c)
+	if userID == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": "unauthorized",
+		})
+	}
+
+	entityID := c.Param("id")
+	commentID := c.Param("commentId")
+
+	var req UpdateCommentRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "invalid request body",
+		})
+	}
+
+	content, err := validateCommentContent(req.Content)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	var authorID string
+	var isDeleted bool
+	err = h.DB.QueryRow(ctx, "SELECT user_id, deleted_at IS NOT NULL FROM "+target.Table+" WHERE id = $1 AND "+target.Column+" = $2", commentID, entityID).Scan(&authorID, &isDeleted)
+	if err != nil || isDeleted {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": "comment not found",
+		})
+	}
+
+	if authorID != userID {
+		return c.JSON(http.StatusForbidden, map[string]string{
+			"error": "you can only edit your own comments",
+		})
+	}
+
+	var updatedAt, editedAt time.Time
+	err = h.DB.QueryRow(ctx, `
+		UPDATE `+target.Table+` SET content = $1, edited_at = NOW(), updated_at = NOW()
+		WHERE id = $2
+		RETURNING updated_at, edited_at
+	`, content, commentID).Scan(&updatedAt, &editedAt)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to update comment",
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"id":         commentID,
+		"content":    content,
+		"updated_at": updatedAt,
+		"edited_at":  editedAt,
+	})
+}
+
+// DeleteLogComment deletes a comment on a log
+func (h *CommentHandler) DeleteLogComment(c echo.Context) error {
+	return h.deleteComment(c, logCommentTarget)
+}
+
+// DeleteListComment deletes a comment on a list
+func (h *CommentHandler) DeleteListComment(c echo.Context) error {
+	return h.deleteComment(c, listCommentTarget)
+}
+
+// deleteComment soft-deletes a comment so replies keep their place in the thread.
+// Authors can delete their own comments and owners can delete any comment on their log or list.
+func (h *CommentHandler) deleteComment(c echo.Context, target commentTarget) error {
+	userID := auth.GetUserID(c)
+	if userID == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": "unauthorized",
+		})
+	}
+
+	commentID := c.Param("commentId")
+	if commentID == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "comment_id is required",
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	// The legacy /comments/:commentId route has no entity id
+	var authorID, entityOwnerID, entityID string
+	err := h.DB.QueryRow(ctx, `
+		SELECT c.user_id, e.user_id, e.id
+		FROM `+target.Table+` c
+		JOIN `+target.EntityTable+` e ON c.`+target.Column+` = e.id
+		WHERE c.id = $1 AND c.deleted_at IS NULL
+	`, commentID).Scan(&authorID, &entityOwnerID, &entityID)
+	if err == pgx.ErrNoRows || (err == nil && c.Param("id") != "" && c.Param("id") != entityID) {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": "comment not found",
+		})
+	} else if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to delete comment",
+		})
+	}
+
+	if authorID != userID && entityOwnerID != userID {
+		return c.JSON(http.StatusForbidden, map[string]string{
+			"error": "you can only delete your own comments",
+		})
+	}
+
+	_, err = h.DB.Exec(ctx, "UPDATE "+target.Table+" SET content = '', deleted_at = NOW(), updated_at = NOW() WHERE id = $1", commentID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to delete comment",
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message": "comment deleted successfully",
+	})
+}
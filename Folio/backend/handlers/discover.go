@@ -68,7 +68,7 @@ func (h *DiscoverHandler) GetRecommendations(c echo.Context) error {
 	})
 }
 
-// GetTrendingLists returns popular public lists
+// GetTrendingLists returns public lists with the most engagement this week, optionally filtered by tag
 func (h *DiscoverHandler) GetTrendingLists(c echo.Context) error {
 	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
 	defer cancel()
@@ -78,17 +78,29 @@ func (h *DiscoverHandler) GetTrendingLists(c echo.Context) error {
 		fmt.Sscanf(l, "%d", &limit)
 	}
 
+	// Trending means recent engagement: likes, subscriptions, forks and additions in the
+	// last week, optionally within the lists carrying every requested tag
+	tags := tagQueryParams(c)
+
 	query := `
-		SELECT l.id, l.user_id, l.name, l.description, l.is_public, l.items_count, l.created_at, l.updated_at,
+		SELECT l.id, l.user_id, l.name, l.description, l.is_public, l.items_count, l.tags, l.created_at, l.updated_at,
 		       u.username, u.name as user_name, u.picture
 		FROM lists l
 		JOIN users u ON l.user_id = u.id
+		LEFT JOIN LATERAL (
+			SELECT
+				(SELECT COUNT(*) FROM reactions r WHERE r.target_type = 'list' AND r.target_id = l.id AND r.created_at > NOW() - INTERVAL '7 days') +
+				2 * (SELECT COUNT(*) FROM list_subscriptions s WHERE s.list_id = l.id AND s.created_at > NOW() - INTERVAL '7 days') +
+				3 * (SELECT COUNT(*) FROM lists f WHERE f.forked_from_list_id = l.id AND f.created_at > NOW() - INTERVAL '7 days') +
+				(SELECT COUNT(*) FROM list_items li WHERE li.list_id = l.id AND li.created_at > NOW() - INTERVAL '7 days') as score
+		) activity ON true
 		WHERE l.is_public = true AND l.items_count > 0
-		ORDER BY l.items_count DESC, l.created_at DESC
+		AND (cardinality($2::text[]) = 0 OR l.tags @> $2::text[])
+		ORDER BY activity.score DESC, l.items_count DESC, l.created_at DESC
 		LIMIT $1
 	`
 
-	rows, err := h.DB.Query(ctx, query, limit)
+	rows, err := h.DB.Query(ctx, query, limit, tags)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{
 			"error": "failed to fetch trending lists",
@@ -105,6 +117,7 @@ func (h *DiscoverHandler) GetTrendingLists(c echo.Context) error {
 			Description *string
 			IsPublic    bool
 			ItemsCount  int
+			Tags        []string
 			CreatedAt   time.Time
 			UpdatedAt   time.Time
 			Username    string
@@ -114,7 +127,7 @@ func (h *DiscoverHandler) GetTrendingLists(c echo.Context) error {
 
 		err := rows.Scan(
 			&list.ID, &list.UserID, &list.Name, &list.Description, &list.IsPublic,
-			&list.ItemsCount, &list.CreatedAt, &list.UpdatedAt,
+			&list.ItemsCount, &list.Tags, &list.CreatedAt, &list.UpdatedAt,
 			&list.Username, &list.UserName, &list.Picture,
 		)
 		if err != nil {
@@ -127,6 +140,7 @@ func (h *DiscoverHandler) GetTrendingLists(c echo.Context) error {
 			"description": list.Description,
 			"is_public":   list.IsPublic,
 			"items_count": list.ItemsCount,
+			"tags":        list.Tags,
 			"created_at":  list.CreatedAt,
 			"updated_at":  list.UpdatedAt,
 			"user": map[string]interface{}{
@@ -138,9 +152,38 @@ func (h *DiscoverHandler) GetTrendingLists(c echo.Context) error {
 		})
 	}
 
+	// Tags that show up alongside the requested ones, to help narrow or widen the search
+	relatedTags := []map[string]interface{}{}
+	if len(tags) > 0 {
+		tagRows, err := h.DB.Query(ctx, `
+			SELECT tag, COUNT(*) as lists_count
+			FROM lists l, unnest(l.tags) as tag
+			WHERE l.is_public = true AND l.tags @> $1::text[]
+			AND NOT tag = ANY($1::text[])
+			GROUP BY tag
+			ORDER BY lists_count DESC, tag
+			LIMIT 10
+		`, tags)
+		if err == nil {
+			defer tagRows.Close()
+			for tagRows.Next() {
+				var tag string
+				var count int
+				if err := tagRows.Scan(&tag, &count); err == nil {
+					relatedTags = append(relatedTags, map[string]interface{}{
+						"tag":         tag,
+						"lists_count": count,
+					})
+				}
+			}
+		}
+	}
+
 	return c.JSON(http.StatusOK, map[string]interface{}{
-		"lists": lists,
-		"count": len(lists),
+		"lists":        lists,
+		"count":        len(lists),
+		"tags":         tags,
+		"related_tags": relatedTags,
 	})
 }
 
@@ -173,6 +216,9 @@ func (h *DiscoverHandler) getPersonalizedRecommendations(ctx context.Context, us
 	
 	// Remove duplicates and filter already logged books
 	allRecommendations = h.deduplicateAndFilterRecommendations(ctx, userID, allRecommendations)
+
+	// Adjust scores using community ratings and the dimensions this reader cares about
+	h.applyRatingSignals(ctx, userProfile, allRecommendations)
 	
 	// Sort by score (personalization strength)
 	sort.Slice(allRecommendations, func(i, j int) bool {
@@ -206,6 +252,7 @@ func (h *DiscoverHandler) getUserReadingProfile(ctx context.Context, userID stri
 		"favorite_books":      []string{},
 		"reading_patterns":    map[string]interface{}{},
 		"review_keywords":     []string{},
+		"dimension_weights":   map[string]float64{},
 	}
 	
 	// Get favorite categories (from highly-rated books)
@@ -275,7 +322,7 @@ func (h *DiscoverHandler) getUserReadingProfile(ctx context.Context, userID stri
 		books := []string{}
 		for rows.Next() {
 			var bookID string
-			var rating int
+			var rating float64
 			if err := rows.Scan(&bookID, &rating); err == nil {
 				books = append(books, bookID)
 			}
@@ -305,10 +352,47 @@ func (h *DiscoverHandler) getUserReadingProfile(ctx context.Context, userID stri
 		}
 		profile["review_keywords"] = keywords
 	}
+
+	profile["dimension_weights"] = h.getDimensionWeights(ctx, userID)
 	
 	return profile
 }
 
+// getDimensionWeights estimates how much each rating dimension drives the user's overall ratings.
+// A dimension whose scores closely track the overall rating matters more to this reader.
+func (h *DiscoverHandler) getDimensionWeights(ctx context.Context, userID string) map[string]float64 {
+	weights := map[string]float64{}
+
+	query := `
+		SELECT AVG(ABS(rating_plot - rating)), COUNT(rating_plot),
+		       AVG(ABS(rating_prose - rating)), COUNT(rating_prose),
+		       AVG(ABS(rating_characters - rating)), COUNT(rating_characters),
+		       AVG(ABS(rating_pacing - rating)), COUNT(rating_pacing)
+		FROM logs
+		WHERE user_id = $1 AND rating IS NOT NULL
+	`
+
+	var diffs [4]*float64
+	var counts [4]int
+	err := h.DB.QueryRow(ctx, query, userID).Scan(
+		&diffs[0], &counts[0], &diffs[1], &counts[1],
+		&diffs[2], &counts[2], &diffs[3], &counts[3],
+	)
+	if err != nil {
+		return weights
+	}
+
+	for i, dimension := range ratingDimensions {
+		// Too few data points to say anything about this dimension
+		if counts[i] < 3 || diffs[i] == nil {
+			continue
+		}
+		weights[dimension] = 1 / (1 + *diffs[i])
+	}
+
+	return weights
+}
+
 // extractKeywordsFromReview extracts meaningful keywords from a review
 func (h *DiscoverHandler) extractKeywordsFromReview(review string) []string {
 	// Simple keyword extraction - in a real system, you'd use NLP
@@ -517,6 +601,81 @@ func (h *DiscoverHandler) deduplicateAndFilterRecommendations(ctx context.Contex
 	return filtered
 }
 
+// applyRatingSignals nudges each recommendation's score by the book's community ratings.
+// The overall average counts for every reader; per-dimension averages are weighted by
+// the reader's dimension weights. Both are damped for books with few ratings.
+func (h *DiscoverHandler) applyRatingSignals(ctx context.Context, profile map[string]interface{}, recommendations []PersonalizedRecommendation) {
+	if len(recommendations) == 0 {
+		return
+	}
+
+	bookIDs := make([]string, 0, len(recommendations))
+	for _, rec := range recommendations {
+		if bookID, ok := rec.Book["id"].(string); ok {
+			bookIDs = append(bookIDs, bookID)
+		}
+	}
+
+	query := `
+		SELECT book_id, AVG(rating), COUNT(rating),
+		       AVG(rating_plot), AVG(rating_prose), AVG(rating_characters), AVG(rating_pacing)
+		FROM logs
+		WHERE book_id = ANY($1) AND is_public = true AND rating IS NOT NULL
+		GROUP BY book_id
+	`
+
+	rows, err := h.DB.Query(ctx, query, bookIDs)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	type communityRating struct {
+		Avg     float64
+		Count   int
+		DimAvgs [4]*float64
+	}
+	ratings := make(map[string]communityRating)
+	for rows.Next() {
+		var bookID string
+		var r communityRating
+		if err := rows.Scan(&bookID, &r.Avg, &r.Count, &r.DimAvgs[0], &r.DimAvgs[1], &r.DimAvgs[2], &r.DimAvgs[3]); err == nil {
+			ratings[bookID] = r
+		}
+	}
+
+	weights, _ := profile["dimension_weights"].(map[string]float64)
+
+	for i := range recommendations {
+		bookID, _ := recommendations[i].Book["id"].(string)
+		r, ok := ratings[bookID]
+		if !ok {
+			continue
+		}
+
+		// Ratings are centred on 3 stars and scaled to [-1, 1]
+		confidence := float64(r.Count) / float64(r.Count+5)
+		boost := 0.1 * (r.Avg - 3) / 2 * confidence
+
+		var fit, totalWeight float64
+		for d, dimension := range ratingDimensions {
+			w := weights[dimension]
+			if w == 0 || r.DimAvgs[d] == nil {
+				continue
+			}
+			fit += w * (*r.DimAvgs[d] - 3) / 2
+			totalWeight += w
+		}
+		if totalWeight > 0 {
+			boost += 0.1 * fit / totalWeight * confidence
+		}
+
+		recommendations[i].Score += boost
+		recommendations[i].Book["community_rating"] = r.Avg
+		recommendations[i].Book["community_rating_count"] = r.Count
+	}
+}
+
 // Helper functions
 func (h *DiscoverHandler) findSimilarBooks(ctx context.Context, bookID string, limit int) []map[string]interface{} {
 	// Get book details first
@@ -0,0 +1,671 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"folio/api/auth"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+)
+
+const maxBatchOperations = 100
+
+// Per-item failures reported back in batch and transfer results
+var (
+	errListItemNotFound  = errors.New("list item not found")
+	errBookAlreadyInList = errors.New("book already in list")
+	errNeighbourNotFound = errors.New("neighbouring item not found in this list")
+	errNotAdjacent       = errors.New("after_item_id and before_item_id are no longer adjacent")
+)
+
+// BatchListItemOperation is one step of a batch. Op is add, remove, move or update_note;
+// add takes book_id and notes, move takes after_item_id, before_item_id or order like
+// UpdateListItemOrder, update_note takes notes, and the rest take item_id.
+type BatchListItemOperation struct {
+	Op           string  `json:"op"`
+	ItemID       string  `json:"item_id"`
+	BookID       string  `json:"book_id"`
+	Notes        *string `json:"notes"`
+	AfterItemID  *string `json:"after_item_id"`
+	BeforeItemID *string `json:"before_item_id"`
+	Order        *int    `json:"order"`
+}
+
+type BatchListItemsRequest struct {
+	Operations []BatchListItemOperation `json:"operations"`
+}
+
+type TransferListItemsRequest struct {
+	TargetListID string   `json:"target_list_id"`
+	ItemIDs      []string `json:"item_ids"`
+	Mode         string   `json:"mode"`
+	IncludeNotes *bool    `json:"include_notes"`
+}
+
+// BatchListItems applies many item operations to a list in one transaction.
+// Either every operation succeeds or none are kept; the response reports each one.
+// The whole batch is recorded as a single revision.
+func (h *ListHandler) BatchListItems(c echo.Context) error {
+	userID := auth.GetUserID(c)
+	if userID == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": "unauthorized",
+		})
+	}
+
+	listID := c.Param("id")
+
+	var req BatchListItemsRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "invalid request body",
+		})
+	}
+
+	if len(req.Operations) == 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "operations is required",
+		})
+	}
+	if len(req.Operations) > maxBatchOperations {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": fmt.Sprintf("at most %d operations are allowed per batch", maxBatchOperations),
+		})
+	}
+
+	for i, op := range req.Operations {
+		if err := op.validate(); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": fmt.Sprintf("operation %d: %s", i, err.Error()),
+			})
+		}
+	}
+
+	expected, err := expectedListVersion(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 15*time.Second)
+	defer cancel()
+
+	tx, err := h.DB.Begin(ctx)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to start transaction",
+		})
+	}
+	defer tx.Rollback(ctx)
+
+	_, role, err := getListRole(ctx, tx, listID, userID)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": "list not found",
+		})
+	}
+
+	if !canEditList(role) {
+		return c.JSON(http.StatusForbidden, map[string]string{
+			"error": "you can only change lists you own or edit",
+		})
+	}
+
+	if isSmartList(ctx, tx, listID) {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "a smart list's items come from its rules and can't be edited",
+		})
+	}
+
+	if version, err := lockListVersion(ctx, tx, listID, expected); err == errListVersionConflict {
+		return listVersionConflict(c, version)
+	} else if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to update list items",
+		})
+	}
+
+	// Each operation runs in a savepoint so one failure doesn't hide the others' results
+	results := []map[string]interface{}{}
+	changes := []map[string]interface{}{}
+	failed := 0
+	for i, op := range req.Operations {
+		result := map[string]interface{}{
+			"index": i,
+			"op":    op.Op,
+		}
+
+		var change map[string]interface{}
+		sp, err := tx.Begin(ctx)
+		if err == nil {
+			change, err = applyBatchOperation(ctx, sp, listID, userID, op, result)
+			if err == nil {
+				err = sp.Commit(ctx)
+			} else {
+				sp.Rollback(ctx)
+			}
+		}
+
+		if err != nil {
+			failed++
+			result["status"] = "error"
+			result["error"] = batchErrorMessage(op, err)
+		} else {
+			result["status"] = "ok"
+			changes = append(changes, change)
+		}
+		results = append(results, result)
+	}
+
+	if failed > 0 {
+		return c.JSON(http.StatusUnprocessableEntity, map[string]interface{}{
+			"error":   fmt.Sprintf("%d of %d operations failed; no changes were saved", failed, len(req.Operations)),
+			"applied": false,
+			"results": results,
+		})
+	}
+
+	err = recordListRevision(ctx, tx, listID, userID, listRevisionChange{
+		Action:  "items_batch",
+		Details: map[string]interface{}{"operations": changes},
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to record revision",
+		})
+	}
+
+	version := currentListVersion(ctx, tx, listID)
+	if err := tx.Commit(ctx); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to commit transaction",
+		})
+	}
+
+	c.Response().Header().Set("ETag", listETag(version))
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"applied": true,
+		"results": results,
+		"version": version,
+	})
+}
+
+// validate checks an operation has the fields its op needs
+func (op BatchListItemOperation) validate() error {
+	switch op.Op {
+	case "add":
+		if op.BookID == "" {
+			return fmt.Errorf("book_id is required for add")
+		}
+	case "remove", "update_note":
+		if op.ItemID == "" {
+			return fmt.Errorf("item_id is required for %s", op.Op)
+		}
+	case "move":
+		if op.ItemID == "" {
+			return fmt.Errorf("item_id is required for move")
+		}
+		if op.Order == nil && op.AfterItemID == nil && op.BeforeItemID == nil {
+			return fmt.Errorf("one of order, after_item_id or before_item_id is required for move")
+		}
+	default:
+		return fmt.Errorf("invalid op. Must be: add, remove, move, or update_note")
+	}
+	return nil
+}
+
+// applyBatchOperation runs one operation, filling in result, and returns the entry
+// describing it in the batch's revision
+func applyBatchOperation(ctx context.Context, tx pgx.Tx, listID, userID string, op BatchListItemOperation, result map[string]interface{}) (map[string]interface{}, error) {
+	switch op.Op {
+	case "add":
+		item, err := appendListItem(ctx, tx, listID, userID, op.BookID, op.Notes)
+		if err != nil {
+			return nil, err
+		}
+		result["item_id"] = item.ID
+		result["book_id"] = op.BookID
+		result["position_key"] = item.PositionKey
+		return map[string]interface{}{"op": op.Op, "item_id": item.ID, "book_id": op.BookID}, nil
+
+	case "remove":
+		var bookID string
+		var notes *string
+		err := tx.QueryRow(ctx, "DELETE FROM list_items WHERE id = $1 AND list_id = $2 RETURNING book_id, notes", op.ItemID, listID).Scan(&bookID, &notes)
+		if err == pgx.ErrNoRows {
+			return nil, errListItemNotFound
+		} else if err != nil {
+			return nil, err
+		}
+		result["item_id"] = op.ItemID
+		result["book_id"] = bookID
+		return map[string]interface{}{"op": op.Op, "item_id": op.ItemID, "book_id": bookID, "notes": notes}, nil
+
+	case "move":
+		index, positionKey, bookID, err := moveListItem(ctx, tx, listID, op.ItemID, op.AfterItemID, op.BeforeItemID, op.Order)
+		if err != nil {
+			return nil, err
+		}
+		result["item_id"] = op.ItemID
+		result["book_id"] = bookID
+		result["item_order"] = index
+		result["position_key"] = positionKey
+		return map[string]interface{}{"op": op.Op, "item_id": op.ItemID, "book_id": bookID, "item_order": index}, nil
+
+	case "update_note":
+		var bookID string
+		var previousNotes *string
+		err := tx.QueryRow(ctx, "SELECT book_id, notes FROM list_items WHERE id = $1 AND list_id = $2", op.ItemID, listID).Scan(&bookID, &previousNotes)
+		if err == pgx.ErrNoRows {
+			return nil, errListItemNotFound
+		} else if err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec(ctx, "UPDATE list_items SET notes = $1 WHERE id = $2", op.Notes, op.ItemID); err != nil {
+			return nil, err
+		}
+		result["item_id"] = op.ItemID
+		result["book_id"] = bookID
+		return map[string]interface{}{"op": op.Op, "item_id": op.ItemID, "book_id": bookID, "previous_notes": previousNotes, "notes": op.Notes}, nil
+	}
+	return nil, fmt.Errorf("invalid op")
+}
+
+// batchErrorMessage turns an operation error into a message safe to return to the client
+func batchErrorMessage(op BatchListItemOperation, err error) string {
+	switch err {
+	case errListItemNotFound, errBookAlreadyInList, errNeighbourNotFound, errNotAdjacent:
+		return err.Error()
+	}
+	switch op.Op {
+	case "add":
+		return "failed to add book to list"
+	case "remove":
+		return "failed to remove book from list"
+	case "move":
+		return "failed to update item order"
+	default:
+		return "failed to update list item"
+	}
+}
+
+// addedListItem is the item appendListItem created and where it landed
+type addedListItem struct {
+	ID          string
+	PositionKey string
+	Order       int
+	CreatedAt   time.Time
+}
+
+// appendListItem adds a book after the list's current last item
+func appendListItem(ctx context.Context, tx pgx.Tx, listID, userID, bookID string, notes *string) (addedListItem, error) {
+	item := addedListItem{}
+
+	var exists bool
+	err := tx.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM list_items WHERE list_id = $1 AND book_id = $2)", listID, bookID).Scan(&exists)
+	if err != nil {
+		return item, err
+	}
+	if exists {
+		return item, errBookAlreadyInList
+	}
+
+	var lastKey string
+	err = tx.QueryRow(ctx, "SELECT COALESCE(MAX(position_key), ''), COUNT(*) FROM list_items WHERE list_id = $1", listID).Scan(&lastKey, &item.Order)
+	if err != nil {
+		return item, err
+	}
+	positionKey, err := keyBetween(lastKey, "")
+	if err != nil {
+		return item, err
+	}
+	item.PositionKey = positionKey
+
+	err = tx.QueryRow(ctx, `
+		INSERT INTO list_items (list_id, book_id, notes, position_key, added_by, created_at)
+		VALUES ($1, $2, $3, $4, $5, NOW())
+		RETURNING id, created_at
+	`, listID, bookID, notes, positionKey, userID).Scan(&item.ID, &item.CreatedAt)
+	return item, err
+}
+
+// moveListItem rewrites one item's position key to place it by its neighbours or at an index.
+// Only the moved item's key changes, so concurrent moves of different items don't clobber each other.
+func moveListItem(ctx context.Context, tx pgx.Tx, listID, itemID string, afterItemID, beforeItemID *string, order *int) (int, string, string, error) {
+	var bookID string
+	err := tx.QueryRow(ctx, "SELECT book_id FROM list_items WHERE id = $1 AND list_id = $2", itemID, listID).Scan(&bookID)
+	if err == pgx.ErrNoRows {
+		return 0, "", "", errListItemNotFound
+	} else if err != nil {
+		return 0, "", "", err
+	}
+
+	// Every other item in order; the moved item lands between two neighbours
+	rows, err := tx.Query(ctx, "SELECT id, position_key FROM list_items WHERE list_id = $1 AND id != $2 ORDER BY position_key", listID, itemID)
+	if err != nil {
+		return 0, "", "", err
+	}
+	ids := []string{}
+	keys := []string{}
+	for rows.Next() {
+		var id, key string
+		if err := rows.Scan(&id, &key); err == nil {
+			ids = append(ids, id)
+			keys = append(keys, key)
+		}
+	}
+	rows.Close()
+
+	index := -1
+	switch {
+	case afterItemID != nil:
+		for i, id := range ids {
+			if id == *afterItemID {
+				index = i + 1
+			}
+		}
+	case beforeItemID != nil:
+		for i, id := range ids {
+			if id == *beforeItemID {
+				index = i
+			}
+		}
+	default:
+		index = *order
+		if index < 0 {
+			index = 0
+		}
+		if index > len(ids) {
+			index = len(ids)
+		}
+	}
+
+	if index < 0 {
+		return 0, "", "", errNeighbourNotFound
+	}
+
+	// When both neighbours are given they must still be adjacent
+	if afterItemID != nil && beforeItemID != nil && (index >= len(ids) || ids[index] != *beforeItemID) {
+		return 0, "", "", errNotAdjacent
+	}
+
+	before, after := "", ""
+	if index > 0 {
+		before = keys[index-1]
+	}
+	if index < len(keys) {
+		after = keys[index]
+	}
+
+	positionKey, err := keyBetween(before, after)
+	if err != nil {
+		return 0, "", "", err
+	}
+
+	if _, err := tx.Exec(ctx, "UPDATE list_items SET position_key = $1 WHERE id = $2", positionKey, itemID); err != nil {
+		return 0, "", "", err
+	}
+	return index, positionKey, bookID, nil
+}
+
+// TransferListItems copies or moves items from this list to the end of another one.
+// Books already on the target are skipped when copying and only removed from the source
+// when moving; everything else is transferred together.
+func (h *ListHandler) TransferListItems(c echo.Context) error {
+	userID := auth.GetUserID(c)
+	if userID == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": "unauthorized",
+		})
+	}
+
+	sourceID := c.Param("id")
+
+	var req TransferListItemsRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "invalid request body",
+		})
+	}
+
+	if req.Mode == "" {
+		req.Mode = "copy"
+	}
+	if req.Mode != "copy" && req.Mode != "move" {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "invalid mode. Must be: copy or move",
+		})
+	}
+	if req.TargetListID == "" || req.TargetListID == sourceID {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "target_list_id must be a different list",
+		})
+	}
+	if len(req.ItemIDs) == 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "item_ids is required",
+		})
+	}
+	if len(req.ItemIDs) > maxBatchOperations {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": fmt.Sprintf("at most %d items can be transferred at once", maxBatchOperations),
+		})
+	}
+
+	includeNotes := true
+	if req.IncludeNotes != nil {
+		includeNotes = *req.IncludeNotes
+	}
+
+	expected, err := expectedListVersion(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 15*time.Second)
+	defer cancel()
+
+	tx, err := h.DB.Begin(ctx)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to start transaction",
+		})
+	}
+	defer tx.Rollback(ctx)
+
+	// Copying needs read access to the source, moving needs edit access
+	var sourcePublic bool
+	err = tx.QueryRow(ctx, "SELECT is_public FROM lists WHERE id = $1", sourceID).Scan(&sourcePublic)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": "list not found",
+		})
+	}
+	_, sourceRole, _ := getListRole(ctx, tx, sourceID, userID)
+	if req.Mode == "move" && !canEditList(sourceRole) {
+		return c.JSON(http.StatusForbidden, map[string]string{
+			"error": "you can only move items out of lists you own or edit",
+		})
+	}
+	if !sourcePublic && !canViewList(sourceRole) {
+		return c.JSON(http.StatusForbidden, map[string]string{
+			"error": "you don't have permission to view this list",
+		})
+	}
+
+	_, targetRole, err := getListRole(ctx, tx, req.TargetListID, userID)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": "target list not found",
+		})
+	}
+	if !canEditList(targetRole) {
+		return c.JSON(http.StatusForbidden, map[string]string{
+			"error": "you can only add books to lists you own or edit",
+		})
+	}
+	if isSmartList(ctx, tx, req.TargetListID) {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "books can't be added to a smart list; edit its rules instead",
+		})
+	}
+
+	// Lock both lists in a fixed order so two opposite transfers can't deadlock.
+	// If-Match refers to the source list.
+	locks := []string{sourceID, req.TargetListID}
+	sort.Strings(locks)
+	for _, id := range locks {
+		var want *int
+		if id == sourceID {
+			want = expected
+		}
+		if version, err := lockListVersion(ctx, tx, id, want); err == errListVersionConflict {
+			return listVersionConflict(c, version)
+		} else if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": "failed to transfer items",
+			})
+		}
+	}
+
+	// Keep the items' order from the source list
+	rows, err := tx.Query(ctx, `
+		SELECT id, book_id, notes FROM list_items
+		WHERE list_id = $1 AND id = ANY($2)
+		ORDER BY position_key
+	`, sourceID, req.ItemIDs)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to transfer items",
+		})
+	}
+	type sourceItem struct {
+		ID     string
+		BookID string
+		Notes  *string
+	}
+	found := map[string]bool{}
+	items := []sourceItem{}
+	for rows.Next() {
+		var item sourceItem
+		if err := rows.Scan(&item.ID, &item.BookID, &item.Notes); err == nil {
+			items = append(items, item)
+			found[item.ID] = true
+		}
+	}
+	rows.Close()
+
+	results := []map[string]interface{}{}
+	for _, id := range req.ItemIDs {
+		if !found[id] {
+			results = append(results, map[string]interface{}{
+				"item_id": id,
+				"status":  "error",
+				"error":   errListItemNotFound.Error(),
+			})
+		}
+	}
+
+	transferred := 0
+	added := []map[string]interface{}{}
+	removed := []map[string]interface{}{}
+	for _, item := range items {
+		result := map[string]interface{}{
+			"item_id": item.ID,
+			"book_id": item.BookID,
+		}
+
+		var notes *string
+		if includeNotes {
+			notes = item.Notes
+		}
+
+		// A book already on the target is skipped when copying; when moving it is
+		// still taken off the source, since the target already has it
+		newItem, err := appendListItem(ctx, tx, req.TargetListID, userID, item.BookID, notes)
+		if err == errBookAlreadyInList && req.Mode == "copy" {
+			result["status"] = "skipped"
+			result["error"] = err.Error()
+			results = append(results, result)
+			continue
+		} else if err != nil && err != errBookAlreadyInList {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": "failed to transfer items",
+			})
+		}
+
+		if err == errBookAlreadyInList {
+			result["already_in_target"] = true
+		} else {
+			result["new_item_id"] = newItem.ID
+			added = append(added, map[string]interface{}{"op": "add", "item_id": newItem.ID, "book_id": item.BookID})
+		}
+
+		if req.Mode == "move" {
+			if _, err := tx.Exec(ctx, "DELETE FROM list_items WHERE id = $1", item.ID); err != nil {
+				return c.JSON(http.StatusInternalServerError, map[string]string{
+					"error": "failed to transfer items",
+				})
+			}
+			removed = append(removed, map[string]interface{}{"op": "remove", "item_id": item.ID, "book_id": item.BookID, "notes": item.Notes})
+		}
+
+		transferred++
+		result["status"] = "ok"
+		results = append(results, result)
+	}
+
+	// One revision per list for the whole transfer
+	provenance := "copied_from_list_id"
+	if req.Mode == "move" {
+		provenance = "moved_from_list_id"
+	}
+	if len(added) > 0 {
+		err = recordListRevision(ctx, tx, req.TargetListID, userID, listRevisionChange{
+			Action:  "items_batch",
+			Details: map[string]interface{}{"operations": added, provenance: sourceID},
+		})
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": "failed to record revision",
+			})
+		}
+	}
+	if len(removed) > 0 {
+		err = recordListRevision(ctx, tx, sourceID, userID, listRevisionChange{
+			Action:  "items_batch",
+			Details: map[string]interface{}{"operations": removed, "moved_to_list_id": req.TargetListID},
+		})
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": "failed to record revision",
+			})
+		}
+	}
+
+	sourceVersion := currentListVersion(ctx, tx, sourceID)
+	targetVersion := currentListVersion(ctx, tx, req.TargetListID)
+	if err := tx.Commit(ctx); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to commit transaction",
+		})
+	}
+
+	c.Response().Header().Set("ETag", listETag(sourceVersion))
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"mode":           req.Mode,
+		"target_list_id": req.TargetListID,
+		"transferred":    transferred,
+		"results":        results,
+		"source_version": sourceVersion,
+		"target_version": targetVersion,
+	})
+}
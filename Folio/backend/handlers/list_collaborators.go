@@ -0,0 +1,558 @@
+package handlers
+
+import (
+	"context"
+	"folio/api/auth"
+	"net/http"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+)
+
+// validCollaboratorRoles mirrors the CHECK constraint on list_collaborators.role
+var validCollaboratorRoles = map[string]bool{
+	"editor": true,
+	"viewer": true,
+}
+
+type InviteCollaboratorRequest struct {
+	Username string `json:"username"`
+	Role     string `json:"role"`
+}
+
+type UpdateCollaboratorRequest struct {
+	Role string `json:"role"`
+}
+
+// rowQuerier is satisfied by both the pool and a transaction
+type rowQuerier interface {
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+}
+
+// getListRole returns the list owner and the user's role on the list:
+// "owner", "editor", "viewer", or "" when the user has no access beyond public visibility.
+// Pending invitations grant no role until accepted.
+func getListRole(ctx context.Context, q rowQuerier, listID, userID string) (ownerID string, role string, err error) {
+	err = q.QueryRow(ctx, `
+		SELECT l.user_id,
+		       CASE WHEN l.user_id = $2 THEN 'owner' ELSE COALESCE(lc.role, '') END
+		FROM lists l
+		LEFT JOIN list_collaborators lc ON lc.list_id = l.id AND lc.user_id = $2 AND lc.status = 'accepted'
+		WHERE l.id = $1
+	`, listID, nullableUserID(userID)).Scan(&ownerID, &role)
+	return ownerID, role, err
+}
+
+// getListItemRole is getListRole for an item, failing when the item isn't on the list
+func getListItemRole(ctx context.Context, q rowQuerier, listID, itemID, userID string) (ownerID string, role string, err error) {
+	err = q.QueryRow(ctx, `
+		SELECT l.user_id,
+		       CASE WHEN l.user_id = $3 THEN 'owner' ELSE COALESCE(lc.role, '') END
+		FROM lists l
+		JOIN list_items li ON l.id = li.list_id
+		LEFT JOIN list_collaborators lc ON lc.list_id = l.id AND lc.user_id = $3 AND lc.status = 'accepted'
+		WHERE l.id = $1 AND li.id = $2
+	`, listID, itemID, nullableUserID(userID)).Scan(&ownerID, &role)
+	return ownerID, role, err
+}
+
+// canEditList reports whether a role may change a list's items
+func canEditList(role string) bool {
+	return role == "owner" || role == "editor"
+}
+
+// canViewList reports whether a role may see a private list
+func canViewList(role string) bool {
+	return role != ""
+}
+
+// GetListCollaborators lists everyone invited to a list; visible to the owner and collaborators
+func (h *ListHandler) GetListCollaborators(c echo.Context) error {
+	userID := auth.GetUserID(c)
+	if userID == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": "unauthorized",
+		})
+	}
+
+	listID := c.Param("id")
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	_, role, err := getListRole(ctx, h.DB, listID, userID)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": "list not found",
+		})
+	}
+
+	if !canViewList(role) {
+		return c.JSON(http.StatusForbidden, map[string]string{
+			"error": "you don't have permission to view this list's collaborators",
+		})
+	}
+
+	query := `
+		SELECT lc.user_id, lc.role, lc.status, lc.created_at, lc.accepted_at,
+		       u.username, u.name, u.picture
+		FROM list_collaborators lc
+		JOIN users u ON lc.user_id = u.id
+		WHERE lc.list_id = $1
+		ORDER BY lc.created_at ASC
+	`
+
+	rows, err := h.DB.Query(ctx, query, listID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to fetch collaborators",
+		})
+	}
+	defer rows.Close()
+
+	collaborators := []map[string]interface{}{}
+	for rows.Next() {
+		var collaborator struct {
+			UserID     string
+			Role       string
+			Status     string
+			CreatedAt  time.Time
+			AcceptedAt *time.Time
+			Username   string
+			Name       string
+			Picture    *string
+		}
+
+		err := rows.Scan(
+			&collaborator.UserID, &collaborator.Role, &collaborator.Status,
+			&collaborator.CreatedAt, &collaborator.AcceptedAt,
+			&collaborator.Username, &collaborator.Name, &collaborator.Picture,
+		)
+		if err != nil {
+			continue
+		}
+
+		// Only the owner sees invitations that haven't been accepted yet
+		if collaborator.Status != "accepted" && role != "owner" && collaborator.UserID != userID {
+			continue
+		}
+
+		collaborators = append(collaborators, map[string]interface{}{
+			"role":        collaborator.Role,
+			"status":      collaborator.Status,
+			"invited_at":  collaborator.CreatedAt,
+			"accepted_at": collaborator.AcceptedAt,
+			"user": map[string]interface{}{
+				"id":       collaborator.UserID,
+				"username": collaborator.Username,
+				"name":     collaborator.Name,
+				"picture":  collaborator.Picture,
+			},
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"collaborators": collaborators,
+		"count":         len(collaborators),
+	})
+}
+
+// InviteCollaborator invites a user to a list as an editor or viewer. Owner only.
+func (h *ListHandler) InviteCollaborator(c echo.Context) error {
+	userID := auth.GetUserID(c)
+	if userID == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": "unauthorized",
+		})
+	}
+
+	listID := c.Param("id")
+
+	var req InviteCollaboratorRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "invalid request body",
+		})
+	}
+
+	if req.Username == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "username is required",
+		})
+	}
+
+	if req.Role == "" {
+		req.Role = "editor"
+	}
+	if !validCollaboratorRoles[req.Role] {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "invalid role. Must be: editor or viewer",
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	ownerID, _, err := getListRole(ctx, h.DB, listID, userID)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": "list not found",
+		})
+	}
+
+	if ownerID != userID {
+		return c.JSON(http.StatusForbidden, map[string]string{
+			"error": "only the list owner can invite collaborators",
+		})
+	}
+
+	var inviteeID string
+	err = h.DB.QueryRow(ctx, "SELECT id FROM users WHERE username = $1", req.Username).Scan(&inviteeID)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": "user not found",
+		})
+	}
+
+	if inviteeID == ownerID {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "you already own this list",
+		})
+	}
+
+	var status string
+	var createdAt time.Time
+	err = h.DB.QueryRow(ctx, `
+		INSERT INTO list_collaborators (list_id, user_id, role, invited_by)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (list_id, user_id) DO NOTHING
+		RETURNING status, created_at
+	`, listID, inviteeID, req.Role, userID).Scan(&status, &createdAt)
+	if err == pgx.ErrNoRows {
+		return c.JSON(http.StatusConflict, map[string]string{
+			"error": "user is already invited to this list",
+		})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to invite collaborator",
+		})
+	}
+
+	return c.JSON(http.StatusCreated, map[string]interface{}{
+		"list_id":    listID,
+		"user_id":    inviteeID,
+		"username":   req.Username,
+		"role":       req.Role,
+		"status":     status,
+		"invited_at": createdAt,
+	})
+}
+
+// AcceptListInvite accepts the current user's pending invitation to a list
+func (h *ListHandler) AcceptListInvite(c echo.Context) error {
+	userID := auth.GetUserID(c)
+	if userID == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": "unauthorized",
+		})
+	}
+
+	listID := c.Param("id")
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	var role string
+	var acceptedAt time.Time
+	err := h.DB.QueryRow(ctx, `
+		UPDATE list_collaborators SET status = 'accepted', accepted_at = NOW()
+		WHERE list_id = $1 AND user_id = $2 AND status = 'pending'
+		RETURNING role, accepted_at
+	`, listID, userID).Scan(&role, &acceptedAt)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": "invitation not found",
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"list_id":     listID,
+		"role":        role,
+		"status":      "accepted",
+		"accepted_at": acceptedAt,
+	})
+}
+
+// UpdateCollaborator changes a collaborator's role. Owner only.
+func (h *ListHandler) UpdateCollaborator(c echo.Context) error {
+	userID := auth.GetUserID(c)
+	if userID == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": "unauthorized",
+		})
+	}
+
+	listID := c.Param("id")
+	collaboratorID := c.Param("userId")
+
+	var req UpdateCollaboratorRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "invalid request body",
+		})
+	}
+
+	if !validCollaboratorRoles[req.Role] {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "invalid role. Must be: editor or viewer",
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	ownerID, _, err := getListRole(ctx, h.DB, listID, userID)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": "list not found",
+		})
+	}
+
+	if ownerID != userID {
+		return c.JSON(http.StatusForbidden, map[string]string{
+			"error": "only the list owner can change collaborator roles",
+		})
+	}
+
+	result, err := h.DB.Exec(ctx, "UPDATE list_collaborators SET role = $1 WHERE list_id = $2 AND user_id = $3", req.Role, listID, collaboratorID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to update collaborator",
+		})
+	}
+
+	if result.RowsAffected() == 0 {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": "collaborator not found",
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"list_id": listID,
+		"user_id": collaboratorID,
+		"role":    req.Role,
+	})
+}
+
+// RemoveCollaborator removes a collaborator or revokes an invitation.
+// The owner can remove anyone; collaborators can remove themselves to leave or decline.
+func (h *ListHandler) RemoveCollaborator(c echo.Context) error {
+	userID := auth.GetUserID(c)
+	if userID == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": "unauthorized",
+		})
+	}
+
+	listID := c.Param("id")
+	collaboratorID := c.Param("userId")
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	ownerID, _, err := getListRole(ctx, h.DB, listID, userID)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": "list not found",
+		})
+	}
+
+	if ownerID != userID && collaboratorID != userID {
+		return c.JSON(http.StatusForbidden, map[string]string{
+			"error": "only the list owner can remove other collaborators",
+		})
+	}
+
+	result, err := h.DB.Exec(ctx, "DELETE FROM list_collaborators WHERE list_id = $1 AND user_id = $2", listID, collaboratorID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to remove collaborator",
+		})
+	}
+
+	if result.RowsAffected() == 0 {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": "collaborator not found",
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message": "collaborator removed",
+	})
+}
+
+// GetMyListInvites lists the current user's pending list invitations
+func (h *ListHandler) GetMyListInvites(c echo.Context) error {
+	userID := auth.GetUserID(c)
+	if userID == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": "unauthorized",
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	query := `
+		SELECT l.id, l.name, l.description, l.items_count, lc.role, lc.created_at,
+		       u.id, u.username, u.name, u.picture
+		FROM list_collaborators lc
+		JOIN lists l ON lc.list_id = l.id
+		JOIN users u ON l.user_id = u.id
+		WHERE lc.user_id = $1 AND lc.status = 'pending'
+		ORDER BY lc.created_at DESC
+	`
+
+	rows, err := h.DB.Query(ctx, query, userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to fetch invitations",
+		})
+	}
+	defer rows.Close()
+
+	invites := []map[string]interface{}{}
+	for rows.Next() {
+		var invite struct {
+			ListID        string
+			Name          string
+			Description   *string
+			ItemsCount    int
+			Role          string
+			InvitedAt     time.Time
+			OwnerID       string
+			OwnerUsername string
+			OwnerName     string
+			OwnerPicture  *string
+		}
+
+		err := rows.Scan(
+			&invite.ListID, &invite.Name, &invite.Description, &invite.ItemsCount,
+			&invite.Role, &invite.InvitedAt,
+			&invite.OwnerID, &invite.OwnerUsername, &invite.OwnerName, &invite.OwnerPicture,
+		)
+		if err != nil {
+			continue
+		}
+
+		invites = append(invites, map[string]interface{}{
+			"role":       invite.Role,
+			"invited_at": invite.InvitedAt,
+			"list": map[string]interface{}{
+				"id":          invite.ListID,
+				"name":        invite.Name,
+				"description": invite.Description,
+				"items_count": invite.ItemsCount,
+			},
+			"owner": map[string]interface{}{
+				"id":       invite.OwnerID,
+				"username": invite.OwnerUsername,
+				"name":     invite.OwnerName,
+				"picture":  invite.OwnerPicture,
+			},
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"invites": invites,
+		"count":   len(invites),
+	})
+}
+
+// GetSharedLists lists the lists the current user collaborates on
+func (h *ListHandler) GetSharedLists(c echo.Context) error {
+	userID := auth.GetUserID(c)
+	if userID == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": "unauthorized",
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	query := `
+		SELECT l.id, l.user_id, l.name, l.description, l.is_public, l.header_image_url, l.theme_color,
+		       l.items_count, l.created_at, l.updated_at, lc.role,
+		       u.username, u.name, u.picture
+		FROM list_collaborators lc
+		JOIN lists l ON lc.list_id = l.id
+		JOIN users u ON l.user_id = u.id
+		WHERE lc.user_id = $1 AND lc.status = 'accepted'
+		ORDER BY l.updated_at DESC
+	`
+
+	rows, err := h.DB.Query(ctx, query, userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to fetch shared lists",
+		})
+	}
+	defer rows.Close()
+
+	lists := []map[string]interface{}{}
+	for rows.Next() {
+		var list struct {
+			ID             string
+			UserID         string
+			Name           string
+			Description    *string
+			IsPublic       bool
+			HeaderImageURL *string
+			ThemeColor     string
+			ItemsCount     int
+			CreatedAt      time.Time
+			UpdatedAt      time.Time
+			Role           string
+			Username       string
+			UserName       string
+			Picture        *string
+		}
+
+		err := rows.Scan(
+			&list.ID, &list.UserID, &list.Name, &list.Description, &list.IsPublic,
+			&list.HeaderImageURL, &list.ThemeColor, &list.ItemsCount, &list.CreatedAt, &list.UpdatedAt,
+			&list.Role, &list.Username, &list.UserName, &list.Picture,
+		)
+		if err != nil {
+			continue
+		}
+
+		lists = append(lists, map[string]interface{}{
+			"id":               list.ID,
+			"user_id":          list.UserID,
+			"name":             list.Name,
+			"description":      list.Description,
+			"is_public":        list.IsPublic,
+			"header_image_url": list.HeaderImageURL,
+			"theme_color":      list.ThemeColor,
+			"items_count":      list.ItemsCount,
+			"created_at":       list.CreatedAt,
+			"updated_at":       list.UpdatedAt,
+			"role":             list.Role,
+			"creator": map[string]interface{}{
+				"id":       list.UserID,
+				"username": list.Username,
+				"name":     list.UserName,
+				"picture":  list.Picture,
+			},
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"lists": lists,
+		"count": len(lists),
+	})
+}
@@ -0,0 +1,297 @@
+package handlers
+
+import (
+	"context"
+	"folio/api/auth"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+type ForkListRequest struct {
+	Name         *string `json:"name"`
+	IsPublic     *bool   `json:"is_public"`
+	IncludeNotes *bool   `json:"include_notes"`
+}
+
+// ForkList copies a list and its items, in order, into the current user's account
+func (h *ListHandler) ForkList(c echo.Context) error {
+	userID := auth.GetUserID(c)
+	if userID == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": "unauthorized",
+		})
+	}
+
+	sourceID := c.Param("id")
+
+	var req ForkListRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "invalid request body",
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	var source struct {
+		UserID         string
+		Name           string
+		Description    *string
+		IsPublic       bool
+		IsRanked       bool
+		HeaderImageURL *string
+		ThemeColor     string
+		ListType       string
+		SmartRules     []byte
+		Tags           []string
+	}
+	err := h.DB.QueryRow(ctx, `
+		SELECT user_id, name, description, is_public, is_ranked, header_image_url, theme_color, list_type, smart_rules, tags
+		FROM lists WHERE id = $1
+	`, sourceID).Scan(&source.UserID, &source.Name, &source.Description, &source.IsPublic, &source.IsRanked, &source.HeaderImageURL, &source.ThemeColor, &source.ListType, &source.SmartRules, &source.Tags)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": "list not found",
+		})
+	}
+
+	// Only public lists can be forked by others; the owner can still copy their own private list
+	if !source.IsPublic && source.UserID != userID {
+		return c.JSON(http.StatusForbidden, map[string]string{
+			"error": "you don't have permission to fork this list",
+		})
+	}
+
+	name := source.Name
+	if req.Name != nil {
+		name = *req.Name
+	}
+	if name == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "name is required",
+		})
+	}
+
+	// The fork starts with the source's visibility
+	isPublic := source.IsPublic
+	if req.IsPublic != nil {
+		isPublic = *req.IsPublic
+	}
+
+	includeNotes := true
+	if req.IncludeNotes != nil {
+		includeNotes = *req.IncludeNotes
+	}
+
+	tx, err := h.DB.Begin(ctx)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to start transaction",
+		})
+	}
+	defer tx.Rollback(ctx)
+
+	// A forked smart list keeps its rules, which then run over the forker's own logs
+	var listID string
+	var createdAt, updatedAt time.Time
+	err = tx.QueryRow(ctx, `
+		INSERT INTO lists (user_id, name, description, is_public, is_ranked, header_image_url, theme_color, list_type, smart_rules, tags, forked_from_list_id, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, NOW(), NOW())
+		RETURNING id, created_at, updated_at
+	`, userID, name, source.Description, isPublic, source.IsRanked, source.HeaderImageURL, source.ThemeColor, source.ListType, source.SmartRules, source.Tags, sourceID).Scan(&listID, &createdAt, &updatedAt)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to fork list",
+		})
+	}
+
+	// Position keys are copied as-is so the fork keeps the source's order
+	tag, err := tx.Exec(ctx, `
+		INSERT INTO list_items (list_id, book_id, notes, position_key, added_by, created_at)
+		SELECT $1, book_id, CASE WHEN $3 THEN notes END, position_key, $4, NOW()
+		FROM list_items
+		WHERE list_id = $2
+	`, listID, sourceID, includeNotes, userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to copy list items",
+		})
+	}
+	itemsCount := int(tag.RowsAffected())
+
+	// A smart fork counts its matches among the forker's logs
+	if source.ListType == smartListType {
+		rules, err := parseSmartListRules(source.SmartRules)
+		if err == nil {
+			itemsCount, err = refreshSmartListCount(ctx, tx, listID, userID, rules)
+		}
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": "failed to count list items",
+			})
+		}
+	}
+
+	if err := recordListRevision(ctx, tx, listID, userID, listRevisionChange{
+		Action:  "list_created",
+		Details: map[string]interface{}{"forked_from_list_id": sourceID},
+	}); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to record list history",
+		})
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to fork list",
+		})
+	}
+
+	version := currentListVersion(ctx, h.DB, listID)
+	c.Response().Header().Set("ETag", listETag(version))
+	return c.JSON(http.StatusCreated, map[string]interface{}{
+		"id":                  listID,
+		"user_id":             userID,
+		"name":                name,
+		"description":         source.Description,
+		"is_public":           isPublic,
+		"is_ranked":           source.IsRanked,
+		"header_image_url":    source.HeaderImageURL,
+		"theme_color":         source.ThemeColor,
+		"list_type":           source.ListType,
+		"tags":                source.Tags,
+		"items_count":         itemsCount,
+		"version":             version,
+		"forked_from_list_id": sourceID,
+		"created_at":          createdAt,
+		"updated_at":          updatedAt,
+	})
+}
+
+// forkSorts are the sort keys accepted by GetListForks
+var forkSorts = map[string]sortOption{
+	"created_at":  {Expr: "l.created_at", Type: "timestamptz", Desc: true},
+	"likes_count": {Expr: "COALESCE(l.likes_count, 0)", Type: "integer", Desc: true},
+	"forks_count": {Expr: "COALESCE(l.forks_count, 0)", Type: "integer", Desc: true},
+}
+
+// GetListForks returns a page of the public lists forked from a list
+func (h *ListHandler) GetListForks(c echo.Context) error {
+	listID := c.Param("id")
+
+	page, err := parsePageRequest(c, forkSorts, "created_at", 20, 100)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	var isPublic bool
+	err = h.DB.QueryRow(ctx, "SELECT is_public FROM lists WHERE id = $1", listID).Scan(&isPublic)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": "list not found",
+		})
+	}
+
+	currentUserID := auth.GetUserID(c)
+	_, role, _ := getListRole(ctx, h.DB, listID, currentUserID)
+	if !isPublic && !canViewList(role) {
+		return c.JSON(http.StatusForbidden, map[string]string{
+			"error": "you don't have permission to view this list",
+		})
+	}
+
+	// Private forks are only shown to their owners
+	qb := newQueryBuilder(listID, nullableUserID(currentUserID))
+	page.applyCursor(qb, "l.id")
+
+	query := `
+		SELECT l.id, l.user_id, l.name, l.description, l.is_public, l.header_image_url, l.theme_color,
+		       l.items_count, COALESCE(l.likes_count, 0), COALESCE(l.forks_count, 0), l.created_at, l.updated_at,
+		       u.username, u.name, u.picture,
+		       ` + page.cursorColumn() + `
+		FROM lists l
+		JOIN users u ON l.user_id = u.id
+		WHERE l.forked_from_list_id = $1
+		AND (l.is_public = true OR l.user_id = $2)` + qb.and() + `
+		` + page.orderBy(qb, "l.id")
+
+	rows, err := h.DB.Query(ctx, query, qb.args...)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to fetch forks",
+		})
+	}
+	defer rows.Close()
+
+	forks := []map[string]interface{}{}
+	cursors := []pageCursor{}
+	for rows.Next() {
+		var fork struct {
+			ID             string
+			UserID         string
+			Name           string
+			Description    *string
+			IsPublic       bool
+			HeaderImageURL *string
+			ThemeColor     string
+			ItemsCount     int
+			LikesCount     int
+			ForksCount     int
+			CreatedAt      time.Time
+			UpdatedAt      time.Time
+			Username       string
+			UserName       string
+			Picture        *string
+			CursorValue    string
+		}
+
+		err := rows.Scan(
+			&fork.ID, &fork.UserID, &fork.Name, &fork.Description, &fork.IsPublic,
+			&fork.HeaderImageURL, &fork.ThemeColor, &fork.ItemsCount, &fork.LikesCount,
+			&fork.ForksCount, &fork.CreatedAt, &fork.UpdatedAt,
+			&fork.Username, &fork.UserName, &fork.Picture, &fork.CursorValue,
+		)
+		if err != nil {
+			continue
+		}
+
+		cursors = append(cursors, pageCursor{Value: fork.CursorValue, ID: fork.ID})
+		forks = append(forks, map[string]interface{}{
+			"id":               fork.ID,
+			"user_id":          fork.UserID,
+			"name":             fork.Name,
+			"description":      fork.Description,
+			"is_public":        fork.IsPublic,
+			"header_image_url": fork.HeaderImageURL,
+			"theme_color":      fork.ThemeColor,
+			"items_count":      fork.ItemsCount,
+			"likes_count":      fork.LikesCount,
+			"forks_count":      fork.ForksCount,
+			"created_at":       fork.CreatedAt,
+			"updated_at":       fork.UpdatedAt,
+			"creator": map[string]interface{}{
+				"id":       fork.UserID,
+				"username": fork.Username,
+				"name":     fork.UserName,
+				"picture":  fork.Picture,
+			},
+		})
+	}
+
+	forks, nextCursor := page.trim(forks, cursors)
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"forks":       forks,
+		"count":       len(forks),
+		"next_cursor": nextCursor,
+		"has_more":    nextCursor != nil,
+	})
+}
@@ -0,0 +1,405 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"folio/api/auth"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// listSnapshotSQL builds the JSON snapshot of list $1 stored with each revision
+const listSnapshotSQL = `
+	jsonb_build_object(
+		'name', l.name,
+		'description', l.description,
+		'is_public', l.is_public,
+		'is_ranked', l.is_ranked,
+		'header_image_url', l.header_image_url,
+		'theme_color', l.theme_color,
+		'items', COALESCE((
+			SELECT jsonb_agg(jsonb_build_object(
+				'book_id', li.book_id,
+				'notes', li.notes,
+				'position_key', li.position_key,
+				'added_by', li.added_by
+			) ORDER BY li.position_key)
+			FROM list_items li WHERE li.list_id = l.id
+		), '[]'::jsonb)
+	)`
+
+// listRevisionChange describes one change for recordListRevision
+type listRevisionChange struct {
+	Action  string
+	ItemID  *string
+	BookID  *string
+	Details map[string]interface{}
+}
+
+// recordListRevision stores a revision for the list's current version and state.
+// Call it after the change, inside the same transaction where there is one.
+func recordListRevision(ctx context.Context, q rowQuerier, listID, userID string, change listRevisionChange) error {
+	details := change.Details
+	if details == nil {
+		details = map[string]interface{}{}
+	}
+	detailsJSON, err := json.Marshal(details)
+	if err != nil {
+		return err
+	}
+
+	var revisionID string
+	return q.QueryRow(ctx, `
+		INSERT INTO list_revisions (list_id, version, user_id, action, item_id, book_id, details, snapshot)
+		SELECT l.id, l.version, $2, $3, $4, $5, $6, `+listSnapshotSQL+`
+		FROM lists l
+		WHERE l.id = $1
+		RETURNING id
+	`, listID, nullableUserID(userID), change.Action, change.ItemID, change.BookID, detailsJSON).Scan(&revisionID)
+}
+
+// listSnapshot is the stored list state a revision can be restored to
+type listSnapshot struct {
+	Name           string  `json:"name"`
+	Description    *string `json:"description"`
+	IsPublic       bool    `json:"is_public"`
+	IsRanked       bool    `json:"is_ranked"`
+	HeaderImageURL *string `json:"header_image_url"`
+	ThemeColor     *string `json:"theme_color"`
+	Items          []struct {
+		BookID      string  `json:"book_id"`
+		Notes       *string `json:"notes"`
+		PositionKey string  `json:"position_key"`
+		AddedBy     *string `json:"added_by"`
+	} `json:"items"`
+}
+
+// listHistorySorts pages history newest first
+var listHistorySorts = map[string]sortOption{
+	"version": {Expr: "r.version", Type: "integer", Desc: true},
+}
+
+// GetListHistory returns a page of a list's revisions, newest first.
+// since_version limits it to changes after a version the client already has.
+func (h *ListHandler) GetListHistory(c echo.Context) error {
+	listID := c.Param("id")
+
+	page, err := parsePageRequest(c, listHistorySorts, "version", 50, 100)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	sinceVersion, err := parseOptionalInt(c, "since_version")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	currentUserID := auth.GetUserID(c)
+
+	var isPublic bool
+	err = h.DB.QueryRow(ctx, "SELECT is_public FROM lists WHERE id = $1", listID).Scan(&isPublic)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": "list not found",
+		})
+	}
+
+	_, role, _ := getListRole(ctx, h.DB, listID, currentUserID)
+	if !isPublic && !canViewList(role) {
+		return c.JSON(http.StatusForbidden, map[string]string{
+			"error": "you don't have permission to view this list",
+		})
+	}
+
+	qb := newQueryBuilder(listID)
+	if sinceVersion != nil {
+		qb.where("r.version > " + qb.arg(*sinceVersion))
+	}
+	page.applyCursor(qb, "r.id")
+
+	revisions, cursors, err := h.queryListRevisions(ctx, qb, page.cursorColumn(), page.orderBy(qb, "r.id"))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to fetch list history",
+		})
+	}
+
+	revisions, nextCursor := page.trim(revisions, cursors)
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"revisions":   revisions,
+		"count":       len(revisions),
+		"next_cursor": nextCursor,
+		"has_more":    nextCursor != nil,
+	})
+}
+
+// queryListRevisions runs the revision query for the list in $1 with the builder's conditions
+func (h *ListHandler) queryListRevisions(ctx context.Context, qb *queryBuilder, cursorColumn, orderBy string) ([]map[string]interface{}, []pageCursor, error) {
+	query := `
+		SELECT r.id, r.version, r.action, r.item_id, r.book_id, r.details, r.created_at,
+		       b.title, b.cover_url,
+		       u.id, u.username, u.name, u.picture,
+		       ` + cursorColumn + `
+		FROM list_revisions r
+		LEFT JOIN books b ON r.book_id = b.id
+		LEFT JOIN users u ON r.user_id = u.id
+		WHERE r.list_id = $1` + qb.and() + `
+		` + orderBy
+
+	rows, err := h.DB.Query(ctx, query, qb.args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	revisions := []map[string]interface{}{}
+	cursors := []pageCursor{}
+	for rows.Next() {
+		var revision struct {
+			ID          string
+			Version     int
+			Action      string
+			ItemID      *string
+			BookID      *string
+			Details     map[string]interface{}
+			CreatedAt   time.Time
+			BookTitle   *string
+			CoverURL    *string
+			UserID      *string
+			Username    *string
+			Name        *string
+			Picture     *string
+			CursorValue string
+		}
+
+		err := rows.Scan(
+			&revision.ID, &revision.Version, &revision.Action, &revision.ItemID, &revision.BookID,
+			&revision.Details, &revision.CreatedAt,
+			&revision.BookTitle, &revision.CoverURL,
+			&revision.UserID, &revision.Username, &revision.Name, &revision.Picture,
+			&revision.CursorValue,
+		)
+		if err != nil {
+			continue
+		}
+
+		var book, user map[string]interface{}
+		if revision.BookID != nil {
+			book = map[string]interface{}{
+				"id":        *revision.BookID,
+				"title":     revision.BookTitle,
+				"cover_url": revision.CoverURL,
+			}
+		}
+		if revision.UserID != nil {
+			user = map[string]interface{}{
+				"id":       *revision.UserID,
+				"username": revision.Username,
+				"name":     revision.Name,
+				"picture":  revision.Picture,
+			}
+		}
+
+		cursors = append(cursors, pageCursor{Value: revision.CursorValue, ID: revision.ID})
+		revisions = append(revisions, map[string]interface{}{
+			"id":         revision.ID,
+			"version":    revision.Version,
+			"action":     revision.Action,
+			"item_id":    revision.ItemID,
+			"details":    revision.Details,
+			"created_at": revision.CreatedAt,
+			"book":       book,
+			"user":       user,
+		})
+	}
+
+	return revisions, cursors, rows.Err()
+}
+
+// RestoreListRevision puts a list back to the state stored with a revision.
+// The restore is itself recorded as a new revision, so it can be undone the same way.
+func (h *ListHandler) RestoreListRevision(c echo.Context) error {
+	userID := auth.GetUserID(c)
+	if userID == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": "unauthorized",
+		})
+	}
+
+	listID := c.Param("id")
+	revisionID := c.Param("revisionId")
+
+	expected, err := expectedListVersion(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	tx, err := h.DB.Begin(ctx)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to start transaction",
+		})
+	}
+	defer tx.Rollback(ctx)
+
+	ownerID, _, err := getListRole(ctx, tx, listID, userID)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": "list not found",
+		})
+	}
+
+	// Restoring can change list details, which only the owner may edit
+	if ownerID != userID {
+		return c.JSON(http.StatusForbidden, map[string]string{
+			"error": "only the list owner can restore a revision",
+		})
+	}
+
+	if version, err := lockListVersion(ctx, tx, listID, expected); err == errListVersionConflict {
+		return listVersionConflict(c, version)
+	} else if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to restore list",
+		})
+	}
+
+	var restoredVersion int
+	var raw []byte
+	err = tx.QueryRow(ctx, "SELECT version, snapshot FROM list_revisions WHERE id = $1 AND list_id = $2", revisionID, listID).Scan(&restoredVersion, &raw)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": "revision not found",
+		})
+	}
+
+	var snapshot listSnapshot
+	if err := json.Unmarshal(raw, &snapshot); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to read revision",
+		})
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE lists
+		SET name = $2, description = $3, is_public = $4, is_ranked = $5, header_image_url = $6,
+		    theme_color = COALESCE($7, theme_color), version = version + 1, updated_at = NOW()
+		WHERE id = $1
+	`, listID, snapshot.Name, snapshot.Description, snapshot.IsPublic, snapshot.IsRanked, snapshot.HeaderImageURL, snapshot.ThemeColor)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to restore list",
+		})
+	}
+
+	bookIDs := make([]string, 0, len(snapshot.Items))
+	for _, item := range snapshot.Items {
+		bookIDs = append(bookIDs, item.BookID)
+	}
+
+	_, err = tx.Exec(ctx, "DELETE FROM list_items WHERE list_id = $1 AND NOT (book_id = ANY($2))", listID, bookIDs)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to restore list",
+		})
+	}
+
+	for _, item := range snapshot.Items {
+		_, err = tx.Exec(ctx, `
+			INSERT INTO list_items (list_id, book_id, notes, position_key, added_by, created_at)
+			VALUES ($1, $2, $3, $4, $5, NOW())
+			ON CONFLICT (list_id, book_id) DO UPDATE
+			SET notes = EXCLUDED.notes, position_key = EXCLUDED.position_key
+		`, listID, item.BookID, item.Notes, item.PositionKey, item.AddedBy)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": "failed to restore list",
+			})
+		}
+	}
+
+	err = recordListRevision(ctx, tx, listID, userID, listRevisionChange{
+		Action: "list_restored",
+		Details: map[string]interface{}{
+			"restored_revision_id": revisionID,
+			"restored_version":     restoredVersion,
+		},
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to record revision",
+		})
+	}
+
+	version := currentListVersion(ctx, tx, listID)
+	if err := tx.Commit(ctx); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to commit transaction",
+		})
+	}
+
+	c.Response().Header().Set("ETag", listETag(version))
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message":          "list restored",
+		"restored_version": restoredVersion,
+		"version":          version,
+	})
+}
+
+// changesSinceLastView returns the revisions made since the user last opened the list and
+// records the current version as seen. It returns nil on a first visit or when nothing changed.
+func (h *ListHandler) changesSinceLastView(ctx context.Context, listID, userID string, currentVersion int) map[string]interface{} {
+	if userID == "" {
+		return nil
+	}
+
+	var lastVersion int
+	var lastViewedAt time.Time
+	err := h.DB.QueryRow(ctx, `
+		SELECT last_viewed_version, last_viewed_at FROM list_views
+		WHERE list_id = $1 AND user_id = $2
+	`, listID, userID).Scan(&lastVersion, &lastViewedAt)
+	seenBefore := err == nil
+
+	h.DB.Exec(ctx, `
+		INSERT INTO list_views (list_id, user_id, last_viewed_version, last_viewed_at)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (list_id, user_id) DO UPDATE
+		SET last_viewed_version = EXCLUDED.last_viewed_version, last_viewed_at = NOW()
+	`, listID, userID, currentVersion)
+
+	if !seenBefore || lastVersion >= currentVersion {
+		return nil
+	}
+
+	// Other people's changes only; the viewer knows what they did themselves
+	qb := newQueryBuilder(listID)
+	qb.where("r.version > " + qb.arg(lastVersion))
+	qb.where("r.user_id IS DISTINCT FROM " + qb.arg(userID))
+	changes, _, err := h.queryListRevisions(ctx, qb, "r.version::text", "ORDER BY r.version ASC\n\t\tLIMIT 50")
+	if err != nil || len(changes) == 0 {
+		return nil
+	}
+
+	return map[string]interface{}{
+		"since_version":  lastVersion,
+		"last_viewed_at": lastViewedAt,
+		"changes":        changes,
+		"count":          len(changes),
+	}
+}
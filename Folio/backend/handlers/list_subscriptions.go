@@ -0,0 +1,285 @@
+package handlers
+
+import (
+	"context"
+	"folio/api/auth"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// SubscribeToList follows a list so its changes show up in the feed
+func (h *ListHandler) SubscribeToList(c echo.Context) error {
+	userID := auth.GetUserID(c)
+	if userID == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": "unauthorized",
+		})
+	}
+
+	listID := c.Param("id")
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	// Public lists can be followed by anyone; private ones only by collaborators
+	var isPublic bool
+	var version int
+	err := h.DB.QueryRow(ctx, "SELECT is_public, version FROM lists WHERE id = $1", listID).Scan(&isPublic, &version)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": "list not found",
+		})
+	}
+
+	ownerID, role, _ := getListRole(ctx, h.DB, listID, userID)
+	if !isPublic && !canViewList(role) {
+		return c.JSON(http.StatusForbidden, map[string]string{
+			"error": "cannot subscribe to private list",
+		})
+	}
+
+	if ownerID == userID {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "you can't subscribe to your own list",
+		})
+	}
+
+	_, err = h.DB.Exec(ctx, `
+		INSERT INTO list_subscriptions (list_id, user_id, created_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (list_id, user_id) DO NOTHING
+	`, listID, userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to subscribe to list",
+		})
+	}
+
+	// Start tracking changes from the version the subscriber has now
+	h.DB.Exec(ctx, `
+		INSERT INTO list_views (list_id, user_id, last_viewed_version, last_viewed_at)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (list_id, user_id) DO NOTHING
+	`, listID, userID, version)
+
+	var subscribersCount int
+	h.DB.QueryRow(ctx, "SELECT COALESCE(subscribers_count, 0) FROM lists WHERE id = $1", listID).Scan(&subscribersCount)
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success":           true,
+		"is_subscribed":     true,
+		"subscribers_count": subscribersCount,
+	})
+}
+
+// UnsubscribeFromList stops following a list
+func (h *ListHandler) UnsubscribeFromList(c echo.Context) error {
+	userID := auth.GetUserID(c)
+	if userID == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": "unauthorized",
+		})
+	}
+
+	listID := c.Param("id")
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	_, err := h.DB.Exec(ctx, "DELETE FROM list_subscriptions WHERE list_id = $1 AND user_id = $2", listID, userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to unsubscribe from list",
+		})
+	}
+
+	var subscribersCount int
+	h.DB.QueryRow(ctx, "SELECT COALESCE(subscribers_count, 0) FROM lists WHERE id = $1", listID).Scan(&subscribersCount)
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success":           true,
+		"is_subscribed":     false,
+		"subscribers_count": subscribersCount,
+	})
+}
+
+// subscribedListSorts are the sort keys accepted by GetSubscribedLists
+var subscribedListSorts = map[string]sortOption{
+	"subscribed_at": {Expr: "s.created_at", Type: "timestamptz", Desc: true},
+	"updated_at":    {Expr: "l.updated_at", Type: "timestamptz", Desc: true},
+	"name":          {Expr: "l.name", Type: "text", Desc: false},
+}
+
+// GetSubscribedLists returns a page of the lists the current user follows, with how many
+// changes other people have made since the user last viewed each one
+func (h *ListHandler) GetSubscribedLists(c echo.Context) error {
+	userID := auth.GetUserID(c)
+	if userID == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": "unauthorized",
+		})
+	}
+
+	page, err := parsePageRequest(c, subscribedListSorts, "subscribed_at", 50, 100)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	qb := newQueryBuilder(userID)
+	page.applyCursor(qb, "l.id")
+
+	// Lists that turned private drop out unless the subscriber collaborates on them
+	query := `
+		SELECT l.id, l.user_id, l.name, l.description, l.is_public, l.header_image_url, l.theme_color,
+		       l.items_count, COALESCE(l.subscribers_count, 0), l.version, l.created_at, l.updated_at,
+		       s.created_at,
+		       u.username, u.name, u.picture,
+		       (
+		           SELECT COUNT(*) FROM list_revisions r
+		           WHERE r.list_id = l.id
+		             AND r.version > COALESCE(v.last_viewed_version, 0)
+		             AND r.user_id IS DISTINCT FROM $1
+		       ) as unseen_changes,
+		       ` + page.cursorColumn() + `
+		FROM list_subscriptions s
+		JOIN lists l ON s.list_id = l.id
+		JOIN users u ON l.user_id = u.id
+		LEFT JOIN list_views v ON v.list_id = l.id AND v.user_id = $1
+		WHERE s.user_id = $1
+		AND (l.is_public = true OR EXISTS(
+			SELECT 1 FROM list_collaborators lc
+			WHERE lc.list_id = l.id AND lc.user_id = $1 AND lc.status = 'accepted'
+		))` + qb.and() + `
+		` + page.orderBy(qb, "l.id")
+
+	rows, err := h.DB.Query(ctx, query, qb.args...)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to fetch subscribed lists",
+		})
+	}
+	defer rows.Close()
+
+	lists := []map[string]interface{}{}
+	cursors := []pageCursor{}
+	for rows.Next() {
+		var list struct {
+			ID               string
+			UserID           string
+			Name             string
+			Description      *string
+			IsPublic         bool
+			HeaderImageURL   *string
+			ThemeColor       string
+			ItemsCount       int
+			SubscribersCount int
+			Version          int
+			CreatedAt        time.Time
+			UpdatedAt        time.Time
+			SubscribedAt     time.Time
+			Username         string
+			UserName         string
+			Picture          *string
+			UnseenChanges    int
+			CursorValue      string
+		}
+
+		err := rows.Scan(
+			&list.ID, &list.UserID, &list.Name, &list.Description, &list.IsPublic,
+			&list.HeaderImageURL, &list.ThemeColor, &list.ItemsCount, &list.SubscribersCount,
+			&list.Version, &list.CreatedAt, &list.UpdatedAt, &list.SubscribedAt,
+			&list.Username, &list.UserName, &list.Picture, &list.UnseenChanges,
+			&list.CursorValue,
+		)
+		if err != nil {
+			continue
+		}
+
+		cursors = append(cursors, pageCursor{Value: list.CursorValue, ID: list.ID})
+		lists = append(lists, map[string]interface{}{
+			"id":                list.ID,
+			"user_id":           list.UserID,
+			"name":              list.Name,
+			"description":       list.Description,
+			"is_public":         list.IsPublic,
+			"header_image_url":  list.HeaderImageURL,
+			"theme_color":       list.ThemeColor,
+			"items_count":       list.ItemsCount,
+			"subscribers_count": list.SubscribersCount,
+			"version":           list.Version,
+			"created_at":        list.CreatedAt,
+			"updated_at":        list.UpdatedAt,
+			"subscribed_at":     list.SubscribedAt,
+			"unseen_changes":    list.UnseenChanges,
+			"creator": map[string]interface{}{
+				"id":       list.UserID,
+				"username": list.Username,
+				"name":     list.UserName,
+				"picture":  list.Picture,
+			},
+		})
+	}
+
+	lists, nextCursor := page.trim(lists, cursors)
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"lists":       lists,
+		"count":       len(lists),
+		"next_cursor": nextCursor,
+		"has_more":    nextCursor != nil,
+	})
+}
+
+// subscribedListFeedSQL selects feed events for books other people added to lists the
+// user ($1) follows, grouped per list, adder and day. Adds recorded inside batch revisions
+// count too. Its columns match GetFeed's.
+const subscribedListFeedSQL = `
+	SELECT
+		'list_items_added' as event_type,
+		l.id as entity_id,
+		r.user_id,
+		l.name as title,
+		l.description,
+		l.items_count,
+		l.likes_count,
+		l.comments_count,
+		l.header_image_url,
+		l.theme_color,
+		MAX(r.created_at) as created_at,
+		u.username,
+		u.name as user_name,
+		u.picture,
+		array_agg(b.id::text ORDER BY r.created_at DESC) as book_ids,
+		array_agg(COALESCE(b.title, '')::text ORDER BY r.created_at DESC) as book_titles,
+		array_agg(COALESCE(b.cover_url, '')::text ORDER BY r.created_at DESC) as book_covers,
+		EXISTS(SELECT 1 FROM list_likes WHERE list_id = l.id AND user_id = $1) as is_liked
+	FROM list_subscriptions s
+	JOIN lists l ON s.list_id = l.id
+	JOIN list_revisions r ON r.list_id = l.id AND r.action IN ('item_added', 'items_batch')
+	CROSS JOIN LATERAL (
+		SELECT r.book_id WHERE r.action = 'item_added'
+		UNION ALL
+		SELECT op->>'book_id' FROM jsonb_array_elements(r.details->'operations') op
+		WHERE r.action = 'items_batch' AND op->>'op' = 'add'
+	) added
+	JOIN users u ON r.user_id = u.id
+	JOIN books b ON added.book_id = b.id
+	WHERE s.user_id = $1
+	AND r.user_id <> $1
+	AND r.created_at > s.created_at
+	AND r.created_at > NOW() - INTERVAL '30 days'
+	AND (l.is_public = true OR EXISTS(
+		SELECT 1 FROM list_collaborators lc
+		WHERE lc.list_id = l.id AND lc.user_id = $1 AND lc.status = 'accepted'
+	))
+	GROUP BY l.id, r.user_id, u.username, u.name, u.picture, date_trunc('day', r.created_at)
+	ORDER BY created_at DESC
+	LIMIT 50
+`
@@ -0,0 +1,258 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"folio/api/auth"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	maxListTags      = 10
+	maxListTagLength = 40
+)
+
+// normalizeListTag lowercases a tag and collapses its whitespace, so "Cozy  Mysteries" and
+// "cozy mysteries" are the same tag
+func normalizeListTag(tag string) string {
+	return strings.Join(strings.Fields(strings.ToLower(tag)), " ")
+}
+
+// normalizeListTags normalizes and de-duplicates the tags set on a list
+func normalizeListTags(tags []string) ([]string, error) {
+	normalized := []string{}
+	seen := map[string]bool{}
+	for _, tag := range tags {
+		tag = normalizeListTag(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		if len(tag) > maxListTagLength {
+			return nil, fmt.Errorf("tags must be at most %d characters", maxListTagLength)
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	if len(normalized) > maxListTags {
+		return nil, fmt.Errorf("a list can have at most %d tags", maxListTags)
+	}
+	return normalized, nil
+}
+
+// tagQueryParams reads the tag query parameter, which may be repeated or comma separated
+func tagQueryParams(c echo.Context) []string {
+	tags := []string{}
+	seen := map[string]bool{}
+	for _, value := range c.QueryParams()["tag"] {
+		for _, tag := range strings.Split(value, ",") {
+			tag = normalizeListTag(tag)
+			if tag != "" && !seen[tag] {
+				seen[tag] = true
+				tags = append(tags, tag)
+			}
+		}
+	}
+	return tags
+}
+
+// listPopularityExpr scores a list by engagement; subscriptions and forks count for more
+// than likes since they show ongoing interest
+const listPopularityExpr = `LN(1 + COALESCE(l.likes_count, 0) + 2 * COALESCE(l.subscribers_count, 0) + 3 * COALESCE(l.forks_count, 0))`
+
+// browseListSorts are the sort keys accepted by BrowseLists. "rank" puts lists matching more
+// of the requested tags first, then the most popular.
+var browseListSorts = map[string]sortOption{
+	"rank": {
+		Expr: `((SELECT COUNT(*) FROM unnest(l.tags) t WHERE t = ANY(r.tags)) * 100 + ` + listPopularityExpr + `)::float8`,
+		Type: "float8",
+		Desc: true,
+	},
+	"likes_count":       {Expr: "COALESCE(l.likes_count, 0)", Type: "integer", Desc: true},
+	"subscribers_count": {Expr: "COALESCE(l.subscribers_count, 0)", Type: "integer", Desc: true},
+	"created_at":        {Expr: "l.created_at", Type: "timestamptz", Desc: true},
+	"updated_at":        {Expr: "l.updated_at", Type: "timestamptz", Desc: true},
+}
+
+// BrowseLists returns a ranked page of public lists, optionally filtered by tag.
+// With several tags, match=all (the default) requires every tag and match=any requires one.
+func (h *ListHandler) BrowseLists(c echo.Context) error {
+	page, err := parsePageRequest(c, browseListSorts, "rank", 20, 100)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	tags := tagQueryParams(c)
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	qb := newQueryBuilder(tags)
+	if len(tags) > 0 {
+		switch c.QueryParam("match") {
+		case "", "all":
+			qb.where("l.tags @> r.tags")
+		case "any":
+			qb.where("l.tags && r.tags")
+		default:
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "match must be 'all' or 'any'",
+			})
+		}
+	}
+	if q := c.QueryParam("q"); q != "" {
+		qb.where("l.name ILIKE " + qb.arg("%"+q+"%"))
+	}
+	page.applyCursor(qb, "l.id")
+
+	// The requested tags are joined in as r.tags for the filters and the rank sort
+	query := `
+		WITH requested AS (SELECT $1::text[] as tags)
+		SELECT l.id, l.user_id, l.name, l.description, l.header_image_url, l.theme_color, l.tags,
+		       l.items_count, COALESCE(l.likes_count, 0), COALESCE(l.subscribers_count, 0), COALESCE(l.forks_count, 0),
+		       l.list_type, l.created_at, l.updated_at,
+		       u.username, u.name, u.picture,
+		       ` + page.cursorColumn() + `
+		FROM lists l
+		JOIN users u ON l.user_id = u.id
+		CROSS JOIN requested r
+		WHERE l.is_public = true AND l.items_count > 0` + qb.and() + `
+		` + page.orderBy(qb, "l.id")
+
+	rows, err := h.DB.Query(ctx, query, qb.args...)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to fetch lists",
+		})
+	}
+	defer rows.Close()
+
+	lists := []map[string]interface{}{}
+	cursors := []pageCursor{}
+	for rows.Next() {
+		var list struct {
+			ID               string
+			UserID           string
+			Name             string
+			Description      *string
+			HeaderImageURL   *string
+			ThemeColor       string
+			Tags             []string
+			ItemsCount       int
+			LikesCount       int
+			SubscribersCount int
+			ForksCount       int
+			ListType         string
+			CreatedAt        time.Time
+			UpdatedAt        time.Time
+			Username         string
+			UserName         string
+			Picture          *string
+			CursorValue      string
+		}
+
+		err := rows.Scan(
+			&list.ID, &list.UserID, &list.Name, &list.Description, &list.HeaderImageURL,
+			&list.ThemeColor, &list.Tags, &list.ItemsCount, &list.LikesCount,
+			&list.SubscribersCount, &list.ForksCount, &list.ListType, &list.CreatedAt, &list.UpdatedAt,
+			&list.Username, &list.UserName, &list.Picture, &list.CursorValue,
+		)
+		if err != nil {
+			continue
+		}
+
+		cursors = append(cursors, pageCursor{Value: list.CursorValue, ID: list.ID})
+		lists = append(lists, map[string]interface{}{
+			"id":                list.ID,
+			"user_id":           list.UserID,
+			"name":              list.Name,
+			"description":       list.Description,
+			"header_image_url":  list.HeaderImageURL,
+			"theme_color":       list.ThemeColor,
+			"tags":              list.Tags,
+			"items_count":       list.ItemsCount,
+			"likes_count":       list.LikesCount,
+			"subscribers_count": list.SubscribersCount,
+			"forks_count":       list.ForksCount,
+			"list_type":         list.ListType,
+			"created_at":        list.CreatedAt,
+			"updated_at":        list.UpdatedAt,
+			"creator": map[string]interface{}{
+				"id":       list.UserID,
+				"username": list.Username,
+				"name":     list.UserName,
+				"picture":  list.Picture,
+			},
+		})
+	}
+
+	lists, nextCursor := page.trim(lists, cursors)
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"lists":       lists,
+		"tags":        tags,
+		"count":       len(lists),
+		"next_cursor": nextCursor,
+		"has_more":    nextCursor != nil,
+	})
+}
+
+// GetListTags autocompletes tags used on public lists, most used first.
+// Without q it returns the most popular tags; the current user's own tags are included too.
+func (h *ListHandler) GetListTags(c echo.Context) error {
+	limit := 10
+	if l, err := parseOptionalInt(c, "limit"); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	} else if l != nil && *l > 0 && *l <= 50 {
+		limit = *l
+	}
+
+	prefix := normalizeListTag(c.QueryParam("q"))
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	// Escape LIKE wildcards so the prefix is matched literally
+	pattern := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(prefix) + "%"
+
+	rows, err := h.DB.Query(ctx, `
+		SELECT tag, COUNT(*) as lists_count
+		FROM lists l, unnest(l.tags) as tag
+		WHERE (l.is_public = true OR l.user_id = $1)
+		AND tag LIKE $2
+		GROUP BY tag
+		ORDER BY lists_count DESC, tag
+		LIMIT $3
+	`, nullableUserID(auth.GetUserID(c)), pattern, limit)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to fetch tags",
+		})
+	}
+	defer rows.Close()
+
+	tags := []map[string]interface{}{}
+	for rows.Next() {
+		var tag string
+		var count int
+		if err := rows.Scan(&tag, &count); err != nil {
+			continue
+		}
+		tags = append(tags, map[string]interface{}{
+			"tag":         tag,
+			"lists_count": count,
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"tags":  tags,
+		"count": len(tags),
+	})
+}
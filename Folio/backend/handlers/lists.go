@@ -2,10 +2,13 @@ package handlers
 
 import (
 	"context"
+	"encoding/json"
 	"folio/api/auth"
 	"net/http"
+	"strconv"
 	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/labstack/echo/v4"
 )
@@ -18,16 +21,22 @@ type CreateListRequest struct {
 	Name            string  `json:"name"`
 	Description     string  `json:"description"`
 	IsPublic        *bool   `json:"is_public"`
+	IsRanked        *bool   `json:"is_ranked"`
 	HeaderImageURL  *string `json:"header_image_url"`
 	ThemeColor      *string `json:"theme_color"`
+	SmartRules      *SmartListRules `json:"smart_rules"`
+	Tags            []string `json:"tags"`
 }
 
 type UpdateListRequest struct {
 	Name            *string `json:"name"`
 	Description     *string `json:"description"`
 	IsPublic        *bool   `json:"is_public"`
+	IsRanked        *bool   `json:"is_ranked"`
 	HeaderImageURL  *string `json:"header_image_url"`
 	ThemeColor      *string `json:"theme_color"`
+	SmartRules      *SmartListRules `json:"smart_rules"`
+	Tags            []string `json:"tags"`
 }
 
 type AddBookToListRequest struct {
@@ -40,7 +49,9 @@ type UpdateListItemRequest struct {
 }
 
 type UpdateListItemOrderRequest struct {
-	Order int `json:"order"`
+	Order        *int    `json:"order"`
+	AfterItemID  *string `json:"after_item_id"`
+	BeforeItemID *string `json:"before_item_id"`
 }
 
 type ReorderListItemsRequest struct {
@@ -77,41 +88,113 @@ func (h *ListHandler) CreateList(c echo.Context) error {
 		isPublic = *req.IsPublic
 	}
 
+	isRanked := false
+	if req.IsRanked != nil {
+		isRanked = *req.IsRanked
+	}
+
 	themeColor := "#6366f1"
 	if req.ThemeColor != nil {
 		themeColor = *req.ThemeColor
 	}
 
+	// Lists created with rules are smart lists
+	listType := staticListType
+	var smartRules []byte
+	if req.SmartRules != nil {
+		if err := req.SmartRules.validate(); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": err.Error(),
+			})
+		}
+		listType = smartListType
+		smartRules, _ = json.Marshal(req.SmartRules)
+	}
+
+	tags, err := normalizeListTags(req.Tags)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
 	query := `
-		INSERT INTO lists (user_id, name, description, is_public, header_image_url, theme_color, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, NOW(), NOW())
-		RETURNING id, created_at, updated_at
+		INSERT INTO lists (user_id, name, description, is_public, is_ranked, header_image_url, theme_color, list_type, smart_rules, tags, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NOW(), NOW())
+		RETURNING id, version, created_at, updated_at
 	`
 
+	tx, err := h.DB.Begin(ctx)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to start transaction",
+		})
+	}
+	defer tx.Rollback(ctx)
+
 	var listID string
+	var version int
 	var createdAt, updatedAt time.Time
-	err := h.DB.QueryRow(ctx, query, userID, req.Name, req.Description, isPublic, req.HeaderImageURL, themeColor).Scan(&listID, &createdAt, &updatedAt)
+	err = tx.QueryRow(ctx, query, userID, req.Name, req.Description, isPublic, isRanked, req.HeaderImageURL, themeColor, listType, smartRules, tags).Scan(&listID, &version, &createdAt, &updatedAt)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{
 			"error": "failed to create list",
 		})
 	}
 
+	// Smart lists store their current match count so they browse like static lists
+	itemsCount := 0
+	if listType == smartListType {
+		itemsCount, err = refreshSmartListCount(ctx, tx, listID, userID, req.SmartRules)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": "failed to count list items",
+			})
+		}
+	}
+
+	err = recordListRevision(ctx, tx, listID, userID, listRevisionChange{Action: "list_created"})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to record revision",
+		})
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to commit transaction",
+		})
+	}
+
 	return c.JSON(http.StatusCreated, map[string]interface{}{
 		"id":              listID,
 		"user_id":         userID,
 		"name":            req.Name,
 		"description":     req.Description,
 		"is_public":       isPublic,
+		"is_ranked":       isRanked,
 		"header_image_url": req.HeaderImageURL,
 		"theme_color":     themeColor,
-		"items_count":     0,
+		"list_type":       listType,
+		"smart_rules":     req.SmartRules,
+		"tags":            tags,
+		"items_count":     itemsCount,
+		"version":         version,
 		"created_at":      createdAt,
 		"updated_at":      updatedAt,
 	})
 }
 
-// GetMyLists retrieves all lists for the current authenticated user
+// listSorts are the sort keys accepted by GetMyLists and GetUserLists
+var listSorts = map[string]sortOption{
+	"created_at":  {Expr: "created_at", Type: "timestamptz", Desc: true},
+	"updated_at":  {Expr: "updated_at", Type: "timestamptz", Desc: true},
+	"name":        {Expr: "name", Type: "text", Desc: false},
+	"items_count": {Expr: "COALESCE(items_count, 0)", Type: "integer", Desc: true},
+	"likes_count": {Expr: "COALESCE(likes_count, 0)", Type: "integer", Desc: true},
+}
+
+// GetMyLists retrieves a page of lists for the current authenticated user
 func (h *ListHandler) GetMyLists(c echo.Context) error {
 	userID := auth.GetUserID(c)
 	if userID == "" {
@@ -120,66 +203,22 @@ func (h *ListHandler) GetMyLists(c echo.Context) error {
 		})
 	}
 
-	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
-	defer cancel()
-
-	// Get all lists for the current user (both public and private)
-	query := `
-		SELECT id, user_id, name, description, is_public, header_image_url, theme_color, items_count, created_at, updated_at
-		FROM lists
-		WHERE user_id = $1
-		ORDER BY created_at DESC
-	`
-
-	rows, err := h.DB.Query(ctx, query, userID)
+	page, err := parsePageRequest(c, listSorts, "created_at", 50, 100)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "failed to fetch lists",
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
 		})
 	}
-	defer rows.Close()
-
-	lists := []map[string]interface{}{}
-	for rows.Next() {
-		var list struct {
-			ID             string
-			UserID         string
-			Name           string
-			Description    *string
-			IsPublic       bool
-			HeaderImageURL *string
-			ThemeColor     string
-			ItemsCount     int
-			CreatedAt      time.Time
-			UpdatedAt      time.Time
-		}
 
-		err := rows.Scan(&list.ID, &list.UserID, &list.Name, &list.Description, &list.IsPublic, &list.HeaderImageURL, &list.ThemeColor, &list.ItemsCount, &list.CreatedAt, &list.UpdatedAt)
-		if err != nil {
-			continue
-		}
-
-		lists = append(lists, map[string]interface{}{
-			"id":              list.ID,
-			"user_id":         list.UserID,
-			"name":            list.Name,
-			"description":     list.Description,
-			"is_public":       list.IsPublic,
-			"header_image_url": list.HeaderImageURL,
-			"theme_color":     list.ThemeColor,
-			"items_count":     list.ItemsCount,
-			"created_at":      list.CreatedAt,
-			"updated_at":      list.UpdatedAt,
-		})
-	}
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
+	defer cancel()
 
-	return c.JSON(http.StatusOK, map[string]interface{}{
-		"lists": lists,
-		"count": len(lists),
-	})
+	// Get all lists for the current user (both public and private)
+	qb := newQueryBuilder(userID)
+	return h.respondWithListPage(c, ctx, qb, page)
 }
 
-// GetUserLists retrieves all lists for a given username
+// GetUserLists retrieves a page of lists for a given username
 func (h *ListHandler) GetUserLists(c echo.Context) error {
 	username := c.Param("username")
 	if username == "" {
@@ -188,43 +227,55 @@ func (h *ListHandler) GetUserLists(c echo.Context) error {
 		})
 	}
 
+	page, err := parsePageRequest(c, listSorts, "created_at", 50, 100)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
 	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
 	defer cancel()
 
 	// Check if requesting user is viewing their own profile
 	currentUserID := auth.GetUserID(c)
-	var isOwnProfile bool
 	var profileUserID string
 
-	err := h.DB.QueryRow(ctx, "SELECT id FROM users WHERE username = $1", username).Scan(&profileUserID)
+	err = h.DB.QueryRow(ctx, "SELECT id FROM users WHERE username = $1", username).Scan(&profileUserID)
 	if err != nil {
 		return c.JSON(http.StatusNotFound, map[string]string{
 			"error": "user not found",
 		})
 	}
 
-	isOwnProfile = (currentUserID == profileUserID)
-
-	var query string
-	if isOwnProfile {
-		// Show all lists for own profile
-		query = `
-			SELECT id, user_id, name, description, is_public, header_image_url, theme_color, items_count, created_at, updated_at
-			FROM lists
-			WHERE user_id = $1
-			ORDER BY created_at DESC
-		`
-	} else {
+	qb := newQueryBuilder(profileUserID)
+	if currentUserID != profileUserID {
 		// Show only public lists for other users
-		query = `
-			SELECT id, user_id, name, description, is_public, header_image_url, theme_color, items_count, created_at, updated_at
-			FROM lists
-			WHERE user_id = $1 AND is_public = true
-			ORDER BY created_at DESC
-		`
+		qb.where("is_public = true")
+	}
+
+	return h.respondWithListPage(c, ctx, qb, page)
+}
+
+// respondWithListPage runs the shared list query for the user in $1 and writes the page
+func (h *ListHandler) respondWithListPage(c echo.Context, ctx context.Context, qb *queryBuilder, page *pageRequest) error {
+	if q := c.QueryParam("q"); q != "" {
+		qb.where("name ILIKE " + qb.arg("%"+q+"%"))
+	}
+	if tags := tagQueryParams(c); len(tags) > 0 {
+		qb.where("tags @> " + qb.arg(tags) + "::text[]")
 	}
 
-	rows, err := h.DB.Query(ctx, query, profileUserID)
+	page.applyCursor(qb, "id")
+
+	query := `
+		SELECT id, user_id, name, description, is_public, header_image_url, theme_color, items_count, list_type, tags, created_at, updated_at,
+		       ` + page.cursorColumn() + `
+		FROM lists
+		WHERE user_id = $1` + qb.and() + `
+		` + page.orderBy(qb, "id")
+
+	rows, err := h.DB.Query(ctx, query, qb.args...)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{
 			"error": "failed to fetch lists",
@@ -233,6 +284,7 @@ func (h *ListHandler) GetUserLists(c echo.Context) error {
 	defer rows.Close()
 
 	lists := []map[string]interface{}{}
+	cursors := []pageCursor{}
 	for rows.Next() {
 		var list struct {
 			ID             string
@@ -243,15 +295,19 @@ func (h *ListHandler) GetUserLists(c echo.Context) error {
 			HeaderImageURL *string
 			ThemeColor     string
 			ItemsCount     int
+			ListType       string
+			Tags           []string
 			CreatedAt      time.Time
 			UpdatedAt      time.Time
+			CursorValue    string
 		}
 
-		err := rows.Scan(&list.ID, &list.UserID, &list.Name, &list.Description, &list.IsPublic, &list.HeaderImageURL, &list.ThemeColor, &list.ItemsCount, &list.CreatedAt, &list.UpdatedAt)
+		err := rows.Scan(&list.ID, &list.UserID, &list.Name, &list.Description, &list.IsPublic, &list.HeaderImageURL, &list.ThemeColor, &list.ItemsCount, &list.ListType, &list.Tags, &list.CreatedAt, &list.UpdatedAt, &list.CursorValue)
 		if err != nil {
 			continue
 		}
 
+		cursors = append(cursors, pageCursor{Value: list.CursorValue, ID: list.ID})
 		lists = append(lists, map[string]interface{}{
 			"id":              list.ID,
 			"user_id":         list.UserID,
@@ -261,14 +317,20 @@ func (h *ListHandler) GetUserLists(c echo.Context) error {
 			"header_image_url": list.HeaderImageURL,
 			"theme_color":     list.ThemeColor,
 			"items_count":     list.ItemsCount,
+			"list_type":       list.ListType,
+			"tags":            list.Tags,
 			"created_at":      list.CreatedAt,
 			"updated_at":      list.UpdatedAt,
 		})
 	}
 
+	lists, nextCursor := page.trim(lists, cursors)
+
 	return c.JSON(http.StatusOK, map[string]interface{}{
-		"lists": lists,
-		"count": len(lists),
+		"lists":       lists,
+		"count":       len(lists),
+		"next_cursor": nextCursor,
+		"has_more":    nextCursor != nil,
 	})
 }
 
@@ -294,6 +356,15 @@ func (h *ListHandler) GetList(c echo.Context) error {
 		HeaderImageURL *string
 		ThemeColor     string
 		ItemsCount     int
+		IsRanked       bool
+		Version        int
+		SubscribersCount int
+		ForksCount     int
+		ForkedFromID   *string
+		ListType       string
+		SmartRules     []byte
+		Rules          *SmartListRules
+		Tags           []string
 		CreatedAt      time.Time
 		UpdatedAt      time.Time
 		CreatorName    string
@@ -302,79 +373,48 @@ func (h *ListHandler) GetList(c echo.Context) error {
 	}
 
 	query := `
-		SELECT l.id, l.user_id, l.name, l.description, l.is_public, l.header_image_url, l.theme_color, l.items_count, l.created_at, l.updated_at,
+		SELECT l.id, l.user_id, l.name, l.description, l.is_public, l.header_image_url, l.theme_color, l.items_count, l.is_ranked, l.version, COALESCE(l.subscribers_count, 0), COALESCE(l.forks_count, 0), l.forked_from_list_id, l.list_type, l.smart_rules, l.tags, l.created_at, l.updated_at,
 		       u.name, u.username, u.picture
 		FROM lists l
 		JOIN users u ON l.user_id = u.id
 		WHERE l.id = $1
 	`
-	err := h.DB.QueryRow(ctx, query, listID).Scan(&list.ID, &list.UserID, &list.Name, &list.Description, &list.IsPublic, &list.HeaderImageURL, &list.ThemeColor, &list.ItemsCount, &list.CreatedAt, &list.UpdatedAt, &list.CreatorName, &list.CreatorUsername, &list.CreatorPicture)
+	err := h.DB.QueryRow(ctx, query, listID).Scan(&list.ID, &list.UserID, &list.Name, &list.Description, &list.IsPublic, &list.HeaderImageURL, &list.ThemeColor, &list.ItemsCount, &list.IsRanked, &list.Version, &list.SubscribersCount, &list.ForksCount, &list.ForkedFromID, &list.ListType, &list.SmartRules, &list.Tags, &list.CreatedAt, &list.UpdatedAt, &list.CreatorName, &list.CreatorUsername, &list.CreatorPicture)
 	if err != nil {
 		return c.JSON(http.StatusNotFound, map[string]string{
 			"error": "list not found",
 		})
 	}
 
-	// Check permissions
+	// Check permissions; collaborators can see private lists
 	currentUserID := auth.GetUserID(c)
-	if !list.IsPublic && currentUserID != list.UserID {
+	_, role, _ := getListRole(ctx, h.DB, listID, currentUserID)
+	if !list.IsPublic && !canViewList(role) {
 		return c.JSON(http.StatusForbidden, map[string]string{
 			"error": "you don't have permission to view this list",
 		})
 	}
 
-	// Get list items with book details
-	itemsQuery := `
-		SELECT li.id, li.book_id, li.notes, li.item_order, li.created_at,
-		       b.title, b.authors, b.cover_url, b.description
-		FROM list_items li
-		JOIN books b ON li.book_id = b.id
-		WHERE li.list_id = $1
-		ORDER BY li.item_order ASC, li.created_at DESC
-	`
-
-	rows, err := h.DB.Query(ctx, itemsQuery, listID)
+	// Smart lists compute their items from the owner's logs; only the owner sees matches from private logs
+	var items []map[string]interface{}
+	if list.ListType == smartListType {
+		rules, rulesErr := parseSmartListRules(list.SmartRules)
+		if rulesErr != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": "list has invalid smart rules",
+			})
+		}
+		list.Rules = rules
+		items, err = h.smartListItems(ctx, list.UserID, rules, currentUserID == list.UserID, list.IsRanked)
+		list.ItemsCount = len(items)
+	} else {
+		items, err = h.listItems(ctx, listID, list.IsRanked)
+	}
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{
 			"error": "failed to fetch list items",
 		})
 	}
-	defer rows.Close()
-
-	items := []map[string]interface{}{}
-	for rows.Next() {
-		var item struct {
-			ID          string
-			BookID      string
-			Notes       *string
-			ItemOrder   int
-			CreatedAt   time.Time
-			BookTitle   string
-			Authors     []string
-			CoverURL    *string
-			Description *string
-		}
-
-		err := rows.Scan(&item.ID, &item.BookID, &item.Notes, &item.ItemOrder, &item.CreatedAt, &item.BookTitle, &item.Authors, &item.CoverURL, &item.Description)
-		if err != nil {
-			continue
-		}
-
-		items = append(items, map[string]interface{}{
-			"id":         item.ID,
-			"book_id":    item.BookID,
-			"notes":      item.Notes,
-			"item_order": item.ItemOrder,
-			"created_at": item.CreatedAt,
-			"book": map[string]interface{}{
-				"id":          item.BookID,
-				"title":       item.BookTitle,
-				"authors":     item.Authors,
-				"cover_url":   item.CoverURL,
-				"description": item.Description,
-			},
-		})
-	}
 
 	// Get users who liked this list (top 5)
 	likedByQuery := `
@@ -411,14 +451,43 @@ func (h *ListHandler) GetList(c echo.Context) error {
 	// Get likes and comments count
 	var likesCount, commentsCount int
 	h.DB.QueryRow(ctx, "SELECT COUNT(*) FROM list_likes WHERE list_id = $1", listID).Scan(&likesCount)
-	h.DB.QueryRow(ctx, "SELECT COUNT(*) FROM list_comments WHERE list_id = $1", listID).Scan(&commentsCount)
+	h.DB.QueryRow(ctx, "SELECT COUNT(*) FROM list_comments WHERE list_id = $1 AND deleted_at IS NULL", listID).Scan(&commentsCount)
 
-	// Check if current user liked this list
-	var isLiked bool
+	// Check if current user liked or subscribed to this list
+	var isLiked, isSubscribed bool
 	if currentUserID != "" {
 		h.DB.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM list_likes WHERE list_id = $1 AND user_id = $2)", listID, currentUserID).Scan(&isLiked)
+		h.DB.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM list_subscriptions WHERE list_id = $1 AND user_id = $2)", listID, currentUserID).Scan(&isSubscribed)
+	}
+
+	reactionsByType, myReactions := reactionSummary(ctx, h.DB, "list", listID, currentUserID)
+
+	// Link back to the list this one was forked from, unless that list is private
+	var forkedFrom map[string]interface{}
+	if list.ForkedFromID != nil {
+		var sourceName, sourceUsername, sourceUserID string
+		var sourcePublic bool
+		err := h.DB.QueryRow(ctx, `
+			SELECT l.name, l.is_public, u.id, u.username
+			FROM lists l
+			JOIN users u ON l.user_id = u.id
+			WHERE l.id = $1
+		`, *list.ForkedFromID).Scan(&sourceName, &sourcePublic, &sourceUserID, &sourceUsername)
+		if err == nil && (sourcePublic || sourceUserID == currentUserID) {
+			forkedFrom = map[string]interface{}{
+				"id":   *list.ForkedFromID,
+				"name": sourceName,
+				"creator": map[string]interface{}{
+					"id":       sourceUserID,
+					"username": sourceUsername,
+				},
+			}
+		}
 	}
 
+	changesSinceLastView := h.changesSinceLastView(ctx, listID, currentUserID, list.Version)
+
+	c.Response().Header().Set("ETag", listETag(list.Version))
 	return c.JSON(http.StatusOK, map[string]interface{}{
 		"id":              list.ID,
 		"user_id":         list.UserID,
@@ -428,9 +497,23 @@ func (h *ListHandler) GetList(c echo.Context) error {
 		"header_image_url": list.HeaderImageURL,
 		"theme_color":     list.ThemeColor,
 		"items_count":     list.ItemsCount,
+		"is_ranked":       list.IsRanked,
+		"list_type":       list.ListType,
+		"smart_rules":     list.Rules,
+		"tags":            list.Tags,
+		"version":         list.Version,
 		"likes_count":     likesCount,
 		"comments_count":  commentsCount,
 		"is_liked":        isLiked,
+		"reaction_counts": reactionsByType,
+		"my_reactions":    myReactions,
+		"subscribers_count": list.SubscribersCount,
+		"is_subscribed":   isSubscribed,
+		"forks_count":     list.ForksCount,
+		"forked_from_list_id": list.ForkedFromID,
+		"forked_from":     forkedFrom,
+		"role":            role,
+		"can_edit":        canEditList(role),
 		"created_at":      list.CreatedAt,
 		"updated_at":      list.UpdatedAt,
 		"creator": map[string]interface{}{
@@ -441,9 +524,92 @@ func (h *ListHandler) GetList(c echo.Context) error {
 		},
 		"items":    items,
 		"liked_by": likedBy,
+		"changes_since_last_view": changesSinceLastView,
 	})
 }
 
+// listItems loads a static list's items with book details, in list order
+func (h *ListHandler) listItems(ctx context.Context, listID string, isRanked bool) ([]map[string]interface{}, error) {
+	itemsQuery := `
+		SELECT li.id, li.book_id, li.notes, li.position_key, li.created_at,
+		       b.title, b.authors, b.cover_url, b.description,
+		       li.added_by, au.username, au.name, au.picture
+		FROM list_items li
+		JOIN books b ON li.book_id = b.id
+		LEFT JOIN users au ON li.added_by = au.id
+		WHERE li.list_id = $1
+		ORDER BY li.position_key ASC
+	`
+
+	rows, err := h.DB.Query(ctx, itemsQuery, listID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []map[string]interface{}{}
+	for rows.Next() {
+		var item struct {
+			ID          string
+			BookID      string
+			Notes       *string
+			PositionKey string
+			CreatedAt   time.Time
+			BookTitle   string
+			Authors     []string
+			CoverURL    *string
+			Description *string
+			AddedByID   *string
+			AddedByUser *string
+			AddedByName *string
+			AddedByPic  *string
+		}
+
+		err := rows.Scan(&item.ID, &item.BookID, &item.Notes, &item.PositionKey, &item.CreatedAt, &item.BookTitle, &item.Authors, &item.CoverURL, &item.Description,
+			&item.AddedByID, &item.AddedByUser, &item.AddedByName, &item.AddedByPic)
+		if err != nil {
+			continue
+		}
+
+		var addedBy map[string]interface{}
+		if item.AddedByID != nil {
+			addedBy = map[string]interface{}{
+				"id":       *item.AddedByID,
+				"username": item.AddedByUser,
+				"name":     item.AddedByName,
+				"picture":  item.AddedByPic,
+			}
+		}
+
+		// Ranked lists number their items from 1
+		var rank *int
+		if isRanked {
+			r := len(items) + 1
+			rank = &r
+		}
+
+		items = append(items, map[string]interface{}{
+			"id":           item.ID,
+			"book_id":      item.BookID,
+			"notes":        item.Notes,
+			"item_order":   len(items),
+			"position_key": item.PositionKey,
+			"rank":         rank,
+			"created_at":   item.CreatedAt,
+			"added_by":     addedBy,
+			"book": map[string]interface{}{
+				"id":          item.BookID,
+				"title":       item.BookTitle,
+				"authors":     item.Authors,
+				"cover_url":   item.CoverURL,
+				"description": item.Description,
+			},
+		})
+	}
+
+	return items, rows.Err()
+}
+
 // UpdateList updates a list's details
 func (h *ListHandler) UpdateList(c echo.Context) error {
 	userID := auth.GetUserID(c)
@@ -467,12 +633,19 @@ func (h *ListHandler) UpdateList(c echo.Context) error {
 		})
 	}
 
+	expected, err := expectedListVersion(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
 	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
 	defer cancel()
 
 	// Check if list exists and belongs to user
-	var ownerID string
-	err := h.DB.QueryRow(ctx, "SELECT user_id FROM lists WHERE id = $1", listID).Scan(&ownerID)
+	var ownerID, listType string
+	err = h.DB.QueryRow(ctx, "SELECT user_id, list_type FROM lists WHERE id = $1", listID).Scan(&ownerID, &listType)
 	if err != nil {
 		return c.JSON(http.StatusNotFound, map[string]string{
 			"error": "list not found",
@@ -485,64 +658,159 @@ func (h *ListHandler) UpdateList(c echo.Context) error {
 		})
 	}
 
+	if req.SmartRules != nil {
+		if listType != smartListType {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "only smart lists have rules",
+			})
+		}
+		if err := req.SmartRules.validate(); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": err.Error(),
+			})
+		}
+	}
+
+	var tags []string
+	if req.Tags != nil {
+		tags, err = normalizeListTags(req.Tags)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": err.Error(),
+			})
+		}
+	}
+
 	// Build update query dynamically
-	query := "UPDATE lists SET updated_at = NOW()"
+	query := "UPDATE lists SET updated_at = NOW(), version = version + 1"
 	args := []interface{}{}
 	argCount := 1
 
 	if req.Name != nil {
 		argCount++
-		query += ", name = $" + string(rune(argCount+'0'-1))
+		query += ", name = $" + strconv.Itoa(argCount)
 		args = append(args, *req.Name)
 	}
 	if req.Description != nil {
 		argCount++
-		query += ", description = $" + string(rune(argCount+'0'-1))
+		query += ", description = $" + strconv.Itoa(argCount)
 		args = append(args, *req.Description)
 	}
 	if req.IsPublic != nil {
 		argCount++
-		query += ", is_public = $" + string(rune(argCount+'0'-1))
+		query += ", is_public = $" + strconv.Itoa(argCount)
 		args = append(args, *req.IsPublic)
 	}
+	if req.IsRanked != nil {
+		argCount++
+		query += ", is_ranked = $" + strconv.Itoa(argCount)
+		args = append(args, *req.IsRanked)
+	}
 	if req.HeaderImageURL != nil {
 		argCount++
-		query += ", header_image_url = $" + string(rune(argCount+'0'-1))
+		query += ", header_image_url = $" + strconv.Itoa(argCount)
 		args = append(args, *req.HeaderImageURL)
 	}
 	if req.ThemeColor != nil {
 		argCount++
-		query += ", theme_color = $" + string(rune(argCount+'0'-1))
+		query += ", theme_color = $" + strconv.Itoa(argCount)
 		args = append(args, *req.ThemeColor)
 	}
+	if req.SmartRules != nil {
+		smartRules, _ := json.Marshal(req.SmartRules)
+		argCount++
+		query += ", smart_rules = $" + strconv.Itoa(argCount)
+		args = append(args, smartRules)
+	}
+	if req.Tags != nil {
+		argCount++
+		query += ", tags = $" + strconv.Itoa(argCount)
+		args = append(args, tags)
+	}
+
+	query += " WHERE id = $1"
+
+	// Only apply the edit to the version the client last saw
+	if expected != nil {
+		argCount++
+		query += " AND version = $" + strconv.Itoa(argCount)
+		args = append(args, *expected)
+	}
 
-	query += " WHERE id = $1 RETURNING id, name, description, is_public, header_image_url, theme_color, updated_at"
+	query += " RETURNING id, user_id, name, description, is_public, is_ranked, header_image_url, theme_color, list_type, smart_rules, tags, version, updated_at"
 	args = append([]interface{}{listID}, args...)
 
+	tx, err := h.DB.Begin(ctx)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to start transaction",
+		})
+	}
+	defer tx.Rollback(ctx)
+
 	var updatedList struct {
 		ID             string
+		UserID         string
 		Name           string
 		Description    *string
 		IsPublic       bool
+		IsRanked       bool
 		HeaderImageURL *string
 		ThemeColor     string
+		ListType       string
+		SmartRules     json.RawMessage
+		Tags           []string
+		Version        int
 		UpdatedAt      time.Time
 	}
 
-	err = h.DB.QueryRow(ctx, query, args...).Scan(&updatedList.ID, &updatedList.Name, &updatedList.Description, &updatedList.IsPublic, &updatedList.HeaderImageURL, &updatedList.ThemeColor, &updatedList.UpdatedAt)
+	err = tx.QueryRow(ctx, query, args...).Scan(&updatedList.ID, &updatedList.UserID, &updatedList.Name, &updatedList.Description, &updatedList.IsPublic, &updatedList.IsRanked, &updatedList.HeaderImageURL, &updatedList.ThemeColor, &updatedList.ListType, &updatedList.SmartRules, &updatedList.Tags, &updatedList.Version, &updatedList.UpdatedAt)
+	if err == pgx.ErrNoRows && expected != nil {
+		return listVersionConflict(c, currentListVersion(ctx, h.DB, listID))
+	}
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{
 			"error": "failed to update list",
 		})
 	}
 
+	if req.SmartRules != nil && updatedList.ListType == smartListType {
+		if _, err := refreshSmartListCount(ctx, tx, listID, updatedList.UserID, req.SmartRules); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": "failed to count list items",
+			})
+		}
+	}
+
+	err = recordListRevision(ctx, tx, listID, userID, listRevisionChange{
+		Action:  "list_updated",
+		Details: map[string]interface{}{"changes": req},
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to record revision",
+		})
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to commit transaction",
+		})
+	}
+
+	c.Response().Header().Set("ETag", listETag(updatedList.Version))
 	return c.JSON(http.StatusOK, map[string]interface{}{
 		"id":              updatedList.ID,
 		"name":            updatedList.Name,
 		"description":     updatedList.Description,
 		"is_public":       updatedList.IsPublic,
+		"is_ranked":       updatedList.IsRanked,
 		"header_image_url": updatedList.HeaderImageURL,
 		"theme_color":     updatedList.ThemeColor,
+		"list_type":       updatedList.ListType,
+		"smart_rules":     updatedList.SmartRules,
+		"tags":            updatedList.Tags,
+		"version":         updatedList.Version,
 		"updated_at":      updatedList.UpdatedAt,
 	})
 }
@@ -612,42 +880,84 @@ func (h *ListHandler) UpdateListItem(c echo.Context) error {
 		})
 	}
 
+	expected, err := expectedListVersion(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
 	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
 	defer cancel()
 
-	// Verify ownership
-	var ownerID string
-	err := h.DB.QueryRow(ctx, `
-		SELECT l.user_id FROM lists l
-		JOIN list_items li ON l.id = li.list_id
-		WHERE l.id = $1 AND li.id = $2
-	`, listID, itemID).Scan(&ownerID)
+	tx, err := h.DB.Begin(ctx)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to start transaction",
+		})
+	}
+	defer tx.Rollback(ctx)
 
+	// Verify the user owns or can edit the list
+	_, role, err := getListItemRole(ctx, tx, listID, itemID, userID)
 	if err != nil {
 		return c.JSON(http.StatusNotFound, map[string]string{
 			"error": "list item not found",
 		})
 	}
 
-	if ownerID != userID {
+	if !canEditList(role) {
 		return c.JSON(http.StatusForbidden, map[string]string{
 			"error": "unauthorized",
 		})
 	}
 
-	_, err = h.DB.Exec(ctx, "UPDATE list_items SET notes = $1 WHERE id = $2", req.Notes, itemID)
+	if version, err := lockListVersion(ctx, tx, listID, expected); err == errListVersionConflict {
+		return listVersionConflict(c, version)
+	} else if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to update list item",
+		})
+	}
+
+	var bookID string
+	var previousNotes *string
+	tx.QueryRow(ctx, "SELECT book_id, notes FROM list_items WHERE id = $1", itemID).Scan(&bookID, &previousNotes)
+
+	_, err = tx.Exec(ctx, "UPDATE list_items SET notes = $1 WHERE id = $2", req.Notes, itemID)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{
 			"error": "failed to update list item",
 		})
 	}
 
+	err = recordListRevision(ctx, tx, listID, userID, listRevisionChange{
+		Action:  "item_note_updated",
+		ItemID:  &itemID,
+		BookID:  &bookID,
+		Details: map[string]interface{}{"previous_notes": previousNotes, "notes": req.Notes},
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to record revision",
+		})
+	}
+
+	version := currentListVersion(ctx, tx, listID)
+	if err := tx.Commit(ctx); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to commit transaction",
+		})
+	}
+
+	c.Response().Header().Set("ETag", listETag(version))
 	return c.JSON(http.StatusOK, map[string]interface{}{
 		"message": "list item updated successfully",
+		"version": version,
 	})
 }
 
-// AddBookToList adds a book to a list
+// AddBookToList adds a book to the end of a list
 func (h *ListHandler) AddBookToList(c echo.Context) error {
 	userID := auth.GetUserID(c)
 	if userID == "" {
@@ -676,63 +986,99 @@ func (h *ListHandler) AddBookToList(c echo.Context) error {
 		})
 	}
 
+	expected, err := expectedListVersion(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
 	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
 	defer cancel()
 
-	// Check if list exists and belongs to user
-	var ownerID string
-	err := h.DB.QueryRow(ctx, "SELECT user_id FROM lists WHERE id = $1", listID).Scan(&ownerID)
+	tx, err := h.DB.Begin(ctx)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to start transaction",
+		})
+	}
+	defer tx.Rollback(ctx)
+
+	// Check if list exists and the user can edit it
+	_, role, err := getListRole(ctx, tx, listID, userID)
 	if err != nil {
 		return c.JSON(http.StatusNotFound, map[string]string{
 			"error": "list not found",
 		})
 	}
 
-	if ownerID != userID {
+	if !canEditList(role) {
 		return c.JSON(http.StatusForbidden, map[string]string{
-			"error": "you can only add books to your own lists",
+			"error": "you can only add books to lists you own or edit",
 		})
 	}
 
-	// Get the current max order for the list
-	var maxOrder int
-	h.DB.QueryRow(ctx, "SELECT COALESCE(MAX(item_order), -1) FROM list_items WHERE list_id = $1", listID).Scan(&maxOrder)
+	if isSmartList(ctx, tx, listID) {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "books can't be added to a smart list; edit its rules instead",
+		})
+	}
 
-	query := `
-		INSERT INTO list_items (list_id, book_id, notes, item_order, created_at)
-		VALUES ($1, $2, $3, $4, NOW())
-		ON CONFLICT (list_id, book_id) DO NOTHING
-		RETURNING id, created_at
-	`
+	if version, err := lockListVersion(ctx, tx, listID, expected); err == errListVersionConflict {
+		return listVersionConflict(c, version)
+	} else if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to add book to list",
+		})
+	}
 
-	var itemID string
-	var createdAt time.Time
-	err = h.DB.QueryRow(ctx, query, listID, req.BookID, req.Notes, maxOrder+1).Scan(&itemID, &createdAt)
-	if err != nil {
-		// Check if it's a duplicate
-		var exists bool
-		h.DB.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM list_items WHERE list_id = $1 AND book_id = $2)", listID, req.BookID).Scan(&exists)
-		if exists {
-			return c.JSON(http.StatusConflict, map[string]string{
-				"error": "book already in list",
-			})
-		}
+	// New books go after the current last item
+	item, err := appendListItem(ctx, tx, listID, userID, req.BookID, req.Notes)
+	if err == errBookAlreadyInList {
+		return c.JSON(http.StatusConflict, map[string]string{
+			"error": err.Error(),
+		})
+	} else if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{
 			"error": "failed to add book to list",
 		})
 	}
 
+	err = recordListRevision(ctx, tx, listID, userID, listRevisionChange{
+		Action: "item_added",
+		ItemID: &item.ID,
+		BookID: &req.BookID,
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to record revision",
+		})
+	}
+
+	version := currentListVersion(ctx, tx, listID)
+	if err := tx.Commit(ctx); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to commit transaction",
+		})
+	}
+
+	c.Response().Header().Set("ETag", listETag(version))
 	return c.JSON(http.StatusCreated, map[string]interface{}{
-		"id":         itemID,
-		"list_id":    listID,
-		"book_id":    req.BookID,
-		"notes":      req.Notes,
-		"item_order": maxOrder + 1,
-		"created_at": createdAt,
+		"id":           item.ID,
+		"list_id":      listID,
+		"book_id":      req.BookID,
+		"notes":        req.Notes,
+		"item_order":   item.Order,
+		"position_key": item.PositionKey,
+		"added_by":     userID,
+		"created_at":   item.CreatedAt,
+		"version":      version,
 	})
 }
 
-// UpdateListItemOrder updates the order of a list item
+// UpdateListItemOrder moves a single list item. Only the moved item's position key is rewritten,
+// so concurrent moves of different items don't clobber each other.
+// The destination is given by after_item_id and/or before_item_id, or by a zero-based order index.
 func (h *ListHandler) UpdateListItemOrder(c echo.Context) error {
 	userID := auth.GetUserID(c)
 	if userID == "" {
@@ -751,42 +1097,101 @@ func (h *ListHandler) UpdateListItemOrder(c echo.Context) error {
 		})
 	}
 
+	if req.Order == nil && req.AfterItemID == nil && req.BeforeItemID == nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "one of order, after_item_id or before_item_id is required",
+		})
+	}
+
+	expected, err := expectedListVersion(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
 	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
 	defer cancel()
 
-	// Verify ownership
-	var ownerID string
-	err := h.DB.QueryRow(ctx, `
-		SELECT l.user_id FROM lists l
-		JOIN list_items li ON l.id = li.list_id
-		WHERE l.id = $1 AND li.id = $2
-	`, listID, itemID).Scan(&ownerID)
+	tx, err := h.DB.Begin(ctx)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to start transaction",
+		})
+	}
+	defer tx.Rollback(ctx)
 
+	// Verify the user owns or can edit the list
+	_, role, err := getListItemRole(ctx, tx, listID, itemID, userID)
 	if err != nil {
 		return c.JSON(http.StatusNotFound, map[string]string{
 			"error": "list item not found",
 		})
 	}
 
-	if ownerID != userID {
+	if !canEditList(role) {
 		return c.JSON(http.StatusForbidden, map[string]string{
 			"error": "unauthorized",
 		})
 	}
 
-	_, err = h.DB.Exec(ctx, "UPDATE list_items SET item_order = $1 WHERE id = $2", req.Order, itemID)
-	if err != nil {
+	if version, err := lockListVersion(ctx, tx, listID, expected); err == errListVersionConflict {
+		return listVersionConflict(c, version)
+	} else if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{
 			"error": "failed to update item order",
 		})
 	}
 
+	index, positionKey, bookID, err := moveListItem(ctx, tx, listID, itemID, req.AfterItemID, req.BeforeItemID, req.Order)
+	if err == errListItemNotFound {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": err.Error(),
+		})
+	} else if err == errNeighbourNotFound {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	} else if err == errNotAdjacent {
+		return c.JSON(http.StatusConflict, map[string]string{
+			"error": err.Error(),
+		})
+	} else if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to update item order",
+		})
+	}
+
+	err = recordListRevision(ctx, tx, listID, userID, listRevisionChange{
+		Action:  "item_moved",
+		ItemID:  &itemID,
+		BookID:  &bookID,
+		Details: map[string]interface{}{"item_order": index},
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to record revision",
+		})
+	}
+
+	version := currentListVersion(ctx, tx, listID)
+	if err := tx.Commit(ctx); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to commit transaction",
+		})
+	}
+
+	c.Response().Header().Set("ETag", listETag(version))
 	return c.JSON(http.StatusOK, map[string]interface{}{
-		"message": "item order updated",
+		"message":      "item order updated",
+		"item_order":   index,
+		"position_key": positionKey,
+		"version":      version,
 	})
 }
 
-// ReorderListItems reorders all items in a list
+// ReorderListItems rewrites the order of a whole list. Items missing from item_ids
+// keep their relative order after the listed ones.
 func (h *ListHandler) ReorderListItems(c echo.Context) error {
 	userID := auth.GetUserID(c)
 	if userID == "" {
@@ -815,6 +1220,13 @@ func (h *ListHandler) ReorderListItems(c echo.Context) error {
 		})
 	}
 
+	expected, err := expectedListVersion(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
 	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
 	defer cancel()
 
@@ -827,24 +1239,69 @@ func (h *ListHandler) ReorderListItems(c echo.Context) error {
 	}
 	defer tx.Rollback(ctx)
 
-	// Check if list exists and belongs to user
-	var ownerID string
-	err = tx.QueryRow(ctx, "SELECT user_id FROM lists WHERE id = $1", listID).Scan(&ownerID)
+	// Check if list exists and the user can edit it
+	_, role, err := getListRole(ctx, tx, listID, userID)
 	if err != nil {
 		return c.JSON(http.StatusNotFound, map[string]string{
 			"error": "list not found",
 		})
 	}
 
-	if ownerID != userID {
+	if !canEditList(role) {
 		return c.JSON(http.StatusForbidden, map[string]string{
-			"error": "you can only reorder your own lists",
+			"error": "you can only reorder lists you own or edit",
 		})
 	}
 
-	// Update item orders in a single transaction
-	for i, itemID := range req.ItemIDs {
-		_, err = tx.Exec(ctx, "UPDATE list_items SET item_order = $1 WHERE id = $2 AND list_id = $3", i, itemID, listID)
+	if version, err := lockListVersion(ctx, tx, listID, expected); err == errListVersionConflict {
+		return listVersionConflict(c, version)
+	} else if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to update item order",
+		})
+	}
+
+	rows, err := tx.Query(ctx, "SELECT id FROM list_items WHERE list_id = $1 ORDER BY position_key", listID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to update item order",
+		})
+	}
+	current := []string{}
+	onList := make(map[string]bool)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err == nil {
+			current = append(current, id)
+			onList[id] = true
+		}
+	}
+	rows.Close()
+
+	ordered := []string{}
+	placed := make(map[string]bool)
+	for _, id := range req.ItemIDs {
+		if onList[id] && !placed[id] {
+			ordered = append(ordered, id)
+			placed[id] = true
+		}
+	}
+	for _, id := range current {
+		if !placed[id] {
+			ordered = append(ordered, id)
+		}
+	}
+
+	keys, err := keysBetween("", "", len(ordered))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to update item order",
+		})
+	}
+
+	// Update item keys in a single transaction
+	for i, itemID := range ordered {
+		_, err = tx.Exec(ctx, "UPDATE list_items SET position_key = $1 WHERE id = $2 AND list_id = $3", keys[i], itemID, listID)
 		if err != nil {
 			return c.JSON(http.StatusInternalServerError, map[string]string{
 				"error": "failed to update item order",
@@ -852,6 +1309,18 @@ func (h *ListHandler) ReorderListItems(c echo.Context) error {
 		}
 	}
 
+	err = recordListRevision(ctx, tx, listID, userID, listRevisionChange{
+		Action:  "items_reordered",
+		Details: map[string]interface{}{"item_ids": ordered},
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to record revision",
+		})
+	}
+
+	version := currentListVersion(ctx, tx, listID)
+
 	// Commit transaction
 	err = tx.Commit(ctx)
 	if err != nil {
@@ -860,8 +1329,10 @@ func (h *ListHandler) ReorderListItems(c echo.Context) error {
 		})
 	}
 
+	c.Response().Header().Set("ETag", listETag(version))
 	return c.JSON(http.StatusOK, map[string]interface{}{
 		"message": "items reordered successfully",
+		"version": version,
 	})
 }
 
@@ -877,38 +1348,78 @@ func (h *ListHandler) RemoveBookFromList(c echo.Context) error {
 	listID := c.Param("id")
 	itemID := c.Param("itemId")
 
+	expected, err := expectedListVersion(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
 	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
 	defer cancel()
 
-	// Verify ownership
-	var ownerID string
-	err := h.DB.QueryRow(ctx, `
-		SELECT l.user_id FROM lists l
-		JOIN list_items li ON l.id = li.list_id
-		WHERE l.id = $1 AND li.id = $2
-	`, listID, itemID).Scan(&ownerID)
+	tx, err := h.DB.Begin(ctx)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to start transaction",
+		})
+	}
+	defer tx.Rollback(ctx)
 
+	// Verify the user owns or can edit the list
+	_, role, err := getListItemRole(ctx, tx, listID, itemID, userID)
 	if err != nil {
 		return c.JSON(http.StatusNotFound, map[string]string{
 			"error": "list item not found",
 		})
 	}
 
-	if ownerID != userID {
+	if !canEditList(role) {
 		return c.JSON(http.StatusForbidden, map[string]string{
 			"error": "unauthorized",
 		})
 	}
 
-	_, err = h.DB.Exec(ctx, "DELETE FROM list_items WHERE id = $1", itemID)
+	if version, err := lockListVersion(ctx, tx, listID, expected); err == errListVersionConflict {
+		return listVersionConflict(c, version)
+	} else if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to remove book from list",
+		})
+	}
+
+	var bookID string
+	var notes *string
+	err = tx.QueryRow(ctx, "DELETE FROM list_items WHERE id = $1 RETURNING book_id, notes", itemID).Scan(&bookID, &notes)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{
 			"error": "failed to remove book from list",
 		})
 	}
 
+	err = recordListRevision(ctx, tx, listID, userID, listRevisionChange{
+		Action:  "item_removed",
+		ItemID:  &itemID,
+		BookID:  &bookID,
+		Details: map[string]interface{}{"notes": notes},
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to record revision",
+		})
+	}
+
+	version := currentListVersion(ctx, tx, listID)
+	if err := tx.Commit(ctx); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to commit transaction",
+		})
+	}
+
+	c.Response().Header().Set("ETag", listETag(version))
 	return c.JSON(http.StatusOK, map[string]interface{}{
 		"message": "book removed from list",
+		"version": version,
 	})
 }
 
@@ -946,11 +1457,11 @@ func (h *ListHandler) LikeList(c echo.Context) error {
 		})
 	}
 
-	// Insert like (ignore if already exists)
+	// Insert like (ignore if already exists); likes are stored as "like" reactions
 	query := `
-		INSERT INTO list_likes (list_id, user_id, created_at)
-		VALUES ($1, $2, NOW())
-		ON CONFLICT (list_id, user_id) DO NOTHING
+		INSERT INTO reactions (target_id, user_id, target_type, reaction, created_at)
+		VALUES ($1, $2, 'list', 'like', NOW())
+		ON CONFLICT (user_id, target_type, target_id, reaction) DO NOTHING
 	`
 
 	_, err = h.DB.Exec(ctx, query, listID, userID)
@@ -984,7 +1495,7 @@ func (h *ListHandler) UnlikeList(c echo.Context) error {
 	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
 	defer cancel()
 
-	query := `DELETE FROM list_likes WHERE list_id = $1 AND user_id = $2`
+	query := `DELETE FROM reactions WHERE target_type = 'list' AND target_id = $1 AND user_id = $2 AND reaction = 'like'`
 	_, err := h.DB.Exec(ctx, query, listID, userID)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{
@@ -997,150 +1508,6 @@ func (h *ListHandler) UnlikeList(c echo.Context) error {
 	})
 }
 
-// GetListComments gets comments for a list
-func (h *ListHandler) GetListComments(c echo.Context) error {
-	listID := c.Param("id")
-	if listID == "" {
-		return c.JSON(http.StatusBadRequest, map[string]string{
-			"error": "list id is required",
-		})
-	}
-
-	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
-	defer cancel()
-
-	query := `
-		SELECT c.id, c.user_id, c.content, c.created_at, c.updated_at,
-		       u.username, u.name, u.picture
-		FROM list_comments c
-		JOIN users u ON c.user_id = u.id
-		WHERE c.list_id = $1
-		ORDER BY c.created_at ASC
-	`
-
-	rows, err := h.DB.Query(ctx, query, listID)
-	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "failed to fetch comments",
-		})
-	}
-	defer rows.Close()
-
-	comments := []map[string]interface{}{}
-	for rows.Next() {
-		var comment struct {
-			ID        string
-			UserID    string
-			Content   string
-			CreatedAt time.Time
-			UpdatedAt time.Time
-			Username  string
-			Name      string
-			Picture   *string
-		}
-
-		err := rows.Scan(
-			&comment.ID, &comment.UserID, &comment.Content,
-			&comment.CreatedAt, &comment.UpdatedAt,
-			&comment.Username, &comment.Name, &comment.Picture,
-		)
-		if err != nil {
-			continue
-		}
-
-		comments = append(comments, map[string]interface{}{
-			"id":         comment.ID,
-			"content":    comment.Content,
-			"created_at": comment.CreatedAt,
-			"updated_at": comment.UpdatedAt,
-			"user": map[string]interface{}{
-				"id":       comment.UserID,
-				"username": comment.Username,
-				"name":     comment.Name,
-				"picture":  comment.Picture,
-			},
-		})
-	}
-
-	return c.JSON(http.StatusOK, map[string]interface{}{
-		"comments": comments,
-		"count":    len(comments),
-	})
-}
-
-// AddListComment adds a comment to a list
-func (h *ListHandler) AddListComment(c echo.Context) error {
-	userID := auth.GetUserID(c)
-	if userID == "" {
-		return c.JSON(http.StatusUnauthorized, map[string]string{
-			"error": "unauthorized",
-		})
-	}
-
-	listID := c.Param("id")
-	if listID == "" {
-		return c.JSON(http.StatusBadRequest, map[string]string{
-			"error": "list id is required",
-		})
-	}
-
-	var req struct {
-		Content string `json:"content"`
-	}
-
-	if err := c.Bind(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{
-			"error": "invalid request body",
-		})
-	}
-
-	if req.Content == "" {
-		return c.JSON(http.StatusBadRequest, map[string]string{
-			"error": "content is required",
-		})
-	}
-
-	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
-	defer cancel()
-
-	// Check if list exists and is public
-	var isPublic bool
-	err := h.DB.QueryRow(ctx, "SELECT is_public FROM lists WHERE id = $1", listID).Scan(&isPublic)
-	if err != nil {
-		return c.JSON(http.StatusNotFound, map[string]string{
-			"error": "list not found",
-		})
-	}
-
-	if !isPublic {
-		return c.JSON(http.StatusForbidden, map[string]string{
-			"error": "cannot comment on private list",
-		})
-	}
-
-	query := `
-		INSERT INTO list_comments (list_id, user_id, content, created_at, updated_at)
-		VALUES ($1, $2, $3, NOW(), NOW())
-		RETURNING id, created_at, updated_at
-	`
-
-	var commentID string
-	var createdAt, updatedAt time.Time
-	err = h.DB.QueryRow(ctx, query, listID, userID, req.Content).Scan(&commentID, &createdAt, &updatedAt)
-	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "failed to add comment",
-		})
-	}
-
-	return c.JSON(http.StatusCreated, map[string]interface{}{
-		"id":         commentID,
-		"content":    req.Content,
-		"created_at": createdAt,
-		"updated_at": updatedAt,
-	})
-}
-
 // GetPopularLists gets popular public lists
 func (h *ListHandler) GetPopularLists(c echo.Context) error {
 	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
@@ -1152,10 +1519,11 @@ func (h *ListHandler) GetPopularLists(c echo.Context) error {
 	}
 
 	query := `
-		SELECT l.id, l.user_id, l.name, l.description, l.is_public, l.header_image_url, l.theme_color, l.items_count, l.created_at, l.updated_at,
+		SELECT l.id, l.user_id, l.name, l.description, l.is_public, l.header_image_url, l.theme_color, l.items_count, l.tags, l.created_at, l.updated_at,
 		       u.name, u.username, u.picture,
 		       COALESCE(like_counts.likes_count, 0) as likes_count,
-		       COALESCE(comment_counts.comments_count, 0) as comments_count
+		       COALESCE(comment_counts.comments_count, 0) as comments_count,
+		       COALESCE(l.subscribers_count, 0) as subscribers_count
 		FROM lists l
 		JOIN users u ON l.user_id = u.id
 		LEFT JOIN (
@@ -1166,6 +1534,7 @@ func (h *ListHandler) GetPopularLists(c echo.Context) error {
 		LEFT JOIN (
 			SELECT list_id, COUNT(*) as comments_count
 			FROM list_comments
+			WHERE deleted_at IS NULL
 			GROUP BY list_id
 		) comment_counts ON l.id = comment_counts.list_id
 		WHERE l.is_public = true AND l.items_count > 0
@@ -1192,6 +1561,7 @@ func (h *ListHandler) GetPopularLists(c echo.Context) error {
 			HeaderImageURL *string
 			ThemeColor     string
 			ItemsCount     int
+			Tags           []string
 			CreatedAt      time.Time
 			UpdatedAt      time.Time
 			CreatorName    string
@@ -1199,9 +1569,10 @@ func (h *ListHandler) GetPopularLists(c echo.Context) error {
 			CreatorPicture *string
 			LikesCount     int
 			CommentsCount  int
+			SubscribersCount int
 		}
 
-		err := rows.Scan(&list.ID, &list.UserID, &list.Name, &list.Description, &list.IsPublic, &list.HeaderImageURL, &list.ThemeColor, &list.ItemsCount, &list.CreatedAt, &list.UpdatedAt, &list.CreatorName, &list.CreatorUsername, &list.CreatorPicture, &list.LikesCount, &list.CommentsCount)
+		err := rows.Scan(&list.ID, &list.UserID, &list.Name, &list.Description, &list.IsPublic, &list.HeaderImageURL, &list.ThemeColor, &list.ItemsCount, &list.Tags, &list.CreatedAt, &list.UpdatedAt, &list.CreatorName, &list.CreatorUsername, &list.CreatorPicture, &list.LikesCount, &list.CommentsCount, &list.SubscribersCount)
 		if err != nil {
 			continue
 		}
@@ -1215,6 +1586,7 @@ func (h *ListHandler) GetPopularLists(c echo.Context) error {
 			"header_image_url": list.HeaderImageURL,
 			"theme_color":     list.ThemeColor,
 			"items_count":     list.ItemsCount,
+			"tags":            list.Tags,
 			"created_at":      list.CreatedAt,
 			"updated_at":      list.UpdatedAt,
 			"creator": map[string]interface{}{
@@ -1225,6 +1597,7 @@ func (h *ListHandler) GetPopularLists(c echo.Context) error {
 			},
 			"likes_count":    list.LikesCount,
 			"comments_count": list.CommentsCount,
+			"subscribers_count": list.SubscribersCount,
 		})
 	}
 
@@ -2,8 +2,11 @@ package handlers
 
 import (
 	"context"
+	"fmt"
 	"folio/api/auth"
+	"log"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -14,16 +17,26 @@ type LogHandler struct {
 	DB *pgxpool.Pool
 }
 
+// validLogStatuses mirrors the CHECK constraint on logs.status
+var validLogStatuses = map[string]bool{
+	"want_to_read": true,
+	"reading":      true,
+	"read":         true,
+	"dnf":          true,
+}
+
 type CreateLogRequest struct {
-	BookID      string  `json:"book_id"`
-	Status      string  `json:"status"`
-	Rating      *int    `json:"rating"`
-	Review      *string `json:"review"`
-	Notes       *string `json:"notes"`
-	StartDate   *string `json:"start_date"`
-	FinishDate  *string `json:"finish_date"`
-	IsPublic    *bool   `json:"is_public"`
-	SpoilerFlag *bool   `json:"spoiler_flag"`
+	BookID           string            `json:"book_id"`
+	Status           string            `json:"status"`
+	Rating           *float64          `json:"rating"`
+	RatingDimensions *RatingDimensions `json:"rating_dimensions"`
+	Review           *string           `json:"review"`
+	ReviewFormat     *string           `json:"review_format"`
+	Notes            *string           `json:"notes"`
+	StartDate        *string           `json:"start_date"`
+	FinishDate       *string           `json:"finish_date"`
+	IsPublic         *bool             `json:"is_public"`
+	SpoilerFlag      *bool             `json:"spoiler_flag"`
 }
 
 // CreateLog creates a new reading log entry
@@ -43,24 +56,43 @@ func (h *LogHandler) CreateLog(c echo.Context) error {
 	}
 
 	// Validate status
-	validStatuses := map[string]bool{
-		"want_to_read": true,
-		"reading":      true,
-		"read":         true,
-		"dnf":          true,
-	}
-	if !validStatuses[req.Status] {
+	if !validLogStatuses[req.Status] {
 		return c.JSON(http.StatusBadRequest, map[string]string{
 			"error": "invalid status. Must be: want_to_read, reading, read, or dnf",
 		})
 	}
 
+	if err := validateRatings(req.Rating, req.RatingDimensions); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	reviewFormat := "text"
+	if req.ReviewFormat != nil {
+		reviewFormat = *req.ReviewFormat
+	}
+	if !validReviewFormats[reviewFormat] {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "invalid review_format. Must be: text or markdown",
+		})
+	}
+
 	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
 	defer cancel()
 
+	rendered, err := renderReview(ctx, h.DB, reviewFormat, req.Review)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to render review",
+		})
+	}
+
 	query := `
-		INSERT INTO logs (user_id, book_id, status, rating, review, notes, start_date, finish_date, is_public, spoiler_flag, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NOW(), NOW())
+		INSERT INTO logs (user_id, book_id, status, rating, rating_plot, rating_prose, rating_characters, rating_pacing,
+		                  review, review_format, review_html, review_has_spoilers,
+		                  notes, start_date, finish_date, is_public, spoiler_flag, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, NOW(), NOW())
 		RETURNING id, created_at, updated_at
 	`
 
@@ -76,9 +108,12 @@ func (h *LogHandler) CreateLog(c echo.Context) error {
 
 	var logID string
 	var createdAt, updatedAt time.Time
+	dims := req.RatingDimensions.values()
 
-	err := h.DB.QueryRow(ctx, query,
-		userID, req.BookID, req.Status, req.Rating, req.Review,
+	err = h.DB.QueryRow(ctx, query,
+		userID, req.BookID, req.Status, req.Rating,
+		dims[0], dims[1], dims[2], dims[3],
+		req.Review, reviewFormat, rendered.HTML, rendered.HasSpoilers,
 		req.Notes, req.StartDate, req.FinishDate, isPublic, spoilerFlag,
 	).Scan(&logID, &createdAt, &updatedAt)
 
@@ -88,23 +123,57 @@ func (h *LogHandler) CreateLog(c echo.Context) error {
 		})
 	}
 
+	if err := saveLogMentions(ctx, h.DB, logID, userID, rendered.mentionIDs); err != nil {
+		log.Printf("failed to save mentions for log %s: %v", logID, err)
+	}
+
+	// Finishing a book can complete challenges, which in turn can earn badges
+	if req.Status == "read" {
+		if err := updateChallengeCompletions(ctx, h.DB, userID); err != nil {
+			log.Printf("failed to update challenge completions for user %s: %v", userID, err)
+		}
+	}
+	badgesAwarded := evaluateBadges(ctx, h.DB, userID, &logID)
+
+	// The new log may match the user's smart lists
+	if err := refreshSmartListCounts(ctx, h.DB, userID); err != nil {
+		log.Printf("failed to refresh smart list counts for user %s: %v", userID, err)
+	}
+
 	return c.JSON(http.StatusCreated, map[string]interface{}{
-		"id":          logID,
-		"user_id":     userID,
-		"book_id":     req.BookID,
-		"status":      req.Status,
-		"rating":      req.Rating,
-		"review":      req.Review,
-		"notes":       req.Notes,
-		"start_date":  req.StartDate,
-		"finish_date": req.FinishDate,
-		"is_public":   isPublic,
-		"created_at":  createdAt,
-		"updated_at":  updatedAt,
+		"id":                logID,
+		"user_id":           userID,
+		"book_id":           req.BookID,
+		"status":            req.Status,
+		"rating":            req.Rating,
+		"rating_dimensions": req.RatingDimensions.toMap(),
+		"review":            req.Review,
+		"review_format":     reviewFormat,
+		"review_html":       rendered.HTML,
+		"has_spoilers":      rendered.HasSpoilers,
+		"mentions":          rendered.Mentions,
+		"notes":             req.Notes,
+		"start_date":        req.StartDate,
+		"finish_date":       req.FinishDate,
+		"is_public":         isPublic,
+		"spoiler_flag":      spoilerFlag,
+		"created_at":        createdAt,
+		"updated_at":        updatedAt,
+		"badges_awarded":    badgesAwarded,
 	})
 }
 
-// GetUserLogs fetches all logs for a given username
+// logSorts are the sort keys accepted by GetUserLogs
+var logSorts = map[string]sortOption{
+	"created_at":  {Expr: "l.created_at", Type: "timestamptz", Desc: true},
+	"finish_date": {Expr: "COALESCE(l.finish_date, '0001-01-01'::date)", Type: "date", Desc: true},
+	"rating":      {Expr: "COALESCE(l.rating, 0)", Type: "numeric", Desc: true},
+	"title":       {Expr: "b.title", Type: "text", Desc: false},
+}
+
+// GetUserLogs fetches a page of logs for a given username.
+// Supports filters (status, min_rating, max_rating, year, has_review, category, author, shelf),
+// sorting (sort=created_at|finish_date|rating|title, order=asc|desc) and cursor pagination.
 func (h *LogHandler) GetUserLogs(c echo.Context) error {
 	username := c.Param("username")
 	if username == "" {
@@ -113,6 +182,13 @@ func (h *LogHandler) GetUserLogs(c echo.Context) error {
 		})
 	}
 
+	page, err := parsePageRequest(c, logSorts, "created_at", 50, 100)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
 	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
 	defer cancel()
 
@@ -126,62 +202,44 @@ func (h *LogHandler) GetUserLogs(c echo.Context) error {
 			isOwnProfile = (currentUserID == profileUserID)
 		}
 	}
-	
 
-	var query string
-	var args []interface{}
+	qb := newQueryBuilder(username)
 
-	if isOwnProfile {
-		// Show all logs (public and private) for own profile
-		query = `
-			SELECT l.id, l.user_id, l.book_id, l.status, l.rating, l.review,
-			       l.notes, l.start_date, l.finish_date, l.is_public, l.created_at,
-			       l.likes_count, l.comments_count,
-			       b.title, b.authors, b.cover_url,
-			       EXISTS(SELECT 1 FROM log_likes WHERE log_id = l.id AND user_id = $2) as is_liked
-			FROM logs l
-			JOIN users u ON l.user_id = u.id
-			JOIN books b ON l.book_id = b.id
-			WHERE u.username = $1
-			ORDER BY l.created_at DESC
-			LIMIT 50
-		`
-		args = []interface{}{username, currentUserID}
-	} else if currentUserID != "" {
-		// Show only public logs for authenticated users viewing other profiles
-		query = `
-			SELECT l.id, l.user_id, l.book_id, l.status, l.rating, l.review,
-			       l.notes, l.start_date, l.finish_date, l.is_public, l.created_at,
-			       l.likes_count, l.comments_count,
-			       b.title, b.authors, b.cover_url,
-			       EXISTS(SELECT 1 FROM log_likes WHERE log_id = l.id AND user_id = $2) as is_liked
-			FROM logs l
-			JOIN users u ON l.user_id = u.id
-			JOIN books b ON l.book_id = b.id
-			WHERE u.username = $1 AND l.is_public = true
-			ORDER BY l.created_at DESC
-			LIMIT 50
-		`
-		args = []interface{}{username, currentUserID}
-	} else {
-		// Show only public logs for unauthenticated users
-		query = `
-			SELECT l.id, l.user_id, l.book_id, l.status, l.rating, l.review,
-			       l.notes, l.start_date, l.finish_date, l.is_public, l.created_at,
-			       l.likes_count, l.comments_count,
-			       b.title, b.authors, b.cover_url,
-			       false as is_liked
-			FROM logs l
-			JOIN users u ON l.user_id = u.id
-			JOIN books b ON l.book_id = b.id
-			WHERE u.username = $1 AND l.is_public = true
-			ORDER BY l.created_at DESC
-			LIMIT 50
-		`
-		args = []interface{}{username}
+	// Only the requesting user's likes are shown
+	isLikedExpr := "false"
+	if currentUserID != "" {
+		isLikedExpr = "EXISTS(SELECT 1 FROM log_likes WHERE log_id = l.id AND user_id = " + qb.arg(currentUserID) + ")"
 	}
 
-	rows, err := h.DB.Query(ctx, query, args...)
+	// Show all logs (public and private) for own profile, only public logs otherwise
+	if !isOwnProfile {
+		qb.where("l.is_public = true")
+	}
+
+	if err := applyLogFilters(c, qb, isOwnProfile); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	page.applyCursor(qb, "l.id")
+
+	query := `
+		SELECT l.id, l.user_id, l.book_id, l.status, l.rating,
+		       l.rating_plot, l.rating_prose, l.rating_characters, l.rating_pacing, l.review,
+		       COALESCE(l.review_format, 'text'), l.review_html, COALESCE(l.review_has_spoilers, false),
+		       l.notes, l.start_date, l.finish_date, l.is_public, COALESCE(l.spoiler_flag, false), l.created_at,
+		       l.likes_count, l.comments_count,
+		       b.title, b.authors, b.cover_url,
+		       ` + isLikedExpr + ` as is_liked,
+		       ` + page.cursorColumn() + `
+		FROM logs l
+		JOIN users u ON l.user_id = u.id
+		JOIN books b ON l.book_id = b.id
+		WHERE u.username = $1` + qb.and() + `
+		` + page.orderBy(qb, "l.id")
+
+	rows, err := h.DB.Query(ctx, query, qb.args...)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{
 			"error": "failed to fetch logs",
@@ -190,18 +248,24 @@ func (h *LogHandler) GetUserLogs(c echo.Context) error {
 	defer rows.Close()
 
 	logs := []map[string]interface{}{}
+	cursors := []pageCursor{}
 	for rows.Next() {
 		var log struct {
 			ID            string
 			UserID        string
 			BookID        string
 			Status        string
-			Rating        *int
+			Rating        *float64
+			Dimensions    RatingDimensions
 			Review        *string
+			ReviewFormat  string
+			ReviewHTML    *string
+			HasSpoilers   bool
 			Notes         *string
 			StartDate     *string
 			FinishDate    *string
 			IsPublic      bool
+			SpoilerFlag   bool
 			CreatedAt     time.Time
 			LikesCount    int
 			CommentsCount int
@@ -209,33 +273,43 @@ func (h *LogHandler) GetUserLogs(c echo.Context) error {
 			Authors       []string
 			CoverURL      *string
 			IsLiked       bool
+			CursorValue   string
 		}
 
 		err := rows.Scan(
 			&log.ID, &log.UserID, &log.BookID, &log.Status, &log.Rating,
-			&log.Review, &log.Notes, &log.StartDate, &log.FinishDate,
-			&log.IsPublic, &log.CreatedAt, &log.LikesCount, &log.CommentsCount,
+			&log.Dimensions.Plot, &log.Dimensions.Prose, &log.Dimensions.Characters, &log.Dimensions.Pacing,
+			&log.Review, &log.ReviewFormat, &log.ReviewHTML, &log.HasSpoilers,
+			&log.Notes, &log.StartDate, &log.FinishDate,
+			&log.IsPublic, &log.SpoilerFlag, &log.CreatedAt, &log.LikesCount, &log.CommentsCount,
 			&log.BookTitle, &log.Authors, &log.CoverURL, &log.IsLiked,
+			&log.CursorValue,
 		)
 		if err != nil {
 			continue
 		}
 
+		cursors = append(cursors, pageCursor{Value: log.CursorValue, ID: log.ID})
 		logs = append(logs, map[string]interface{}{
-			"id":             log.ID,
-			"user_id":        log.UserID,
-			"book_id":        log.BookID,
-			"status":         log.Status,
-			"rating":         log.Rating,
-			"review":         log.Review,
-			"notes":          log.Notes,
-			"start_date":     log.StartDate,
-			"finish_date":    log.FinishDate,
-			"is_public":      log.IsPublic,
-			"created_at":     log.CreatedAt,
-			"likes_count":    log.LikesCount,
-			"comments_count": log.CommentsCount,
-			"is_liked":       log.IsLiked,
+			"id":                log.ID,
+			"user_id":           log.UserID,
+			"book_id":           log.BookID,
+			"status":            log.Status,
+			"rating":            log.Rating,
+			"rating_dimensions": log.Dimensions.toMap(),
+			"review":            log.Review,
+			"review_format":     log.ReviewFormat,
+			"review_html":       reviewHTML(log.ReviewHTML, log.Review),
+			"has_spoilers":      log.HasSpoilers,
+			"notes":             log.Notes,
+			"start_date":        log.StartDate,
+			"finish_date":       log.FinishDate,
+			"is_public":         log.IsPublic,
+			"spoiler_flag":      log.SpoilerFlag,
+			"created_at":        log.CreatedAt,
+			"likes_count":       log.LikesCount,
+			"comments_count":    log.CommentsCount,
+			"is_liked":          log.IsLiked,
 			"book": map[string]interface{}{
 				"title":     log.BookTitle,
 				"authors":   log.Authors,
@@ -244,12 +318,88 @@ func (h *LogHandler) GetUserLogs(c echo.Context) error {
 		})
 	}
 
+	logs, nextCursor := page.trim(logs, cursors)
+
 	return c.JSON(http.StatusOK, map[string]interface{}{
-		"logs":  logs,
-		"count": len(logs),
+		"logs":        logs,
+		"count":       len(logs),
+		"next_cursor": nextCursor,
+		"has_more":    nextCursor != nil,
 	})
 }
 
+// applyLogFilters adds the optional GetUserLogs filters to the query builder
+func applyLogFilters(c echo.Context, qb *queryBuilder, isOwnProfile bool) error {
+	if status := c.QueryParam("status"); status != "" {
+		statuses := strings.Split(status, ",")
+		for _, s := range statuses {
+			if !validLogStatuses[s] {
+				return fmt.Errorf("invalid status. Must be: want_to_read, reading, read, or dnf")
+			}
+		}
+		qb.where("l.status = ANY(" + qb.arg(statuses) + ")")
+	}
+
+	minRating, err := parseOptionalFloat(c, "min_rating")
+	if err != nil {
+		return err
+	}
+	if minRating != nil {
+		qb.where("l.rating >= " + qb.arg(*minRating))
+	}
+
+	maxRating, err := parseOptionalFloat(c, "max_rating")
+	if err != nil {
+		return err
+	}
+	if maxRating != nil {
+		qb.where("l.rating <= " + qb.arg(*maxRating))
+	}
+
+	year, err := parseOptionalInt(c, "year")
+	if err != nil {
+		return err
+	}
+	if year != nil {
+		qb.where("EXTRACT(YEAR FROM l.finish_date) = " + qb.arg(*year))
+	}
+
+	hasReview, err := parseOptionalBool(c, "has_review")
+	if err != nil {
+		return err
+	}
+	if hasReview != nil {
+		if *hasReview {
+			qb.where("(l.review IS NOT NULL AND l.review != '')")
+		} else {
+			qb.where("(l.review IS NULL OR l.review = '')")
+		}
+	}
+
+	if category := c.QueryParam("category"); category != "" {
+		qb.where("EXISTS (SELECT 1 FROM unnest(b.categories) AS category WHERE LOWER(category) = LOWER(" + qb.arg(category) + "))")
+	}
+
+	if author := c.QueryParam("author"); author != "" {
+		qb.where("EXISTS (SELECT 1 FROM unnest(b.authors) AS author WHERE author ILIKE " + qb.arg("%"+author+"%") + ")")
+	}
+
+	// Shelf filter by slug; other users' private shelves are hidden
+	if shelf := c.QueryParam("shelf"); shelf != "" {
+		shelfVisibility := ""
+		if !isOwnProfile {
+			shelfVisibility = " AND s.is_public = true"
+		}
+		qb.where(`EXISTS (
+			SELECT 1 FROM log_shelves ls
+			JOIN shelves s ON ls.shelf_id = s.id
+			WHERE ls.log_id = l.id AND s.slug = ` + qb.arg(shelf) + shelfVisibility + `
+		)`)
+	}
+
+	return nil
+}
+
 // GetFeed gets the list-based activity feed for the current user
 func (h *LogHandler) GetFeed(c echo.Context) error {
 	userID := auth.GetUserID(c)
@@ -271,7 +421,7 @@ func (h *LogHandler) GetFeed(c echo.Context) error {
 		})
 	}
 
-	var query string
+	var query, feedOrder string
 	var args []interface{}
 
 	if followerCount > 0 {
@@ -306,6 +456,7 @@ func (h *LogHandler) GetFeed(c echo.Context) error {
 			LIMIT 50
 		`
 		args = []interface{}{userID}
+		feedOrder = "created_at DESC"
 	} else {
 		// If no followers, show popular public lists to bootstrap engagement
 		query = `
@@ -336,8 +487,15 @@ func (h *LogHandler) GetFeed(c echo.Context) error {
 			LIMIT 50
 		`
 		args = []interface{}{userID}
+		// Keep the popular lists first, in popularity order, ahead of any other events
+		feedOrder = "CASE WHEN event_type = 'list_created' THEN likes_count END DESC NULLS LAST, created_at DESC"
 	}
 
+	// Mix in books added to lists the user subscribes to and highlights shared by people they follow.
+	// Each part keeps its own limit so busy subscriptions can't crowd out the rest
+	query = `SELECT * FROM ((` + query + `) UNION ALL (` + subscribedListFeedSQL + `) UNION ALL (` + sharedHighlightFeedSQL + `)) feed
+		ORDER BY ` + feedOrder
+
 	rows, err := h.DB.Query(ctx, query, args...)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{
@@ -382,15 +540,35 @@ func (h *LogHandler) GetFeed(c echo.Context) error {
 			continue
 		}
 
-		// Fetch preview books for this list (top 3 covers)
+		// Fetch preview books for this list (top 3 covers); for added-items events
+		// the preview is the newly added books themselves
 		previewBooks := []map[string]interface{}{}
-		if item.ItemsCount > 0 {
+		if len(item.BookIDs) > 0 {
+			for i, bookID := range item.BookIDs {
+				if i == 3 {
+					break
+				}
+				var bookCover *string
+				if i < len(item.BookCovers) && item.BookCovers[i] != "" {
+					bookCover = &item.BookCovers[i]
+				}
+				bookTitle := ""
+				if i < len(item.BookTitles) {
+					bookTitle = item.BookTitles[i]
+				}
+				previewBooks = append(previewBooks, map[string]interface{}{
+					"id":        bookID,
+					"title":     bookTitle,
+					"cover_url": bookCover,
+				})
+			}
+		} else if item.ItemsCount > 0 {
 			bookQuery := `
 				SELECT b.id, b.title, b.cover_url
 				FROM list_items li
 				JOIN books b ON li.book_id = b.id
 				WHERE li.list_id = $1
-				ORDER BY li.item_order
+				ORDER BY li.position_key
 				LIMIT 3
 			`
 			bookRows, err := h.DB.Query(ctx, bookQuery, item.EntityID)
@@ -410,7 +588,32 @@ func (h *LogHandler) GetFeed(c echo.Context) error {
 			}
 		}
 
-		feed = append(feed, map[string]interface{}{
+		// Highlight events carry the highlight, the note on it and its book
+		if item.EventType == "highlight_shared" {
+			event := map[string]interface{}{
+				"event_type":      item.EventType,
+				"id":              item.EntityID,
+				"content":         item.Title,
+				"note":            item.Description,
+				"reactions_count": item.LikesCount,
+				"created_at":      item.CreatedAt,
+				"is_liked":        item.IsLiked,
+				"book":            nil,
+				"user": map[string]interface{}{
+					"id":       item.UserID,
+					"username": item.Username,
+					"name":     item.UserName,
+					"picture":  item.Picture,
+				},
+			}
+			if len(previewBooks) > 0 {
+				event["book"] = previewBooks[0]
+			}
+			feed = append(feed, event)
+			continue
+		}
+
+		event := map[string]interface{}{
 			"event_type":       item.EventType,
 			"id":               item.EntityID,
 			"title":            item.Title,
@@ -429,7 +632,11 @@ func (h *LogHandler) GetFeed(c echo.Context) error {
 				"name":     item.UserName,
 				"picture":  item.Picture,
 			},
-		})
+		}
+		if item.EventType == "list_items_added" {
+			event["new_items_count"] = len(item.BookIDs)
+		}
+		feed = append(feed, event)
 	}
 
 	return c.JSON(http.StatusOK, map[string]interface{}{
@@ -453,7 +660,9 @@ func (h *LogHandler) GetSingleLog(c echo.Context) error {
 	currentUserID := auth.GetUserID(c)
 
 	query := `
-		SELECT l.id, l.user_id, l.book_id, l.status, l.rating, l.review,
+		SELECT l.id, l.user_id, l.book_id, l.status, l.rating,
+		       l.rating_plot, l.rating_prose, l.rating_characters, l.rating_pacing, l.review,
+		       COALESCE(l.review_format, 'text'), l.review_html, COALESCE(l.review_has_spoilers, false),
 		       l.notes, l.start_date, l.finish_date, l.is_public, l.spoiler_flag, l.created_at,
 		       l.likes_count, l.comments_count,
 		       u.username, u.name, u.picture,
@@ -470,8 +679,12 @@ func (h *LogHandler) GetSingleLog(c echo.Context) error {
 		UserID        string
 		BookID        string
 		Status        string
-		Rating        *int
+		Rating        *float64
+		Dimensions    RatingDimensions
 		Review        *string
+		ReviewFormat  string
+		ReviewHTML    *string
+		HasSpoilers   bool
 		Notes         *string
 		StartDate     *string
 		FinishDate    *string
@@ -493,7 +706,9 @@ func (h *LogHandler) GetSingleLog(c echo.Context) error {
 	}
 
 	err := h.DB.QueryRow(ctx, query, logID, currentUserID).Scan(
-		&log.ID, &log.UserID, &log.BookID, &log.Status, &log.Rating, &log.Review,
+		&log.ID, &log.UserID, &log.BookID, &log.Status, &log.Rating,
+		&log.Dimensions.Plot, &log.Dimensions.Prose, &log.Dimensions.Characters, &log.Dimensions.Pacing, &log.Review,
+		&log.ReviewFormat, &log.ReviewHTML, &log.HasSpoilers,
 		&log.Notes, &log.StartDate, &log.FinishDate, &log.IsPublic, &log.SpoilerFlag, &log.CreatedAt,
 		&log.LikesCount, &log.CommentsCount,
 		&log.Username, &log.Name, &log.Picture,
@@ -514,20 +729,53 @@ func (h *LogHandler) GetSingleLog(c echo.Context) error {
 		})
 	}
 
+	reactionsByType, myReactions := reactionSummary(ctx, h.DB, "log", logID, currentUserID)
+
+	mentions := []map[string]interface{}{}
+	mentionRows, err := h.DB.Query(ctx, `
+		SELECT u.id, u.username, u.name, u.picture
+		FROM log_mentions m
+		JOIN users u ON m.user_id = u.id
+		WHERE m.log_id = $1
+		ORDER BY m.created_at
+	`, log.ID)
+	if err == nil {
+		defer mentionRows.Close()
+		for mentionRows.Next() {
+			var id, username, name string
+			var picture *string
+			if err := mentionRows.Scan(&id, &username, &name, &picture); err == nil {
+				mentions = append(mentions, map[string]interface{}{
+					"id":       id,
+					"username": username,
+					"name":     name,
+					"picture":  picture,
+				})
+			}
+		}
+	}
+
 	return c.JSON(http.StatusOK, map[string]interface{}{
-		"id":             log.ID,
-		"status":         log.Status,
-		"rating":         log.Rating,
-		"review":         log.Review,
-		"notes":          log.Notes,
-		"start_date":     log.StartDate,
-		"finish_date":    log.FinishDate,
-		"is_public":      log.IsPublic,
-		"spoiler_flag":   log.SpoilerFlag,
-		"created_at":     log.CreatedAt,
-		"likes_count":    log.LikesCount,
-		"comments_count": log.CommentsCount,
-		"is_liked":       log.IsLiked,
+		"id":                log.ID,
+		"status":            log.Status,
+		"rating":            log.Rating,
+		"rating_dimensions": log.Dimensions.toMap(),
+		"review":            log.Review,
+		"review_format":     log.ReviewFormat,
+		"review_html":       reviewHTML(log.ReviewHTML, log.Review),
+		"has_spoilers":      log.HasSpoilers,
+		"mentions":          mentions,
+		"notes":             log.Notes,
+		"start_date":        log.StartDate,
+		"finish_date":       log.FinishDate,
+		"is_public":         log.IsPublic,
+		"spoiler_flag":      log.SpoilerFlag,
+		"created_at":        log.CreatedAt,
+		"likes_count":       log.LikesCount,
+		"comments_count":    log.CommentsCount,
+		"is_liked":          log.IsLiked,
+		"reaction_counts":   reactionsByType,
+		"my_reactions":      myReactions,
 		"user": map[string]interface{}{
 			"id":       log.UserID,
 			"username": log.Username,
@@ -545,4 +793,3 @@ func (h *LogHandler) GetSingleLog(c echo.Context) error {
 		},
 	})
 }
-
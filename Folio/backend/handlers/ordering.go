@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+)
+
+// positionDigits is the base62 alphabet for list item position keys, in byte order
+const positionDigits = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// keyBetween returns a position key that sorts strictly between a and b.
+// An empty a means the start of the list and an empty b the end.
+// Keys are fractions in base62 that never end in '0', so there is always room between two of them.
+func keyBetween(a, b string) (string, error) {
+	if b != "" && a >= b {
+		return "", fmt.Errorf("position keys out of order: %q >= %q", a, b)
+	}
+	if strings.HasSuffix(a, "0") || strings.HasSuffix(b, "0") {
+		return "", fmt.Errorf("invalid position key")
+	}
+	return positionMidpoint(a, b), nil
+}
+
+func positionMidpoint(a, b string) string {
+	if b != "" {
+		// Keep the shared prefix and find a midpoint in what follows it
+		n := 0
+		for n < len(b) && positionDigitAt(a, n) == b[n] {
+			n++
+		}
+		if n > 0 {
+			rest := ""
+			if n < len(a) {
+				rest = a[n:]
+			}
+			return b[:n] + positionMidpoint(rest, b[n:])
+		}
+	}
+
+	digitA := 0
+	if a != "" {
+		digitA = strings.IndexByte(positionDigits, a[0])
+	}
+	digitB := len(positionDigits)
+	if b != "" {
+		digitB = strings.IndexByte(positionDigits, b[0])
+	}
+
+	if digitB-digitA > 1 {
+		return string(positionDigits[(digitA+digitB+1)/2])
+	}
+
+	// The first digits are adjacent
+	if len(b) > 1 {
+		return b[:1]
+	}
+	rest := ""
+	if len(a) > 1 {
+		rest = a[1:]
+	}
+	return string(positionDigits[digitA]) + positionMidpoint(rest, "")
+}
+
+func positionDigitAt(s string, i int) byte {
+	if i < len(s) {
+		return s[i]
+	}
+	return positionDigits[0]
+}
+
+// keysBetween returns n evenly spread keys between a and b, used when rewriting a whole list's order
+func keysBetween(a, b string, n int) ([]string, error) {
+	if n == 0 {
+		return []string{}, nil
+	}
+	if n == 1 {
+		key, err := keyBetween(a, b)
+		if err != nil {
+			return nil, err
+		}
+		return []string{key}, nil
+	}
+
+	mid, err := keyBetween(a, b)
+	if err != nil {
+		return nil, err
+	}
+	left, err := keysBetween(a, mid, n/2)
+	if err != nil {
+		return nil, err
+	}
+	right, err := keysBetween(mid, b, n-n/2-1)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := append(left, mid)
+	return append(keys, right...), nil
+}
+
+// errListVersionConflict means the client edited a stale copy of the list
+var errListVersionConflict = errors.New("list has been modified")
+
+// listETag renders a list version as an ETag
+func listETag(version int) string {
+	return fmt.Sprintf(`"%d"`, version)
+}
+
+// expectedListVersion reads the version the client last saw from If-Match, or nil when it sent none
+func expectedListVersion(c echo.Context) (*int, error) {
+	header := strings.TrimSpace(c.Request().Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return nil, nil
+	}
+	header = strings.TrimPrefix(header, "W/")
+	version, err := strconv.Atoi(strings.Trim(header, `"`))
+	if err != nil {
+		return nil, fmt.Errorf("If-Match must be a list version")
+	}
+	return &version, nil
+}
+
+// lockListVersion locks the list row for the rest of tx and checks it against the client's version
+func lockListVersion(ctx context.Context, tx pgx.Tx, listID string, expected *int) (int, error) {
+	var version int
+	err := tx.QueryRow(ctx, "SELECT version FROM lists WHERE id = $1 FOR UPDATE", listID).Scan(&version)
+	if err != nil {
+		return 0, err
+	}
+	if expected != nil && *expected != version {
+		return version, errListVersionConflict
+	}
+	return version, nil
+}
+
+// currentListVersion reads the version after a change, for the response ETag
+func currentListVersion(ctx context.Context, q rowQuerier, listID string) int {
+	var version int
+	q.QueryRow(ctx, "SELECT version FROM lists WHERE id = $1", listID).Scan(&version)
+	return version
+}
+
+// listVersionConflict writes the 409 response for a stale If-Match
+func listVersionConflict(c echo.Context, version int) error {
+	c.Response().Header().Set("ETag", listETag(version))
+	return c.JSON(http.StatusConflict, map[string]interface{}{
+		"error":   "list has been modified since you loaded it",
+		"version": version,
+	})
+}
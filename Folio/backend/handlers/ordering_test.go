@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+)
+
+// checkPositionKey fails unless key is a valid key strictly between a and b. Go compares
+// strings byte by byte, as positions are under COLLATE "C".
+func checkPositionKey(t *testing.T, a, b, key string) {
+	t.Helper()
+	if key == "" || strings.HasSuffix(key, "0") {
+		t.Fatalf("key %q is not a valid position key", key)
+	}
+	for _, r := range key {
+		if !strings.ContainsRune(positionDigits, r) {
+			t.Fatalf("key %q has a digit outside base62", key)
+		}
+	}
+	if key <= a {
+		t.Fatalf("key %q does not sort after %q", key, a)
+	}
+	if b != "" && key >= b {
+		t.Fatalf("key %q does not sort before %q", key, b)
+	}
+}
+
+func TestKeyBetween(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+	}{
+		{"empty list", "", ""},
+		{"at the start", "", "V"},
+		{"at the end", "V", ""},
+		{"before the first digit", "", "1"},
+		{"after the last digit", "z", ""},
+		{"between", "A", "z"},
+		{"adjacent keys", "a", "b"},
+		{"adjacent longer keys", "aV", "aW"},
+		{"prefix of the next key", "a", "a1"},
+		{"shared prefix", "abc", "abd"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := keyBetween(tt.a, tt.b)
+			if err != nil {
+				t.Fatalf("keyBetween(%q, %q) returned error: %v", tt.a, tt.b, err)
+			}
+			checkPositionKey(t, tt.a, tt.b, key)
+		})
+	}
+}
+
+func TestKeyBetweenInvalidBounds(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+	}{
+		{"reversed", "b", "a"},
+		{"equal", "a", "a"},
+		{"trailing zero", "a0", "b"},
+		{"trailing zero in the upper bound", "a", "b0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if key, err := keyBetween(tt.a, tt.b); err == nil {
+				t.Errorf("keyBetween(%q, %q) = %q, want an error", tt.a, tt.b, key)
+			}
+			if keys, err := keysBetween(tt.a, tt.b, 3); err == nil {
+				t.Errorf("keysBetween(%q, %q, 3) = %q, want an error", tt.a, tt.b, keys)
+			}
+		})
+	}
+}
+
+func TestKeyBetweenRepeated(t *testing.T) {
+	t.Run("prepends", func(t *testing.T) {
+		first := "V"
+		for i := 0; i < 200; i++ {
+			key, err := keyBetween("", first)
+			if err != nil {
+				t.Fatalf("prepend %d: %v", i, err)
+			}
+			checkPositionKey(t, "", first, key)
+			first = key
+		}
+	})
+
+	t.Run("appends", func(t *testing.T) {
+		last := "V"
+		for i := 0; i < 200; i++ {
+			key, err := keyBetween(last, "")
+			if err != nil {
+				t.Fatalf("append %d: %v", i, err)
+			}
+			checkPositionKey(t, last, "", key)
+			last = key
+		}
+	})
+
+	t.Run("inserts after the same key", func(t *testing.T) {
+		next := "b"
+		for i := 0; i < 200; i++ {
+			key, err := keyBetween("a", next)
+			if err != nil {
+				t.Fatalf("insert %d: %v", i, err)
+			}
+			checkPositionKey(t, "a", next, key)
+			next = key
+		}
+	})
+}
+
+func TestKeysBetween(t *testing.T) {
+	bounds := []struct{ a, b string }{
+		{"", ""},
+		{"", "1"},
+		{"a", "b"},
+		{"y", ""},
+	}
+
+	for _, bound := range bounds {
+		for _, n := range []int{0, 1, 2, 3, 10, 100} {
+			keys, err := keysBetween(bound.a, bound.b, n)
+			if err != nil {
+				t.Fatalf("keysBetween(%q, %q, %d) returned error: %v", bound.a, bound.b, n, err)
+			}
+			if len(keys) != n {
+				t.Fatalf("keysBetween(%q, %q, %d) returned %d keys", bound.a, bound.b, n, len(keys))
+			}
+			previous := bound.a
+			for _, key := range keys {
+				checkPositionKey(t, previous, bound.b, key)
+				previous = key
+			}
+		}
+	}
+}
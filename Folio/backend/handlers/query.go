@@ -0,0 +1,226 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// sortOption maps a public sort key to the SQL expression used for ordering and keyset cursors.
+// Expr must never be NULL (wrap nullable columns in COALESCE) so cursors compare cleanly.
+type sortOption struct {
+	Expr string // SQL expression to order by
+	Type string // Postgres type the cursor value is cast back to
+	Desc bool   // Default direction when no order is requested
+}
+
+// pageCursor is the opaque position handed back to clients as next_cursor
+type pageCursor struct {
+	Value string `json:"v"`
+	ID    string `json:"id"`
+}
+
+// pageRequest holds the parsed limit, sort and cursor for a list endpoint
+type pageRequest struct {
+	Limit   int
+	SortKey string
+	Sort    sortOption
+	Desc    bool
+	Cursor  *pageCursor
+}
+
+// queryBuilder accumulates WHERE conditions and their positional arguments
+type queryBuilder struct {
+	args       []interface{}
+	conditions []string
+}
+
+// newQueryBuilder starts a builder with the given fixed arguments ($1, $2, ...)
+func newQueryBuilder(args ...interface{}) *queryBuilder {
+	return &queryBuilder{args: args}
+}
+
+// arg registers a value and returns its placeholder
+func (qb *queryBuilder) arg(value interface{}) string {
+	qb.args = append(qb.args, value)
+	return fmt.Sprintf("$%d", len(qb.args))
+}
+
+// where adds a condition; use arg to build its placeholders
+func (qb *queryBuilder) where(condition string) {
+	qb.conditions = append(qb.conditions, condition)
+}
+
+// and renders the accumulated conditions for appending after an existing WHERE
+func (qb *queryBuilder) and() string {
+	if len(qb.conditions) == 0 {
+		return ""
+	}
+	return "\n\t\tAND " + strings.Join(qb.conditions, "\n\t\tAND ")
+}
+
+// parsePageRequest reads limit, sort, order and cursor query parameters
+func parsePageRequest(c echo.Context, sorts map[string]sortOption, defaultSort string, defaultLimit, maxLimit int) (*pageRequest, error) {
+	page := &pageRequest{Limit: defaultLimit, SortKey: defaultSort}
+
+	if l := c.QueryParam("limit"); l != "" {
+		limit, err := strconv.Atoi(l)
+		if err != nil || limit <= 0 {
+			return nil, fmt.Errorf("limit must be a positive integer")
+		}
+		page.Limit = limit
+	}
+	if page.Limit > maxLimit {
+		page.Limit = maxLimit
+	}
+
+	if s := c.QueryParam("sort"); s != "" {
+		page.SortKey = s
+	}
+	opt, ok := sorts[page.SortKey]
+	if !ok {
+		keys := make([]string, 0, len(sorts))
+		for key := range sorts {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		return nil, fmt.Errorf("invalid sort. Must be one of: %s", strings.Join(keys, ", "))
+	}
+	page.Sort = opt
+	page.Desc = opt.Desc
+
+	switch strings.ToLower(c.QueryParam("order")) {
+	case "":
+	case "asc":
+		page.Desc = false
+	case "desc":
+		page.Desc = true
+	default:
+		return nil, fmt.Errorf("order must be 'asc' or 'desc'")
+	}
+
+	if cursor := c.QueryParam("cursor"); cursor != "" {
+		decoded, err := decodeCursor(cursor)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cursor")
+		}
+		page.Cursor = decoded
+	}
+
+	return page, nil
+}
+
+// applyCursor restricts the query to rows after the cursor position.
+// idExpr is the unique tiebreaker column that keeps ordering stable.
+func (p *pageRequest) applyCursor(qb *queryBuilder, idExpr string) {
+	if p.Cursor == nil {
+		return
+	}
+	op := ">"
+	if p.Desc {
+		op = "<"
+	}
+	qb.where(fmt.Sprintf("(%s, %s::text) %s (CAST(%s AS %s), %s)",
+		p.Sort.Expr, idExpr, op, qb.arg(p.Cursor.Value), p.Sort.Type, qb.arg(p.Cursor.ID)))
+}
+
+// cursorColumn selects the sort value as text so it can be echoed back in the next cursor
+func (p *pageRequest) cursorColumn() string {
+	return fmt.Sprintf("(%s)::text", p.Sort.Expr)
+}
+
+// orderBy renders the ORDER BY and LIMIT clauses; one extra row is fetched to detect more pages
+func (p *pageRequest) orderBy(qb *queryBuilder, idExpr string) string {
+	dir := "ASC"
+	if p.Desc {
+		dir = "DESC"
+	}
+	return fmt.Sprintf("ORDER BY %s %s, %s::text %s\n\t\tLIMIT %s", p.Sort.Expr, dir, idExpr, dir, qb.arg(p.Limit+1))
+}
+
+// trim drops the look-ahead row and returns the cursor for the next page, if any
+func (p *pageRequest) trim(items []map[string]interface{}, cursors []pageCursor) ([]map[string]interface{}, *string) {
+	if len(items) <= p.Limit || len(cursors) < p.Limit {
+		return items, nil
+	}
+	next := encodeCursor(cursors[p.Limit-1])
+	return items[:p.Limit], &next
+}
+
+func encodeCursor(cursor pageCursor) string {
+	raw, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeCursor(encoded string) (*pageCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	var cursor pageCursor
+	if err := json.Unmarshal(raw, &cursor); err != nil {
+		return nil, err
+	}
+	if cursor.ID == "" {
+		return nil, fmt.Errorf("cursor is missing id")
+	}
+	return &cursor, nil
+}
+
+// parseOptionalInt parses an integer query parameter, returning nil when it's absent
+func parseOptionalInt(c echo.Context, name string) (*int, error) {
+	v := c.QueryParam(name)
+	if v == "" {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be an integer", name)
+	}
+	return &n, nil
+}
+
+// parseOptionalBool parses a boolean query parameter, returning nil when it's absent
+func parseOptionalBool(c echo.Context, name string) (*bool, error) {
+	v := c.QueryParam(name)
+	if v == "" {
+		return nil, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be true or false", name)
+	}
+	return &b, nil
+}
+
+// parseOptionalFloat parses a decimal query parameter, returning nil when it's absent
+func parseOptionalFloat(c echo.Context, name string) (*float64, error) {
+	v := c.QueryParam(name)
+	if v == "" {
+		return nil, nil
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be a number", name)
+	}
+	return &f, nil
+}
+
+// parseOptionalDate parses a YYYY-MM-DD query parameter, returning nil when it's absent
+func parseOptionalDate(c echo.Context, name string) (*time.Time, error) {
+	v := c.QueryParam(name)
+	if v == "" {
+		return nil, nil
+	}
+	d, err := time.Parse("2006-01-02", v)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be a date (YYYY-MM-DD)", name)
+	}
+	return &d, nil
+}
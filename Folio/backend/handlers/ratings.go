@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+)
+
+// ratingDimensions are the optional aspects a reader can rate alongside the overall rating.
+// Each maps to a rating_<dimension> column on logs.
+var ratingDimensions = []string{"plot", "prose", "characters", "pacing"}
+
+// RatingDimensions holds the optional per-dimension ratings on a log
+type RatingDimensions struct {
+	Plot       *float64 `json:"plot"`
+	Prose      *float64 `json:"prose"`
+	Characters *float64 `json:"characters"`
+	Pacing     *float64 `json:"pacing"`
+}
+
+// values returns the dimension ratings in ratingDimensions order
+func (d *RatingDimensions) values() []*float64 {
+	if d == nil {
+		return []*float64{nil, nil, nil, nil}
+	}
+	return []*float64{d.Plot, d.Prose, d.Characters, d.Pacing}
+}
+
+// toMap renders the dimensions for a JSON response
+func (d *RatingDimensions) toMap() map[string]interface{} {
+	out := make(map[string]interface{}, len(ratingDimensions))
+	for i, v := range d.values() {
+		out[ratingDimensions[i]] = v
+	}
+	return out
+}
+
+// validRating reports whether r is on the half-star scale from 0.5 to 5
+func validRating(r *float64) bool {
+	if r == nil {
+		return true
+	}
+	return *r >= 0.5 && *r <= 5 && math.Mod(*r*2, 1) == 0
+}
+
+// validateRatings checks the overall rating and every dimension
+func validateRatings(rating *float64, dims *RatingDimensions) error {
+	if !validRating(rating) {
+		return fmt.Errorf("rating must be between 0.5 and 5 in half-star steps")
+	}
+	for i, v := range dims.values() {
+		if !validRating(v) {
+			return fmt.Errorf("%s rating must be between 0.5 and 5 in half-star steps", ratingDimensions[i])
+		}
+	}
+	return nil
+}
+
+// ratingHistogramBuckets are the keys of the half-star histogram returned by GetBookStats
+var ratingHistogramBuckets = []string{"0.5", "1.0", "1.5", "2.0", "2.5", "3.0", "3.5", "4.0", "4.5", "5.0"}
@@ -0,0 +1,398 @@
+package handlers
+
+import (
+	"context"
+	"folio/api/auth"
+	"net/http"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/labstack/echo/v4"
+)
+
+// reactionTypes is the fixed set of reactions, in display order. "like" is the
+// old binary like and still drives likes_count on logs and lists.
+var reactionTypes = []string{"like", "love", "laugh", "wow", "sad", "insightful"}
+
+// reactionTargets are the kinds of things that can be reacted to
+var reactionTargets = map[string]bool{
+	"log":          true,
+	"list":         true,
+	"log_comment":  true,
+	"list_comment": true,
+	"annotation":   true,
+}
+
+func isValidReaction(reaction string) bool {
+	for _, r := range reactionTypes {
+		if r == reaction {
+			return true
+		}
+	}
+	return false
+}
+
+// ReactionHandler serves reactions on logs, lists, comments and annotations
+type ReactionHandler struct {
+	DB *pgxpool.Pool
+}
+
+// canViewReactionTarget reports whether the target exists (err is nil) and the user may see,
+// and so react to, it. Comments follow the visibility of the log or list they belong to.
+func (h *ReactionHandler) canViewReactionTarget(ctx context.Context, targetType, targetID, userID string) (bool, error) {
+	switch targetType {
+	case "log":
+		var ownerID string
+		var isPublic bool
+		err := h.DB.QueryRow(ctx, "SELECT user_id, COALESCE(is_public, true) FROM logs WHERE id = $1", targetID).Scan(&ownerID, &isPublic)
+		if err != nil {
+			return false, err
+		}
+		return isPublic || ownerID == userID, nil
+	case "list":
+		var isPublic bool
+		err := h.DB.QueryRow(ctx, "SELECT is_public FROM lists WHERE id = $1", targetID).Scan(&isPublic)
+		if err != nil {
+			return false, err
+		}
+		if isPublic {
+			return true, nil
+		}
+		_, role, _ := getListRole(ctx, h.DB, targetID, userID)
+		return canViewList(role), nil
+	case "log_comment":
+		var logID string
+		err := h.DB.QueryRow(ctx, "SELECT log_id FROM log_comments WHERE id = $1 AND deleted_at IS NULL", targetID).Scan(&logID)
+		if err != nil {
+			return false, err
+		}
+		return h.canViewReactionTarget(ctx, "log", logID, userID)
+	case "list_comment":
+		var listID string
+		err := h.DB.QueryRow(ctx, "SELECT list_id FROM list_comments WHERE id = $1 AND deleted_at IS NULL", targetID).Scan(&listID)
+		if err != nil {
+			return false, err
+		}
+		return h.canViewReactionTarget(ctx, "list", listID, userID)
+	case "annotation":
+		var visible bool
+		err := h.DB.QueryRow(ctx, "SELECT "+annotationVisibleSQL("a", "$2")+" FROM annotations a WHERE a.id = $1",
+			targetID, nullableUserID(userID)).Scan(&visible)
+		if err != nil {
+			return false, err
+		}
+		return visible, nil
+	}
+	return false, nil
+}
+
+// checkReactionTarget validates the :type and :id params and writes the error response
+// when the target can't be used; ok is false in that case
+func (h *ReactionHandler) checkReactionTarget(ctx context.Context, c echo.Context, userID string) (targetType, targetID string, ok bool, err error) {
+	targetType = c.Param("type")
+	targetID = c.Param("id")
+	if !reactionTargets[targetType] {
+		return "", "", false, c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "invalid reaction target type",
+		})
+	}
+
+	visible, err := h.canViewReactionTarget(ctx, targetType, targetID, userID)
+	if err != nil {
+		return "", "", false, c.JSON(http.StatusNotFound, map[string]string{
+			"error": "target not found",
+		})
+	}
+	if !visible {
+		return "", "", false, c.JSON(http.StatusForbidden, map[string]string{
+			"error": "you don't have permission to view this " + targetType,
+		})
+	}
+	return targetType, targetID, true, nil
+}
+
+// reactionSummary returns the per-reaction counts on a target and the user's own reactions
+func reactionSummary(ctx context.Context, db *pgxpool.Pool, targetType, targetID, userID string) (map[string]int, []string) {
+	counts := reactionCounts(ctx, db, targetType, []string{targetID})[targetID]
+
+	mine := []string{}
+	if userID != "" {
+		rows, err := db.Query(ctx, `
+			SELECT reaction FROM reactions
+			WHERE target_type = $1 AND target_id = $2 AND user_id = $3
+		`, targetType, targetID, userID)
+		if err == nil {
+			defer rows.Close()
+			for rows.Next() {
+				var reaction string
+				if err := rows.Scan(&reaction); err == nil {
+					mine = append(mine, reaction)
+				}
+			}
+		}
+	}
+	return counts, mine
+}
+
+// reactionCounts returns the per-reaction counts for several targets of one type.
+// Every target gets a map, with zeros for reactions nobody has used.
+func reactionCounts(ctx context.Context, db *pgxpool.Pool, targetType string, targetIDs []string) map[string]map[string]int {
+	counts := make(map[string]map[string]int, len(targetIDs))
+	for _, id := range targetIDs {
+		counts[id] = map[string]int{}
+		for _, r := range reactionTypes {
+			counts[id][r] = 0
+		}
+	}
+	if len(targetIDs) == 0 {
+		return counts
+	}
+
+	rows, err := db.Query(ctx, `
+		SELECT target_id, reaction, COUNT(*)
+		FROM reactions
+		WHERE target_type = $1 AND target_id = ANY($2::uuid[])
+		GROUP BY target_id, reaction
+	`, targetType, targetIDs)
+	if err != nil {
+		return counts
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var targetID, reaction string
+		var count int
+		if err := rows.Scan(&targetID, &reaction, &count); err == nil {
+			if _, ok := counts[targetID]; ok {
+				counts[targetID][reaction] = count
+			}
+		}
+	}
+	return counts
+}
+
+func reactionTotal(counts map[string]int) int {
+	total := 0
+	for _, n := range counts {
+		total += n
+	}
+	return total
+}
+
+// GetReactions returns the reaction counts on a target and the current user's reactions
+func (h *ReactionHandler) GetReactions(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	currentUserID := auth.GetUserID(c)
+	targetType, targetID, ok, err := h.checkReactionTarget(ctx, c, currentUserID)
+	if !ok {
+		return err
+	}
+
+	counts, mine := reactionSummary(ctx, h.DB, targetType, targetID, currentUserID)
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"target_type":  targetType,
+		"target_id":    targetID,
+		"reactions":    reactionTypes,
+		"counts":       counts,
+		"total":        reactionTotal(counts),
+		"my_reactions": mine,
+	})
+}
+
+// AddReaction adds one of the allowed reactions to a target. Adding it twice is a no-op.
+func (h *ReactionHandler) AddReaction(c echo.Context) error {
+	userID := auth.GetUserID(c)
+	if userID == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": "unauthorized",
+		})
+	}
+
+	reaction := c.Param("reaction")
+	if !isValidReaction(reaction) {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"error":   "invalid reaction",
+			"allowed": reactionTypes,
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	targetType, targetID, ok, err := h.checkReactionTarget(ctx, c, userID)
+	if !ok {
+		return err
+	}
+
+	_, err = h.DB.Exec(ctx, `
+		INSERT INTO reactions (user_id, target_type, target_id, reaction, created_at)
+		VALUES ($1, $2, $3, $4, NOW())
+		ON CONFLICT (user_id, target_type, target_id, reaction) DO NOTHING
+	`, userID, targetType, targetID, reaction)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to add reaction",
+		})
+	}
+
+	counts, mine := reactionSummary(ctx, h.DB, targetType, targetID, userID)
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"target_type":  targetType,
+		"target_id":    targetID,
+		"counts":       counts,
+		"total":        reactionTotal(counts),
+		"my_reactions": mine,
+	})
+}
+
+// RemoveReaction removes one of the current user's reactions from a target
+func (h *ReactionHandler) RemoveReaction(c echo.Context) error {
+	userID := auth.GetUserID(c)
+	if userID == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": "unauthorized",
+		})
+	}
+
+	targetType := c.Param("type")
+	targetID := c.Param("id")
+	reaction := c.Param("reaction")
+	if !reactionTargets[targetType] {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "invalid reaction target type",
+		})
+	}
+	if !isValidReaction(reaction) {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"error":   "invalid reaction",
+			"allowed": reactionTypes,
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	// Removing needs no visibility check: users can always take back their own reactions
+	_, err := h.DB.Exec(ctx, `
+		DELETE FROM reactions
+		WHERE user_id = $1 AND target_type = $2 AND target_id = $3 AND reaction = $4
+	`, userID, targetType, targetID, reaction)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to remove reaction",
+		})
+	}
+
+	counts, mine := reactionSummary(ctx, h.DB, targetType, targetID, userID)
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"target_type":  targetType,
+		"target_id":    targetID,
+		"counts":       counts,
+		"total":        reactionTotal(counts),
+		"my_reactions": mine,
+	})
+}
+
+// reactionUserSorts are the sort keys accepted by GetReactionUsers; newest first by default
+var reactionUserSorts = map[string]sortOption{
+	"created_at": {Expr: "r.created_at", Type: "timestamptz", Desc: true},
+}
+
+// GetReactionUsers returns a page of who reacted to a target, optionally for one reaction
+func (h *ReactionHandler) GetReactionUsers(c echo.Context) error {
+	page, err := parsePageRequest(c, reactionUserSorts, "created_at", 50, 100)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	reaction := c.QueryParam("reaction")
+	if reaction != "" && !isValidReaction(reaction) {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"error":   "invalid reaction",
+			"allowed": reactionTypes,
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	currentUserID := auth.GetUserID(c)
+	targetType, targetID, ok, err := h.checkReactionTarget(ctx, c, currentUserID)
+	if !ok {
+		return err
+	}
+
+	qb := newQueryBuilder(targetType, targetID)
+	if reaction != "" {
+		qb.where("r.reaction = " + qb.arg(reaction))
+	}
+	page.applyCursor(qb, "r.id")
+
+	query := `
+		SELECT r.id, r.reaction, r.created_at,
+		       u.id, u.username, u.name, u.picture,
+		       ` + page.cursorColumn() + `
+		FROM reactions r
+		JOIN users u ON r.user_id = u.id
+		WHERE r.target_type = $1 AND r.target_id = $2` + qb.and() + `
+		` + page.orderBy(qb, "r.id")
+
+	rows, err := h.DB.Query(ctx, query, qb.args...)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to fetch reactions",
+		})
+	}
+	defer rows.Close()
+
+	reactions := []map[string]interface{}{}
+	cursors := []pageCursor{}
+	for rows.Next() {
+		var r struct {
+			ID          string
+			Reaction    string
+			CreatedAt   time.Time
+			UserID      string
+			Username    string
+			Name        string
+			Picture     *string
+			CursorValue string
+		}
+
+		err := rows.Scan(
+			&r.ID, &r.Reaction, &r.CreatedAt,
+			&r.UserID, &r.Username, &r.Name, &r.Picture, &r.CursorValue,
+		)
+		if err != nil {
+			continue
+		}
+
+		cursors = append(cursors, pageCursor{Value: r.CursorValue, ID: r.ID})
+		reactions = append(reactions, map[string]interface{}{
+			"reaction":   r.Reaction,
+			"created_at": r.CreatedAt,
+			"user": map[string]interface{}{
+				"id":       r.UserID,
+				"username": r.Username,
+				"name":     r.Name,
+				"picture":  r.Picture,
+			},
+		})
+	}
+
+	reactions, nextCursor := page.trim(reactions, cursors)
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"reactions":   reactions,
+		"count":       len(reactions),
+		"next_cursor": nextCursor,
+		"has_more":    nextCursor != nil,
+	})
+}
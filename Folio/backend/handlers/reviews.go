@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"context"
+	"folio/api/markdown"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// validReviewFormats mirrors the CHECK constraint on logs.review_format
+var validReviewFormats = map[string]bool{
+	"text":     true,
+	"markdown": true,
+}
+
+// renderedReview is a review rendered to sanitized HTML with its mentions resolved to user IDs
+type renderedReview struct {
+	HTML        *string
+	HasSpoilers bool
+	Mentions    []map[string]interface{}
+	mentionIDs  []string
+}
+
+// renderReview renders review in the given format. @mentions are resolved
+// against users so that only real usernames become links.
+func renderReview(ctx context.Context, db *pgxpool.Pool, format string, review *string) (*renderedReview, error) {
+	rendered := &renderedReview{Mentions: []map[string]interface{}{}}
+	if review == nil || *review == "" {
+		return rendered, nil
+	}
+
+	known := make(map[string]bool)
+	userIDs := make(map[string]string)
+	if usernames := markdown.ExtractMentions(*review); len(usernames) > 0 {
+		rows, err := db.Query(ctx, "SELECT id, username FROM users WHERE username = ANY($1)", usernames)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var id, username string
+			if err := rows.Scan(&id, &username); err == nil {
+				known[username] = true
+				userIDs[username] = id
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	var result markdown.Result
+	if format == "markdown" {
+		result = markdown.Render(*review, known)
+	} else {
+		result = markdown.RenderText(*review, known)
+	}
+
+	rendered.HTML = &result.HTML
+	rendered.HasSpoilers = result.HasSpoilers
+	for _, username := range result.Mentions {
+		rendered.mentionIDs = append(rendered.mentionIDs, userIDs[username])
+		rendered.Mentions = append(rendered.Mentions, map[string]interface{}{
+			"id":       userIDs[username],
+			"username": username,
+		})
+	}
+
+	return rendered, nil
+}
+
+// saveLogMentions records who was mentioned in a log's review. The author's own mention is skipped.
+func saveLogMentions(ctx context.Context, db *pgxpool.Pool, logID, authorID string, userIDs []string) error {
+	for _, userID := range userIDs {
+		if userID == authorID {
+			continue
+		}
+		_, err := db.Exec(ctx, `
+			INSERT INTO log_mentions (log_id, user_id) VALUES ($1, $2)
+			ON CONFLICT (log_id, user_id) DO NOTHING
+		`, logID, userID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reviewHTML returns the stored rendering, falling back to escaping the raw text
+// for reviews written before rendering was stored
+func reviewHTML(stored *string, review *string) *string {
+	if stored != nil || review == nil || *review == "" {
+		return stored
+	}
+	html := markdown.RenderText(*review, nil).HTML
+	return &html
+}
@@ -0,0 +1,442 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"folio/api/auth"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/labstack/echo/v4"
+)
+
+type ShelfHandler struct {
+	DB *pgxpool.Pool
+}
+
+// refreshSmartCounts recounts the user's smart lists after a change to their shelves,
+// since smart lists can match on shelves. Failures are logged; the counts catch up on
+// the next change.
+func (h *ShelfHandler) refreshSmartCounts(ctx context.Context, userID string) {
+	if err := refreshSmartListCounts(ctx, h.DB, userID); err != nil {
+		log.Printf("failed to refresh smart list counts for user %s: %v", userID, err)
+	}
+}
+
+type CreateShelfRequest struct {
+	Name        string  `json:"name"`
+	Description *string `json:"description"`
+	IsPublic    *bool   `json:"is_public"`
+}
+
+type UpdateShelfRequest struct {
+	Name        *string `json:"name"`
+	Description *string `json:"description"`
+	IsPublic    *bool   `json:"is_public"`
+}
+
+type AddLogToShelfRequest struct {
+	LogID string `json:"log_id"`
+}
+
+var slugInvalidChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// slugify turns a shelf name like "Re-read Someday" into "re-read-someday"
+func slugify(name string) string {
+	return strings.Trim(slugInvalidChars.ReplaceAllString(strings.ToLower(name), "-"), "-")
+}
+
+// GetMyShelves returns the current user's shelves with their log counts
+func (h *ShelfHandler) GetMyShelves(c echo.Context) error {
+	userID := auth.GetUserID(c)
+	if userID == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": "unauthorized",
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	query := `
+		SELECT id, name, slug, description, is_public, logs_count, created_at, updated_at
+		FROM shelves
+		WHERE user_id = $1
+		ORDER BY name ASC
+	`
+
+	rows, err := h.DB.Query(ctx, query, userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to fetch shelves",
+		})
+	}
+	defer rows.Close()
+
+	shelves := []map[string]interface{}{}
+	for rows.Next() {
+		var shelf struct {
+			ID          string
+			Name        string
+			Slug        string
+			Description *string
+			IsPublic    bool
+			LogsCount   int
+			CreatedAt   time.Time
+			UpdatedAt   time.Time
+		}
+
+		err := rows.Scan(&shelf.ID, &shelf.Name, &shelf.Slug, &shelf.Description, &shelf.IsPublic, &shelf.LogsCount, &shelf.CreatedAt, &shelf.UpdatedAt)
+		if err != nil {
+			continue
+		}
+
+		shelves = append(shelves, map[string]interface{}{
+			"id":          shelf.ID,
+			"name":        shelf.Name,
+			"slug":        shelf.Slug,
+			"description": shelf.Description,
+			"is_public":   shelf.IsPublic,
+			"logs_count":  shelf.LogsCount,
+			"created_at":  shelf.CreatedAt,
+			"updated_at":  shelf.UpdatedAt,
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"shelves": shelves,
+		"count":   len(shelves),
+	})
+}
+
+// CreateShelf creates a new personal shelf
+func (h *ShelfHandler) CreateShelf(c echo.Context) error {
+	userID := auth.GetUserID(c)
+	if userID == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": "unauthorized",
+		})
+	}
+
+	var req CreateShelfRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "invalid request body",
+		})
+	}
+
+	slug := slugify(req.Name)
+	if slug == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "name is required",
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	isPublic := true
+	if req.IsPublic != nil {
+		isPublic = *req.IsPublic
+	}
+
+	query := `
+		INSERT INTO shelves (user_id, name, slug, description, is_public, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, NOW(), NOW())
+		ON CONFLICT (user_id, slug) DO NOTHING
+		RETURNING id, created_at, updated_at
+	`
+
+	var shelfID string
+	var createdAt, updatedAt time.Time
+	err := h.DB.QueryRow(ctx, query, userID, strings.TrimSpace(req.Name), slug, req.Description, isPublic).Scan(&shelfID, &createdAt, &updatedAt)
+	if err != nil {
+		var exists bool
+		h.DB.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM shelves WHERE user_id = $1 AND slug = $2)", userID, slug).Scan(&exists)
+		if exists {
+			return c.JSON(http.StatusConflict, map[string]string{
+				"error": "a shelf with this name already exists",
+			})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to create shelf",
+		})
+	}
+
+	return c.JSON(http.StatusCreated, map[string]interface{}{
+		"id":          shelfID,
+		"user_id":     userID,
+		"name":        strings.TrimSpace(req.Name),
+		"slug":        slug,
+		"description": req.Description,
+		"is_public":   isPublic,
+		"logs_count":  0,
+		"created_at":  createdAt,
+		"updated_at":  updatedAt,
+	})
+}
+
+// UpdateShelf renames a shelf or changes its visibility
+func (h *ShelfHandler) UpdateShelf(c echo.Context) error {
+	userID := auth.GetUserID(c)
+	if userID == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": "unauthorized",
+		})
+	}
+
+	shelfID := c.Param("id")
+	if shelfID == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "shelf_id is required",
+		})
+	}
+
+	var req UpdateShelfRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "invalid request body",
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	var ownerID string
+	err := h.DB.QueryRow(ctx, "SELECT user_id FROM shelves WHERE id = $1", shelfID).Scan(&ownerID)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": "shelf not found",
+		})
+	}
+
+	if ownerID != userID {
+		return c.JSON(http.StatusForbidden, map[string]string{
+			"error": "you can only update your own shelves",
+		})
+	}
+
+	query := "UPDATE shelves SET updated_at = NOW()"
+	args := []interface{}{shelfID}
+	argIdx := 2
+
+	if req.Name != nil {
+		slug := slugify(*req.Name)
+		if slug == "" {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "name cannot be empty",
+			})
+		}
+		query += fmt.Sprintf(", name = $%d, slug = $%d", argIdx, argIdx+1)
+		args = append(args, strings.TrimSpace(*req.Name), slug)
+		argIdx += 2
+	}
+	if req.Description != nil {
+		query += fmt.Sprintf(", description = $%d", argIdx)
+		args = append(args, *req.Description)
+		argIdx++
+	}
+	if req.IsPublic != nil {
+		query += fmt.Sprintf(", is_public = $%d", argIdx)
+		args = append(args, *req.IsPublic)
+		argIdx++
+	}
+
+	query += " WHERE id = $1 RETURNING id, name, slug, description, is_public, logs_count, updated_at"
+
+	var shelf struct {
+		ID          string
+		Name        string
+		Slug        string
+		Description *string
+		IsPublic    bool
+		LogsCount   int
+		UpdatedAt   time.Time
+	}
+
+	err = h.DB.QueryRow(ctx, query, args...).Scan(&shelf.ID, &shelf.Name, &shelf.Slug, &shelf.Description, &shelf.IsPublic, &shelf.LogsCount, &shelf.UpdatedAt)
+	var pgErr *pgconn.PgError
+	if err == pgx.ErrNoRows {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": "shelf not found",
+		})
+	} else if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return c.JSON(http.StatusConflict, map[string]string{
+			"error": "a shelf with this name already exists",
+		})
+	} else if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to update shelf",
+		})
+	}
+
+	h.refreshSmartCounts(ctx, userID)
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"id":          shelf.ID,
+		"name":        shelf.Name,
+		"slug":        shelf.Slug,
+		"description": shelf.Description,
+		"is_public":   shelf.IsPublic,
+		"logs_count":  shelf.LogsCount,
+		"updated_at":  shelf.UpdatedAt,
+	})
+}
+
+// DeleteShelf deletes a shelf; the logs on it are left untouched
+func (h *ShelfHandler) DeleteShelf(c echo.Context) error {
+	userID := auth.GetUserID(c)
+	if userID == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": "unauthorized",
+		})
+	}
+
+	shelfID := c.Param("id")
+	if shelfID == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "shelf_id is required",
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	result, err := h.DB.Exec(ctx, "DELETE FROM shelves WHERE id = $1 AND user_id = $2", shelfID, userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to delete shelf",
+		})
+	}
+
+	if result.RowsAffected() == 0 {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": "shelf not found",
+		})
+	}
+
+	h.refreshSmartCounts(ctx, userID)
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message": "shelf deleted successfully",
+	})
+}
+
+// AddLogToShelf puts one of the user's logs on a shelf
+func (h *ShelfHandler) AddLogToShelf(c echo.Context) error {
+	userID := auth.GetUserID(c)
+	if userID == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": "unauthorized",
+		})
+	}
+
+	shelfID := c.Param("id")
+	if shelfID == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "shelf_id is required",
+		})
+	}
+
+	var req AddLogToShelfRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "invalid request body",
+		})
+	}
+
+	if req.LogID == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "log_id is required",
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	// Both the shelf and the log must belong to the current user
+	var shelfOwnerID, logOwnerID string
+	err := h.DB.QueryRow(ctx, `
+		SELECT s.user_id, l.user_id
+		FROM shelves s, logs l
+		WHERE s.id = $1 AND l.id = $2
+	`, shelfID, req.LogID).Scan(&shelfOwnerID, &logOwnerID)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": "shelf or log not found",
+		})
+	}
+
+	if shelfOwnerID != userID || logOwnerID != userID {
+		return c.JSON(http.StatusForbidden, map[string]string{
+			"error": "you can only shelve your own logs on your own shelves",
+		})
+	}
+
+	_, err = h.DB.Exec(ctx, `
+		INSERT INTO log_shelves (shelf_id, log_id, created_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (shelf_id, log_id) DO NOTHING
+	`, shelfID, req.LogID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to add log to shelf",
+		})
+	}
+
+	h.refreshSmartCounts(ctx, userID)
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message":  "log added to shelf",
+		"shelf_id": shelfID,
+		"log_id":   req.LogID,
+	})
+}
+
+// RemoveLogFromShelf takes a log off a shelf
+func (h *ShelfHandler) RemoveLogFromShelf(c echo.Context) error {
+	userID := auth.GetUserID(c)
+	if userID == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": "unauthorized",
+		})
+	}
+
+	shelfID := c.Param("id")
+	logID := c.Param("logId")
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	query := `
+		DELETE FROM log_shelves ls
+		USING shelves s
+		WHERE ls.shelf_id = s.id AND s.id = $1 AND s.user_id = $2 AND ls.log_id = $3
+	`
+
+	result, err := h.DB.Exec(ctx, query, shelfID, userID, logID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to remove log from shelf",
+		})
+	}
+
+	if result.RowsAffected() == 0 {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": "log not on shelf",
+		})
+	}
+
+	h.refreshSmartCounts(ctx, userID)
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message": "log removed from shelf",
+	})
+}
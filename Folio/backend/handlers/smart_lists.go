@@ -0,0 +1,519 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"folio/api/auth"
+	"net/http"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	staticListType = "static"
+	smartListType  = "smart"
+
+	defaultSmartListLimit = 100
+	maxSmartListLimit     = 500
+)
+
+// SmartListRules is the saved query behind a smart list. Every rule that is set must match;
+// rules holding several values (status, categories, authors, shelves) match any of them.
+type SmartListRules struct {
+	Status         []string `json:"status,omitempty"`
+	MinRating      *float64 `json:"min_rating,omitempty"`
+	MaxRating      *float64 `json:"max_rating,omitempty"`
+	Categories     []string `json:"categories,omitempty"`
+	Authors        []string `json:"authors,omitempty"`
+	Shelves        []string `json:"shelves,omitempty"`
+	FinishedYear   *int     `json:"finished_year,omitempty"`
+	FinishedAfter  *string  `json:"finished_after,omitempty"`
+	FinishedBefore *string  `json:"finished_before,omitempty"`
+	HasReview      *bool    `json:"has_review,omitempty"`
+	MinPages       *int     `json:"min_pages,omitempty"`
+	MaxPages       *int     `json:"max_pages,omitempty"`
+	Language       *string  `json:"language,omitempty"`
+	Sort           string   `json:"sort,omitempty"`
+	Order          string   `json:"order,omitempty"`
+	Limit          int      `json:"limit,omitempty"`
+}
+
+// smartListSorts maps a rule sort key to its column in the matched logs and its default direction
+var smartListSorts = map[string]sortOption{
+	"finish_date": {Expr: "finish_date", Desc: true},
+	"rating":      {Expr: "rating", Desc: true},
+	"title":       {Expr: "title", Desc: false},
+	"created_at":  {Expr: "created_at", Desc: true},
+}
+
+// validate checks the rules and fills in defaults for sort and limit
+func (r *SmartListRules) validate() error {
+	for _, s := range r.Status {
+		if !validLogStatuses[s] {
+			return fmt.Errorf("invalid status. Must be: want_to_read, reading, read, or dnf")
+		}
+	}
+	for _, rating := range []*float64{r.MinRating, r.MaxRating} {
+		if rating != nil && (*rating < 0 || *rating > 5) {
+			return fmt.Errorf("rating rules must be between 0 and 5")
+		}
+	}
+	for _, date := range []*string{r.FinishedAfter, r.FinishedBefore} {
+		if date == nil {
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", *date); err != nil {
+			return fmt.Errorf("finished_after and finished_before must be YYYY-MM-DD dates")
+		}
+	}
+
+	if r.Sort == "" {
+		r.Sort = "finish_date"
+	}
+	if _, ok := smartListSorts[r.Sort]; !ok {
+		return fmt.Errorf("invalid sort. Must be: finish_date, rating, title, or created_at")
+	}
+	if r.Order != "" && r.Order != "asc" && r.Order != "desc" {
+		return fmt.Errorf("invalid order. Must be: asc or desc")
+	}
+
+	if r.Limit == 0 {
+		r.Limit = defaultSmartListLimit
+	}
+	if r.Limit < 0 || r.Limit > maxSmartListLimit {
+		return fmt.Errorf("limit must be between 1 and %d", maxSmartListLimit)
+	}
+	return nil
+}
+
+// parseSmartListRules decodes and validates the rules stored on a list
+func parseSmartListRules(raw []byte) (*SmartListRules, error) {
+	var rules SmartListRules
+	if err := json.Unmarshal(raw, &rules); err != nil {
+		return nil, fmt.Errorf("smart_rules must be an object")
+	}
+	if err := rules.validate(); err != nil {
+		return nil, err
+	}
+	return &rules, nil
+}
+
+// apply adds the rule conditions over logs l and books b to the query builder.
+// Private logs and shelves are only matched when the viewer owns the list.
+func (r *SmartListRules) apply(qb *queryBuilder, includePrivate bool) {
+	if !includePrivate {
+		qb.where("l.is_public = true")
+	}
+	if len(r.Status) > 0 {
+		qb.where("l.status = ANY(" + qb.arg(r.Status) + ")")
+	}
+	if r.MinRating != nil {
+		qb.where("l.rating >= " + qb.arg(*r.MinRating))
+	}
+	if r.MaxRating != nil {
+		qb.where("l.rating <= " + qb.arg(*r.MaxRating))
+	}
+	if len(r.Categories) > 0 {
+		qb.where("EXISTS (SELECT 1 FROM unnest(b.categories) AS category, unnest(" + qb.arg(r.Categories) + "::text[]) AS wanted WHERE LOWER(category) = LOWER(wanted))")
+	}
+	if len(r.Authors) > 0 {
+		patterns := make([]string, len(r.Authors))
+		for i, author := range r.Authors {
+			patterns[i] = "%" + author + "%"
+		}
+		qb.where("EXISTS (SELECT 1 FROM unnest(b.authors) AS author WHERE author ILIKE ANY(" + qb.arg(patterns) + "))")
+	}
+	if len(r.Shelves) > 0 {
+		shelfVisibility := ""
+		if !includePrivate {
+			shelfVisibility = " AND s.is_public = true"
+		}
+		qb.where(`EXISTS (
+			SELECT 1 FROM log_shelves ls
+			JOIN shelves s ON ls.shelf_id = s.id
+			WHERE ls.log_id = l.id AND s.slug = ANY(` + qb.arg(r.Shelves) + `)` + shelfVisibility + `
+		)`)
+	}
+	if r.FinishedYear != nil {
+		qb.where("EXTRACT(YEAR FROM l.finish_date) = " + qb.arg(*r.FinishedYear))
+	}
+	if r.FinishedAfter != nil {
+		qb.where("l.finish_date >= " + qb.arg(*r.FinishedAfter) + "::date")
+	}
+	if r.FinishedBefore != nil {
+		qb.where("l.finish_date <= " + qb.arg(*r.FinishedBefore) + "::date")
+	}
+	if r.HasReview != nil {
+		if *r.HasReview {
+			qb.where("(l.review IS NOT NULL AND l.review != '')")
+		} else {
+			qb.where("(l.review IS NULL OR l.review = '')")
+		}
+	}
+	if r.MinPages != nil {
+		qb.where("b.page_count >= " + qb.arg(*r.MinPages))
+	}
+	if r.MaxPages != nil {
+		qb.where("b.page_count <= " + qb.arg(*r.MaxPages))
+	}
+	if r.Language != nil {
+		qb.where("LOWER(b.language) = LOWER(" + qb.arg(*r.Language) + ")")
+	}
+}
+
+// smartListMatchSQL selects the owner's ($1) logs matching the rules. A book logged more
+// than once counts once, using its latest log.
+func smartListMatchSQL(qb *queryBuilder, rules *SmartListRules, includePrivate bool) string {
+	rules.apply(qb, includePrivate)
+	return `
+		SELECT DISTINCT ON (l.book_id) l.id, l.book_id, l.created_at, l.rating, l.finish_date,
+		       b.title, b.authors, b.cover_url, b.description
+		FROM logs l
+		JOIN books b ON l.book_id = b.id
+		WHERE l.user_id = $1` + qb.and() + `
+		ORDER BY l.book_id, l.created_at DESC`
+}
+
+// smartListItems computes a smart list's items from its owner's logs, in the same shape GetList
+// returns for static lists. The item id is the matching log's id.
+func (h *ListHandler) smartListItems(ctx context.Context, ownerID string, rules *SmartListRules, includePrivate, isRanked bool) ([]map[string]interface{}, error) {
+	qb := newQueryBuilder(ownerID)
+	matched := smartListMatchSQL(qb, rules, includePrivate)
+
+	sort := smartListSorts[rules.Sort]
+	desc := sort.Desc
+	if rules.Order != "" {
+		desc = rules.Order == "desc"
+	}
+	dir := "ASC"
+	if desc {
+		dir = "DESC"
+	}
+
+	query := `
+		SELECT id, book_id, created_at, title, authors, cover_url, description
+		FROM (` + matched + `) matched
+		ORDER BY ` + sort.Expr + ` ` + dir + ` NULLS LAST, id
+		LIMIT ` + qb.arg(rules.Limit)
+
+	rows, err := h.DB.Query(ctx, query, qb.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []map[string]interface{}{}
+	for rows.Next() {
+		var item struct {
+			ID          string
+			BookID      string
+			CreatedAt   time.Time
+			BookTitle   string
+			Authors     []string
+			CoverURL    *string
+			Description *string
+		}
+		if err := rows.Scan(&item.ID, &item.BookID, &item.CreatedAt, &item.BookTitle, &item.Authors, &item.CoverURL, &item.Description); err != nil {
+			continue
+		}
+
+		var rank *int
+		if isRanked {
+			r := len(items) + 1
+			rank = &r
+		}
+
+		items = append(items, map[string]interface{}{
+			"id":           item.ID,
+			"book_id":      item.BookID,
+			"notes":        nil,
+			"item_order":   len(items),
+			"position_key": nil,
+			"rank":         rank,
+			"created_at":   item.CreatedAt,
+			"added_by":     nil,
+			"book": map[string]interface{}{
+				"id":          item.BookID,
+				"title":       item.BookTitle,
+				"authors":     item.Authors,
+				"cover_url":   item.CoverURL,
+				"description": item.Description,
+			},
+		})
+	}
+	return items, rows.Err()
+}
+
+// refreshSmartListCount stores how many books a smart list currently shows other people in
+// lists.items_count, so browsing, trending and list cards see smart lists like static ones.
+// Only public logs are counted since the count is shown to everyone.
+func refreshSmartListCount(ctx context.Context, q rowQuerier, listID, ownerID string, rules *SmartListRules) (int, error) {
+	qb := newQueryBuilder(ownerID)
+	matched := smartListMatchSQL(qb, rules, false)
+
+	var count int
+	err := q.QueryRow(ctx, `
+		UPDATE lists
+		SET items_count = (SELECT LEAST(COUNT(*), `+qb.arg(rules.Limit)+`) FROM (`+matched+`) matched)
+		WHERE id = `+qb.arg(listID)+`
+		RETURNING items_count
+	`, qb.args...).Scan(&count)
+	return count, err
+}
+
+// refreshSmartListCounts recounts every smart list a user owns, after their logs or shelves change
+func refreshSmartListCounts(ctx context.Context, db *pgxpool.Pool, userID string) error {
+	rows, err := db.Query(ctx, "SELECT id, smart_rules FROM lists WHERE user_id = $1 AND list_type = $2", userID, smartListType)
+	if err != nil {
+		return err
+	}
+
+	type smartList struct {
+		ID    string
+		Rules *SmartListRules
+	}
+	lists := []smartList{}
+	for rows.Next() {
+		var listID string
+		var raw []byte
+		if err := rows.Scan(&listID, &raw); err != nil {
+			rows.Close()
+			return err
+		}
+		if rules, err := parseSmartListRules(raw); err == nil {
+			lists = append(lists, smartList{ID: listID, Rules: rules})
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, list := range lists {
+		if _, err := refreshSmartListCount(ctx, db, list.ID, userID, list.Rules); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isSmartList reports whether a list's items are computed rather than stored
+func isSmartList(ctx context.Context, q rowQuerier, listID string) bool {
+	var listType string
+	q.QueryRow(ctx, "SELECT list_type FROM lists WHERE id = $1", listID).Scan(&listType)
+	return listType == smartListType
+}
+
+// snapshotVisibility decides whether a snapshot is public, defaulting to the source list,
+// and whether it may copy books matched only by private logs and shelves. A public
+// snapshot never does, so private reading data doesn't end up on a public list.
+func snapshotVisibility(sourceIsPublic bool, requested *bool) (isPublic, includePrivate bool) {
+	isPublic = sourceIsPublic
+	if requested != nil {
+		isPublic = *requested
+	}
+	return isPublic, !isPublic
+}
+
+type SnapshotSmartListRequest struct {
+	Name     *string `json:"name"`
+	IsPublic *bool   `json:"is_public"`
+}
+
+// SnapshotSmartList freezes a smart list's current books into a new static list owned by the user
+func (h *ListHandler) SnapshotSmartList(c echo.Context) error {
+	userID := auth.GetUserID(c)
+	if userID == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": "unauthorized",
+		})
+	}
+
+	sourceID := c.Param("id")
+
+	var req SnapshotSmartListRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "invalid request body",
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	var source struct {
+		UserID         string
+		Name           string
+		Description    *string
+		IsPublic       bool
+		IsRanked       bool
+		HeaderImageURL *string
+		ThemeColor     string
+		ListType       string
+		SmartRules     []byte
+		Tags           []string
+	}
+	err := h.DB.QueryRow(ctx, `
+		SELECT user_id, name, description, is_public, is_ranked, header_image_url, theme_color, list_type, smart_rules, tags
+		FROM lists WHERE id = $1
+	`, sourceID).Scan(&source.UserID, &source.Name, &source.Description, &source.IsPublic, &source.IsRanked,
+		&source.HeaderImageURL, &source.ThemeColor, &source.ListType, &source.SmartRules, &source.Tags)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": "list not found",
+		})
+	}
+
+	if source.UserID != userID {
+		return c.JSON(http.StatusForbidden, map[string]string{
+			"error": "you can only snapshot your own lists",
+		})
+	}
+	if source.ListType != smartListType {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "only smart lists can be snapshotted",
+		})
+	}
+
+	rules, err := parseSmartListRules(source.SmartRules)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "list has invalid smart rules",
+		})
+	}
+
+	isPublic, includePrivate := snapshotVisibility(source.IsPublic, req.IsPublic)
+	items, err := h.smartListItems(ctx, userID, rules, includePrivate, source.IsRanked)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to fetch list items",
+		})
+	}
+
+	name := source.Name + " (" + time.Now().Format("Jan 2, 2006") + ")"
+	if req.Name != nil {
+		name = *req.Name
+	}
+	if name == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "name is required",
+		})
+	}
+
+	keys, err := keysBetween("", "", len(items))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to order list items",
+		})
+	}
+
+	tx, err := h.DB.Begin(ctx)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to start transaction",
+		})
+	}
+	defer tx.Rollback(ctx)
+
+	var listID string
+	var createdAt, updatedAt time.Time
+	err = tx.QueryRow(ctx, `
+		INSERT INTO lists (user_id, name, description, is_public, is_ranked, header_image_url, theme_color, tags, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NOW(), NOW())
+		RETURNING id, created_at, updated_at
+	`, userID, name, source.Description, isPublic, source.IsRanked, source.HeaderImageURL, source.ThemeColor, source.Tags).Scan(&listID, &createdAt, &updatedAt)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to create list",
+		})
+	}
+
+	for i, item := range items {
+		_, err := tx.Exec(ctx, `
+			INSERT INTO list_items (list_id, book_id, position_key, added_by, created_at)
+			VALUES ($1, $2, $3, $4, NOW())
+		`, listID, item["book_id"], keys[i], userID)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": "failed to copy list items",
+			})
+		}
+	}
+
+	if err := recordListRevision(ctx, tx, listID, userID, listRevisionChange{
+		Action:  "list_created",
+		Details: map[string]interface{}{"snapshot_of_list_id": sourceID},
+	}); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to record list history",
+		})
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to create list",
+		})
+	}
+
+	version := currentListVersion(ctx, h.DB, listID)
+	c.Response().Header().Set("ETag", listETag(version))
+	return c.JSON(http.StatusCreated, map[string]interface{}{
+		"id":                  listID,
+		"user_id":             userID,
+		"name":                name,
+		"description":         source.Description,
+		"is_public":           isPublic,
+		"is_ranked":           source.IsRanked,
+		"header_image_url":    source.HeaderImageURL,
+		"theme_color":         source.ThemeColor,
+		"list_type":           staticListType,
+		"tags":                source.Tags,
+		"items_count":         len(items),
+		"version":             version,
+		"snapshot_of_list_id": sourceID,
+		"created_at":          createdAt,
+		"updated_at":          updatedAt,
+	})
+}
+
+// PreviewSmartList runs rules against the user's own logs without saving them
+func (h *ListHandler) PreviewSmartList(c echo.Context) error {
+	userID := auth.GetUserID(c)
+	if userID == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": "unauthorized",
+		})
+	}
+
+	var rules SmartListRules
+	if err := c.Bind(&rules); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "invalid request body",
+		})
+	}
+
+	if err := rules.validate(); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	items, err := h.smartListItems(ctx, userID, &rules, true, false)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to fetch list items",
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"smart_rules": rules,
+		"items":       items,
+		"items_count": len(items),
+	})
+}
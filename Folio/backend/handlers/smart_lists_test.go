@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSnapshotVisibility(t *testing.T) {
+	yes, no := true, false
+
+	tests := []struct {
+		name           string
+		sourceIsPublic bool
+		requested      *bool
+		isPublic       bool
+		includePrivate bool
+	}{
+		{"public source", true, nil, true, false},
+		{"private source", false, nil, false, true},
+		{"public source made private", true, &no, false, true},
+		{"private source made public", false, &yes, true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			isPublic, includePrivate := snapshotVisibility(tt.sourceIsPublic, tt.requested)
+			if isPublic != tt.isPublic || includePrivate != tt.includePrivate {
+				t.Errorf("snapshotVisibility() = (%v, %v), want (%v, %v)", isPublic, includePrivate, tt.isPublic, tt.includePrivate)
+			}
+		})
+	}
+}
+
+func TestPublicSnapshotSkipsPrivateLogs(t *testing.T) {
+	rules := &SmartListRules{Shelves: []string{"favourites"}}
+	if err := rules.validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	_, includePrivate := snapshotVisibility(false, nil)
+	private := smartListMatchSQL(newQueryBuilder("owner"), rules, includePrivate)
+	if strings.Contains(private, "l.is_public") || strings.Contains(private, "s.is_public") {
+		t.Errorf("private snapshot filters on visibility:\n%s", private)
+	}
+
+	_, includePrivate = snapshotVisibility(true, nil)
+	public := smartListMatchSQL(newQueryBuilder("owner"), rules, includePrivate)
+	for _, condition := range []string{"l.is_public = true", "s.is_public = true"} {
+		if !strings.Contains(public, condition) {
+			t.Errorf("public snapshot query is missing %q:\n%s", condition, public)
+		}
+	}
+}
@@ -14,26 +14,49 @@ type SocialHandler struct {
 	DB *pgxpool.Pool
 }
 
-// GetPopularUsers returns the top curators by list count
+// popularUserSorts are the sort keys accepted by GetPopularUsers.
+// Expressions refer to the aggregated columns of the inner curator query.
+var popularUserSorts = map[string]sortOption{
+	"list_count":      {Expr: "list_count", Type: "bigint", Desc: true},
+	"followers_count": {Expr: "followers_count", Type: "bigint", Desc: true},
+	"created_at":      {Expr: "created_at", Type: "timestamptz", Desc: true},
+}
+
+// GetPopularUsers returns a page of top curators by list count
 func (h *SocialHandler) GetPopularUsers(c echo.Context) error {
+	page, err := parsePageRequest(c, popularUserSorts, "list_count", 10, 50)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
 	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
 	defer cancel()
 
 	currentUserID := auth.GetUserID(c)
 
-	query := `
-		SELECT u.id, u.username, u.name, u.picture, u.bio, COUNT(l.id) as list_count,
-		       EXISTS(SELECT 1 FROM followers WHERE follower_id = $1 AND following_id = u.id) as is_following
-		FROM users u
-		LEFT JOIN lists l ON u.id = l.user_id AND l.is_public = true
-		WHERE u.is_guest = false
-		GROUP BY u.id, u.username, u.name, u.picture, u.bio
-		HAVING COUNT(l.id) > 0
-		ORDER BY list_count DESC, u.created_at DESC
-		LIMIT 10
-	`
+	// Aggregates can't be filtered in WHERE, so the cursor applies to the wrapped curator query
+	qb := newQueryBuilder(nullableUserID(currentUserID))
+	page.applyCursor(qb, "id")
 
-	rows, err := h.DB.Query(ctx, query, currentUserID)
+	query := `
+		SELECT id, username, name, picture, bio, list_count, is_following,
+		       ` + page.cursorColumn() + `
+		FROM (
+			SELECT u.id, u.username, u.name, u.picture, u.bio, u.created_at, COUNT(l.id) as list_count,
+			       (SELECT COUNT(*) FROM followers WHERE following_id = u.id) as followers_count,
+			       EXISTS(SELECT 1 FROM followers WHERE follower_id = $1 AND following_id = u.id) as is_following
+			FROM users u
+			LEFT JOIN lists l ON u.id = l.user_id AND l.is_public = true
+			WHERE u.is_guest = false
+			GROUP BY u.id, u.username, u.name, u.picture, u.bio, u.created_at
+			HAVING COUNT(l.id) > 0
+		) curators
+		WHERE true` + qb.and() + `
+		` + page.orderBy(qb, "id")
+
+	rows, err := h.DB.Query(ctx, query, qb.args...)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{
 			"error": "failed to fetch popular users",
@@ -42,6 +65,7 @@ func (h *SocialHandler) GetPopularUsers(c echo.Context) error {
 	defer rows.Close()
 
 	users := []map[string]interface{}{}
+	cursors := []pageCursor{}
 	for rows.Next() {
 		var user struct {
 			ID          string
@@ -51,13 +75,15 @@ func (h *SocialHandler) GetPopularUsers(c echo.Context) error {
 			Bio         *string
 			ListCount   int
 			IsFollowing bool
+			CursorValue string
 		}
 
-		err := rows.Scan(&user.ID, &user.Username, &user.Name, &user.Picture, &user.Bio, &user.ListCount, &user.IsFollowing)
+		err := rows.Scan(&user.ID, &user.Username, &user.Name, &user.Picture, &user.Bio, &user.ListCount, &user.IsFollowing, &user.CursorValue)
 		if err != nil {
 			continue
 		}
 
+		cursors = append(cursors, pageCursor{Value: user.CursorValue, ID: user.ID})
 		users = append(users, map[string]interface{}{
 			"id":           user.ID,
 			"username":     user.Username,
@@ -69,9 +95,13 @@ func (h *SocialHandler) GetPopularUsers(c echo.Context) error {
 		})
 	}
 
+	users, nextCursor := page.trim(users, cursors)
+
 	return c.JSON(http.StatusOK, map[string]interface{}{
-		"users": users,
-		"count": len(users),
+		"users":       users,
+		"count":       len(users),
+		"next_cursor": nextCursor,
+		"has_more":    nextCursor != nil,
 	})
 }
 
@@ -135,6 +165,41 @@ func (h *SocialHandler) GetUserProfile(c echo.Context) error {
 	h.DB.QueryRow(ctx, "SELECT COUNT(*) FROM logs WHERE user_id = $1 AND status = 'reading'", user.ID).Scan(&readingBooks)
 	h.DB.QueryRow(ctx, "SELECT COUNT(*) FROM logs WHERE user_id = $1 AND status = 'want_to_read'", user.ID).Scan(&wantToReadBooks)
 
+	// Get shelf counts (private shelves only for the owner). Other viewers only see
+	// the public logs on each shelf counted
+	shelves := []map[string]interface{}{}
+	shelfQuery := `
+		SELECT s.id, s.name, s.slug,
+		       CASE WHEN s.user_id::text = $2 THEN s.logs_count
+		            ELSE (SELECT COUNT(*) FROM log_shelves ls
+		                  JOIN logs l ON ls.log_id = l.id
+		                  WHERE ls.shelf_id = s.id AND l.is_public = true)
+		       END AS logs_count
+		FROM shelves s
+		WHERE s.user_id = $1 AND (s.is_public = true OR s.user_id::text = $2)
+		ORDER BY s.name ASC
+	`
+	shelfRows, err := h.DB.Query(ctx, shelfQuery, user.ID, currentUserID)
+	if err == nil {
+		defer shelfRows.Close()
+		for shelfRows.Next() {
+			var shelf struct {
+				ID        string
+				Name      string
+				Slug      string
+				LogsCount int
+			}
+			if err := shelfRows.Scan(&shelf.ID, &shelf.Name, &shelf.Slug, &shelf.LogsCount); err == nil {
+				shelves = append(shelves, map[string]interface{}{
+					"id":         shelf.ID,
+					"name":       shelf.Name,
+					"slug":       shelf.Slug,
+					"logs_count": shelf.LogsCount,
+				})
+			}
+		}
+	}
+
 	return c.JSON(http.StatusOK, map[string]interface{}{
 		"id":           user.ID,
 		"username":     user.Username,
@@ -152,6 +217,7 @@ func (h *SocialHandler) GetUserProfile(c echo.Context) error {
 			"reading_books":      readingBooks,
 			"want_to_read_books": wantToReadBooks,
 		},
+		"shelves": shelves,
 	})
 }
 
@@ -297,18 +363,18 @@ func (h *SocialHandler) ToggleLike(c echo.Context) error {
 		})
 	}
 
-	// Check if already liked
+	// Check if already liked; likes are stored as "like" reactions
 	var likeID string
 	err = h.DB.QueryRow(ctx, 
-		"SELECT id FROM log_likes WHERE user_id = $1 AND log_id = $2",
+		"SELECT id FROM reactions WHERE user_id = $1 AND target_type = 'log' AND target_id = $2 AND reaction = 'like'",
 		userID, logID,
 	).Scan(&likeID)
 
 	if err != nil {
 		// No existing like, create one
 		query := `
-			INSERT INTO log_likes (user_id, log_id, created_at)
-			VALUES ($1, $2, NOW())
+			INSERT INTO reactions (user_id, target_type, target_id, reaction, created_at)
+			VALUES ($1, 'log', $2, 'like', NOW())
 			RETURNING id
 		`
 		err = h.DB.QueryRow(ctx, query, userID, logID).Scan(&likeID)
@@ -325,7 +391,7 @@ func (h *SocialHandler) ToggleLike(c echo.Context) error {
 	}
 
 	// Like exists, remove it
-	_, err = h.DB.Exec(ctx, "DELETE FROM log_likes WHERE id = $1", likeID)
+	_, err = h.DB.Exec(ctx, "DELETE FROM reactions WHERE id = $1", likeID)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{
 			"error": "failed to unlike log",
@@ -338,203 +404,3 @@ func (h *SocialHandler) ToggleLike(c echo.Context) error {
 	})
 }
 
-// GetLogComments retrieves all comments for a specific log
-func (h *SocialHandler) GetLogComments(c echo.Context) error {
-	logID := c.Param("id")
-	if logID == "" {
-		return c.JSON(http.StatusBadRequest, map[string]string{
-			"error": "log_id is required",
-		})
-	}
-
-	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
-	defer cancel()
-
-	query := `
-		SELECT lc.id, lc.user_id, lc.log_id, lc.content, lc.created_at, lc.updated_at,
-		       u.username, u.name, u.picture
-		FROM log_comments lc
-		JOIN users u ON lc.user_id = u.id
-		WHERE lc.log_id = $1
-		ORDER BY lc.created_at ASC
-	`
-
-	rows, err := h.DB.Query(ctx, query, logID)
-	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "failed to fetch comments",
-		})
-	}
-	defer rows.Close()
-
-	comments := []map[string]interface{}{}
-	for rows.Next() {
-		var comment struct {
-			ID        string
-			UserID    string
-			LogID     string
-			Content   string
-			CreatedAt time.Time
-			UpdatedAt time.Time
-			Username  string
-			Name      string
-			Picture   *string
-		}
-
-		err := rows.Scan(
-			&comment.ID, &comment.UserID, &comment.LogID, &comment.Content,
-			&comment.CreatedAt, &comment.UpdatedAt,
-			&comment.Username, &comment.Name, &comment.Picture,
-		)
-		if err != nil {
-			continue
-		}
-
-		comments = append(comments, map[string]interface{}{
-			"id":         comment.ID,
-			"log_id":     comment.LogID,
-			"content":    comment.Content,
-			"created_at": comment.CreatedAt,
-			"updated_at": comment.UpdatedAt,
-			"user": map[string]interface{}{
-				"id":       comment.UserID,
-				"username": comment.Username,
-				"name":     comment.Name,
-				"picture":  comment.Picture,
-			},
-		})
-	}
-
-	return c.JSON(http.StatusOK, map[string]interface{}{
-		"comments": comments,
-		"count":    len(comments),
-	})
-}
-
-type CreateCommentRequest struct {
-	Content string `json:"content"`
-}
-
-// CreateComment adds a new comment to a log
-func (h *SocialHandler) CreateComment(c echo.Context) error {
-	userID := auth.GetUserID(c)
-	if userID == "" {
-		return c.JSON(http.StatusUnauthorized, map[string]string{
-			"error": "unauthorized",
-		})
-	}
-
-	logID := c.Param("id")
-	if logID == "" {
-		return c.JSON(http.StatusBadRequest, map[string]string{
-			"error": "log_id is required",
-		})
-	}
-
-	var req CreateCommentRequest
-	if err := c.Bind(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{
-			"error": "invalid request body",
-		})
-	}
-
-	if req.Content == "" {
-		return c.JSON(http.StatusBadRequest, map[string]string{
-			"error": "content is required",
-		})
-	}
-
-	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
-	defer cancel()
-
-	// Check if log exists
-	var logExists bool
-	err := h.DB.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM logs WHERE id = $1)", logID).Scan(&logExists)
-	if err != nil || !logExists {
-		return c.JSON(http.StatusNotFound, map[string]string{
-			"error": "log not found",
-		})
-	}
-
-	query := `
-		INSERT INTO log_comments (user_id, log_id, content, created_at, updated_at)
-		VALUES ($1, $2, $3, NOW(), NOW())
-		RETURNING id, created_at, updated_at
-	`
-
-	var commentID string
-	var createdAt, updatedAt time.Time
-	err = h.DB.QueryRow(ctx, query, userID, logID, req.Content).Scan(&commentID, &createdAt, &updatedAt)
-	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "failed to create comment",
-		})
-	}
-
-	// Get user info for response
-	var username, name string
-	var picture *string
-	h.DB.QueryRow(ctx, "SELECT username, name, picture FROM users WHERE id = $1", userID).Scan(&username, &name, &picture)
-
-	return c.JSON(http.StatusCreated, map[string]interface{}{
-		"id":         commentID,
-		"log_id":     logID,
-		"content":    req.Content,
-		"created_at": createdAt,
-		"updated_at": updatedAt,
-		"user": map[string]interface{}{
-			"id":       userID,
-			"username": username,
-			"name":     name,
-			"picture":  picture,
-		},
-	})
-}
-
-// DeleteComment allows a user to delete their own comment
-func (h *SocialHandler) DeleteComment(c echo.Context) error {
-	userID := auth.GetUserID(c)
-	if userID == "" {
-		return c.JSON(http.StatusUnauthorized, map[string]string{
-			"error": "unauthorized",
-		})
-	}
-
-	commentID := c.Param("commentId")
-	if commentID == "" {
-		return c.JSON(http.StatusBadRequest, map[string]string{
-			"error": "comment_id is required",
-		})
-	}
-
-	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
-	defer cancel()
-
-	// Check if comment exists and belongs to user
-	var ownerID string
-	err := h.DB.QueryRow(ctx, "SELECT user_id FROM log_comments WHERE id = $1", commentID).Scan(&ownerID)
-	if err != nil {
-		return c.JSON(http.StatusNotFound, map[string]string{
-			"error": "comment not found",
-		})
-	}
-
-	if ownerID != userID {
-		return c.JSON(http.StatusForbidden, map[string]string{
-			"error": "you can only delete your own comments",
-		})
-	}
-
-	// Delete comment
-	_, err = h.DB.Exec(ctx, "DELETE FROM log_comments WHERE id = $1", commentID)
-	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "failed to delete comment",
-		})
-	}
-
-	return c.JSON(http.StatusOK, map[string]interface{}{
-		"message": "comment deleted successfully",
-	})
-}
-
@@ -0,0 +1,111 @@
+package importers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Apple Books keeps annotations in AEAnnotation*.sqlite and book metadata in
+// BKLibrary*.sqlite, both under ~/Library/Containers/com.apple.iBooksX/Data/Documents.
+// Timestamps are Core Data seconds since 2001-01-01 UTC.
+var coreDataEpoch = time.Date(2001, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+func parseAppleBooksFiles(files Files) ([]Clipping, int, error) {
+	if !isSQLite(files["file"]) {
+		return nil, 0, fmt.Errorf("not an Apple Books annotation database")
+	}
+	db, err := openSQLite(files["file"])
+	if err != nil {
+		return nil, 0, err
+	}
+	annotations, err := db.readTable("ZAEANNOTATION")
+	if err != nil {
+		return nil, 0, fmt.Errorf("not an Apple Books annotation database: %w", err)
+	}
+
+	// Without the library database, books are known only by their asset id
+	type book struct {
+		Title   string
+		Authors []string
+	}
+	books := map[string]book{}
+	if library := files["library"]; len(library) > 0 {
+		libraryDB, err := openSQLite(library)
+		if err != nil {
+			return nil, 0, fmt.Errorf("library: %w", err)
+		}
+		assets, err := libraryDB.readTable("ZBKLIBRARYASSET")
+		if err != nil {
+			return nil, 0, fmt.Errorf("not an Apple Books library database: %w", err)
+		}
+		for _, asset := range assets {
+			authors := []string{}
+			for _, author := range strings.Split(rowString(asset, "ZAUTHOR"), "&") {
+				if author = strings.TrimSpace(author); author != "" {
+					authors = append(authors, author)
+				}
+			}
+			books[rowString(asset, "ZASSETID")] = book{Title: rowString(asset, "ZTITLE"), Authors: authors}
+		}
+	}
+
+	clippings := []Clipping{}
+	skipped := 0
+	for _, row := range annotations {
+		if deleted, _ := rowFloat(row, "ZANNOTATIONDELETED"); deleted != 0 {
+			continue
+		}
+
+		clipping := Clipping{
+			Kind:       KindHighlight,
+			Content:    strings.TrimSpace(rowString(row, "ZANNOTATIONSELECTEDTEXT")),
+			Note:       strings.TrimSpace(rowString(row, "ZANNOTATIONNOTE")),
+			ExternalID: rowString(row, "ZANNOTATIONUUID"),
+		}
+
+		// Notes written without selecting text are standalone notes; rows with
+		// neither are bookmarks and reading-position markers
+		if clipping.Content == "" {
+			if clipping.Note == "" {
+				continue
+			}
+			clipping.Kind = KindNote
+			clipping.Content, clipping.Note = clipping.Note, ""
+		}
+		clipping.Content = truncateContent(clipping.Content)
+
+		assetID := rowString(row, "ZANNOTATIONASSETID")
+		if b, ok := books[assetID]; ok && b.Title != "" {
+			clipping.Title, clipping.Authors = b.Title, b.Authors
+		} else {
+			clipping.Title = assetID
+		}
+		if clipping.Title == "" {
+			skipped++
+			continue
+		}
+
+		if start, ok := rowFloat(row, "ZPLLOCATIONRANGESTART"); ok {
+			location := int(start)
+			clipping.LocationStart, clipping.LocationEnd = &location, &location
+			if end, ok := rowFloat(row, "ZPLLOCATIONRANGEEND"); ok && int(end) >= location {
+				locationEnd := int(end)
+				clipping.LocationEnd = &locationEnd
+			}
+		}
+
+		if cfi := rowString(row, "ZANNOTATIONLOCATION"); strings.HasPrefix(cfi, "epubcfi(") {
+			clipping.CFI = cfi
+		}
+
+		if created, ok := rowFloat(row, "ZANNOTATIONCREATIONDATE"); ok {
+			t := coreDataEpoch.Add(time.Duration(created * float64(time.Second)))
+			clipping.AddedAt = &t
+		}
+
+		clippings = append(clippings, clipping)
+	}
+
+	return clippings, skipped, nil
+}
@@ -0,0 +1,98 @@
+// Package importers parses annotation exports from reading apps into a common
+// Clipping form that the annotations handlers can match to books and store.
+//
+// Supported formats:
+//   - kindle: Kindle "My Clippings.txt", in English, German, French, Spanish,
+//     Italian, Portuguese and Dutch
+//   - readwise: the Readwise CSV export
+//   - apple-books: the Apple Books AEAnnotation SQLite database, with the
+//     BKLibrary database as an optional "library" file for titles and authors
+//   - kobo: the KoboReader.sqlite database from the device's .kobo folder
+package importers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// maxClippingLength bounds a single clipping in bytes; Kindle itself truncates long highlights
+const maxClippingLength = 20000
+
+// Kind says what a clipping is
+type Kind string
+
+const (
+	KindHighlight Kind = "highlight"
+	KindNote      Kind = "note"
+	KindBookmark  Kind = "bookmark"
+)
+
+// Clipping is one highlight, note or bookmark read from an export
+type Clipping struct {
+	Title         string
+	Authors       []string
+	Kind          Kind
+	Content       string
+	Note          string // a note written on this highlight, for formats that store them together
+	Tags          []string
+	Page          *int
+	LocationStart *int
+	LocationEnd   *int
+	CFI           string // an EPUB CFI, for formats that record one
+	AddedAt       *time.Time
+	ExternalID    string // the source app's own id, when it has one
+}
+
+// Files holds an uploaded export keyed by form field: "file" is the export itself and
+// some formats accept companion files
+type Files map[string][]byte
+
+// Format is one supported export format
+type Format struct {
+	Name string
+	// Parse returns the clippings in the upload and how many entries couldn't be read
+	Parse func(files Files) (clippings []Clipping, skipped int, err error)
+	// NotesByLocation is set when notes are separate clippings that belong to the
+	// highlight ending at their location, as on Kindle
+	NotesByLocation bool
+}
+
+var formats = map[string]Format{
+	"kindle":      {Name: "kindle", Parse: parseKindleFiles, NotesByLocation: true},
+	"readwise":    {Name: "readwise", Parse: parseReadwiseFiles},
+	"apple-books": {Name: "apple-books", Parse: parseAppleBooksFiles},
+	"kobo":        {Name: "kobo", Parse: parseKoboFiles},
+}
+
+// truncateContent cuts s to at most maxClippingLength bytes without splitting a UTF-8 character
+func truncateContent(s string) string {
+	if len(s) <= maxClippingLength {
+		return s
+	}
+	end := maxClippingLength
+	for end > 0 && !utf8.RuneStart(s[end]) {
+		end--
+	}
+	return s[:end]
+}
+
+// Lookup returns the format with the given name
+func Lookup(name string) (Format, error) {
+	format, ok := formats[name]
+	if !ok {
+		return Format{}, fmt.Errorf("unsupported format. Must be one of: %s", joinNames())
+	}
+	return format, nil
+}
+
+func joinNames() string {
+	names := make([]string, 0, len(formats))
+	for name := range formats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,327 @@
+package importers
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// kindleSeparator ends every entry in My Clippings.txt
+const kindleSeparator = "=========="
+
+// byteOrderMark is written by Kindle at the start of the file
+const byteOrderMark = "\uFEFF"
+
+// Words Kindle uses on the metadata line, per locale, all lowercase
+var (
+	kindleHighlightWords = []string{"highlight", "markierung", "surlignement", "subrayado", "evidenziazione", "destaque", "markering"}
+	kindleNoteWords      = []string{"note", "notiz", "nota", "notitie"}
+	kindleBookmarkWords  = []string{"bookmark", "lesezeichen", "signet", "marcador", "segnalibro", "bladwijzer"}
+	kindlePageWords      = []string{"page", "seite", "página", "pagina", "pág", "pag."}
+	kindleLocationWords  = []string{"location", "loc.", "position", "emplacement", "posición", "posizione", "posição", "positie", "pos."}
+	kindleAddedWords     = []string{"added", "hinzugefügt", "ajouté", "añadido", "aggiunto", "adicionado", "toegevoegd"}
+)
+
+// kindleMonths maps month names and common abbreviations in every supported locale
+var kindleMonths = map[string]time.Month{
+	"january": time.January, "february": time.February, "march": time.March, "april": time.April,
+	"may": time.May, "june": time.June, "july": time.July, "august": time.August,
+	"september": time.September, "october": time.October, "november": time.November, "december": time.December,
+	"jan": time.January, "feb": time.February, "mar": time.March, "apr": time.April, "jun": time.June,
+	"jul": time.July, "aug": time.August, "sep": time.September, "sept": time.September, "oct": time.October,
+	"nov": time.November, "dec": time.December,
+	// German
+	"januar": time.January, "februar": time.February, "märz": time.March, "mai": time.May, "juni": time.June,
+	"juli": time.July, "oktober": time.October, "dezember": time.December,
+	// French
+	"janvier": time.January, "février": time.February, "mars": time.March, "avril": time.April,
+	"juin": time.June, "juillet": time.July, "août": time.August, "septembre": time.September,
+	"octobre": time.October, "novembre": time.November, "décembre": time.December,
+	// Spanish
+	"enero": time.January, "febrero": time.February, "marzo": time.March, "abril": time.April, "mayo": time.May,
+	"junio": time.June, "julio": time.July, "agosto": time.August, "septiembre": time.September,
+	"setiembre": time.September, "octubre": time.October, "noviembre": time.November, "diciembre": time.December,
+	// Italian
+	"gennaio": time.January, "febbraio": time.February, "aprile": time.April, "maggio": time.May,
+	"giugno": time.June, "luglio": time.July, "settembre": time.September, "ottobre": time.October,
+	"dicembre": time.December,
+	// Portuguese
+	"janeiro": time.January, "fevereiro": time.February, "março": time.March, "maio": time.May,
+	"junho": time.June, "julho": time.July, "setembro": time.September, "outubro": time.October,
+	"novembro": time.November, "dezembro": time.December,
+	// Dutch
+	"januari": time.January, "februari": time.February, "maart": time.March, "mei": time.May,
+	"augustus": time.August,
+}
+
+var (
+	kindleRangePattern   = regexp.MustCompile(`(\d+)(?:\s*-\s*(\d+))?`)
+	kindleTimePattern    = regexp.MustCompile(`(\d{1,2}):(\d{2})(?::(\d{2}))?\s*([ap]\.?\s?m\.?)?`)
+	kindleISODatePattern = regexp.MustCompile(`(\d{4})[/.-](\d{1,2})[/.-](\d{1,2})`)
+	kindleNumDatePattern = regexp.MustCompile(`(\d{1,2})[/.-](\d{1,2})[/.-](\d{4})`)
+	kindleYearPattern    = regexp.MustCompile(`\b(\d{4})\b`)
+	kindleDayPattern     = regexp.MustCompile(`\b(\d{1,2})\b`)
+	kindleWordPattern    = regexp.MustCompile(`[\p{L}]+`)
+)
+
+// ParseKindleClippings reads a My Clippings.txt file. Entries that can't be parsed are
+// skipped and counted in skipped rather than failing the whole file.
+func ParseKindleClippings(r io.Reader) (clippings []Clipping, skipped int, err error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+
+	entry := []string{}
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) != kindleSeparator {
+			entry = append(entry, line)
+			continue
+		}
+		if clipping, ok := parseKindleEntry(entry); ok {
+			clippings = append(clippings, clipping)
+		} else if len(strings.TrimSpace(strings.Join(entry, ""))) > 0 {
+			skipped++
+		}
+		entry = entry[:0]
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to read clippings: %w", err)
+	}
+
+	// A file cut off mid-entry still yields its last clipping
+	if clipping, ok := parseKindleEntry(entry); ok {
+		clippings = append(clippings, clipping)
+	} else if len(strings.TrimSpace(strings.Join(entry, ""))) > 0 {
+		skipped++
+	}
+
+	return clippings, skipped, nil
+}
+
+func parseKindleFiles(files Files) ([]Clipping, int, error) {
+	return ParseKindleClippings(bytes.NewReader(files["file"]))
+}
+
+// parseKindleEntry parses the lines between two separators:
+// the title line, the metadata line, a blank line and the content
+func parseKindleEntry(lines []string) (Clipping, bool) {
+	// Drop leading blank lines and the byte order mark Kindle writes at the top of the file
+	for len(lines) > 0 && strings.TrimSpace(strings.TrimPrefix(lines[0], byteOrderMark)) == "" {
+		lines = lines[1:]
+	}
+	if len(lines) < 2 {
+		return Clipping{}, false
+	}
+
+	clipping := Clipping{}
+	clipping.Title, clipping.Authors = parseKindleTitle(strings.TrimPrefix(lines[0], byteOrderMark))
+	if clipping.Title == "" {
+		return Clipping{}, false
+	}
+
+	if !parseKindleMetadata(lines[1], &clipping) {
+		return Clipping{}, false
+	}
+
+	clipping.Content = truncateContent(strings.TrimSpace(strings.Join(lines[2:], "\n")))
+	if clipping.Content == "" && clipping.Kind != KindBookmark {
+		return Clipping{}, false
+	}
+
+	return clipping, true
+}
+
+// parseKindleTitle splits "Title (Last, First; Other Author)" into the title and its authors
+func parseKindleTitle(line string) (string, []string) {
+	line = strings.TrimSpace(line)
+	authors := []string{}
+	if strings.HasSuffix(line, ")") {
+		if open := matchingParen(line); open > 0 {
+			for _, author := range strings.Split(line[open+1:len(line)-1], ";") {
+				if author = normalizeKindleAuthor(author); author != "" {
+					authors = append(authors, author)
+				}
+			}
+			line = strings.TrimSpace(line[:open])
+		}
+	}
+	return line, authors
+}
+
+// matchingParen returns the index of the "(" matching the final ")", so titles that
+// themselves contain parentheses keep them
+func matchingParen(s string) int {
+	depth := 0
+	for i := len(s) - 1; i >= 0; i-- {
+		switch s[i] {
+		case ')':
+			depth++
+		case '(':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// normalizeKindleAuthor turns "Austen, Jane" into "Jane Austen"
+func normalizeKindleAuthor(author string) string {
+	author = strings.TrimSpace(author)
+	if parts := strings.Split(author, ","); len(parts) == 2 {
+		last, first := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+		if first != "" && last != "" {
+			return first + " " + last
+		}
+	}
+	return author
+}
+
+// parseKindleMetadata reads the "- Your Highlight on page 12 | Location 172-174 | Added on ..."
+// line. Older devices write "Loc. 172-74", abbreviating the end of the range.
+func parseKindleMetadata(line string, clipping *Clipping) bool {
+	line = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), "-"))
+	if line == "" {
+		return false
+	}
+
+	segments := strings.Split(line, "|")
+	lower := strings.ToLower(segments[0])
+	switch {
+	case containsAny(lower, kindleBookmarkWords):
+		clipping.Kind = KindBookmark
+	case containsAny(lower, kindleHighlightWords):
+		clipping.Kind = KindHighlight
+	case containsAny(lower, kindleNoteWords):
+		clipping.Kind = KindNote
+	default:
+		return false
+	}
+
+	for _, segment := range segments {
+		lower := strings.ToLower(strings.TrimSpace(segment))
+		if i := indexAny(lower, kindleAddedWords); i >= 0 {
+			clipping.AddedAt = parseKindleDate(lower[i:])
+			continue
+		}
+		// The first segment may hold both the page and the location
+		if i := indexAny(lower, kindleLocationWords); i >= 0 {
+			if start, end, ok := parseKindleRange(lower[i:]); ok {
+				clipping.LocationStart, clipping.LocationEnd = &start, &end
+			}
+			lower = lower[:i]
+		}
+		if i := indexAny(lower, kindlePageWords); i >= 0 {
+			if start, _, ok := parseKindleRange(lower[i:]); ok {
+				clipping.Page = &start
+			}
+		}
+	}
+
+	return true
+}
+
+// parseKindleRange reads the first "172-174" or "172" in s, expanding "172-74" to 172-174
+func parseKindleRange(s string) (int, int, bool) {
+	match := kindleRangePattern.FindStringSubmatch(s)
+	if match == nil {
+		return 0, 0, false
+	}
+	start, err := strconv.Atoi(match[1])
+	if err != nil {
+		return 0, 0, false
+	}
+	end := start
+	if match[2] != "" {
+		if n, err := strconv.Atoi(match[2]); err == nil {
+			end = n
+			if end < start && len(match[2]) < len(match[1]) {
+				prefix := match[1][:len(match[1])-len(match[2])]
+				end, _ = strconv.Atoi(prefix + match[2])
+			}
+		}
+	}
+	if end < start {
+		end = start
+	}
+	return start, end, true
+}
+
+// parseKindleDate reads the timestamp after "Added on" in any supported locale, e.g.
+// "Sunday, March 3, 2019 10:15:23 PM", "Sonntag, 3. März 2019 22:15:23" or
+// "domingo, 3 de marzo de 2019 22:15:23". It returns nil when no date can be found.
+func parseKindleDate(s string) *time.Time {
+	hour, minute, second := 0, 0, 0
+	if match := kindleTimePattern.FindStringSubmatch(s); match != nil {
+		hour, _ = strconv.Atoi(match[1])
+		minute, _ = strconv.Atoi(match[2])
+		if match[3] != "" {
+			second, _ = strconv.Atoi(match[3])
+		}
+		meridiem := strings.NewReplacer(".", "", " ", "").Replace(match[4])
+		if meridiem == "pm" && hour < 12 {
+			hour += 12
+		} else if meridiem == "am" && hour == 12 {
+			hour = 0
+		}
+		s = strings.Replace(s, match[0], " ", 1)
+	}
+
+	var year, day int
+	var month time.Month
+	if match := kindleISODatePattern.FindStringSubmatch(s); match != nil {
+		year, _ = strconv.Atoi(match[1])
+		m, _ := strconv.Atoi(match[2])
+		month = time.Month(m)
+		day, _ = strconv.Atoi(match[3])
+	} else if match := kindleNumDatePattern.FindStringSubmatch(s); match != nil {
+		// Numeric dates are ambiguous; Kindle uses day first everywhere but en-US
+		day, _ = strconv.Atoi(match[1])
+		m, _ := strconv.Atoi(match[2])
+		month = time.Month(m)
+		year, _ = strconv.Atoi(match[3])
+	} else {
+		match := kindleYearPattern.FindStringSubmatch(s)
+		if match == nil {
+			return nil
+		}
+		year, _ = strconv.Atoi(match[1])
+		s = strings.Replace(s, match[0], " ", 1)
+		for _, word := range kindleWordPattern.FindAllString(s, -1) {
+			if m, ok := kindleMonths[word]; ok {
+				month = m
+				break
+			}
+		}
+		if match := kindleDayPattern.FindStringSubmatch(s); match != nil {
+			day, _ = strconv.Atoi(match[1])
+		}
+	}
+
+	if year < 1990 || month < time.January || month > time.December || day < 1 || day > 31 || hour > 23 || minute > 59 || second > 59 {
+		return nil
+	}
+	t := time.Date(year, month, day, hour, minute, second, 0, time.UTC)
+	return &t
+}
+
+func containsAny(s string, words []string) bool {
+	return indexAny(s, words) >= 0
+}
+
+// indexAny returns the position of the first of words found in s, or -1
+func indexAny(s string, words []string) int {
+	best := -1
+	for _, word := range words {
+		if i := strings.Index(s, word); i >= 0 && (best < 0 || i < best) {
+			best = i
+		}
+	}
+	return best
+}
@@ -0,0 +1,186 @@
+package importers
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func intPtr(n int) *int { return &n }
+
+func timePtr(t time.Time) *time.Time { return &t }
+
+func TestParseKindleClippings(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []Clipping
+		skipped int
+	}{
+		{
+			name: "english highlight",
+			input: "\uFEFFPride and Prejudice (Austen, Jane)\r\n" +
+				"- Your Highlight on page 12 | Location 172-174 | Added on Sunday, March 3, 2019 10:15:23 PM\r\n" +
+				"\r\n" +
+				"It is a truth universally acknowledged.\r\n" +
+				"==========\r\n",
+			want: []Clipping{{
+				Title:         "Pride and Prejudice",
+				Authors:       []string{"Jane Austen"},
+				Kind:          KindHighlight,
+				Content:       "It is a truth universally acknowledged.",
+				Page:          intPtr(12),
+				LocationStart: intPtr(172),
+				LocationEnd:   intPtr(174),
+				AddedAt:       timePtr(time.Date(2019, time.March, 3, 22, 15, 23, 0, time.UTC)),
+			}},
+		},
+		{
+			name: "abbreviated location range and several authors",
+			input: "Good Omens (Pratchett, Terry; Gaiman, Neil)\n" +
+				"- Highlight Loc. 172-74  | Added on Monday, April 1, 2013, 09:05 AM\n" +
+				"\n" +
+				"Kindly remember.\n" +
+				"==========\n",
+			want: []Clipping{{
+				Title:         "Good Omens",
+				Authors:       []string{"Terry Pratchett", "Neil Gaiman"},
+				Kind:          KindHighlight,
+				Content:       "Kindly remember.",
+				LocationStart: intPtr(172),
+				LocationEnd:   intPtr(174),
+				AddedAt:       timePtr(time.Date(2013, time.April, 1, 9, 5, 0, 0, time.UTC)),
+			}},
+		},
+		{
+			name: "german note",
+			input: "Der Process (Kafka, Franz)\n" +
+				"- Ihre Notiz auf Seite 5 | Position 80 | Hinzugefügt am Sonntag, 3. März 2019 22:15:23\n" +
+				"\n" +
+				"Jemand musste Josef K. verleumdet haben.\n" +
+				"==========\n",
+			want: []Clipping{{
+				Title:         "Der Process",
+				Authors:       []string{"Franz Kafka"},
+				Kind:          KindNote,
+				Content:       "Jemand musste Josef K. verleumdet haben.",
+				Page:          intPtr(5),
+				LocationStart: intPtr(80),
+				LocationEnd:   intPtr(80),
+				AddedAt:       timePtr(time.Date(2019, time.March, 3, 22, 15, 23, 0, time.UTC)),
+			}},
+		},
+		{
+			name: "title with parentheses and bookmark",
+			input: "Dune (Dune Chronicles) (Herbert, Frank)\n" +
+				"- Your Bookmark on Location 300 | Added on 2020-01-02 08:00:00\n" +
+				"\n" +
+				"\n" +
+				"==========\n",
+			want: []Clipping{{
+				Title:         "Dune (Dune Chronicles)",
+				Authors:       []string{"Frank Herbert"},
+				Kind:          KindBookmark,
+				LocationStart: intPtr(300),
+				LocationEnd:   intPtr(300),
+				AddedAt:       timePtr(time.Date(2020, time.January, 2, 8, 0, 0, 0, time.UTC)),
+			}},
+		},
+		{
+			name: "unparseable entries are skipped",
+			input: "Just a title\n" +
+				"==========\n" +
+				"Some Book (Author, An)\n" +
+				"- Something unknown happened\n" +
+				"\n" +
+				"text\n" +
+				"==========\n" +
+				"Empty Highlight (Author, An)\n" +
+				"- Your Highlight on page 1 | Added on Sunday, March 3, 2019 10:15:23 PM\n" +
+				"\n" +
+				"==========\n",
+			want:    nil,
+			skipped: 3,
+		},
+		{
+			name: "file cut off mid-entry keeps the last clipping",
+			input: "Emma (Austen, Jane)\n" +
+				"- Your Highlight on page 3 | Added on Sunday, March 3, 2019 10:15:23 PM\n" +
+				"\n" +
+				"Handsome, clever, and rich.",
+			want: []Clipping{{
+				Title:   "Emma",
+				Authors: []string{"Jane Austen"},
+				Kind:    KindHighlight,
+				Content: "Handsome, clever, and rich.",
+				Page:    intPtr(3),
+				AddedAt: timePtr(time.Date(2019, time.March, 3, 22, 15, 23, 0, time.UTC)),
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, skipped, err := ParseKindleClippings(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("ParseKindleClippings() error = %v", err)
+			}
+			if skipped != tt.skipped {
+				t.Errorf("skipped = %d, want %d", skipped, tt.skipped)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("clippings = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseKindleClippingsTruncatesOnRuneBoundary(t *testing.T) {
+	// "é" is two bytes, so an odd byte limit would split one in half
+	content := strings.Repeat("a", maxClippingLength-1) + strings.Repeat("é", 10)
+	input := "Long (Writer, A)\n- Your Highlight on page 1\n\n" + content + "\n==========\n"
+
+	clippings, _, err := ParseKindleClippings(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ParseKindleClippings() error = %v", err)
+	}
+	if len(clippings) != 1 {
+		t.Fatalf("got %d clippings, want 1", len(clippings))
+	}
+	got := clippings[0].Content
+	if !utf8.ValidString(got) {
+		t.Errorf("truncated content is not valid UTF-8")
+	}
+	if want := strings.Repeat("a", maxClippingLength-1); got != want {
+		t.Errorf("truncated content has %d bytes, want %d", len(got), len(want))
+	}
+}
+
+func TestTruncateContent(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  int
+	}{
+		{"short", "hello", 5},
+		{"exact", strings.Repeat("a", maxClippingLength), maxClippingLength},
+		{"ascii", strings.Repeat("a", maxClippingLength+5), maxClippingLength},
+		{"two-byte rune across the limit", strings.Repeat("a", maxClippingLength-1) + "é", maxClippingLength - 1},
+		{"four-byte rune across the limit", strings.Repeat("a", maxClippingLength-2) + "😀", maxClippingLength - 2},
+		{"rune ending at the limit", strings.Repeat("a", maxClippingLength-2) + "éé", maxClippingLength},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := truncateContent(tt.input)
+			if len(got) != tt.want {
+				t.Errorf("len(truncateContent()) = %d, want %d", len(got), tt.want)
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("truncateContent() returned invalid UTF-8")
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package importers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Kobo devices keep highlights in the Bookmark table of .kobo/KoboReader.sqlite, joined to
+// the book's row in content by VolumeID. A note is stored on the highlight it was written on.
+var koboTimeLayouts = []string{
+	"2006-01-02T15:04:05.000",
+	"2006-01-02T15:04:05Z",
+	"2006-01-02T15:04:05",
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+}
+
+func parseKoboFiles(files Files) ([]Clipping, int, error) {
+	if !isSQLite(files["file"]) {
+		return nil, 0, fmt.Errorf("not a KoboReader.sqlite database")
+	}
+	db, err := openSQLite(files["file"])
+	if err != nil {
+		return nil, 0, err
+	}
+	if !db.hasTable("Bookmark") || !db.hasTable("content") {
+		return nil, 0, fmt.Errorf("not a KoboReader.sqlite database")
+	}
+
+	bookmarks, err := db.readTable("Bookmark")
+	if err != nil {
+		return nil, 0, err
+	}
+	content, err := db.readTable("content")
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Books are the content rows with ContentType 6; chapters share the table
+	type book struct {
+		Title   string
+		Authors []string
+	}
+	books := map[string]book{}
+	for _, row := range content {
+		if contentType, _ := rowFloat(row, "ContentType"); contentType != 6 {
+			continue
+		}
+		authors := []string{}
+		for _, author := range strings.Split(rowString(row, "Attribution"), ",") {
+			if author = strings.TrimSpace(author); author != "" {
+				authors = append(authors, author)
+			}
+		}
+		books[rowString(row, "ContentID")] = book{Title: rowString(row, "Title"), Authors: authors}
+	}
+
+	clippings := []Clipping{}
+	skipped := 0
+	for _, row := range bookmarks {
+		if strings.EqualFold(rowString(row, "Hidden"), "true") {
+			continue
+		}
+		switch strings.ToLower(rowString(row, "Type")) {
+		case "dogear", "markup":
+			// Page bookmarks and handwriting have no text to import
+			continue
+		}
+
+		clipping := Clipping{
+			Kind:       KindHighlight,
+			Content:    strings.TrimSpace(rowString(row, "Text")),
+			Note:       strings.TrimSpace(rowString(row, "Annotation")),
+			ExternalID: rowString(row, "BookmarkID"),
+		}
+		if clipping.Content == "" {
+			if clipping.Note == "" {
+				continue
+			}
+			clipping.Kind = KindNote
+			clipping.Content, clipping.Note = clipping.Note, ""
+		}
+		clipping.Content = truncateContent(clipping.Content)
+
+		b, ok := books[rowString(row, "VolumeID")]
+		if !ok || b.Title == "" {
+			skipped++
+			continue
+		}
+		clipping.Title, clipping.Authors = b.Title, b.Authors
+
+		created := rowString(row, "DateCreated")
+		for _, layout := range koboTimeLayouts {
+			if t, err := time.Parse(layout, created); err == nil {
+				t = t.UTC()
+				clipping.AddedAt = &t
+				break
+			}
+		}
+
+		clippings = append(clippings, clipping)
+	}
+
+	return clippings, skipped, nil
+}
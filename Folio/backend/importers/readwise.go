@@ -0,0 +1,108 @@
+package importers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Readwise exports one highlight per row, with its note and tags alongside
+var readwiseTimeLayouts = []string{
+	"2006-01-02 15:04:05-07:00",
+	"2006-01-02 15:04:05.999999-07:00",
+	"2006-01-02 15:04:05",
+	time.RFC3339,
+	"2006-01-02",
+}
+
+func parseReadwiseFiles(files Files) ([]Clipping, int, error) {
+	data := bytes.TrimPrefix(files["file"], []byte(byteOrderMark))
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read CSV header")
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["highlight"]; !ok {
+		return nil, 0, fmt.Errorf("not a Readwise export: missing Highlight column")
+	}
+	if _, ok := columns["book title"]; !ok {
+		return nil, 0, fmt.Errorf("not a Readwise export: missing Book Title column")
+	}
+
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	clippings := []Clipping{}
+	skipped := 0
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			skipped++
+			continue
+		}
+
+		clipping := Clipping{
+			Title:   field(record, "book title"),
+			Kind:    KindHighlight,
+			Content: field(record, "highlight"),
+			Note:    field(record, "note"),
+		}
+		if clipping.Title == "" || clipping.Content == "" {
+			skipped++
+			continue
+		}
+		clipping.Content = truncateContent(clipping.Content)
+
+		for _, author := range strings.Split(field(record, "book author"), ",") {
+			if author = strings.TrimSpace(author); author != "" {
+				clipping.Authors = append(clipping.Authors, author)
+			}
+		}
+		for _, tag := range strings.Split(field(record, "tags"), ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				clipping.Tags = append(clipping.Tags, tag)
+			}
+		}
+
+		if location, err := strconv.Atoi(field(record, "location")); err == nil {
+			switch strings.ToLower(field(record, "location type")) {
+			case "page":
+				clipping.Page = &location
+			case "location":
+				clipping.LocationStart, clipping.LocationEnd = &location, &location
+			}
+		}
+
+		if highlighted := field(record, "highlighted at"); highlighted != "" {
+			for _, layout := range readwiseTimeLayouts {
+				if t, err := time.Parse(layout, highlighted); err == nil {
+					t = t.UTC()
+					clipping.AddedAt = &t
+					break
+				}
+			}
+		}
+
+		clippings = append(clippings, clipping)
+	}
+
+	return clippings, skipped, nil
+}
@@ -0,0 +1,390 @@
+package importers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// This is a minimal read-only reader for SQLite database files, enough to scan the
+// tables of the Apple Books and Kobo exports without a cgo driver. It handles rowid
+// tables on UTF-8 databases, including overflow pages. Changes still sitting in a
+// -wal file are not seen, so exports should be copied while the app is closed.
+
+const sqliteHeader = "SQLite format 3\x00"
+
+// maxSQLitePages bounds how many pages a table scan or overflow chain may visit
+const maxSQLitePages = 1 << 20
+
+type sqliteDB struct {
+	data       []byte
+	pageSize   int
+	usableSize int
+}
+
+// sqliteRow maps column names to values: nil, int64, float64, string or []byte
+type sqliteRow map[string]interface{}
+
+func openSQLite(data []byte) (*sqliteDB, error) {
+	if len(data) < 100 || string(data[:16]) != sqliteHeader {
+		return nil, fmt.Errorf("not a SQLite database")
+	}
+	pageSize := int(binary.BigEndian.Uint16(data[16:18]))
+	if pageSize == 1 {
+		pageSize = 65536
+	}
+	if pageSize < 512 || pageSize&(pageSize-1) != 0 {
+		return nil, fmt.Errorf("invalid SQLite page size")
+	}
+	if encoding := binary.BigEndian.Uint32(data[56:60]); encoding > 1 {
+		return nil, fmt.Errorf("only UTF-8 SQLite databases are supported")
+	}
+	return &sqliteDB{
+		data:       data,
+		pageSize:   pageSize,
+		usableSize: pageSize - int(data[20]),
+	}, nil
+}
+
+// page returns page n, counting from 1 as SQLite does
+func (db *sqliteDB) page(n int) ([]byte, error) {
+	start := (n - 1) * db.pageSize
+	if n < 1 || start+db.pageSize > len(db.data) {
+		return nil, fmt.Errorf("SQLite page %d out of range", n)
+	}
+	return db.data[start : start+db.pageSize], nil
+}
+
+// readVarint decodes a SQLite varint and returns it with its length
+func readVarint(b []byte) (int64, int) {
+	var v uint64
+	for i := 0; i < 9 && i < len(b); i++ {
+		if i == 8 {
+			return int64(v<<8 | uint64(b[i])), 9
+		}
+		v = v<<7 | uint64(b[i]&0x7f)
+		if b[i]&0x80 == 0 {
+			return int64(v), i + 1
+		}
+	}
+	return int64(v), len(b)
+}
+
+// scanTable calls fn for every row of the table b-tree rooted at rootPage, in rowid order.
+// Pages are walked with an explicit stack, and a page seen twice means the file is corrupt,
+// so a page cycle can neither loop nor recurse without bound.
+func (db *sqliteDB) scanTable(rootPage int, fn func(rowid int64, values []interface{})) error {
+	visited := map[int]bool{}
+	stack := []int{rootPage}
+	for len(stack) > 0 {
+		n := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		if visited[n] {
+			return fmt.Errorf("corrupt SQLite page %d", n)
+		}
+		visited[n] = true
+		if len(visited) > maxSQLitePages {
+			return fmt.Errorf("SQLite table is too large or corrupt")
+		}
+
+		page, err := db.page(n)
+		if err != nil {
+			return err
+		}
+		header := 0
+		if n == 1 {
+			header = 100
+		}
+		if header+8 > len(page) {
+			return fmt.Errorf("corrupt SQLite page %d", n)
+		}
+
+		pageType := page[header]
+		cells := int(binary.BigEndian.Uint16(page[header+3 : header+5]))
+		pointers := header + 8
+		if pageType == 0x05 {
+			pointers = header + 12
+		}
+		if pointers+2*cells > len(page) {
+			return fmt.Errorf("corrupt SQLite page %d", n)
+		}
+
+		switch pageType {
+		case 0x05:
+			// Push the right-most child first and the cells' children in reverse,
+			// so they come off the stack left to right
+			stack = append(stack, int(binary.BigEndian.Uint32(page[header+8:])))
+			for i := cells - 1; i >= 0; i-- {
+				offset := int(binary.BigEndian.Uint16(page[pointers+2*i:]))
+				if offset+4 > len(page) {
+					return fmt.Errorf("corrupt SQLite page %d", n)
+				}
+				stack = append(stack, int(binary.BigEndian.Uint32(page[offset:])))
+			}
+		case 0x0d:
+			for i := 0; i < cells; i++ {
+				offset := int(binary.BigEndian.Uint16(page[pointers+2*i:]))
+				if offset >= len(page) {
+					return fmt.Errorf("corrupt SQLite page %d", n)
+				}
+				cell := page[offset:]
+				payloadSize, n1 := readVarint(cell)
+				rowid, n2 := readVarint(cell[n1:])
+				payload, err := db.payload(cell[n1+n2:], int(payloadSize))
+				if err != nil {
+					return err
+				}
+				values, err := decodeRecord(payload)
+				if err != nil {
+					return err
+				}
+				fn(rowid, values)
+			}
+		default:
+			return fmt.Errorf("unsupported SQLite page type %#x", pageType)
+		}
+	}
+	return nil
+}
+
+// payload assembles a cell's payload, following overflow pages when it doesn't fit locally
+func (db *sqliteDB) payload(cell []byte, size int) ([]byte, error) {
+	if size < 0 || size > len(db.data) {
+		return nil, fmt.Errorf("corrupt SQLite cell")
+	}
+	u := db.usableSize
+	maxLocal := u - 35
+	local := size
+	if size > maxLocal {
+		minLocal := (u-12)*32/255 - 23
+		local = minLocal + (size-minLocal)%(u-4)
+		if local > maxLocal {
+			local = minLocal
+		}
+	}
+	if local > len(cell) {
+		return nil, fmt.Errorf("corrupt SQLite cell")
+	}
+
+	payload := make([]byte, 0, size)
+	payload = append(payload, cell[:local]...)
+	if local == size {
+		return payload, nil
+	}
+	if local+4 > len(cell) {
+		return nil, fmt.Errorf("corrupt SQLite cell")
+	}
+
+	next := int(binary.BigEndian.Uint32(cell[local:]))
+	for hops := 0; len(payload) < size; hops++ {
+		if next == 0 || hops > maxSQLitePages {
+			return nil, fmt.Errorf("corrupt SQLite overflow chain")
+		}
+		page, err := db.page(next)
+		if err != nil {
+			return nil, err
+		}
+		chunk := page[4:u]
+		if remaining := size - len(payload); len(chunk) > remaining {
+			chunk = chunk[:remaining]
+		}
+		payload = append(payload, chunk...)
+		next = int(binary.BigEndian.Uint32(page))
+	}
+	return payload, nil
+}
+
+// decodeRecord decodes a record into nil, int64, float64, string and []byte values
+func decodeRecord(record []byte) ([]interface{}, error) {
+	headerSize, n := readVarint(record)
+	if int(headerSize) > len(record) || headerSize < int64(n) {
+		return nil, fmt.Errorf("corrupt SQLite record")
+	}
+
+	types := []int64{}
+	for pos := n; pos < int(headerSize); {
+		t, n := readVarint(record[pos:headerSize])
+		types = append(types, t)
+		pos += n
+	}
+
+	values := make([]interface{}, len(types))
+	body := record[headerSize:]
+	for i, t := range types {
+		size := 0
+		switch {
+		case t == 0 || t == 8 || t == 9:
+		case t >= 1 && t <= 4:
+			size = int(t)
+		case t == 5:
+			size = 6
+		case t == 6 || t == 7:
+			size = 8
+		case t >= 12:
+			size = int(t-12) / 2
+		default:
+			return nil, fmt.Errorf("corrupt SQLite record")
+		}
+		if size > len(body) {
+			return nil, fmt.Errorf("corrupt SQLite record")
+		}
+		field := body[:size]
+		body = body[size:]
+
+		switch {
+		case t == 0:
+			values[i] = nil
+		case t == 8:
+			values[i] = int64(0)
+		case t == 9:
+			values[i] = int64(1)
+		case t <= 6:
+			// Big-endian two's complement of 1 to 8 bytes
+			v := int64(int8(field[0]))
+			for _, b := range field[1:] {
+				v = v<<8 | int64(b)
+			}
+			values[i] = v
+		case t == 7:
+			values[i] = math.Float64frombits(binary.BigEndian.Uint64(field))
+		case t%2 == 0:
+			values[i] = append([]byte(nil), field...)
+		default:
+			values[i] = string(field)
+		}
+	}
+	return values, nil
+}
+
+// readTable returns every row of the named table, keyed by column name
+func (db *sqliteDB) readTable(name string) ([]sqliteRow, error) {
+	var rootPage int
+	var createSQL string
+	err := db.scanTable(1, func(_ int64, values []interface{}) {
+		// sqlite_master columns: type, name, tbl_name, rootpage, sql
+		if len(values) < 5 || values[0] != "table" {
+			return
+		}
+		if tableName, _ := values[1].(string); strings.EqualFold(tableName, name) {
+			root, _ := values[3].(int64)
+			rootPage = int(root)
+			createSQL, _ = values[4].(string)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if rootPage == 0 {
+		return nil, fmt.Errorf("table %s not found", name)
+	}
+
+	columns, rowidColumn := parseCreateTable(createSQL)
+	rows := []sqliteRow{}
+	err = db.scanTable(rootPage, func(rowid int64, values []interface{}) {
+		row := sqliteRow{}
+		for i, column := range columns {
+			// Columns added by ALTER TABLE are missing from older rows
+			if i < len(values) {
+				row[column] = values[i]
+			} else {
+				row[column] = nil
+			}
+		}
+		if rowidColumn != "" {
+			row[rowidColumn] = rowid
+		}
+		rows = append(rows, row)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// hasTable reports whether the database has a table with the given name
+func (db *sqliteDB) hasTable(name string) bool {
+	found := false
+	db.scanTable(1, func(_ int64, values []interface{}) {
+		if len(values) >= 2 && values[0] == "table" {
+			if tableName, _ := values[1].(string); strings.EqualFold(tableName, name) {
+				found = true
+			}
+		}
+	})
+	return found
+}
+
+// parseCreateTable returns the column names of a CREATE TABLE statement and the
+// INTEGER PRIMARY KEY column, if any, whose value is the rowid
+func parseCreateTable(sql string) (columns []string, rowidColumn string) {
+	open := strings.Index(sql, "(")
+	end := strings.LastIndex(sql, ")")
+	if open < 0 || end <= open {
+		return nil, ""
+	}
+
+	definitions := []string{}
+	depth, start := 0, open+1
+	for i := open + 1; i < end; i++ {
+		switch sql[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				definitions = append(definitions, sql[start:i])
+				start = i + 1
+			}
+		}
+	}
+	definitions = append(definitions, sql[start:end])
+
+	for _, definition := range definitions {
+		definition = strings.TrimSpace(definition)
+		upper := strings.ToUpper(definition)
+		if definition == "" || strings.HasPrefix(upper, "CONSTRAINT") || strings.HasPrefix(upper, "PRIMARY KEY") ||
+			strings.HasPrefix(upper, "UNIQUE") || strings.HasPrefix(upper, "CHECK") || strings.HasPrefix(upper, "FOREIGN KEY") {
+			continue
+		}
+		fields := strings.Fields(definition)
+		name := strings.Trim(fields[0], "\"`[]")
+		columns = append(columns, name)
+		if len(fields) > 1 && strings.EqualFold(fields[1], "INTEGER") && strings.Contains(upper, "PRIMARY KEY") {
+			rowidColumn = name
+		}
+	}
+	return columns, rowidColumn
+}
+
+// isSQLite reports whether data looks like a SQLite database file
+func isSQLite(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(sqliteHeader))
+}
+
+func rowString(row sqliteRow, column string) string {
+	switch v := row[column].(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case int64:
+		return fmt.Sprint(v)
+	case float64:
+		return fmt.Sprint(v)
+	}
+	return ""
+}
+
+func rowFloat(row sqliteRow, column string) (float64, bool) {
+	switch v := row[column].(type) {
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
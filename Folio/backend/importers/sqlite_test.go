@@ -0,0 +1,244 @@
+package importers
+
+import (
+	"encoding/binary"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestReadVarint(t *testing.T) {
+	tests := []struct {
+		name  string
+		input []byte
+		value int64
+		size  int
+	}{
+		{"zero", []byte{0x00}, 0, 1},
+		{"one byte", []byte{0x7f}, 127, 1},
+		{"two bytes", []byte{0x81, 0x00}, 128, 2},
+		{"trailing bytes ignored", []byte{0x81, 0x01, 0xff}, 129, 2},
+		{"three bytes", []byte{0x81, 0x80, 0x00}, 16384, 3},
+		{"nine bytes use all of the last byte", []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, -1, 9},
+		{"truncated", []byte{0x81}, 1, 1},
+		{"empty", []byte{}, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, size := readVarint(tt.input)
+			if value != tt.value || size != tt.size {
+				t.Errorf("readVarint(% x) = (%d, %d), want (%d, %d)", tt.input, value, size, tt.value, tt.size)
+			}
+		})
+	}
+}
+
+// record builds a SQLite record from serial types and the body they describe
+func record(types []byte, body ...byte) []byte {
+	return append(append([]byte{byte(len(types) + 1)}, types...), body...)
+}
+
+func TestDecodeRecord(t *testing.T) {
+	float := make([]byte, 8)
+	binary.BigEndian.PutUint64(float, math.Float64bits(1.5))
+
+	tests := []struct {
+		name    string
+		input   []byte
+		want    []interface{}
+		wantErr bool
+	}{
+		{
+			name:  "null and constants",
+			input: record([]byte{0, 8, 9}),
+			want:  []interface{}{nil, int64(0), int64(1)},
+		},
+		{
+			name:  "signed integers",
+			input: record([]byte{1, 2, 3}, 0xff, 0x01, 0x02, 0xff, 0xff, 0xfe),
+			want:  []interface{}{int64(-1), int64(258), int64(-2)},
+		},
+		{
+			name:  "six and eight byte integers",
+			input: record([]byte{5, 6}, 0, 0, 0, 0, 0x01, 0x00, 0, 0, 0, 0, 0, 0, 0, 0x2a),
+			want:  []interface{}{int64(256), int64(42)},
+		},
+		{
+			name:  "float",
+			input: record([]byte{7}, float...),
+			want:  []interface{}{1.5},
+		},
+		{
+			name:  "text and blob",
+			input: record([]byte{17, 16, 13}, 'h', 'i', 0x01, 0x02),
+			want:  []interface{}{"hi", []byte{0x01, 0x02}, ""},
+		},
+		{
+			name:    "header longer than record",
+			input:   []byte{0x10, 0x01},
+			wantErr: true,
+		},
+		{
+			name:    "reserved serial type",
+			input:   record([]byte{10}),
+			wantErr: true,
+		},
+		{
+			name:    "body shorter than its types",
+			input:   record([]byte{6}, 0x01),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeRecord(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("decodeRecord() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decodeRecord() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCreateTable(t *testing.T) {
+	tests := []struct {
+		name        string
+		sql         string
+		columns     []string
+		rowidColumn string
+	}{
+		{
+			name:        "integer primary key",
+			sql:         "CREATE TABLE ZAEANNOTATION (Z_PK INTEGER PRIMARY KEY, ZANNOTATIONSELECTEDTEXT VARCHAR, ZANNOTATIONDELETED INTEGER)",
+			columns:     []string{"Z_PK", "ZANNOTATIONSELECTEDTEXT", "ZANNOTATIONDELETED"},
+			rowidColumn: "Z_PK",
+		},
+		{
+			name:    "quoted names and table constraints",
+			sql:     "CREATE TABLE \"Bookmark\" (\"BookmarkID\" TEXT NOT NULL, `Text` TEXT, [Color] INTEGER DEFAULT (0), PRIMARY KEY (BookmarkID), UNIQUE (Text, Color))",
+			columns: []string{"BookmarkID", "Text", "Color"},
+		},
+		{
+			name:    "text primary key is not the rowid",
+			sql:     "CREATE TABLE content (ContentID TEXT PRIMARY KEY, Title TEXT, CHECK (length(Title) > 0), CONSTRAINT fk FOREIGN KEY (ContentID) REFERENCES other(id))",
+			columns: []string{"ContentID", "Title"},
+		},
+		{
+			name: "not a create statement",
+			sql:  "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			columns, rowidColumn := parseCreateTable(tt.sql)
+			if !reflect.DeepEqual(columns, tt.columns) || rowidColumn != tt.rowidColumn {
+				t.Errorf("parseCreateTable() = (%q, %q), want (%q, %q)", columns, rowidColumn, tt.columns, tt.rowidColumn)
+			}
+		})
+	}
+}
+
+const testPageSize = 512
+
+// sqliteFile lays out pages into a database file; page 1 leaves room for the file header
+func sqliteFile(pages ...[]byte) []byte {
+	data := make([]byte, testPageSize*len(pages))
+	for i, page := range pages {
+		copy(data[i*testPageSize:], page)
+	}
+	copy(data, sqliteHeader)
+	binary.BigEndian.PutUint16(data[16:], testPageSize)
+	binary.BigEndian.PutUint32(data[56:], 1)
+	return data
+}
+
+// interiorPage builds a table interior page pointing at children and then right
+func interiorPage(header int, right int, children ...int) []byte {
+	page := make([]byte, testPageSize)
+	page[header] = 0x05
+	binary.BigEndian.PutUint16(page[header+3:], uint16(len(children)))
+	binary.BigEndian.PutUint32(page[header+8:], uint32(right))
+	offset := 400
+	for i, child := range children {
+		binary.BigEndian.PutUint16(page[header+12+2*i:], uint16(offset))
+		binary.BigEndian.PutUint32(page[offset:], uint32(child))
+		page[offset+4] = byte(i + 1)
+		offset += 5
+	}
+	return page
+}
+
+// leafPage builds a table leaf page holding one-column text rows with the given rowids
+func leafPage(rowids ...int) []byte {
+	page := make([]byte, testPageSize)
+	page[0] = 0x0d
+	binary.BigEndian.PutUint16(page[3:], uint16(len(rowids)))
+	offset := 300
+	for i, rowid := range rowids {
+		payload := record([]byte{15}, 'x')
+		cell := append([]byte{byte(len(payload)), byte(rowid)}, payload...)
+		binary.BigEndian.PutUint16(page[8+2*i:], uint16(offset))
+		copy(page[offset:], cell)
+		offset += len(cell)
+	}
+	return page
+}
+
+func TestScanTable(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		want    []int64
+		wantErr bool
+	}{
+		{
+			name: "children in order then the right child",
+			data: sqliteFile(interiorPage(100, 3, 2), leafPage(1, 2), leafPage(3)),
+			want: []int64{1, 2, 3},
+		},
+		{
+			name: "nested interior pages",
+			data: sqliteFile(interiorPage(100, 4, 2), interiorPage(0, 3, 5), leafPage(3), leafPage(4), leafPage(1, 2)),
+			want: []int64{1, 2, 3, 4},
+		},
+		{
+			name:    "right pointer back to its own page",
+			data:    sqliteFile(interiorPage(100, 1)),
+			wantErr: true,
+		},
+		{
+			name:    "child pointing back at an ancestor",
+			data:    sqliteFile(interiorPage(100, 2), interiorPage(0, 1)),
+			wantErr: true,
+		},
+		{
+			name:    "child out of range",
+			data:    sqliteFile(interiorPage(100, 9)),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, err := openSQLite(tt.data)
+			if err != nil {
+				t.Fatalf("openSQLite() error = %v", err)
+			}
+			got := []int64{}
+			err = db.scanTable(1, func(rowid int64, values []interface{}) {
+				got = append(got, rowid)
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("scanTable() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("scanTable() rowids = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+// Package langdetect guesses the language of short texts such as highlights and notes, so
+// they can be indexed with the matching Postgres text search configuration. It counts
+// each language's most common function words, which is enough to tell apart a sentence
+// of French from one of German, and falls back to the script for Russian. Texts that are
+// too short or too mixed to call are left undetected.
+package langdetect
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Languages maps the supported ISO 639-1 codes to their Postgres text search
+// configurations. The annotation_search_config SQL function mirrors it.
+var Languages = map[string]string{
+	"da": "danish",
+	"de": "german",
+	"en": "english",
+	"es": "spanish",
+	"fi": "finnish",
+	"fr": "french",
+	"hu": "hungarian",
+	"it": "italian",
+	"nl": "dutch",
+	"no": "norwegian",
+	"pt": "portuguese",
+	"ro": "romanian",
+	"ru": "russian",
+	"sv": "swedish",
+	"tr": "turkish",
+}
+
+// Detection needs minWords words, minHits of them function words, and the best language
+// to have at least minLead times the hits of the runner-up
+const (
+	minWords = 4
+	minHits  = 2
+	minLead  = 1.5
+)
+
+var functionWords = map[string]map[string]bool{
+	"da": toSet("og i at det er en til på med af for ikke der som de han den var jeg har om men et sig hun fra"),
+	"de": toSet("der die das und ist nicht ein eine zu den von mit sich des auf für im dem auch es als wie wir ich sie aber oder"),
+	"en": toSet("the and of to is in that it for was with as be on not this are but by you have from they which"),
+	"es": toSet("el la los las de que y en un una es por con para no se del al lo como pero su más sus fue"),
+	"fi": toSet("ja on ei se että hän oli ovat mutta kun niin tai ole olla jos vain myös joka mitä tämä kuin"),
+	"fr": toSet("le la les de des et est un une du que qui dans pour pas ne sur au aux ce cette il elle nous vous avec mais"),
+	"hu": toSet("a az és hogy nem egy is van meg de ez csak mint már volt ki még el vagy azt"),
+	"it": toSet("il lo la gli le di che e è un una per non con del della sono si ma come anche nel alla"),
+	"nl": toSet("de het een en van is dat niet op te zijn met voor die aan er maar ook als bij wat"),
+	"no": toSet("og i det er en til på som av for ikke med har de jeg var men seg hun ble fra kan"),
+	"pt": toSet("o a os as de que e do da em um uma é para não com por mais se dos das mas como foi"),
+	"ro": toSet("și în de la cu pe nu este o un care ce din să mai sau dar pentru fost sunt"),
+	"sv": toSet("och i att det är en som på av för med inte den till har de jag var men om sig hon från"),
+	"tr": toSet("ve bir bu da de için ile ne ama çok daha gibi olarak var ben sen o değil mi kadar"),
+}
+
+func toSet(words string) map[string]bool {
+	set := map[string]bool{}
+	for _, word := range strings.Fields(words) {
+		set[word] = true
+	}
+	return set
+}
+
+// Detect returns the ISO 639-1 code of text's language, or "" when it can't tell
+func Detect(text string) string {
+	letters, cyrillic := 0, 0
+	for _, r := range text {
+		if unicode.IsLetter(r) {
+			letters++
+			if unicode.Is(unicode.Cyrillic, r) {
+				cyrillic++
+			}
+		}
+	}
+	if letters > 0 && cyrillic*2 > letters {
+		return "ru"
+	}
+
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\''
+	})
+	if len(words) < minWords {
+		return ""
+	}
+
+	hits := map[string]int{}
+	for _, word := range words {
+		word = strings.Trim(word, "'")
+		for code, set := range functionWords {
+			if set[word] {
+				hits[code]++
+			}
+		}
+	}
+
+	best, bestHits, runnerUp := "", 0, 0
+	for code, count := range hits {
+		if count > bestHits || (count == bestHits && code < best) {
+			best, bestHits, runnerUp = code, count, bestHits
+		} else if count > runnerUp {
+			runnerUp = count
+		}
+	}
+	if bestHits < minHits || float64(bestHits) < minLead*float64(runnerUp) {
+		return ""
+	}
+	return best
+}
+
+// Normalize returns the supported language code for code or a configuration name such as
+// "french", and whether it is supported
+func Normalize(code string) (string, bool) {
+	code = strings.ToLower(strings.TrimSpace(code))
+	if _, ok := Languages[code]; ok {
+		return code, true
+	}
+	for iso, config := range Languages {
+		if config == code {
+			return iso, true
+		}
+	}
+	return "", false
+}
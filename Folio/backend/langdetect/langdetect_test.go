@@ -0,0 +1,69 @@
+package langdetect
+
+import "testing"
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"english", "It is a truth universally acknowledged that a single man in possession of a good fortune must be in want of a wife.", "en"},
+		{"french", "On ne voit bien qu'avec le cœur. L'essentiel est invisible pour les yeux, et il faut le dire aux enfants qui ne le savent pas.", "fr"},
+		{"german", "Jemand musste Josef K. verleumdet haben, denn ohne dass er etwas Böses getan hätte, wurde er eines Morgens verhaftet und es war nicht klar warum.", "de"},
+		{"spanish", "En un lugar de la Mancha, de cuyo nombre no quiero acordarme, no ha mucho tiempo que vivía un hidalgo de los de lanza en astillero.", "es"},
+		{"italian", "Nel mezzo del cammin di nostra vita mi ritrovai per una selva oscura, ché la diritta via era smarrita e non si vedeva.", "it"},
+		{"dutch", "Het is niet de bedoeling dat je een boek leest zonder er iets van te leren, maar het is ook niet erg als dat een keer gebeurt.", "nl"},
+		{"russian by script", "Все счастливые семьи похожи друг на друга.", "ru"},
+		{"too short", "Call me Ishmael", ""},
+		{"no function words", "Stately, plump Buck Mulligan ascended", ""},
+		{"mixed evenly", "the and le et", ""},
+		{"empty", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Detect(tt.text); got != tt.want {
+				t.Errorf("Detect(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+		ok    bool
+	}{
+		{"fr", "fr", true},
+		{" DE ", "de", true},
+		{"french", "fr", true},
+		{"Portuguese", "pt", true},
+		{"english", "en", true},
+		{"xx", "", false},
+		{"klingon", "", false},
+		{"", "", false},
+	}
+
+	for _, tt := range tests {
+		got, ok := Normalize(tt.input)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("Normalize(%q) = (%q, %v), want (%q, %v)", tt.input, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestFunctionWordsCoverLanguages(t *testing.T) {
+	// Russian is detected by script; every other language needs function words
+	for code := range Languages {
+		if _, ok := functionWords[code]; !ok && code != "ru" {
+			t.Errorf("language %q has no function words", code)
+		}
+	}
+	for code := range functionWords {
+		if _, ok := Languages[code]; !ok {
+			t.Errorf("function words for unsupported language %q", code)
+		}
+	}
+}
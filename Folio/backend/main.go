@@ -102,6 +102,10 @@ func setupRoutes(e *echo.Echo, app *App) {
 	guestHandler := &handlers.GuestHandler{DB: app.DB}
 	listHandler := &handlers.ListHandler{DB: app.DB}
 	annotationHandler := &handlers.AnnotationHandler{DB: app.DB}
+	challengeHandler := &handlers.ChallengeHandler{DB: app.DB}
+	shelfHandler := &handlers.ShelfHandler{DB: app.DB}
+	commentHandler := &handlers.CommentHandler{DB: app.DB}
+	reactionHandler := &handlers.ReactionHandler{DB: app.DB}
 
 	// API routes
 	api := e.Group("/api")
@@ -115,16 +119,23 @@ func setupRoutes(e *echo.Echo, app *App) {
 	api.GET("/auth/google/convert", authHandler.ConvertGuestToUser)
 	api.POST("/auth/guest", guestHandler.CreateGuestUser)
 	api.GET("/search", bookHandler.SearchBooks)
-	api.GET("/books/:id", bookHandler.GetBook)
+	api.GET("/books/:id", bookHandler.GetBook, auth.OptionalJWTMiddleware)
 	api.GET("/books/:id/reviews", bookHandler.GetBookReviews)
 	api.GET("/books/:id/stats", bookHandler.GetBookStats)
 	api.GET("/books/:id/lists", bookHandler.GetBookLists)
+	api.GET("/books/:id/highlights", annotationHandler.GetBookHighlights, auth.OptionalJWTMiddleware)
+	api.GET("/books/:id/highlights/popular", annotationHandler.GetPopularHighlights, auth.OptionalJWTMiddleware)
 	api.GET("/discover", discoverHandler.GetRecommendations)
 	api.GET("/discover/lists", discoverHandler.GetTrendingLists)
+	api.GET("/lists", listHandler.BrowseLists)
+	api.GET("/lists/tags", listHandler.GetListTags)
 	api.GET("/lists/popular", listHandler.GetPopularLists)
-	api.GET("/users/popular", socialHandler.GetPopularUsers)
-	api.GET("/users/:username", socialHandler.GetUserProfile)
+	api.GET("/users/popular", socialHandler.GetPopularUsers, auth.OptionalJWTMiddleware)
+	api.GET("/users/:username", socialHandler.GetUserProfile, auth.OptionalJWTMiddleware)
 	api.GET("/users/:username/logs", logHandler.GetUserLogs, auth.OptionalJWTMiddleware)
+	api.GET("/users/:username/badges", challengeHandler.GetUserBadges)
+	api.GET("/challenges", challengeHandler.GetChallenges, auth.OptionalJWTMiddleware)
+	api.GET("/challenges/:id", challengeHandler.GetChallenge, auth.OptionalJWTMiddleware)
 
 	// Protected endpoints
 	protected := api.Group("", auth.JWTMiddleware)
@@ -140,12 +151,21 @@ func setupRoutes(e *echo.Echo, app *App) {
 	
 	// Like and comment endpoints
 	protected.POST("/logs/:id/like", socialHandler.ToggleLike)
-	protected.GET("/logs/:id/comments", socialHandler.GetLogComments)
-	protected.POST("/logs/:id/comments", socialHandler.CreateComment)
-	protected.DELETE("/comments/:commentId", socialHandler.DeleteComment)
+	protected.GET("/logs/:id/comments", commentHandler.GetLogComments)
+	protected.POST("/logs/:id/comments", commentHandler.CreateLogComment)
+	protected.PUT("/logs/:id/comments/:commentId", commentHandler.UpdateLogComment)
+	protected.DELETE("/logs/:id/comments/:commentId", commentHandler.DeleteLogComment)
+	protected.DELETE("/comments/:commentId", commentHandler.DeleteLogComment)
+
+	// Reactions on logs, lists, comments and annotations; :type is log, list, log_comment, list_comment or annotation
+	protected.GET("/reactions/:type/:id", reactionHandler.GetReactions)
+	protected.GET("/reactions/:type/:id/users", reactionHandler.GetReactionUsers)
+	protected.PUT("/reactions/:type/:id/:reaction", reactionHandler.AddReaction)
+	protected.DELETE("/reactions/:type/:id/:reaction", reactionHandler.RemoveReaction)
 	
 	// List endpoints
 	protected.POST("/lists", listHandler.CreateList)
+	protected.POST("/lists/smart/preview", listHandler.PreviewSmartList)
 	protected.GET("/me/lists", listHandler.GetMyLists)
 	protected.GET("/users/:username/lists", listHandler.GetUserLists)
 	protected.GET("/lists/:id", listHandler.GetList)
@@ -155,26 +175,80 @@ func setupRoutes(e *echo.Echo, app *App) {
 	protected.PUT("/lists/:id/items/:itemId", listHandler.UpdateListItem)
 	protected.PUT("/lists/:id/items/:itemId/order", listHandler.UpdateListItemOrder)
 	protected.PUT("/lists/:id/items/order", listHandler.ReorderListItems)
+	protected.POST("/lists/:id/items/batch", listHandler.BatchListItems)
+	protected.POST("/lists/:id/items/transfer", listHandler.TransferListItems)
 	protected.DELETE("/lists/:id/items/:itemId", listHandler.RemoveBookFromList)
+	protected.GET("/lists/:id/history", listHandler.GetListHistory)
+	protected.POST("/lists/:id/history/:revisionId/restore", listHandler.RestoreListRevision)
 	
 	// List social features
 	protected.POST("/lists/:id/like", listHandler.LikeList)
 	protected.DELETE("/lists/:id/like", listHandler.UnlikeList)
-	protected.GET("/lists/:id/comments", listHandler.GetListComments)
-	protected.POST("/lists/:id/comments", listHandler.AddListComment)
+	protected.POST("/lists/:id/subscribe", listHandler.SubscribeToList)
+	protected.DELETE("/lists/:id/subscribe", listHandler.UnsubscribeFromList)
+	protected.POST("/lists/:id/fork", listHandler.ForkList)
+	protected.POST("/lists/:id/snapshot", listHandler.SnapshotSmartList)
+	protected.GET("/lists/:id/forks", listHandler.GetListForks)
+	protected.GET("/lists/:id/comments", commentHandler.GetListComments)
+	protected.POST("/lists/:id/comments", commentHandler.CreateListComment)
+	protected.PUT("/lists/:id/comments/:commentId", commentHandler.UpdateListComment)
+	protected.DELETE("/lists/:id/comments/:commentId", commentHandler.DeleteListComment)
+
+	// List collaboration
+	protected.GET("/me/shared-lists", listHandler.GetSharedLists)
+	protected.GET("/me/subscribed-lists", listHandler.GetSubscribedLists)
+	protected.GET("/me/list-invites", listHandler.GetMyListInvites)
+	protected.GET("/lists/:id/collaborators", listHandler.GetListCollaborators)
+	protected.POST("/lists/:id/collaborators", listHandler.InviteCollaborator)
+	protected.POST("/lists/:id/collaborators/accept", listHandler.AcceptListInvite)
+	protected.PUT("/lists/:id/collaborators/:userId", listHandler.UpdateCollaborator)
+	protected.DELETE("/lists/:id/collaborators/:userId", listHandler.RemoveCollaborator)
 	
 	// Annotation endpoints
 	protected.POST("/annotations/capture", annotationHandler.CaptureAnnotation)
+	protected.POST("/annotations/import/:format", annotationHandler.ImportAnnotations)
+	protected.GET("/annotations/imports", annotationHandler.GetAnnotationImports)
+	protected.GET("/annotations/imports/:id", annotationHandler.GetAnnotationImport)
+	protected.GET("/annotations/export", annotationHandler.ExportAnnotations)
 	protected.GET("/users/me/recents", annotationHandler.GetUserRecents)
 	protected.GET("/books/:id/annotations", annotationHandler.GetBookAnnotations)
 	protected.GET("/annotations/unassociated", annotationHandler.GetUnassociatedAnnotations)
+	protected.POST("/annotations/assign", annotationHandler.AssignAnnotations)
 	protected.PATCH("/annotations/:id", annotationHandler.UpdateAnnotation)
 	protected.DELETE("/annotations/:id", annotationHandler.DeleteAnnotation)
+	protected.PUT("/annotations/:id/parent", annotationHandler.SetAnnotationParent)
+	protected.DELETE("/annotations/:id/parent", annotationHandler.RemoveAnnotationParent)
 	protected.GET("/annotations/search", annotationHandler.SearchAnnotations)
+	protected.POST("/annotations/suggest-tags", annotationHandler.SuggestAnnotationTags)
+	protected.GET("/annotations/:id/related", annotationHandler.GetRelatedAnnotations)
+	protected.GET("/annotations/:id/book-suggestions", annotationHandler.GetAnnotationBookSuggestions)
 	
 	// Theme and thread endpoints for the synthesizer
 	protected.GET("/users/me/themes", annotationHandler.GetUserThemes)
 	protected.GET("/annotations/thread", annotationHandler.GetAnnotationThread)
+	protected.POST("/annotations/tags/rename", annotationHandler.RenameTag)
+	protected.POST("/annotations/tags/merge", annotationHandler.MergeTags)
+	protected.PUT("/annotations/tags/parent", annotationHandler.NestTag)
+
+	// Spaced-repetition review of annotations
+	protected.GET("/me/review-queue", annotationHandler.GetReviewQueue)
+	protected.GET("/me/review-settings", annotationHandler.GetReviewSettings)
+	protected.PUT("/me/review-settings", annotationHandler.UpdateReviewSettings)
+	protected.POST("/annotations/:id/review", annotationHandler.ReviewAnnotation)
+
+	// Challenge and streak endpoints
+	protected.POST("/challenges", challengeHandler.CreateChallenge)
+	protected.POST("/challenges/:id/join", challengeHandler.JoinChallenge)
+	protected.DELETE("/challenges/:id/join", challengeHandler.LeaveChallenge)
+	protected.GET("/me/streak", challengeHandler.GetMyStreak)
+
+	// Shelf endpoints
+	protected.GET("/me/shelves", shelfHandler.GetMyShelves)
+	protected.POST("/shelves", shelfHandler.CreateShelf)
+	protected.PUT("/shelves/:id", shelfHandler.UpdateShelf)
+	protected.DELETE("/shelves/:id", shelfHandler.DeleteShelf)
+	protected.POST("/shelves/:id/logs", shelfHandler.AddLogToShelf)
+	protected.DELETE("/shelves/:id/logs/:logId", shelfHandler.RemoveLogFromShelf)
 }
 
 // healthCheck performs a database query and returns system status
@@ -0,0 +1,353 @@
+// Package markdown renders the safe markdown subset allowed in reviews.
+//
+// Supported syntax:
+//   - **bold**, *italic* and _italic_
+//   - > blockquotes
+//   - "- item" / "* item" unordered lists and "1. item" ordered lists
+//   - [text](https://link) links (http, https, mailto and site-relative only)
+//   - ||spoiler|| inline spoilers, rendered as <span class="spoiler">
+//   - @username mentions, linked only when the username is known
+//
+// All other input is HTML-escaped, so the output only ever contains the tags
+// emitted here and is safe to embed without further sanitization.
+package markdown
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+// Result is the rendered HTML along with what was found while rendering
+type Result struct {
+	HTML        string
+	Mentions    []string
+	HasSpoilers bool
+}
+
+// maxQuoteDepth bounds blockquote nesting so hostile input can't recurse forever
+const maxQuoteDepth = 5
+
+var (
+	mentionPattern     = regexp.MustCompile(`(?:^|[^A-Za-z0-9_.])@([A-Za-z0-9_][A-Za-z0-9_.]{0,49})`)
+	orderedItemPattern = regexp.MustCompile(`^\d{1,9}[.)]\s+`)
+)
+
+// ExtractMentions returns the distinct usernames mentioned in src, in order of appearance
+func ExtractMentions(src string) []string {
+	seen := make(map[string]bool)
+	mentions := []string{}
+	for _, match := range mentionPattern.FindAllStringSubmatch(src, -1) {
+		username := strings.TrimRight(match[1], ".")
+		if username == "" || seen[username] {
+			continue
+		}
+		seen[username] = true
+		mentions = append(mentions, username)
+	}
+	return mentions
+}
+
+// Render converts markdown to sanitized HTML. known holds the usernames that
+// resolve to real users; other @mentions are left as plain text.
+func Render(src string, known map[string]bool) Result {
+	r := &renderer{known: known, mentioned: make(map[string]bool)}
+	lines := strings.Split(normalizeNewlines(src), "\n")
+	out := r.blocks(lines, 0)
+	return Result{HTML: out, Mentions: r.mentions, HasSpoilers: r.spoilers}
+}
+
+// RenderText renders a plain-text review: HTML is escaped, blank lines become
+// paragraphs and known @mentions are linked. No other markup is interpreted.
+func RenderText(src string, known map[string]bool) Result {
+	r := &renderer{known: known, mentioned: make(map[string]bool), plain: true}
+
+	var b strings.Builder
+	for _, para := range strings.Split(normalizeNewlines(src), "\n\n") {
+		para = strings.TrimSpace(para)
+		if para == "" {
+			continue
+		}
+		b.WriteString("<p>")
+		b.WriteString(strings.ReplaceAll(r.inline(para, false), "\n", "<br>"))
+		b.WriteString("</p>")
+	}
+
+	return Result{HTML: b.String(), Mentions: r.mentions}
+}
+
+type renderer struct {
+	known     map[string]bool
+	mentioned map[string]bool
+	mentions  []string
+	spoilers  bool
+	plain     bool
+}
+
+func normalizeNewlines(s string) string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	return strings.ReplaceAll(s, "\r", "\n")
+}
+
+// blocks renders paragraphs, blockquotes and lists
+func (r *renderer) blocks(lines []string, depth int) string {
+	var b strings.Builder
+	paragraph := []string{}
+
+	flush := func() {
+		if len(paragraph) == 0 {
+			return
+		}
+		b.WriteString("<p>")
+		b.WriteString(r.inline(strings.Join(paragraph, "\n"), false))
+		b.WriteString("</p>")
+		paragraph = paragraph[:0]
+	}
+
+	for i := 0; i < len(lines); {
+		line := strings.TrimRight(lines[i], " \t")
+		trimmed := strings.TrimLeft(line, " \t")
+
+		switch {
+		case trimmed == "":
+			flush()
+			i++
+
+		case strings.HasPrefix(trimmed, ">"):
+			flush()
+			quoted := []string{}
+			for i < len(lines) {
+				t := strings.TrimLeft(lines[i], " \t")
+				if !strings.HasPrefix(t, ">") {
+					break
+				}
+				t = strings.TrimPrefix(t, ">")
+				quoted = append(quoted, strings.TrimPrefix(t, " "))
+				i++
+			}
+			b.WriteString("<blockquote>")
+			if depth < maxQuoteDepth {
+				b.WriteString(r.blocks(quoted, depth+1))
+			} else {
+				b.WriteString("<p>")
+				b.WriteString(r.inline(strings.Join(quoted, "\n"), false))
+				b.WriteString("</p>")
+			}
+			b.WriteString("</blockquote>")
+
+		case isUnorderedItem(trimmed):
+			flush()
+			b.WriteString("<ul>")
+			for i < len(lines) {
+				t := strings.TrimLeft(lines[i], " \t")
+				if !isUnorderedItem(t) {
+					break
+				}
+				b.WriteString("<li>")
+				b.WriteString(r.inline(strings.TrimSpace(t[2:]), false))
+				b.WriteString("</li>")
+				i++
+			}
+			b.WriteString("</ul>")
+
+		case orderedItemPattern.MatchString(trimmed):
+			flush()
+			b.WriteString("<ol>")
+			for i < len(lines) {
+				t := strings.TrimLeft(lines[i], " \t")
+				loc := orderedItemPattern.FindStringIndex(t)
+				if loc == nil {
+					break
+				}
+				b.WriteString("<li>")
+				b.WriteString(r.inline(strings.TrimSpace(t[loc[1]:]), false))
+				b.WriteString("</li>")
+				i++
+			}
+			b.WriteString("</ol>")
+
+		default:
+			paragraph = append(paragraph, trimmed)
+			i++
+		}
+	}
+	flush()
+
+	return b.String()
+}
+
+func isUnorderedItem(line string) bool {
+	return len(line) >= 2 && (line[0] == '-' || line[0] == '*' || line[0] == '+') && (line[1] == ' ' || line[1] == '\t')
+}
+
+// inline renders emphasis, links, spoilers and mentions within a block.
+// inLink suppresses nested links.
+func (r *renderer) inline(s string, inLink bool) string {
+	var b strings.Builder
+
+	for i := 0; i < len(s); {
+		ch := s[i]
+
+		if r.plain {
+			if ch == '@' && mentionStart(s, i) {
+				if n := r.mention(&b, s, i, inLink); n > 0 {
+					i += n
+					continue
+				}
+			}
+			b.WriteString(html.EscapeString(s[i : i+1]))
+			i++
+			continue
+		}
+
+		switch {
+		case ch == '\\' && i+1 < len(s) && strings.IndexByte("\\*_[]()|@>-#`", s[i+1]) >= 0:
+			b.WriteString(html.EscapeString(s[i+1 : i+2]))
+			i += 2
+			continue
+
+		case strings.HasPrefix(s[i:], "||"):
+			if end := strings.Index(s[i+2:], "||"); end > 0 {
+				r.spoilers = true
+				b.WriteString(`<span class="spoiler">`)
+				b.WriteString(r.inline(s[i+2:i+2+end], inLink))
+				b.WriteString("</span>")
+				i += end + 4
+				continue
+			}
+
+		case strings.HasPrefix(s[i:], "**"):
+			if end := strings.Index(s[i+2:], "**"); end > 0 {
+				b.WriteString("<strong>")
+				b.WriteString(r.inline(s[i+2:i+2+end], inLink))
+				b.WriteString("</strong>")
+				i += end + 4
+				continue
+			}
+
+		case (ch == '*' || ch == '_') && emphasisStart(s, i):
+			if end := emphasisEnd(s, i+1, ch); end > i+1 {
+				b.WriteString("<em>")
+				b.WriteString(r.inline(s[i+1:end], inLink))
+				b.WriteString("</em>")
+				i = end + 1
+				continue
+			}
+
+		case ch == '[' && !inLink:
+			if n := r.link(&b, s, i); n > 0 {
+				i += n
+				continue
+			}
+
+		case ch == '@' && mentionStart(s, i):
+			if n := r.mention(&b, s, i, inLink); n > 0 {
+				i += n
+				continue
+			}
+		}
+
+		b.WriteString(html.EscapeString(s[i : i+1]))
+		i++
+	}
+
+	return b.String()
+}
+
+// emphasisStart reports whether a single * or _ at i can open emphasis
+func emphasisStart(s string, i int) bool {
+	if i+1 >= len(s) || s[i+1] == ' ' || s[i+1] == s[i] {
+		return false
+	}
+	// Underscores inside words (snake_case) are literal
+	return s[i] == '*' || i == 0 || !isWordChar(s[i-1])
+}
+
+// emphasisEnd finds the closing delimiter for emphasis opened before start
+func emphasisEnd(s string, start int, delim byte) int {
+	for j := start; j < len(s); j++ {
+		if s[j] != delim || s[j-1] == ' ' {
+			continue
+		}
+		if delim == '_' && j+1 < len(s) && isWordChar(s[j+1]) {
+			continue
+		}
+		return j
+	}
+	return -1
+}
+
+// link renders [text](url) at i and returns the bytes consumed, or 0 if it isn't a safe link
+func (r *renderer) link(b *strings.Builder, s string, i int) int {
+	closeText := strings.Index(s[i:], "](")
+	if closeText <= 1 {
+		return 0
+	}
+	closeURL := strings.IndexByte(s[i+closeText+2:], ')')
+	if closeURL < 0 {
+		return 0
+	}
+
+	text := s[i+1 : i+closeText]
+	href := strings.TrimSpace(s[i+closeText+2 : i+closeText+2+closeURL])
+	if !safeURL(href) {
+		return 0
+	}
+
+	b.WriteString(`<a href="`)
+	b.WriteString(html.EscapeString(href))
+	b.WriteString(`" rel="nofollow noopener noreferrer" target="_blank">`)
+	b.WriteString(r.inline(text, true))
+	b.WriteString("</a>")
+
+	return closeText + 2 + closeURL + 1
+}
+
+// safeURL allows only http(s), mailto and site-relative links
+func safeURL(href string) bool {
+	if href == "" || strings.ContainsAny(href, " \t\n\"'<>") {
+		return false
+	}
+	lower := strings.ToLower(href)
+	switch {
+	case strings.HasPrefix(lower, "https://"), strings.HasPrefix(lower, "http://"), strings.HasPrefix(lower, "mailto:"):
+		return true
+	case strings.HasPrefix(href, "/") && !strings.HasPrefix(href, "//"):
+		return true
+	}
+	return false
+}
+
+func mentionStart(s string, i int) bool {
+	return i == 0 || !(isWordChar(s[i-1]) || s[i-1] == '.')
+}
+
+// mention renders @username at i when the user is known and returns the bytes consumed
+func (r *renderer) mention(b *strings.Builder, s string, i int, inLink bool) int {
+	j := i + 1
+	for j < len(s) && j-i <= 50 && (isWordChar(s[j]) || s[j] == '.') {
+		j++
+	}
+	username := strings.TrimRight(s[i+1:j], ".")
+	if username == "" || !r.known[username] {
+		return 0
+	}
+
+	if !r.mentioned[username] {
+		r.mentioned[username] = true
+		r.mentions = append(r.mentions, username)
+	}
+
+	escaped := html.EscapeString(username)
+	if inLink {
+		b.WriteString("@" + escaped)
+	} else {
+		b.WriteString(`<a href="/users/` + escaped + `" class="mention" data-username="` + escaped + `">@` + escaped + `</a>`)
+	}
+
+	return len(username) + 1
+}
+
+func isWordChar(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
@@ -0,0 +1,188 @@
+package markdown
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRenderEscaping(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"plain paragraph", "Hello world", "<p>Hello world</p>"},
+		{"script tag", "<script>alert(1)</script>", "<p>&lt;script&gt;alert(1)&lt;/script&gt;</p>"},
+		{"attribute quotes", `a "quoted" & 'single'`, "<p>a &#34;quoted&#34; &amp; &#39;single&#39;</p>"},
+		{"html inside bold", "**<b>x</b>**", "<p><strong>&lt;b&gt;x&lt;/b&gt;</strong></p>"},
+		{"backslash escape", `\*not italic\*`, "<p>*not italic*</p>"},
+		{"snake_case stays literal", "snake_case_name", "<p>snake_case_name</p>"},
+		{"emphasis", "*a* and _b_", "<p><em>a</em> and <em>b</em></p>"},
+		{"safe link", "[site](https://example.com)", `<p><a href="https://example.com" rel="nofollow noopener noreferrer" target="_blank">site</a></p>`},
+		{"relative link", "[home](/books)", `<p><a href="/books" rel="nofollow noopener noreferrer" target="_blank">home</a></p>`},
+		{"javascript link", "[x](javascript:alert(1))", "<p>[x](javascript:alert(1))</p>"},
+		{"protocol-relative link", "[x](//evil.com)", "<p>[x](//evil.com)</p>"},
+		{"quoted href", `[x](https://a.com/"onmouseover=")`, "<p>[x](https://a.com/&#34;onmouseover=&#34;)</p>"},
+		{"blockquote", "> quoted\n> text", "<blockquote><p>quoted\ntext</p></blockquote>"},
+		{"unordered list", "- one\n- <two>", "<ul><li>one</li><li>&lt;two&gt;</li></ul>"},
+		{"ordered list", "1. one\n2. two", "<ol><li>one</li><li>two</li></ol>"},
+		{"paragraphs", "one\n\ntwo", "<p>one</p><p>two</p>"},
+		{"windows newlines", "one\r\n\r\ntwo", "<p>one</p><p>two</p>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Render(tt.src, nil)
+			if got.HTML != tt.want {
+				t.Errorf("Render(%q).HTML = %q, want %q", tt.src, got.HTML, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderNestedQuotesAreBounded(t *testing.T) {
+	src := ">>>>>>>>>> deep"
+	got := Render(src, nil).HTML
+	want := "<blockquote><blockquote><blockquote><blockquote><blockquote><blockquote>" +
+		"<p>&gt;&gt;&gt;&gt; deep</p>" +
+		"</blockquote></blockquote></blockquote></blockquote></blockquote></blockquote>"
+	if got != want {
+		t.Errorf("Render(%q).HTML = %q, want %q", src, got, want)
+	}
+}
+
+func TestRenderSpoilers(t *testing.T) {
+	tests := []struct {
+		name        string
+		src         string
+		want        string
+		hasSpoilers bool
+	}{
+		{"inline spoiler", "He ||dies|| at the end", `<p>He <span class="spoiler">dies</span> at the end</p>`, true},
+		{"spoiler with markup", "||**big** <reveal>||", `<p><span class="spoiler"><strong>big</strong> &lt;reveal&gt;</span></p>`, true},
+		{"unclosed spoiler", "||not closed", "<p>||not closed</p>", false},
+		{"empty spoiler", "||||", "<p>||||</p>", false},
+		{"escaped bars", `\|\|x\|\|`, "<p>||x||</p>", false},
+		{"no spoiler", "nothing hidden", "<p>nothing hidden</p>", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Render(tt.src, nil)
+			if got.HTML != tt.want {
+				t.Errorf("Render(%q).HTML = %q, want %q", tt.src, got.HTML, tt.want)
+			}
+			if got.HasSpoilers != tt.hasSpoilers {
+				t.Errorf("Render(%q).HasSpoilers = %v, want %v", tt.src, got.HasSpoilers, tt.hasSpoilers)
+			}
+		})
+	}
+}
+
+func TestRenderMentions(t *testing.T) {
+	known := map[string]bool{"alice": true, "bob.smith": true}
+
+	tests := []struct {
+		name     string
+		src      string
+		want     string
+		mentions []string
+	}{
+		{
+			"known user",
+			"thanks @alice",
+			`<p>thanks <a href="/users/alice" class="mention" data-username="alice">@alice</a></p>`,
+			[]string{"alice"},
+		},
+		{
+			"unknown user",
+			"thanks @mallory",
+			"<p>thanks @mallory</p>",
+			nil,
+		},
+		{
+			"trailing period",
+			"ask @bob.smith.",
+			`<p>ask <a href="/users/bob.smith" class="mention" data-username="bob.smith">@bob.smith</a>.</p>`,
+			[]string{"bob.smith"},
+		},
+		{
+			"email address",
+			"mail me at carol@alice",
+			"<p>mail me at carol@alice</p>",
+			nil,
+		},
+		{
+			"inside link",
+			"[by @alice](https://example.com)",
+			`<p><a href="https://example.com" rel="nofollow noopener noreferrer" target="_blank">by @alice</a></p>`,
+			[]string{"alice"},
+		},
+		{
+			"repeated mention",
+			"@alice and @alice",
+			`<p><a href="/users/alice" class="mention" data-username="alice">@alice</a> and <a href="/users/alice" class="mention" data-username="alice">@alice</a></p>`,
+			[]string{"alice"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Render(tt.src, known)
+			if got.HTML != tt.want {
+				t.Errorf("Render(%q).HTML = %q, want %q", tt.src, got.HTML, tt.want)
+			}
+			if !reflect.DeepEqual(got.Mentions, tt.mentions) {
+				t.Errorf("Render(%q).Mentions = %v, want %v", tt.src, got.Mentions, tt.mentions)
+			}
+		})
+	}
+}
+
+func TestRenderText(t *testing.T) {
+	known := map[string]bool{"alice": true}
+
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"markup is literal", "**not bold** ||not hidden||", "<p>**not bold** ||not hidden||</p>"},
+		{"html is escaped", "<i>hi</i>", "<p>&lt;i&gt;hi&lt;/i&gt;</p>"},
+		{"line breaks", "one\ntwo\n\nthree", "<p>one<br>two</p><p>three</p>"},
+		{"mentions are linked", "hi @alice", `<p>hi <a href="/users/alice" class="mention" data-username="alice">@alice</a></p>`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := RenderText(tt.src, known)
+			if got.HTML != tt.want {
+				t.Errorf("RenderText(%q).HTML = %q, want %q", tt.src, got.HTML, tt.want)
+			}
+			if got.HasSpoilers {
+				t.Errorf("RenderText(%q).HasSpoilers = true, want false", tt.src)
+			}
+		})
+	}
+}
+
+func TestExtractMentions(t *testing.T) {
+	tests := []struct {
+		src  string
+		want []string
+	}{
+		{"", []string{}},
+		{"@alice", []string{"alice"}},
+		{"@alice, @bob and @alice again", []string{"alice", "bob"}},
+		{"ping @bob.smith.", []string{"bob.smith"}},
+		{"carol@example.com", []string{}},
+		{"(@dave)", []string{"dave"}},
+	}
+
+	for _, tt := range tests {
+		got := ExtractMentions(tt.src)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ExtractMentions(%q) = %v, want %v", tt.src, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package review
+
+import (
+	"folio/api/similarity"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Cloze cards blank out the most distinctive words of a highlight, one per wordsPerBlank
+// words and at most maxBlanks, and need at least minClozeWords words to be worth it
+const (
+	minClozeWords = 6
+	wordsPerBlank = 12
+	maxBlanks     = 3
+	// Blank replaces each hidden word in the prompt
+	Blank = "[...]"
+)
+
+// Cloze is a highlight with some of its words hidden, to be recalled
+type Cloze struct {
+	Prompt  string   `json:"prompt"`
+	Answers []string `json:"answers"`
+}
+
+// MakeCloze turns text into a cloze card, or returns nil when it is too short. Longer words
+// that aren't stop words are hidden first, since they tend to carry the meaning; blanks
+// are never next to each other.
+func MakeCloze(text string) *Cloze {
+	words := strings.Fields(text)
+	if len(words) < minClozeWords {
+		return nil
+	}
+
+	type candidate struct {
+		index int
+		core  string
+	}
+	candidates := []candidate{}
+	for i, word := range words {
+		core := strings.TrimFunc(word, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+		// Terms drops stop words and very short words
+		if len(similarity.Terms(core)) != 1 {
+			continue
+		}
+		candidates = append(candidates, candidate{i, core})
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return len([]rune(candidates[i].core)) > len([]rune(candidates[j].core))
+	})
+
+	blanks := len(words) / wordsPerBlank
+	if blanks < 1 {
+		blanks = 1
+	}
+	if blanks > maxBlanks {
+		blanks = maxBlanks
+	}
+
+	hidden := map[int]string{}
+	for _, c := range candidates {
+		if len(hidden) == blanks {
+			break
+		}
+		if _, ok := hidden[c.index-1]; ok {
+			continue
+		}
+		if _, ok := hidden[c.index+1]; ok {
+			continue
+		}
+		hidden[c.index] = c.core
+	}
+	if len(hidden) == 0 {
+		return nil
+	}
+
+	cloze := &Cloze{Answers: []string{}}
+	prompt := make([]string, len(words))
+	for i, word := range words {
+		core, ok := hidden[i]
+		if !ok {
+			prompt[i] = word
+			continue
+		}
+		// Keep the punctuation around the hidden word
+		prompt[i] = strings.Replace(word, core, Blank, 1)
+		cloze.Answers = append(cloze.Answers, core)
+	}
+	cloze.Prompt = strings.Join(prompt, " ")
+	return cloze
+}
@@ -0,0 +1,130 @@
+package review
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSchedule(t *testing.T) {
+	day := time.Date(2024, time.March, 10, 18, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		card  Card
+		grade int
+		want  Card
+	}{
+		{
+			name:  "first review",
+			card:  NewCard(day),
+			grade: 4,
+			want:  Card{Ease: 2.5, IntervalDays: 1, Repetitions: 1, DueOn: Day(day).AddDate(0, 0, 1)},
+		},
+		{
+			name:  "second review",
+			card:  Card{Ease: 2.5, IntervalDays: 1, Repetitions: 1},
+			grade: 4,
+			want:  Card{Ease: 2.5, IntervalDays: 6, Repetitions: 2, DueOn: Day(day).AddDate(0, 0, 6)},
+		},
+		{
+			name:  "then interval times ease",
+			card:  Card{Ease: 2.5, IntervalDays: 6, Repetitions: 2},
+			grade: 4,
+			want:  Card{Ease: 2.5, IntervalDays: 15, Repetitions: 3, DueOn: Day(day).AddDate(0, 0, 15)},
+		},
+		{
+			name:  "interval uses the ease before the review",
+			card:  Card{Ease: 2, IntervalDays: 15, Repetitions: 3},
+			grade: 5,
+			want:  Card{Ease: 2.1, IntervalDays: 30, Repetitions: 4, DueOn: Day(day).AddDate(0, 0, 30)},
+		},
+		{
+			name:  "hard pass lowers ease",
+			card:  Card{Ease: 2.5, IntervalDays: 6, Repetitions: 2},
+			grade: 3,
+			want:  Card{Ease: 2.36, IntervalDays: 15, Repetitions: 3, DueOn: Day(day).AddDate(0, 0, 15)},
+		},
+		{
+			name:  "ease floor",
+			card:  Card{Ease: 1.4, IntervalDays: 10, Repetitions: 2},
+			grade: 3,
+			want:  Card{Ease: MinEase, IntervalDays: 14, Repetitions: 3, DueOn: Day(day).AddDate(0, 0, 14)},
+		},
+		{
+			name:  "ease floor on a lapse",
+			card:  Card{Ease: MinEase, IntervalDays: 10, Repetitions: 2},
+			grade: 0,
+			want:  Card{Ease: MinEase, IntervalDays: 1, Lapses: 1, DueOn: Day(day).AddDate(0, 0, 1)},
+		},
+		{
+			name:  "lapse starts over",
+			card:  Card{Ease: 2.5, IntervalDays: 30, Repetitions: 4, Lapses: 1},
+			grade: 2,
+			want:  Card{Ease: 2.18, IntervalDays: 1, Lapses: 2, DueOn: Day(day).AddDate(0, 0, 1)},
+		},
+		{
+			name:  "failing a new card isn't a lapse",
+			card:  NewCard(day),
+			grade: 0,
+			want:  Card{Ease: 1.7, IntervalDays: 1, DueOn: Day(day).AddDate(0, 0, 1)},
+		},
+		{
+			name:  "missing ease defaults",
+			card:  Card{},
+			grade: 4,
+			want:  Card{Ease: DefaultEase, IntervalDays: 1, Repetitions: 1, DueOn: Day(day).AddDate(0, 0, 1)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Schedule(tt.card, tt.grade, day); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Schedule(%+v, %d) = %+v, want %+v", tt.card, tt.grade, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMakeCloze(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want *Cloze
+	}{
+		{
+			name: "too short",
+			text: "Call me Ishmael.",
+			want: nil,
+		},
+		{
+			name: "only stop words",
+			text: "It was the one thing that they could never say",
+			want: nil,
+		},
+		{
+			name: "punctuation stays around the blank",
+			text: "Memory, however, is treacherous; the past keeps shifting.",
+			want: &Cloze{
+				Prompt:  "Memory, however, is [...]; the past keeps shifting.",
+				Answers: []string{"treacherous"},
+			},
+		},
+		{
+			name: "blanks are never adjacent",
+			text: "The extraordinary circumstances of that summer were remembered by everyone in the village for many years after the war had finally and quietly ended there.",
+			want: &Cloze{
+				Prompt:  "The [...] circumstances of that summer were [...] by everyone in the village for many years after the war had finally and quietly ended there.",
+				Answers: []string{"extraordinary", "remembered"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MakeCloze(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("MakeCloze(%q) = %+v, want %+v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+// Package review schedules annotations for spaced repetition with a variant of the SM-2
+// algorithm and turns highlights into cloze cards. Scheduling works in whole days: a card
+// is due on a date, and reviewing it moves that date further out the better it was
+// remembered.
+package review
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// Grades run from 0 (forgotten completely) to 5 (perfect recall). Grades below
+// PassingGrade count as a lapse and start the card over.
+const (
+	MinGrade     = 0
+	MaxGrade     = 5
+	PassingGrade = 3
+)
+
+// Ease bounds how quickly intervals grow; new cards start at DefaultEase
+const (
+	DefaultEase = 2.5
+	MinEase     = 1.3
+)
+
+// Card is the scheduling state of one reviewed annotation
+type Card struct {
+	Ease         float64
+	IntervalDays int
+	Repetitions  int
+	Lapses       int
+	DueOn        time.Time
+}
+
+// NewCard is the state of a card that was never reviewed, due on day
+func NewCard(day time.Time) Card {
+	return Card{Ease: DefaultEase, DueOn: Day(day)}
+}
+
+// Day truncates t to its UTC date
+func Day(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// ValidateGrade checks that grade is on the 0-5 scale
+func ValidateGrade(grade int) error {
+	if grade < MinGrade || grade > MaxGrade {
+		return fmt.Errorf("grade must be between %d and %d", MinGrade, MaxGrade)
+	}
+	return nil
+}
+
+// Schedule returns the card's state after a review with grade on day. Passing reviews
+// space the card out to 1 day, then 6 days, then by the ease factor each time; a lapse
+// starts it over at 1 day. The ease factor moves with every grade as in SM-2 and never
+// drops below MinEase.
+func Schedule(card Card, grade int, day time.Time) Card {
+	next := card
+	if next.Ease == 0 {
+		next.Ease = DefaultEase
+	}
+
+	if grade >= PassingGrade {
+		switch next.Repetitions {
+		case 0:
+			next.IntervalDays = 1
+		case 1:
+			next.IntervalDays = 6
+		default:
+			next.IntervalDays = int(math.Round(float64(next.IntervalDays) * next.Ease))
+		}
+		next.Repetitions++
+	} else {
+		if next.Repetitions > 0 {
+			next.Lapses++
+		}
+		next.Repetitions = 0
+		next.IntervalDays = 1
+	}
+
+	miss := float64(MaxGrade - grade)
+	next.Ease = math.Max(MinEase, next.Ease+0.1-miss*(0.08+miss*0.02))
+	next.Ease = math.Round(next.Ease*100) / 100
+	next.DueOn = Day(day).AddDate(0, 0, next.IntervalDays)
+	return next
+}
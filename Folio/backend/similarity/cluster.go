@@ -0,0 +1,178 @@
+package similarity
+
+import (
+	"math"
+	"sort"
+)
+
+// clusterPasses is how many times documents are reassigned to their nearest centroid
+const clusterPasses = 3
+
+// Cluster is a group of similar documents
+type Cluster struct {
+	Documents []Document
+	// Label is a few words that characterize the cluster, most characteristic first
+	Label []string
+	// Cohesion is the average similarity of the documents to the cluster's center
+	Cohesion float64
+}
+
+// Cluster groups the indexed documents for which include returns true. A document joins
+// the nearest cluster whose center it is at least threshold similar to, or starts a new
+// one; clusters are then refined by reassigning documents to their nearest center.
+// Clusters smaller than minSize are dropped. Largest clusters come first.
+func (ix *Index) Cluster(include func(Document) bool, threshold float64, minSize int) []Cluster {
+	members := []int{}
+	for i, doc := range ix.Documents {
+		if include(doc) && len(ix.vectors[i]) > 0 {
+			members = append(members, i)
+		}
+	}
+
+	// Leader clustering: one pass to find the initial groups
+	sums := []Vector{}
+	centroids := []Vector{}
+	assignment := map[int]int{}
+	for _, i := range members {
+		best, bestScore := -1, threshold
+		for c, centroid := range centroids {
+			if score := Cosine(ix.vectors[i], centroid); score >= bestScore {
+				best, bestScore = c, score
+			}
+		}
+		if best < 0 {
+			sums = append(sums, Vector{})
+			centroids = append(centroids, nil)
+			best = len(centroids) - 1
+		}
+		assignment[i] = best
+		for term, weight := range ix.vectors[i] {
+			sums[best][term] += weight
+		}
+		centroids[best] = normalize(copyVector(sums[best]))
+	}
+
+	// Refinement: move documents to their nearest center until nothing changes
+	for pass := 0; pass < clusterPasses; pass++ {
+		changed := false
+		for _, i := range members {
+			best, bestScore := assignment[i], Cosine(ix.vectors[i], centroids[assignment[i]])
+			for c, centroid := range centroids {
+				if score := Cosine(ix.vectors[i], centroid); score > bestScore+1e-9 {
+					best, bestScore = c, score
+				}
+			}
+			if best != assignment[i] {
+				assignment[i] = best
+				changed = true
+			}
+		}
+		if !changed {
+			break
+		}
+		centroids = ix.centroids(members, assignment, len(centroids))
+	}
+
+	groups := make([][]int, len(centroids))
+	for _, i := range members {
+		groups[assignment[i]] = append(groups[assignment[i]], i)
+	}
+
+	clusters := []Cluster{}
+	for c, group := range groups {
+		if len(group) < minSize || len(group) == 0 {
+			continue
+		}
+		cluster := Cluster{}
+		cohesion := 0.0
+		for _, i := range group {
+			cluster.Documents = append(cluster.Documents, ix.Documents[i])
+			cohesion += Cosine(ix.vectors[i], centroids[c])
+		}
+		cluster.Cohesion = math.Round(cohesion/float64(len(group))*100) / 100
+		cluster.Label = ix.label(centroids[c], group, 3)
+		clusters = append(clusters, cluster)
+	}
+
+	sort.SliceStable(clusters, func(i, j int) bool {
+		if len(clusters[i].Documents) != len(clusters[j].Documents) {
+			return len(clusters[i].Documents) > len(clusters[j].Documents)
+		}
+		return clusters[i].Cohesion > clusters[j].Cohesion
+	})
+	return clusters
+}
+
+// centroids returns the normalized mean vector of each cluster
+func (ix *Index) centroids(members []int, assignment map[int]int, count int) []Vector {
+	sums := make([]Vector, count)
+	for c := range sums {
+		sums[c] = Vector{}
+	}
+	for _, i := range members {
+		for term, weight := range ix.vectors[i] {
+			sums[assignment[i]][term] += weight
+		}
+	}
+	for _, sum := range sums {
+		normalize(sum)
+	}
+	return sums
+}
+
+// normalize scales v to unit length in place and returns it
+func normalize(v Vector) Vector {
+	norm := 0.0
+	for _, weight := range v {
+		norm += weight * weight
+	}
+	if norm == 0 {
+		return v
+	}
+	norm = math.Sqrt(norm)
+	for term := range v {
+		v[term] /= norm
+	}
+	return v
+}
+
+// label picks the heaviest centroid terms that appear in more than one of the group's
+// documents, so a word from a single note doesn't name the whole cluster
+func (ix *Index) label(centroid Vector, group []int, n int) []string {
+	type weighted struct {
+		term   string
+		weight float64
+	}
+	terms := []weighted{}
+	for term, weight := range centroid {
+		shared := 0
+		for _, i := range group {
+			if _, ok := ix.vectors[i][term]; ok {
+				shared++
+			}
+		}
+		if shared > 1 || len(group) == 1 {
+			terms = append(terms, weighted{term, weight})
+		}
+	}
+	sort.Slice(terms, func(i, j int) bool {
+		if terms[i].weight != terms[j].weight {
+			return terms[i].weight > terms[j].weight
+		}
+		return terms[i].term < terms[j].term
+	})
+
+	label := []string{}
+	for i := 0; i < len(terms) && i < n; i++ {
+		label = append(label, ix.Word(terms[i].term))
+	}
+	return label
+}
+
+func copyVector(v Vector) Vector {
+	copied := make(Vector, len(v))
+	for term, weight := range v {
+		copied[term] = weight
+	}
+	return copied
+}
@@ -0,0 +1,193 @@
+package similarity
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Document is one text in an index, usually an annotation
+type Document struct {
+	ID   string
+	Text string
+	Tags []string
+}
+
+// Vector is a sparse, L2-normalized TF-IDF vector keyed by term
+type Vector map[string]float64
+
+// Index holds TF-IDF vectors for a set of documents. IDF weights come from the documents
+// themselves, so terms common across a user's notes count for less than distinctive ones.
+type Index struct {
+	Documents []Document
+	vectors   []Vector
+	df        map[string]int
+	// surface maps a term to the word it most often came from, for readable labels
+	surface map[string]map[string]int
+}
+
+// NewIndex builds an index over docs
+func NewIndex(docs []Document) *Index {
+	index := &Index{
+		Documents: docs,
+		df:        map[string]int{},
+		surface:   map[string]map[string]int{},
+	}
+
+	termLists := make([][]string, len(docs))
+	for i, doc := range docs {
+		terms := Terms(doc.Text)
+		termLists[i] = terms
+		seen := map[string]bool{}
+		for _, term := range terms {
+			if !seen[term] {
+				seen[term] = true
+				index.df[term]++
+			}
+		}
+		index.recordSurface(doc.Text)
+	}
+
+	index.vectors = make([]Vector, len(docs))
+	for i, terms := range termLists {
+		index.vectors[i] = index.weigh(terms)
+	}
+	return index
+}
+
+// recordSurface counts which words each term came from
+func (ix *Index) recordSurface(text string) {
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		terms := Terms(word)
+		if len(terms) != 1 {
+			continue
+		}
+		if ix.surface[terms[0]] == nil {
+			ix.surface[terms[0]] = map[string]int{}
+		}
+		ix.surface[terms[0]][word]++
+	}
+}
+
+// idf is the smoothed inverse document frequency of term
+func (ix *Index) idf(term string) float64 {
+	return math.Log(float64(1+len(ix.Documents))/float64(1+ix.df[term])) + 1
+}
+
+// weigh turns a list of terms into a normalized TF-IDF vector, damping repeated terms
+func (ix *Index) weigh(terms []string) Vector {
+	counts := map[string]int{}
+	for _, term := range terms {
+		counts[term]++
+	}
+	vector := Vector{}
+	norm := 0.0
+	for term, count := range counts {
+		weight := (1 + math.Log(float64(count))) * ix.idf(term)
+		vector[term] = weight
+		norm += weight * weight
+	}
+	if norm == 0 {
+		return vector
+	}
+	norm = math.Sqrt(norm)
+	for term := range vector {
+		vector[term] /= norm
+	}
+	return vector
+}
+
+// Vectorize returns the vector for text using the index's IDF weights
+func (ix *Index) Vectorize(text string) Vector {
+	return ix.weigh(Terms(text))
+}
+
+// VectorOf returns the vector of the document with the given id, or nil
+func (ix *Index) VectorOf(id string) Vector {
+	for i, doc := range ix.Documents {
+		if doc.ID == id {
+			return ix.vectors[i]
+		}
+	}
+	return nil
+}
+
+// Cosine returns the cosine similarity of two normalized vectors
+func Cosine(a, b Vector) float64 {
+	if len(b) < len(a) {
+		a, b = b, a
+	}
+	sum := 0.0
+	for term, weight := range a {
+		sum += weight * b[term]
+	}
+	return sum
+}
+
+// Match is a document and how similar it is to what was searched for
+type Match struct {
+	Document Document
+	Score    float64
+	// Terms are the shared terms that contributed most, as readable words
+	Terms []string
+}
+
+// Similar returns up to limit documents most similar to v scoring at least minScore,
+// best first, leaving out the document with id exclude
+func (ix *Index) Similar(v Vector, limit int, minScore float64, exclude string) []Match {
+	matches := []Match{}
+	for i, doc := range ix.Documents {
+		if doc.ID == exclude {
+			continue
+		}
+		if score := Cosine(v, ix.vectors[i]); score >= minScore && score > 0 {
+			matches = append(matches, Match{Document: doc, Score: score, Terms: ix.sharedTerms(v, ix.vectors[i], 3)})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].Score > matches[j].Score
+	})
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches
+}
+
+// sharedTerms returns the n terms contributing most to the similarity of a and b
+func (ix *Index) sharedTerms(a, b Vector, n int) []string {
+	type contribution struct {
+		term  string
+		score float64
+	}
+	shared := []contribution{}
+	for term, weight := range a {
+		if other, ok := b[term]; ok {
+			shared = append(shared, contribution{term, weight * other})
+		}
+	}
+	sort.Slice(shared, func(i, j int) bool {
+		if shared[i].score != shared[j].score {
+			return shared[i].score > shared[j].score
+		}
+		return shared[i].term < shared[j].term
+	})
+	terms := []string{}
+	for i := 0; i < len(shared) && i < n; i++ {
+		terms = append(terms, ix.Word(shared[i].term))
+	}
+	return terms
+}
+
+// Word returns the word a term most often came from in the indexed documents
+func (ix *Index) Word(term string) string {
+	best, bestCount := term, 0
+	for word, count := range ix.surface[term] {
+		if count > bestCount || (count == bestCount && word < best) {
+			best, bestCount = word, count
+		}
+	}
+	return best
+}
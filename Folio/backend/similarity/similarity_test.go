@@ -0,0 +1,156 @@
+package similarity
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestStem(t *testing.T) {
+	tests := []struct {
+		word string
+		want string
+	}{
+		{"habits", "habit"},
+		{"habit", "habit"},
+		{"reading", "read"},
+		{"reads", "read"},
+		{"running", "run"},
+		{"falling", "fall"},
+		{"note", "not"},
+		{"notes", "not"},
+		{"noted", "not"},
+		{"stories", "story"},
+		{"happiness", "happi"},
+		{"kindness", "kind"},
+		{"quickly", "quick"},
+		{"relational", "relat"},
+		{"class", "class"},
+		{"cat", "cat"},
+		{"bed", "bed"},
+	}
+
+	for _, tt := range tests {
+		if got := Stem(tt.word); got != tt.want {
+			t.Errorf("Stem(%q) = %q, want %q", tt.word, got, tt.want)
+		}
+	}
+}
+
+func TestTerms(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"The habits of highly effective people", []string{"habit", "high", "effectiv", "peopl"}},
+		{"Marcus Aurelius’s Meditations", []string{"marcu", "aureliu", "meditation"}},
+		{"It's 1984, and we're reading it again!", []string{"read"}},
+		{"  ", []string{}},
+	}
+
+	for _, tt := range tests {
+		if got := Terms(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Terms(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func testIndex() *Index {
+	return NewIndex([]Document{
+		{ID: "1", Text: "Stoic philosophy teaches acceptance of what we cannot control", Tags: []string{"stoicism"}},
+		{ID: "2", Text: "The stoic accepts fate and controls only his own judgements", Tags: []string{"stoicism", "philosophy"}},
+		{ID: "3", Text: "Marcus Aurelius wrote about stoic control and acceptance"},
+		{ID: "4", Text: "Bread dough needs flour, water, salt and yeast", Tags: []string{"baking"}},
+		{ID: "5", Text: "Knead the dough until the bread flour develops gluten"},
+		{ID: "6", Text: "Sourdough bread rises slowly with wild yeast and flour"},
+		{ID: "7", Text: "A completely unrelated sentence about orbital mechanics"},
+	})
+}
+
+func TestSuggestTags(t *testing.T) {
+	index := testIndex()
+
+	tags := func(suggestions []TagSuggestion) []string {
+		names := []string{}
+		for _, suggestion := range suggestions {
+			names = append(names, suggestion.Tag)
+		}
+		return names
+	}
+
+	got := index.SuggestTags("Acceptance is the heart of stoic control", nil, 5)
+	if len(got) == 0 || got[0].Tag != "stoicism" {
+		t.Errorf("SuggestTags() = %v, want stoicism first", got)
+	}
+	for _, suggestion := range got {
+		if suggestion.Tag == "baking" {
+			t.Errorf("SuggestTags() suggested baking for a stoic text: %v", got)
+		}
+		if suggestion.Score <= 0 || suggestion.Score > 1 {
+			t.Errorf("SuggestTags() score %v out of range", suggestion.Score)
+		}
+	}
+
+	if got := tags(index.SuggestTags("Acceptance is the heart of stoic control", []string{"Stoicism"}, 5)); contains(got, "stoicism") {
+		t.Errorf("SuggestTags() = %v, want stoicism excluded", got)
+	}
+
+	if got := tags(index.SuggestTags("flour and yeast for bread", nil, 1)); !reflect.DeepEqual(got, []string{"baking"}) {
+		t.Errorf("SuggestTags() = %v, want [baking]", got)
+	}
+
+	// The tag's own name in the text counts even without a similar tagged document
+	if got := tags(index.SuggestTags("thoughts on baking", nil, 5)); !contains(got, "baking") {
+		t.Errorf("SuggestTags() = %v, want baking", got)
+	}
+
+	if got := index.SuggestTags("the and of", nil, 5); len(got) != 0 {
+		t.Errorf("SuggestTags() on stop words = %v, want none", got)
+	}
+}
+
+func TestCluster(t *testing.T) {
+	index := testIndex()
+
+	clusters := index.Cluster(func(doc Document) bool { return true }, 0.1, 3)
+	groups := [][]string{}
+	for _, cluster := range clusters {
+		ids := []string{}
+		for _, doc := range cluster.Documents {
+			ids = append(ids, doc.ID)
+		}
+		sort.Strings(ids)
+		groups = append(groups, ids)
+		if len(cluster.Label) == 0 {
+			t.Errorf("cluster %v has no label", ids)
+		}
+		if cluster.Cohesion <= 0 || cluster.Cohesion > 1 {
+			t.Errorf("cluster %v cohesion = %v", ids, cluster.Cohesion)
+		}
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i][0] < groups[j][0] })
+	if want := [][]string{{"1", "2", "3"}, {"4", "5", "6"}}; !reflect.DeepEqual(groups, want) {
+		t.Errorf("Cluster() groups = %v, want %v", groups, want)
+	}
+
+	// Only included documents are clustered, and small groups are dropped
+	untagged := index.Cluster(func(doc Document) bool { return len(doc.Tags) == 0 }, 0.1, 2)
+	if len(untagged) != 1 || len(untagged[0].Documents) != 2 {
+		t.Fatalf("Cluster() of untagged documents = %v, want one pair", untagged)
+	}
+	if ids := []string{untagged[0].Documents[0].ID, untagged[0].Documents[1].ID}; !contains(ids, "5") || !contains(ids, "6") {
+		t.Errorf("Cluster() of untagged documents = %v, want 5 and 6", ids)
+	}
+	if got := index.Cluster(func(doc Document) bool { return true }, 0.1, 4); len(got) != 0 {
+		t.Errorf("Cluster() with minSize 4 = %d clusters, want 0", len(got))
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,103 @@
+package similarity
+
+import (
+	"math"
+	"sort"
+	"strings"
+)
+
+// Tag suggestions come from the nearest tagged documents, each voting for its tags with its
+// similarity, plus a bonus for tags whose words appear in the text itself
+const (
+	tagNeighbors      = 15
+	tagNeighborMin    = 0.1
+	tagNameBonus      = 0.5
+	minTagSuggestion  = 0.2
+	defaultTagSuggest = 5
+)
+
+// TagSuggestion is a tag that fits a text, with a confidence between 0 and 1
+type TagSuggestion struct {
+	Tag   string
+	Score float64
+}
+
+// SuggestTags returns up to limit tags from the indexed documents that fit text, leaving
+// out the tags in exclude
+func (ix *Index) SuggestTags(text string, exclude []string, limit int) []TagSuggestion {
+	if limit <= 0 {
+		limit = defaultTagSuggest
+	}
+	excluded := map[string]bool{}
+	for _, tag := range exclude {
+		excluded[strings.ToLower(tag)] = true
+	}
+
+	vector := ix.Vectorize(text)
+	if len(vector) == 0 {
+		return []TagSuggestion{}
+	}
+
+	scores := map[string]float64{}
+	total := 0.0
+	for _, match := range ix.Similar(vector, tagNeighbors, tagNeighborMin, "") {
+		if len(match.Document.Tags) == 0 {
+			continue
+		}
+		total += match.Score
+		for _, tag := range match.Document.Tags {
+			scores[tag] += match.Score
+		}
+	}
+	// Votes are shares of the neighbors' total similarity, so one close neighbor with a
+	// tag counts for more than several distant ones without it
+	if total > 0 {
+		for tag := range scores {
+			scores[tag] /= total
+		}
+	}
+
+	// Tags named by the text itself, e.g. "stoicism" on a note about Stoicism
+	textTerms := map[string]bool{}
+	for term := range vector {
+		textTerms[term] = true
+	}
+	checked := map[string]bool{}
+	for _, doc := range ix.Documents {
+		for _, tag := range doc.Tags {
+			if checked[tag] {
+				continue
+			}
+			checked[tag] = true
+			tagTerms := Terms(tag)
+			named := len(tagTerms) > 0
+			for _, term := range tagTerms {
+				if !textTerms[term] {
+					named = false
+					break
+				}
+			}
+			if named {
+				scores[tag] += tagNameBonus
+			}
+		}
+	}
+
+	suggestions := []TagSuggestion{}
+	for tag, score := range scores {
+		if excluded[strings.ToLower(tag)] || score < minTagSuggestion {
+			continue
+		}
+		suggestions = append(suggestions, TagSuggestion{Tag: tag, Score: math.Min(1, math.Round(score*100)/100)})
+	}
+	sort.Slice(suggestions, func(i, j int) bool {
+		if suggestions[i].Score != suggestions[j].Score {
+			return suggestions[i].Score > suggestions[j].Score
+		}
+		return suggestions[i].Tag < suggestions[j].Tag
+	})
+	if len(suggestions) > limit {
+		suggestions = suggestions[:limit]
+	}
+	return suggestions
+}
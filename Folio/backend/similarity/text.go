@@ -0,0 +1,125 @@
+// Package similarity compares short texts such as highlights and notes using TF-IDF
+// vectors. It runs entirely in process: an index is built from a user's annotations
+// when needed, and powers related notes, tag suggestions and theme clustering.
+//
+// Text is lowercased, split into words, stripped of English stop words and reduced by
+// a light suffix stemmer, so "habits" and "habit" or "reading" and "read" match.
+package similarity
+
+import (
+	"strings"
+	"unicode"
+)
+
+// minTermLength drops one- and two-letter words, which carry little meaning
+const minTermLength = 3
+
+var stopWords = toSet(`
+a about above after again against all also am an and any are aren't as at be because been
+before being below between both but by can can't cannot could couldn't did didn't do does
+doesn't doing don't down during each even ever every few for from further get gets got had
+hadn't has hasn't have haven't having he he'd he'll he's her here here's hers herself him
+himself his how how's however i i'd i'll i'm i've if in into is isn't it it's its itself
+just let's like made make many may me might more most much must mustn't my myself never no
+nor not now of off often on once one only or other ought our ours ourselves out over own
+perhaps quite rather really said same say says shall shan't she she'd she'll she's should
+shouldn't since so some still such than that that's the their theirs them themselves then
+there there's these they they'd they'll they're they've thing things this those though
+through thus to too under until up upon us very was wasn't we we'd we'll we're we've were
+weren't what what's when when's where where's whether which while who who's whom whose why
+why's will with within without won't would wouldn't yet you you'd you'll you're you've your
+yours yourself yourselves
+`)
+
+func toSet(words string) map[string]bool {
+	set := map[string]bool{}
+	for _, word := range strings.Fields(words) {
+		set[word] = true
+	}
+	return set
+}
+
+// Terms splits text into the stemmed, lowercase terms used for comparison
+func Terms(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\'' && r != '’'
+	})
+
+	terms := make([]string, 0, len(words))
+	for _, word := range words {
+		word = strings.Trim(strings.ReplaceAll(word, "’", "'"), "'")
+		if stopWords[word] {
+			continue
+		}
+		word = strings.TrimSuffix(word, "'s")
+		if len([]rune(word)) < minTermLength || isNumber(word) {
+			continue
+		}
+		terms = append(terms, Stem(word))
+	}
+	return terms
+}
+
+func isNumber(word string) bool {
+	for _, r := range word {
+		if !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// stemSuffixes are removed longest first; each keeps at least three letters of stem
+var stemSuffixes = []struct {
+	suffix, replacement string
+}{
+	{"ational", "ate"},
+	{"fulness", "ful"},
+	{"iveness", "ive"},
+	{"ization", "ize"},
+	{"ousness", "ous"},
+	{"ements", ""},
+	{"ement", ""},
+	{"ments", ""},
+	{"ities", "ity"},
+	{"ness", ""},
+	{"ment", ""},
+	{"ings", ""},
+	{"ies", "y"},
+	{"ied", "y"},
+	{"ing", ""},
+	{"edly", ""},
+	{"ly", ""},
+	{"ed", ""},
+	{"es", ""},
+	{"s", ""},
+}
+
+// Stem reduces an English word to a rough stem. It is deliberately light: it only has to
+// map related forms of a word to the same term, not produce a dictionary word. A final
+// "e" is always dropped so that "note", "notes" and "noted" all become "not".
+func Stem(word string) string {
+	if len(word) <= minTermLength || (strings.HasSuffix(word, "ss") && !strings.HasSuffix(word, "ness")) {
+		return word
+	}
+	stem := word
+	for _, rule := range stemSuffixes {
+		if !strings.HasSuffix(word, rule.suffix) {
+			continue
+		}
+		candidate := word[:len(word)-len(rule.suffix)] + rule.replacement
+		if len(candidate) < minTermLength {
+			continue
+		}
+		// "running" -> "runn" -> "run"
+		if n := len(candidate); rule.replacement == "" && n >= 4 && candidate[n-1] == candidate[n-2] && !strings.ContainsRune("lsz", rune(candidate[n-1])) {
+			candidate = candidate[:n-1]
+		}
+		stem = candidate
+		break
+	}
+	if len(stem) > minTermLength && strings.HasSuffix(stem, "e") {
+		stem = stem[:len(stem)-1]
+	}
+	return stem
+}